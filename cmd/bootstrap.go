@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/config"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/service"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+)
+
+// bootstrapAdmin creates the first superadmin of a tenant, the default
+// tenant unless its slug is given, and prints its credentials, which are not
+// shown again.
+func bootstrapAdmin(ctx context.Context, cfg *config.Config, db *sql.DB, args []string) error {
+	if len(args) != 1 && len(args) != 2 {
+		return fmt.Errorf("usage: bootstrap-admin USERNAME [TENANT]")
+	}
+
+	users := store.NewPostgres(db)
+	if len(args) == 2 {
+		t, err := users.GetTenantBySlug(ctx, args[1])
+		if err == store.ErrNotFound {
+			return fmt.Errorf("unknown tenant %q", args[1])
+		}
+		if err != nil {
+			return err
+		}
+		ctx = tenant.WithID(ctx, t.ID)
+	}
+
+	creds, err := service.BootstrapAdmin(ctx, cfg, users, args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Created superadmin %q. The credentials are only shown once.\n\n", creds.Admin.Username)
+	fmt.Printf("Password:    %s\n", creds.Password)
+	fmt.Printf("TOTP secret: %s\n", creds.TotpSecret)
+	fmt.Printf("TOTP URL:    %s\n", creds.TotpUrl)
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/hojamuhammet/user-admin-grpc-go/internal/config"
 	"github.com/hojamuhammet/user-admin-grpc-go/internal/database"
 	"github.com/hojamuhammet/user-admin-grpc-go/internal/server"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/webhook"
 	"github.com/joho/godotenv"
 )
 
@@ -34,6 +35,25 @@ func main() {
 	}
 	defer db.Close() // Close the database connection when the program exits
 
+	// "bootstrap-admin USERNAME [TENANT]" creates the first superadmin instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "bootstrap-admin" {
+		if err := bootstrapAdmin(ctx, cfg, db, os.Args[2:]); err != nil {
+			log.Fatalf("Failed to bootstrap admin: %v", err)
+		}
+		return
+	}
+
+	// Deliver outbox events to registered webhooks in the background
+	dispatcher := webhook.NewDispatcher(db, webhook.DispatcherConfig{
+		PollInterval: cfg.WebhookPollInterval,
+		MaxAttempts:  cfg.WebhookMaxAttempts,
+		BaseBackoff:  cfg.WebhookBaseBackoff,
+		MaxBackoff:   cfg.WebhookMaxBackoff,
+		Timeout:      cfg.WebhookTimeout,
+		AllowPrivate: cfg.WebhookAllowPrivate,
+	})
+	go dispatcher.Run(ctx)
+
 	// Create a gRPC server
 	grpcServer := server.NewServer(ctx, cfg, db)
 
@@ -53,6 +73,9 @@ func main() {
 	grpcServer.Stop()
 	log.Println("gRPC server stopped")
 
+	// Stop the webhook dispatcher
+	cancel()
+
 	// Add any additional cleanup logic here
 	log.Println("Application gracefully terminated")
 }
\ No newline at end of file
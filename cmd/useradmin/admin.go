@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var adminHeader = []string{"ID", "USERNAME", "ROLES", "DISABLED", "LOCKED UNTIL", "LAST LOGIN", "CREATED"}
+
+func newLoginCommand() *cobra.Command {
+	var username, password, code string
+	cmd := &cobra.Command{
+		Use:   "login --username NAME",
+		Short: "Sign in as an admin and save the access token in the current profile",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			in := bufio.NewReader(cmd.InOrStdin())
+			var err error
+			if password == "" {
+				if password, err = prompt(cmd, in, "Password: "); err != nil {
+					return err
+				}
+			}
+			if code == "" {
+				if code, err = prompt(cmd, in, "Authenticator code: "); err != nil {
+					return err
+				}
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := pb.NewAdminServiceClient(c.Conn()).AdminLogin(ctx, &pb.AdminLoginRequest{
+				Username: username,
+				Password: password,
+				TotpCode: code,
+			})
+			if err != nil {
+				return err
+			}
+
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			name := firstNonEmpty(flags.profile, cfg.CurrentProfile)
+			profile, ok := cfg.Profiles[name]
+			if !ok {
+				// Without a profile the token can still be passed with --token
+				fmt.Fprintln(cmd.OutOrStdout(), resp.AccessToken)
+				return nil
+			}
+			profile.Token = resp.AccessToken
+			if err := cfg.save(); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Logged in as %s until %s, token saved to profile %q\n",
+				resp.Admin.Username, formatTimestamp(resp.AccessTokenExpireTime), name)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&username, "username", "", "admin username")
+	cmd.Flags().StringVar(&password, "password", "", "password, prompted for when not given")
+	cmd.Flags().StringVar(&code, "code", "", "authenticator code, prompted for when not given")
+	cmd.MarkFlagRequired("username")
+	return cmd
+}
+
+func newAdminCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "admin",
+		Short: "Manage the admins of the API (requires the superadmin role)",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List admins",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := pb.NewAdminServiceClient(c.Conn()).ListAdmins(ctx, &pb.Empty{})
+			if err != nil {
+				return err
+			}
+			return printAdmins(cmd.OutOrStdout(), flags.output, resp.Admins)
+		},
+	}
+
+	var roles []string
+	create := &cobra.Command{
+		Use:   "create USERNAME --role ROLE",
+		Short: "Create an admin and show its generated password and TOTP secret",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			creds, err := pb.NewAdminServiceClient(c.Conn()).CreateAdmin(ctx, &pb.CreateAdminRequest{Username: args[0], Roles: roles})
+			if err != nil {
+				return err
+			}
+			return printCredentials(cmd.OutOrStdout(), creds)
+		},
+	}
+	create.Flags().StringSliceVar(&roles, "role", nil, "role: superadmin, admin, support or viewer (repeatable)")
+	create.MarkFlagRequired("role")
+
+	setRoles := &cobra.Command{
+		Use:   "roles ID ROLE...",
+		Short: "Replace the roles of an admin",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseAdminID(args[0])
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			admin, err := pb.NewAdminServiceClient(c.Conn()).SetAdminRoles(ctx, &pb.SetAdminRolesRequest{Id: id, Roles: args[1:]})
+			if err != nil {
+				return err
+			}
+			return printAdmins(cmd.OutOrStdout(), flags.output, []*pb.Admin{admin})
+		},
+	}
+
+	var reason string
+	reset := &cobra.Command{
+		Use:   "reset ID",
+		Short: "Generate a new password and TOTP secret for an admin and lift a lockout",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseAdminID(args[0])
+			if err != nil {
+				return err
+			}
+			if !flags.yes {
+				ok, err := confirm(cmd, fmt.Sprintf("Reset the credentials of admin %d? Its current tokens stop working.", id))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Aborted")
+					return nil
+				}
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			creds, err := pb.NewAdminServiceClient(c.Conn()).ResetAdminCredentials(ctx, &pb.ResetAdminCredentialsRequest{Id: id, Reason: reason})
+			if err != nil {
+				return err
+			}
+			return printCredentials(cmd.OutOrStdout(), creds)
+		},
+	}
+	reset.Flags().StringVar(&reason, "reason", "", "reason for the reset, recorded in the audit log")
+
+	cmd.AddCommand(list, create, newAdminDisableCommand(true), newAdminDisableCommand(false), setRoles, reset)
+	return cmd
+}
+
+func newAdminDisableCommand(disable bool) *cobra.Command {
+	use, short := "enable ID", "Enable a disabled admin"
+	if disable {
+		use, short = "disable ID", "Disable an admin, its tokens stop working at once"
+	}
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseAdminID(args[0])
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			admins := pb.NewAdminServiceClient(c.Conn())
+			var admin *pb.Admin
+			if disable {
+				admin, err = admins.DisableAdmin(ctx, &pb.AdminID{Id: id})
+			} else {
+				admin, err = admins.EnableAdmin(ctx, &pb.AdminID{Id: id})
+			}
+			if err != nil {
+				return err
+			}
+			return printAdmins(cmd.OutOrStdout(), flags.output, []*pb.Admin{admin})
+		},
+	}
+}
+
+// prompt reads a line from the terminal.
+func prompt(cmd *cobra.Command, in *bufio.Reader, question string) (string, error) {
+	fmt.Fprint(cmd.ErrOrStderr(), question)
+	answer, err := in.ReadString('\n')
+	if err != nil && answer == "" {
+		return "", fmt.Errorf("failed to read input: %v", err)
+	}
+	return strings.TrimSpace(answer), nil
+}
+
+func parseAdminID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid admin ID %q", arg)
+	}
+	return id, nil
+}
+
+// printCredentials shows generated credentials. They cannot be retrieved again.
+func printCredentials(w io.Writer, creds *pb.AdminCredentials) error {
+	if flags.output == "json" {
+		data, err := protojson.MarshalOptions{UseProtoNames: true, Multiline: true}.Marshal(creds)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	fmt.Fprintf(w, "Credentials of admin %q, only shown once:\n\n", creds.Admin.Username)
+	fmt.Fprintf(w, "Password:    %s\n", creds.Password)
+	fmt.Fprintf(w, "TOTP secret: %s\n", creds.TotpSecret)
+	fmt.Fprintf(w, "TOTP URL:    %s\n", creds.TotpUrl)
+	return nil
+}
+
+// printAdmins writes admins in the selected output format.
+func printAdmins(w io.Writer, format string, admins []*pb.Admin) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(adminHeader, "\t"))
+		for _, a := range admins {
+			fmt.Fprintln(tw, strings.Join(adminRow(a), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(adminHeader)
+		for _, a := range admins {
+			cw.Write(adminRow(a))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(admins))
+		for _, a := range admins {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(a)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func adminRow(a *pb.Admin) []string {
+	return []string{
+		strconv.FormatInt(a.Id, 10),
+		a.Username,
+		strings.Join(a.Roles, ","),
+		strconv.FormatBool(a.Disabled),
+		formatTimestamp(a.LockExpireTime),
+		formatTimestamp(a.LastLoginTime),
+		formatTimestamp(a.CreateTime),
+	}
+}
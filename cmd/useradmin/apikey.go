@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var apiKeyHeader = []string{"ID", "NAME", "PREFIX", "SCOPES", "EXPIRES", "LAST USED", "REVOKED", "CREATED BY", "CREATED"}
+
+func newAPIKeyCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "apikey",
+		Short: "Manage the API keys of backend services (requires the superadmin role)",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List API keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := pb.NewApiKeyServiceClient(c.Conn()).ListApiKeys(ctx, &pb.Empty{})
+			if err != nil {
+				return err
+			}
+			return printAPIKeys(cmd.OutOrStdout(), flags.output, resp.ApiKeys)
+		},
+	}
+
+	var scopes []string
+	var expiresIn time.Duration
+	create := &cobra.Command{
+		Use:   "create NAME --scope SCOPE",
+		Short: "Create an API key and show it once",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &pb.CreateApiKeyRequest{Name: args[0], Scopes: scopes}
+			if expiresIn > 0 {
+				req.ExpireTime = timestamppb.New(time.Now().Add(expiresIn))
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			secret, err := pb.NewApiKeyServiceClient(c.Conn()).CreateApiKey(ctx, req)
+			if err != nil {
+				return err
+			}
+			return printAPIKeySecret(cmd.OutOrStdout(), secret)
+		},
+	}
+	create.Flags().StringSliceVar(&scopes, "scope", nil, "scope: users:read, users:write, users:delete or webhooks:manage (repeatable)")
+	create.Flags().DurationVar(&expiresIn, "expires-in", 0, "lifetime of the key, e.g. 8760h (default: never expires)")
+	create.MarkFlagRequired("scope")
+
+	rotate := &cobra.Command{
+		Use:   "rotate ID",
+		Short: "Replace the secret of an API key, the previous one stops working at once",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseAPIKeyID(args[0])
+			if err != nil {
+				return err
+			}
+			if !flags.yes {
+				ok, err := confirm(cmd, fmt.Sprintf("Rotate API key %d? Callers using the current key are rejected until they are updated.", id))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Aborted")
+					return nil
+				}
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			secret, err := pb.NewApiKeyServiceClient(c.Conn()).RotateApiKey(ctx, &pb.ApiKeyID{Id: id})
+			if err != nil {
+				return err
+			}
+			return printAPIKeySecret(cmd.OutOrStdout(), secret)
+		},
+	}
+
+	var reason string
+	revoke := &cobra.Command{
+		Use:   "revoke ID",
+		Short: "Revoke an API key for good",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := parseAPIKeyID(args[0])
+			if err != nil {
+				return err
+			}
+			if !flags.yes {
+				ok, err := confirm(cmd, fmt.Sprintf("Revoke API key %d? This cannot be undone.", id))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Aborted")
+					return nil
+				}
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			key, err := pb.NewApiKeyServiceClient(c.Conn()).RevokeApiKey(ctx, &pb.RevokeApiKeyRequest{Id: id, Reason: reason})
+			if err != nil {
+				return err
+			}
+			return printAPIKeys(cmd.OutOrStdout(), flags.output, []*pb.ApiKey{key})
+		},
+	}
+	revoke.Flags().StringVar(&reason, "reason", "", "reason for revoking, recorded in the audit log")
+
+	cmd.AddCommand(list, create, rotate, revoke)
+	return cmd
+}
+
+func parseAPIKeyID(arg string) (int64, error) {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("invalid API key ID %q", arg)
+	}
+	return id, nil
+}
+
+// printAPIKeySecret shows a generated key. It cannot be retrieved again.
+func printAPIKeySecret(w io.Writer, secret *pb.ApiKeySecret) error {
+	if flags.output == "json" {
+		data, err := protojson.MarshalOptions{UseProtoNames: true, Multiline: true}.Marshal(secret)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	fmt.Fprintf(w, "API key %q, only shown once:\n\n", secret.ApiKey.Name)
+	fmt.Fprintf(w, "%s\n", secret.Key)
+	return nil
+}
+
+// printAPIKeys writes API keys in the selected output format.
+func printAPIKeys(w io.Writer, format string, keys []*pb.ApiKey) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(apiKeyHeader, "\t"))
+		for _, k := range keys {
+			fmt.Fprintln(tw, strings.Join(apiKeyRow(k), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(apiKeyHeader)
+		for _, k := range keys {
+			cw.Write(apiKeyRow(k))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(keys))
+		for _, k := range keys {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(k)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func apiKeyRow(k *pb.ApiKey) []string {
+	return []string{
+		strconv.FormatInt(k.Id, 10),
+		k.Name,
+		k.Prefix,
+		strings.Join(k.Scopes, ","),
+		formatTimestamp(k.ExpireTime),
+		formatTimestamp(k.LastUseTime),
+		formatTimestamp(k.RevokeTime),
+		k.CreatedBy,
+		formatTimestamp(k.CreateTime),
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var attributeHeader = []string{"NAME", "TYPE", "ALLOWED VALUES", "REQUIRED", "PII", "DESCRIPTION", "UPDATED"}
+
+// attributeTypeNames maps the --type values to the types of the API.
+var attributeTypeNames = map[string]pb.AttributeType{
+	"string":  pb.AttributeType_ATTRIBUTE_TYPE_STRING,
+	"integer": pb.AttributeType_ATTRIBUTE_TYPE_INTEGER,
+	"number":  pb.AttributeType_ATTRIBUTE_TYPE_NUMBER,
+	"boolean": pb.AttributeType_ATTRIBUTE_TYPE_BOOLEAN,
+	"date":    pb.AttributeType_ATTRIBUTE_TYPE_DATE,
+}
+
+func newAttributeCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attribute",
+		Short: "Manage the custom attributes users can have",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List attribute definitions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := pb.NewAttributeServiceClient(c.Conn()).ListAttributes(ctx, &pb.Empty{})
+			if err != nil {
+				return err
+			}
+			return printAttributes(cmd.OutOrStdout(), flags.output, resp.Attributes)
+		},
+	}
+
+	var attributeType, description string
+	var allowed []string
+	var required, pii bool
+	define := &cobra.Command{
+		Use:   "define NAME --type TYPE",
+		Short: "Define an attribute or replace its definition (requires the superadmin role)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t, ok := attributeTypeNames[attributeType]
+			if !ok {
+				return fmt.Errorf("--type must be one of string, integer, number, boolean or date")
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			def, err := pb.NewAttributeServiceClient(c.Conn()).DefineAttribute(ctx, &pb.AttributeDefinition{
+				Name:          args[0],
+				Type:          t,
+				AllowedValues: allowed,
+				Required:      required,
+				Pii:           pii,
+				Description:   description,
+			})
+			if err != nil {
+				return err
+			}
+			return printAttributes(cmd.OutOrStdout(), flags.output, []*pb.AttributeDefinition{def})
+		},
+	}
+	define.Flags().StringVar(&attributeType, "type", "", "type: string, integer, number, boolean or date")
+	define.Flags().StringSliceVar(&allowed, "allowed", nil, "allowed value of a string attribute (repeatable)")
+	define.Flags().BoolVar(&required, "required", false, "require the attribute when users are created")
+	define.Flags().BoolVar(&pii, "pii", false, "mark the attribute as personal data, left out of webhooks")
+	define.Flags().StringVar(&description, "description", "", "what the attribute is for")
+	define.MarkFlagRequired("type")
+	define.RegisterFlagCompletionFunc("type", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"string", "integer", "number", "boolean", "date"}, cobra.ShellCompDirectiveNoFileComp
+	})
+
+	remove := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete an attribute definition, users keep their values (requires the superadmin role)",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !flags.yes {
+				ok, err := confirm(cmd, fmt.Sprintf("Delete attribute %s? Its values can no longer be set.", args[0]))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Aborted")
+					return nil
+				}
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			if _, err := pb.NewAttributeServiceClient(c.Conn()).DeleteAttribute(ctx, &pb.AttributeName{Name: args[0]}); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Deleted attribute %s\n", args[0])
+			return nil
+		},
+	}
+
+	cmd.AddCommand(list, define, remove)
+	return cmd
+}
+
+// printAttributes writes attribute definitions in the selected output format.
+func printAttributes(w io.Writer, format string, defs []*pb.AttributeDefinition) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(attributeHeader, "\t"))
+		for _, d := range defs {
+			fmt.Fprintln(tw, strings.Join(attributeRow(d), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(attributeHeader)
+		for _, d := range defs {
+			cw.Write(attributeRow(d))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(defs))
+		for _, d := range defs {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(d)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func attributeRow(d *pb.AttributeDefinition) []string {
+	attributeType := strings.ToLower(strings.TrimPrefix(d.Type.String(), "ATTRIBUTE_TYPE_"))
+	return []string{
+		d.Name,
+		attributeType,
+		strings.Join(d.AllowedValues, ","),
+		strconv.FormatBool(d.Required),
+		strconv.FormatBool(d.Pii),
+		d.Description,
+		formatTimestamp(d.UpdateTime),
+	}
+}
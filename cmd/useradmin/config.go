@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Profile holds the connection settings for one UserService deployment.
+type Profile struct {
+	Address  string `json:"address"`
+	Token    string `json:"token,omitempty"`
+	Tenant   string `json:"tenant,omitempty"`
+	Insecure bool   `json:"insecure,omitempty"`
+}
+
+// Config is stored as JSON in the user's config directory.
+type Config struct {
+	CurrentProfile string              `json:"current_profile"`
+	Profiles       map[string]*Profile `json:"profiles"`
+}
+
+// configPath returns $USERADMIN_CONFIG or <user config dir>/useradmin/config.json.
+func configPath() (string, error) {
+	if path := os.Getenv("USERADMIN_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to locate config directory: %v", err)
+	}
+	return filepath.Join(dir, "useradmin", "config.json"), nil
+}
+
+// loadConfig reads the config file. A missing file yields an empty config.
+func loadConfig() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{Profiles: map[string]*Profile{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	return cfg, nil
+}
+
+// save writes the config file readable only by the current user, since it contains tokens.
+func (c *Config) save() error {
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", path, err)
+	}
+	return nil
+}
+
+// profileNames returns the configured profile names in sorted order.
+func (c *Config) profileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/client"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var revisionHeader = []string{"REVISION", "TIME", "ACTOR", "ACTION", "CHANGES"}
+
+func newHistoryCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show and revert the change history of users",
+	}
+
+	var page, pageSize int32
+	list := &cobra.Command{
+		Use:   "list ID",
+		Short: "List the revisions of a user, newest first",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.ListUserRevisions(ctx, ids[0], page, pageSize)
+			if err != nil {
+				return err
+			}
+			return printRevisions(cmd.OutOrStdout(), flags.output, resp.Revisions)
+		},
+	}
+	list.Flags().Int32Var(&page, "page", 1, "page number")
+	list.Flags().Int32Var(&pageSize, "page-size", 20, "revisions per page")
+
+	asOf := &cobra.Command{
+		Use:   "as-of ID TIME",
+		Short: "Show a user as it was at a time (RFC 3339, e.g. 2024-05-01T12:00:00Z)",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args[:1])
+			if err != nil {
+				return err
+			}
+			at, err := time.Parse(time.RFC3339, args[1])
+			if err != nil {
+				return fmt.Errorf("invalid time %q, use RFC 3339", args[1])
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			user, err := c.GetUserAsOf(ctx, ids[0], at)
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), flags.output, []*pb.GetUserResponse{user})
+		},
+	}
+
+	var reason string
+	revert := &cobra.Command{
+		Use:   "revert ID REVISION --reason TEXT",
+		Short: "Restore a user to an earlier revision",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			revision, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil || revision <= 0 {
+				return fmt.Errorf("invalid revision %q", args[1])
+			}
+			return runDestructive(cmd, args[:1], fmt.Sprintf("Revert to revision %d:", revision), func(ctx context.Context, c *client.Client, id int64) error {
+				_, err := c.RevertUser(ctx, id, revision, reason)
+				return err
+			})
+		},
+	}
+	revert.Flags().StringVar(&reason, "reason", "", "reason for the revert, recorded in the audit log")
+	revert.MarkFlagRequired("reason")
+
+	cmd.AddCommand(list, asOf, revert)
+	return cmd
+}
+
+// printRevisions writes revisions in the selected output format.
+func printRevisions(w io.Writer, format string, revisions []*pb.UserRevision) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(revisionHeader, "\t"))
+		for _, r := range revisions {
+			fmt.Fprintln(tw, strings.Join(revisionRow(r), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(revisionHeader)
+		for _, r := range revisions {
+			cw.Write(revisionRow(r))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(revisions))
+		for _, r := range revisions {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(r)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func revisionRow(r *pb.UserRevision) []string {
+	changes := make([]string, 0, len(r.Changes))
+	for _, change := range r.Changes {
+		changes = append(changes, fmt.Sprintf("%s: %q -> %q", change.Field, change.OldValue, change.NewValue))
+	}
+	return []string{
+		strconv.FormatInt(r.Revision, 10),
+		formatTimestamp(r.CreateTime),
+		r.Actor,
+		r.Action,
+		strings.Join(changes, ", "),
+	}
+}
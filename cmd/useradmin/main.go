@@ -0,0 +1,141 @@
+// Command useradmin is a command-line client for UserService.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+type globalFlags struct {
+	profile  string
+	address  string
+	token    string
+	apiKey   string
+	tenant   string
+	insecure bool
+	output   string
+	timeout  time.Duration
+	yes      bool
+}
+
+var flags globalFlags
+
+func main() {
+	if err := newRootCommand().Execute(); err != nil {
+		os.Exit(1)
+	}
+}
+
+func newRootCommand() *cobra.Command {
+	root := &cobra.Command{
+		Use:          "useradmin",
+		Short:        "Manage users through UserService",
+		SilenceUsage: true,
+	}
+
+	pf := root.PersistentFlags()
+	pf.StringVar(&flags.profile, "profile", "", "config profile to use (default: the current profile)")
+	pf.StringVar(&flags.address, "address", "", "server address, overrides the profile (env USERADMIN_ADDRESS)")
+	pf.StringVar(&flags.token, "token", "", "auth token, overrides the profile (env USERADMIN_TOKEN)")
+	pf.StringVar(&flags.apiKey, "api-key", "", "API key, sent instead of the token (env USERADMIN_API_KEY)")
+	pf.StringVar(&flags.tenant, "tenant", "", "tenant slug, overrides the profile (env USERADMIN_TENANT)")
+	pf.BoolVar(&flags.insecure, "insecure", false, "connect without TLS")
+	pf.StringVarP(&flags.output, "output", "o", "table", "output format: table, json or csv")
+	pf.DurationVar(&flags.timeout, "timeout", client.DefaultTimeout, "deadline for each call")
+	pf.BoolVarP(&flags.yes, "yes", "y", false, "skip confirmation prompts")
+
+	root.RegisterFlagCompletionFunc("output", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"table", "json", "csv"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	root.RegisterFlagCompletionFunc("profile", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		cfg, err := loadConfig()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return cfg.profileNames(), cobra.ShellCompDirectiveNoFileComp
+	})
+
+	root.AddCommand(
+		newListCommand(),
+		newGetCommand(),
+		newLookupCommand(),
+		newCreateCommand(),
+		newUpdateCommand(),
+		newDeleteCommand(),
+		newBlockCommand(true),
+		newBlockCommand(false),
+		newVerifyEmailCommand(),
+		newPhoneCommand(),
+		newHistoryCommand(),
+		newDuplicatesCommand(),
+		newMergeCommand(),
+		newTagCommand(),
+		newNoteCommand(),
+		newStatsCommand(),
+		newAttributeCommand(),
+		newLoginCommand(),
+		newAdminCommand(),
+		newAPIKeyCommand(),
+		newTenantCommand(),
+		newProfileCommand(),
+	)
+	return root
+}
+
+// connect dials the server using flags, then environment variables, then the selected profile.
+func connect(ctx context.Context) (*client.Client, error) {
+	cfg, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	name := flags.profile
+	if name == "" {
+		name = cfg.CurrentProfile
+	}
+	profile := &Profile{}
+	if name != "" {
+		p, ok := cfg.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("profile %q does not exist", name)
+		}
+		profile = p
+	}
+
+	address := firstNonEmpty(flags.address, os.Getenv("USERADMIN_ADDRESS"), profile.Address)
+	token := firstNonEmpty(flags.token, os.Getenv("USERADMIN_TOKEN"), profile.Token)
+	apiKey := firstNonEmpty(flags.apiKey, os.Getenv("USERADMIN_API_KEY"))
+	tenant := firstNonEmpty(flags.tenant, os.Getenv("USERADMIN_TENANT"), profile.Tenant)
+	if address == "" {
+		return nil, fmt.Errorf("no server address: pass --address or configure a profile with 'useradmin profile set'")
+	}
+
+	opts := []client.Option{client.WithTimeout(flags.timeout)}
+	if flags.insecure || profile.Insecure {
+		opts = append(opts, client.WithInsecure())
+	}
+	if tenant != "" {
+		opts = append(opts, client.WithTenant(tenant))
+	}
+	switch {
+	case apiKey != "":
+		opts = append(opts, client.WithAPIKey(apiKey))
+	case token != "":
+		opts = append(opts, client.WithToken(token))
+	}
+	return client.Dial(ctx, address, opts...)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
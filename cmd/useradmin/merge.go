@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var duplicateHeader = []string{"USER", "DUPLICATE", "SCORE", "REASONS"}
+
+func newDuplicatesCommand() *cobra.Command {
+	var minScore float64
+	var limit int32
+	cmd := &cobra.Command{
+		Use:   "duplicates [ID]",
+		Short: "List likely duplicate users, of one user or of all users",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			req := &pb.FindDuplicateUsersRequest{MinScore: minScore, Limit: limit}
+			if len(ids) > 0 {
+				req.UserId = ids[0]
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			duplicates, err := c.FindDuplicateUsers(ctx, req)
+			if err != nil {
+				return err
+			}
+			return printDuplicates(cmd.OutOrStdout(), flags.output, duplicates)
+		},
+	}
+	cmd.Flags().Float64Var(&minScore, "min-score", 0.5, "lowest score to list, between 0 and 1")
+	cmd.Flags().Int32Var(&limit, "limit", 50, "maximum number of pairs")
+	return cmd
+}
+
+func newMergeCommand() *cobra.Command {
+	var reason string
+	var fromSource, fromTarget []string
+	cmd := &cobra.Command{
+		Use:   "merge SOURCE TARGET --reason TEXT",
+		Short: "Merge a duplicate user into another and retire it",
+		Long: "Merge a duplicate user into another and retire it. Empty fields of the target are filled\n" +
+			"from the source, other fields keep the value of the target unless given with --source-field.\n" +
+			"Tags, notes, active sessions and phone history of the source move to the target.",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			req := &pb.MergeUsersRequest{
+				SourceId: ids[0],
+				TargetId: ids[1],
+				Fields:   make(map[string]pb.MergeResolution),
+				Reason:   reason,
+			}
+			for _, field := range fromSource {
+				req.Fields[field] = pb.MergeResolution_MERGE_RESOLUTION_SOURCE
+			}
+			for _, field := range fromTarget {
+				if _, ok := req.Fields[field]; ok {
+					return fmt.Errorf("field %s is given for both the source and the target", field)
+				}
+				req.Fields[field] = pb.MergeResolution_MERGE_RESOLUTION_TARGET
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			if !flags.yes {
+				source, err := c.GetUser(ctx, req.SourceId)
+				if err != nil {
+					return err
+				}
+				target, err := c.GetUser(ctx, req.TargetId)
+				if err != nil {
+					return err
+				}
+				ok, err := confirm(cmd, fmt.Sprintf("Merge user %d (%s %s) into user %d (%s %s) and retire user %d?",
+					source.Id, strings.TrimSpace(source.FirstName+" "+source.LastName), source.PhoneNumber,
+					target.Id, strings.TrimSpace(target.FirstName+" "+target.LastName), target.PhoneNumber, source.Id))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Aborted")
+					return nil
+				}
+			}
+
+			if _, err := c.MergeUsers(ctx, req); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Merged user %d into user %d\n", req.SourceId, req.TargetId)
+			return printFreshUser(ctx, cmd, c, req.TargetId)
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "", "reason for the merge, recorded in the audit log")
+	cmd.Flags().StringSliceVar(&fromSource, "source-field", nil, "field to take from the source, e.g. phone_number or custom_attributes.tier (repeatable)")
+	cmd.Flags().StringSliceVar(&fromTarget, "target-field", nil, "field to keep from the target even if it is empty (repeatable)")
+	cmd.MarkFlagRequired("reason")
+	return cmd
+}
+
+// printDuplicates writes duplicate pairs in the selected output format.
+func printDuplicates(w io.Writer, format string, duplicates []*pb.DuplicateUsers) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(duplicateHeader, "\t"))
+		for _, d := range duplicates {
+			fmt.Fprintln(tw, strings.Join(duplicateRow(d), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(duplicateHeader)
+		for _, d := range duplicates {
+			cw.Write(duplicateRow(d))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(duplicates))
+		for _, d := range duplicates {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(d)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func duplicateRow(d *pb.DuplicateUsers) []string {
+	describe := func(u *pb.GetUserResponse) string {
+		name := strings.TrimSpace(u.GetFirstName() + " " + u.GetLastName())
+		return fmt.Sprintf("%d %s (%s)", u.GetId(), name, u.GetPhoneNumber())
+	}
+	return []string{
+		describe(d.User),
+		describe(d.Duplicate),
+		strconv.FormatFloat(d.Score, 'f', 2, 64),
+		strings.Join(d.Reasons, ", "),
+	}
+}
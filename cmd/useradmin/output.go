@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var userHeader = []string{"ID", "PUBLIC ID", "FIRST NAME", "LAST NAME", "PHONE", "EMAIL", "GENDER", "DATE OF BIRTH", "LOCATION", "BLOCKED", "REGISTERED"}
+
+// printUsers writes users in the selected output format.
+func printUsers(w io.Writer, format string, users []*pb.GetUserResponse) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(userHeader, "\t"))
+		for _, u := range users {
+			fmt.Fprintln(tw, strings.Join(userRow(u), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(userHeader)
+		for _, u := range users {
+			cw.Write(userRow(u))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(users))
+		for _, u := range users {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(u)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func userRow(u *pb.GetUserResponse) []string {
+	return []string{
+		strconv.FormatInt(u.Id, 10),
+		u.PublicId,
+		u.FirstName,
+		u.LastName,
+		u.PhoneNumber,
+		u.Email,
+		u.Gender,
+		formatDate(u.BirthDate),
+		u.Location,
+		strconv.FormatBool(u.Blocked),
+		formatTimestamp(u.RegistrationTime),
+	}
+}
+
+func formatDate(d *date.Date) string {
+	if d == nil {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// formatTimestamp renders t in the local time zone.
+func formatTimestamp(t *timestamppb.Timestamp) string {
+	if t == nil {
+		return ""
+	}
+	return t.AsTime().Local().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/client"
+	"github.com/spf13/cobra"
+)
+
+func newPhoneCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "phone",
+		Short: "Change phone numbers",
+		Long:  "Phone numbers are changed by confirming a code sent to the new number, or by an audited override.",
+	}
+
+	change := &cobra.Command{
+		Use:   "change ID NUMBER",
+		Short: "Send a confirmation code to a new phone number",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args[:1])
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.StartPhoneChange(ctx, ids[0], args[1])
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Code sent to %s, confirm it with: useradmin phone confirm %d CODE\n", resp.PhoneNumber, ids[0])
+			return nil
+		},
+	}
+
+	confirmCmd := &cobra.Command{
+		Use:   "confirm ID CODE",
+		Short: "Confirm a phone change with the code sent to the new number",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args[:1])
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			if _, err := c.ConfirmPhoneChange(ctx, ids[0], args[1]); err != nil {
+				return err
+			}
+			return printFreshUser(ctx, cmd, c, ids[0])
+		},
+	}
+
+	var reason string
+	override := &cobra.Command{
+		Use:   "override ID NUMBER --reason TEXT",
+		Short: "Change a phone number without verification",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDestructive(cmd, args[:1], "Override phone number of", func(ctx context.Context, c *client.Client, id int64) error {
+				_, err := c.OverridePhoneNumber(ctx, id, args[1], reason)
+				return err
+			})
+		},
+	}
+	override.Flags().StringVar(&reason, "reason", "", "reason for the override, recorded in the audit log")
+	override.MarkFlagRequired("reason")
+
+	cmd.AddCommand(change, confirmCmd, override)
+	return cmd
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newProfileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "profile",
+		Short: "Manage connection profiles",
+	}
+
+	completeProfiles := func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		cfg, err := loadConfig()
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveError
+		}
+		return cfg.profileNames(), cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var address, token, tenant string
+	var insecure bool
+	set := &cobra.Command{
+		Use:   "set NAME",
+		Short: "Create or update a profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			profile, ok := cfg.Profiles[args[0]]
+			if !ok {
+				profile = &Profile{}
+				cfg.Profiles[args[0]] = profile
+			}
+			if cmd.Flags().Changed("address") {
+				profile.Address = address
+			}
+			if cmd.Flags().Changed("token") {
+				profile.Token = token
+			}
+			if cmd.Flags().Changed("tenant") {
+				profile.Tenant = tenant
+			}
+			if cmd.Flags().Changed("insecure") {
+				profile.Insecure = insecure
+			}
+			if cfg.CurrentProfile == "" {
+				cfg.CurrentProfile = args[0]
+			}
+			return cfg.save()
+		},
+		ValidArgsFunction: completeProfiles,
+	}
+	set.Flags().StringVar(&address, "address", "", "server address (host:port)")
+	set.Flags().StringVar(&token, "token", "", "auth token")
+	set.Flags().StringVar(&tenant, "tenant", "", "tenant slug, for calls without a token")
+	set.Flags().BoolVar(&insecure, "insecure", false, "connect without TLS")
+
+	use := &cobra.Command{
+		Use:   "use NAME",
+		Short: "Select the profile used by default",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if _, ok := cfg.Profiles[args[0]]; !ok {
+				return fmt.Errorf("profile %q does not exist", args[0])
+			}
+			cfg.CurrentProfile = args[0]
+			return cfg.save()
+		},
+		ValidArgsFunction: completeProfiles,
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+
+			tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(tw, "CURRENT\tNAME\tADDRESS\tTENANT\tTOKEN\tINSECURE")
+			for _, name := range cfg.profileNames() {
+				p := cfg.Profiles[name]
+				current := ""
+				if name == cfg.CurrentProfile {
+					current = "*"
+				}
+				hasToken := "no"
+				if p.Token != "" {
+					hasToken = "yes"
+				}
+				fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\n", current, name, p.Address, p.Tenant, hasToken, p.Insecure)
+			}
+			return tw.Flush()
+		},
+	}
+
+	remove := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete a profile",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := loadConfig()
+			if err != nil {
+				return err
+			}
+			if _, ok := cfg.Profiles[args[0]]; !ok {
+				return fmt.Errorf("profile %q does not exist", args[0])
+			}
+			delete(cfg.Profiles, args[0])
+			if cfg.CurrentProfile == args[0] {
+				cfg.CurrentProfile = ""
+			}
+			return cfg.save()
+		},
+		ValidArgsFunction: completeProfiles,
+	}
+
+	cmd.AddCommand(set, use, list, remove)
+	return cmd
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var statsHeader = []string{"SECTION", "KEY", "COUNT"}
+
+var statsIntervals = map[string]pb.StatsInterval{
+	"day":   pb.StatsInterval_STATS_INTERVAL_DAY,
+	"week":  pb.StatsInterval_STATS_INTERVAL_WEEK,
+	"month": pb.StatsInterval_STATS_INTERVAL_MONTH,
+}
+
+func newStatsCommand() *cobra.Command {
+	var from, to, interval, timeZone string
+	var locationLimit int32
+	var filterFlags userFilterFlags
+	cmd := &cobra.Command{
+		Use:   "stats [filter flags]",
+		Short: "Show user counts, registrations per day, week or month and demographics",
+		Long: "Show user counts, registrations per day, week or month and demographics.\n" +
+			"--from and --to take a date (YYYY-MM-DD, in --time-zone) or an RFC 3339 time and\n" +
+			"default to the 30 days before now.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			req := &pb.GetUserStatsRequest{TimeZone: timeZone, LocationLimit: locationLimit}
+			var ok bool
+			if req.Interval, ok = statsIntervals[interval]; !ok {
+				return fmt.Errorf("--interval must be day, week or month")
+			}
+			loc := time.UTC
+			var err error
+			if timeZone != "" {
+				if loc, err = time.LoadLocation(timeZone); err != nil {
+					return fmt.Errorf("unknown time zone %q", timeZone)
+				}
+			}
+			if req.Filter, err = filterFlags.message(); err != nil {
+				return err
+			}
+			if req.StartTime, err = parseStatsTime("--from", from, loc); err != nil {
+				return err
+			}
+			if req.EndTime, err = parseStatsTime("--to", to, loc); err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			stats, err := c.GetUserStats(ctx, req)
+			if err != nil {
+				return err
+			}
+			return printStats(cmd.OutOrStdout(), flags.output, stats, loc)
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&from, "from", "", "start of the registrations counted")
+	f.StringVar(&to, "to", "", "end of the registrations counted, exclusive")
+	f.StringVar(&interval, "interval", "day", "registration buckets: day, week or month")
+	f.StringVar(&timeZone, "time-zone", "", "IANA time zone of buckets and ages, e.g. Asia/Ashgabat (default UTC)")
+	f.Int32Var(&locationLimit, "locations", 0, "locations listed before the rest are counted as other (default 10)")
+	filterFlags.register(cmd)
+	cmd.RegisterFlagCompletionFunc("interval", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"day", "week", "month"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+// parseStatsTime accepts a date in loc or an RFC 3339 time. Empty values
+// leave the default of the server.
+func parseStatsTime(flag, value string, loc *time.Location) (*timestamppb.Timestamp, error) {
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return timestamppb.New(t), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q, use YYYY-MM-DD or RFC 3339", flag, value)
+	}
+	return timestamppb.New(t), nil
+}
+
+// printStats writes stats in the selected output format. Tables and CSV list
+// one count per row, with registration buckets shown by their start date in
+// loc.
+func printStats(w io.Writer, format string, stats *pb.UserStats, loc *time.Location) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(statsHeader, "\t"))
+		for _, row := range statsRows(stats, loc) {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(statsHeader)
+		for _, row := range statsRows(stats, loc) {
+			cw.Write(row)
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		data, err := protojson.MarshalOptions{UseProtoNames: true, Multiline: true}.Marshal(stats)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func statsRows(stats *pb.UserStats, loc *time.Location) [][]string {
+	row := func(section, key string, count int64) []string {
+		return []string{section, key, strconv.FormatInt(count, 10)}
+	}
+	rows := [][]string{
+		row("users", "total", stats.TotalUsers),
+		row("users", "blocked", stats.BlockedUsers),
+		row("users", "deleted", stats.DeletedUsers),
+		row("users", "registered", stats.RegisteredUsers),
+	}
+	for _, b := range stats.Registrations {
+		rows = append(rows, row("registrations", b.StartTime.AsTime().In(loc).Format("2006-01-02"), b.Count))
+	}
+	for _, section := range []struct {
+		name   string
+		counts []*pb.StatsCount
+	}{
+		{"gender", stats.Genders},
+		{"age", stats.AgeBands},
+		{"location", stats.Locations},
+	} {
+		for _, c := range section.counts {
+			rows = append(rows, row(section.name, c.Key, c.Count))
+		}
+	}
+	return rows
+}
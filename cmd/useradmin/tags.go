@@ -0,0 +1,361 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var (
+	tagHeader  = []string{"TAG", "USERS", "CREATED BY", "CREATED"}
+	noteHeader = []string{"ID", "TIME", "AUTHOR", "NOTE"}
+)
+
+func newTagCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tag",
+		Short: "Show and change the tags of users",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List all tags with the number of users having them",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Raw().ListTags(ctx, &pb.Empty{})
+			if err != nil {
+				return err
+			}
+			return printTags(cmd.OutOrStdout(), flags.output, resp.Tags)
+		},
+	}
+
+	show := &cobra.Command{
+		Use:   "show ID",
+		Short: "Show the tags of a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Raw().GetUserTags(ctx, &pb.UserID{Id: ids[0]})
+			if err != nil {
+				return err
+			}
+			return printUserTags(cmd.OutOrStdout(), resp)
+		},
+	}
+
+	cmd.AddCommand(list, show, newTagChangeCommand(true), newTagChangeCommand(false), newBulkTagCommand())
+	return cmd
+}
+
+func newTagChangeCommand(add bool) *cobra.Command {
+	use, short := "remove ID TAG...", "Remove tags from a user"
+	if add {
+		use, short = "add ID TAG...", "Tag a user, unknown tags are added to the catalog"
+	}
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args[:1])
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var tags []string
+			if add {
+				tags, err = c.AddUserTags(ctx, ids[0], args[1:]...)
+			} else {
+				tags, err = c.RemoveUserTags(ctx, ids[0], args[1:]...)
+			}
+			if err != nil {
+				return err
+			}
+			return printUserTags(cmd.OutOrStdout(), &pb.UserTags{UserId: ids[0], Tags: tags})
+		},
+	}
+}
+
+// userFilterFlags are the flags selecting users on the server.
+type userFilterFlags struct {
+	tags        []string
+	ids         []string
+	blocked     string
+	country     string
+	phonePrefix string
+}
+
+func (f *userFilterFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&f.tags, "with-tag", nil, "only users with all of these tags (repeatable)")
+	cmd.Flags().StringSliceVar(&f.ids, "id", nil, "only users with these IDs (repeatable)")
+	cmd.Flags().StringVar(&f.blocked, "blocked", "", "only blocked (true) or unblocked (false) users")
+	cmd.Flags().StringVar(&f.country, "country", "", "only users with phone numbers of this country, e.g. TM")
+	cmd.Flags().StringVar(&f.phonePrefix, "phone-prefix", "", "only phone numbers starting with this prefix, e.g. +99365")
+}
+
+func (f *userFilterFlags) message() (*pb.UserFilter, error) {
+	filter := &pb.UserFilter{Tags: f.tags, Country: f.country, PhonePrefix: f.phonePrefix}
+	switch f.blocked {
+	case "":
+	case "true":
+		filter.Blocked = pb.BlockedFilter_BLOCKED_FILTER_BLOCKED
+	case "false":
+		filter.Blocked = pb.BlockedFilter_BLOCKED_FILTER_NOT_BLOCKED
+	default:
+		return nil, fmt.Errorf("--blocked must be true or false")
+	}
+	if len(f.ids) > 0 {
+		parsed, err := parseIDs(f.ids)
+		if err != nil {
+			return nil, err
+		}
+		filter.Ids = parsed
+	}
+	return filter, nil
+}
+
+func newBulkTagCommand() *cobra.Command {
+	var add, remove []string
+	var filterFlags userFilterFlags
+	cmd := &cobra.Command{
+		Use:   "bulk --add TAG | --remove TAG [filter flags]",
+		Short: "Add and remove tags on all users matching a filter",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter, err := filterFlags.message()
+			if err != nil {
+				return err
+			}
+			if !flags.yes {
+				ok, err := confirm(cmd, "Change the tags of all users matching the filter?")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Aborted")
+					return nil
+				}
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Raw().BulkTagUsers(ctx, &pb.BulkTagUsersRequest{Filter: filter, AddTags: add, RemoveTags: remove})
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%d users matched\n", resp.MatchedUsers)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&add, "add", nil, "tag to add (repeatable)")
+	cmd.Flags().StringSliceVar(&remove, "remove", nil, "tag to remove (repeatable)")
+	filterFlags.register(cmd)
+	return cmd
+}
+
+func newNoteCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "note",
+		Short: "Add and show notes on users",
+	}
+
+	add := &cobra.Command{
+		Use:   "add ID TEXT",
+		Short: "Add a note to a user, notes cannot be changed afterwards",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args[:1])
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			note, err := c.AddUserNote(ctx, ids[0], args[1])
+			if err != nil {
+				return err
+			}
+			return printNotes(cmd.OutOrStdout(), flags.output, []*pb.UserNote{note})
+		},
+	}
+
+	var page, pageSize int32
+	list := &cobra.Command{
+		Use:   "list ID",
+		Short: "List the notes of a user, newest first",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := c.Raw().ListUserNotes(ctx, &pb.ListUserNotesRequest{UserId: ids[0], Page: page, PageSize: pageSize})
+			if err != nil {
+				return err
+			}
+			return printNotes(cmd.OutOrStdout(), flags.output, resp.Notes)
+		},
+	}
+	list.Flags().Int32Var(&page, "page", 1, "page number")
+	list.Flags().Int32Var(&pageSize, "page-size", 20, "notes per page")
+
+	cmd.AddCommand(add, list)
+	return cmd
+}
+
+// printUserTags writes the tags of a user, one per line in table and csv
+// output.
+func printUserTags(w io.Writer, tags *pb.UserTags) error {
+	if flags.output == "json" {
+		data, err := protojson.MarshalOptions{UseProtoNames: true, Multiline: true}.Marshal(tags)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(data))
+		return err
+	}
+	for _, tag := range tags.Tags {
+		fmt.Fprintln(w, tag)
+	}
+	return nil
+}
+
+// printTags writes the tag catalog in the selected output format.
+func printTags(w io.Writer, format string, tags []*pb.Tag) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(tagHeader, "\t"))
+		for _, t := range tags {
+			fmt.Fprintln(tw, strings.Join(tagRow(t), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(tagHeader)
+		for _, t := range tags {
+			cw.Write(tagRow(t))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(tags))
+		for _, t := range tags {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(t)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func tagRow(t *pb.Tag) []string {
+	return []string{
+		t.Name,
+		strconv.FormatInt(t.UserCount, 10),
+		t.CreatedBy,
+		formatTimestamp(t.CreateTime),
+	}
+}
+
+// printNotes writes notes in the selected output format.
+func printNotes(w io.Writer, format string, notes []*pb.UserNote) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(noteHeader, "\t"))
+		for _, n := range notes {
+			fmt.Fprintln(tw, strings.Join(noteRow(n), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(noteHeader)
+		for _, n := range notes {
+			cw.Write(noteRow(n))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(notes))
+		for _, n := range notes {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(n)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+func noteRow(n *pb.UserNote) []string {
+	return []string{
+		strconv.FormatInt(n.Id, 10),
+		formatTimestamp(n.CreateTime),
+		n.Author,
+		// Keep multi-line notes on one table row
+		strings.ReplaceAll(n.Body, "\n", " "),
+	}
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var tenantHeader = []string{"ID", "SLUG", "NAME", "PHONE COUNTRIES", "OTP TTL", "OTP ATTEMPTS", "DISABLED", "CREATED BY", "CREATED"}
+
+// tenantConfigFlags are the overrides of a tenant set on the command line.
+type tenantConfigFlags struct {
+	phoneCountries      []string
+	phoneDefaultCountry string
+	otpTTL              time.Duration
+	otpMaxAttempts      int32
+}
+
+func (f *tenantConfigFlags) register(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&f.phoneCountries, "phone-country", nil, "ISO code of a country phone numbers are accepted from (repeatable)")
+	cmd.Flags().StringVar(&f.phoneDefaultCountry, "phone-default-country", "", "country of numbers without a country code")
+	cmd.Flags().DurationVar(&f.otpTTL, "otp-ttl", 0, "how long login and phone change codes are valid, e.g. 5m")
+	cmd.Flags().Int32Var(&f.otpMaxAttempts, "otp-max-attempts", 0, "wrong codes after which a code is discarded")
+}
+
+// changed tells whether any override was set.
+func (f *tenantConfigFlags) changed(cmd *cobra.Command) bool {
+	for _, name := range []string{"phone-country", "phone-default-country", "otp-ttl", "otp-max-attempts"} {
+		if cmd.Flags().Changed(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *tenantConfigFlags) message() *pb.TenantConfig {
+	c := &pb.TenantConfig{
+		PhoneCountries:      f.phoneCountries,
+		PhoneDefaultCountry: f.phoneDefaultCountry,
+		PhoneOtpMaxAttempts: f.otpMaxAttempts,
+	}
+	if f.otpTTL > 0 {
+		c.PhoneOtpTtl = durationpb.New(f.otpTTL)
+	}
+	return c
+}
+
+func newTenantCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "tenant",
+		Short: "Manage tenants (requires the superadmin role in the default tenant)",
+	}
+
+	list := &cobra.Command{
+		Use:   "list",
+		Short: "List tenants",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := pb.NewTenantServiceClient(c.Conn()).ListTenants(ctx, &pb.Empty{})
+			if err != nil {
+				return err
+			}
+			return printTenants(cmd.OutOrStdout(), flags.output, resp.Tenants)
+		},
+	}
+
+	show := &cobra.Command{
+		Use:   "show ID|SLUG",
+		Short: "Show a tenant",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			t, err := pb.NewTenantServiceClient(c.Conn()).GetTenant(ctx, parseTenantID(args[0]))
+			if err != nil {
+				return err
+			}
+			return printTenants(cmd.OutOrStdout(), flags.output, []*pb.Tenant{t})
+		},
+	}
+
+	var name, adminUsername string
+	var createConfig tenantConfigFlags
+	create := &cobra.Command{
+		Use:   "create SLUG --name NAME",
+		Short: "Create a tenant, optionally with its first superadmin",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			resp, err := pb.NewTenantServiceClient(c.Conn()).CreateTenant(ctx, &pb.CreateTenantRequest{
+				Slug:          args[0],
+				Name:          name,
+				Config:        createConfig.message(),
+				AdminUsername: adminUsername,
+			})
+			if err != nil {
+				return err
+			}
+			if resp.Admin != nil && flags.output == "json" {
+				data, err := protojson.MarshalOptions{UseProtoNames: true, Multiline: true}.Marshal(resp)
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), string(data))
+				return err
+			}
+			if err := printTenants(cmd.OutOrStdout(), flags.output, []*pb.Tenant{resp.Tenant}); err != nil {
+				return err
+			}
+			if resp.Admin == nil {
+				return nil
+			}
+			fmt.Fprintln(cmd.OutOrStdout())
+			return printCredentials(cmd.OutOrStdout(), resp.Admin)
+		},
+	}
+	create.Flags().StringVar(&name, "name", "", "display name of the tenant")
+	create.Flags().StringVar(&adminUsername, "admin", "", "username of the first superadmin of the tenant")
+	createConfig.register(create)
+	create.MarkFlagRequired("name")
+
+	var newName string
+	var updateConfig tenantConfigFlags
+	update := &cobra.Command{
+		Use:   "update ID",
+		Short: "Rename a tenant or replace its overrides",
+		Long: "Rename a tenant or replace its overrides. Setting any of the override flags replaces all\n" +
+			"overrides, so overrides that are not given fall back to the server settings.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil || id <= 0 {
+				return fmt.Errorf("invalid tenant ID %q", args[0])
+			}
+			req := &pb.UpdateTenantRequest{Id: id, Name: newName}
+			if updateConfig.changed(cmd) {
+				req.Config = updateConfig.message()
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			t, err := pb.NewTenantServiceClient(c.Conn()).UpdateTenant(ctx, req)
+			if err != nil {
+				return err
+			}
+			return printTenants(cmd.OutOrStdout(), flags.output, []*pb.Tenant{t})
+		},
+	}
+	update.Flags().StringVar(&newName, "name", "", "new display name")
+	updateConfig.register(update)
+
+	cmd.AddCommand(list, show, create, update, newTenantDisableCommand(true), newTenantDisableCommand(false))
+	return cmd
+}
+
+func newTenantDisableCommand(disable bool) *cobra.Command {
+	use, short := "enable ID|SLUG", "Enable a disabled tenant"
+	if disable {
+		use, short = "disable ID|SLUG", "Disable a tenant, all its calls are rejected at once"
+	}
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if disable && !flags.yes {
+				ok, err := confirm(cmd, fmt.Sprintf("Disable tenant %s? Its users, admins and API keys are rejected until it is enabled again.", args[0]))
+				if err != nil {
+					return err
+				}
+				if !ok {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Aborted")
+					return nil
+				}
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			tenants := pb.NewTenantServiceClient(c.Conn())
+			var t *pb.Tenant
+			if disable {
+				t, err = tenants.DisableTenant(ctx, parseTenantID(args[0]))
+			} else {
+				t, err = tenants.EnableTenant(ctx, parseTenantID(args[0]))
+			}
+			if err != nil {
+				return err
+			}
+			return printTenants(cmd.OutOrStdout(), flags.output, []*pb.Tenant{t})
+		},
+	}
+}
+
+// parseTenantID accepts a tenant ID or slug.
+func parseTenantID(arg string) *pb.TenantID {
+	if id, err := strconv.ParseInt(arg, 10, 64); err == nil {
+		return &pb.TenantID{Id: id}
+	}
+	return &pb.TenantID{Slug: arg}
+}
+
+// printTenants writes tenants in the selected output format.
+func printTenants(w io.Writer, format string, tenants []*pb.Tenant) error {
+	switch format {
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(tenantHeader, "\t"))
+		for _, t := range tenants {
+			fmt.Fprintln(tw, strings.Join(tenantRow(t), "\t"))
+		}
+		return tw.Flush()
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write(tenantHeader)
+		for _, t := range tenants {
+			cw.Write(tenantRow(t))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		items := make([]json.RawMessage, 0, len(tenants))
+		for _, t := range tenants {
+			data, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(t)
+			if err != nil {
+				return err
+			}
+			items = append(items, data)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(items)
+	}
+	return fmt.Errorf("unknown output format %q", format)
+}
+
+// tenantRow shows overrides that are not set as empty.
+func tenantRow(t *pb.Tenant) []string {
+	var countries, ttl, attempts string
+	if c := t.Config; c != nil {
+		countries = strings.Join(c.PhoneCountries, ",")
+		if c.PhoneDefaultCountry != "" {
+			countries += " (default " + c.PhoneDefaultCountry + ")"
+		}
+		if c.PhoneOtpTtl != nil {
+			ttl = c.PhoneOtpTtl.AsDuration().String()
+		}
+		if c.PhoneOtpMaxAttempts > 0 {
+			attempts = strconv.Itoa(int(c.PhoneOtpMaxAttempts))
+		}
+	}
+	return []string{
+		strconv.FormatInt(t.Id, 10),
+		t.Slug,
+		t.Name,
+		strings.TrimSpace(countries),
+		ttl,
+		attempts,
+		strconv.FormatBool(t.Disabled),
+		t.CreatedBy,
+		formatTimestamp(t.CreateTime),
+	}
+}
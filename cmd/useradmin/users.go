@@ -0,0 +1,492 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/client"
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/type/date"
+)
+
+func newListCommand() *cobra.Command {
+	var page, pageSize, limit int32
+	var all bool
+	var blocked, search, phonePrefix, gender, location string
+	var tags []string
+	var attributes map[string]string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List users",
+		Long: "List users one page at a time. With --all or any filter flag, pages are\n" +
+			"fetched until the end (or --limit matching users) and filtered locally.\n" +
+			"--tag and --attr are applied by the server.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if blocked != "" && blocked != "true" && blocked != "false" {
+				return fmt.Errorf("--blocked must be true or false")
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var serverFilter *pb.UserFilter
+			if len(tags) > 0 || len(attributes) > 0 {
+				serverFilter = &pb.UserFilter{Tags: tags, CustomAttributes: attributes}
+			}
+			filtered := blocked != "" || search != "" || phonePrefix != "" || gender != "" || location != ""
+			if !all && !filtered {
+				resp, err := c.FilterUsers(ctx, serverFilter, page, pageSize)
+				if err != nil {
+					return err
+				}
+				return printUsers(cmd.OutOrStdout(), flags.output, resp.Users)
+			}
+
+			matches := func(u *pb.GetUserResponse) bool {
+				if blocked != "" && strconv.FormatBool(u.Blocked) != blocked {
+					return false
+				}
+				if phonePrefix != "" && !strings.HasPrefix(u.PhoneNumber, phonePrefix) {
+					return false
+				}
+				if gender != "" && !strings.EqualFold(u.Gender, gender) {
+					return false
+				}
+				if location != "" && !strings.EqualFold(u.Location, location) {
+					return false
+				}
+				if search != "" {
+					haystack := strings.ToLower(strings.Join([]string{u.FirstName, u.LastName, u.PhoneNumber, u.Email}, " "))
+					if !strings.Contains(haystack, strings.ToLower(search)) {
+						return false
+					}
+				}
+				return true
+			}
+
+			if pageSize <= 0 {
+				pageSize = client.DefaultPageSize
+			}
+			var users []*pb.GetUserResponse
+			for page := int32(1); ; page++ {
+				resp, err := c.FilterUsers(ctx, serverFilter, page, pageSize)
+				if err != nil {
+					return err
+				}
+				for _, u := range resp.Users {
+					if matches(u) {
+						users = append(users, u)
+						if limit > 0 && int32(len(users)) >= limit {
+							return printUsers(cmd.OutOrStdout(), flags.output, users)
+						}
+					}
+				}
+				if int32(len(resp.Users)) < pageSize {
+					return printUsers(cmd.OutOrStdout(), flags.output, users)
+				}
+			}
+		},
+	}
+
+	f := cmd.Flags()
+	f.Int32Var(&page, "page", 1, "page to fetch")
+	f.Int32Var(&pageSize, "page-size", 50, "users per page")
+	f.BoolVar(&all, "all", false, "fetch all pages")
+	f.Int32Var(&limit, "limit", 0, "stop after this many matching users (0 for no limit)")
+	f.StringVar(&blocked, "blocked", "", "only blocked (true) or unblocked (false) users")
+	f.StringVar(&search, "search", "", "case-insensitive match on name, phone number or email")
+	f.StringVar(&phonePrefix, "phone-prefix", "", "only phone numbers starting with this prefix")
+	f.StringVar(&gender, "gender", "", "only users with this gender")
+	f.StringVar(&location, "location", "", "only users with this location")
+	f.StringSliceVar(&tags, "tag", nil, "only users with all of these tags (repeatable)")
+	f.StringToStringVar(&attributes, "attr", nil, "only users with this custom attribute value, NAME=VALUE (repeatable)")
+
+	cmd.RegisterFlagCompletionFunc("blocked", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"true", "false"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+func newGetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get ID...",
+		Short: "Show users by ID",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var users []*pb.GetUserResponse
+			for _, id := range ids {
+				user, err := c.GetUser(ctx, id)
+				if err != nil {
+					return fmt.Errorf("user %d: %w", id, err)
+				}
+				users = append(users, user)
+			}
+			return printUsers(cmd.OutOrStdout(), flags.output, users)
+		},
+	}
+}
+
+func newLookupCommand() *cobra.Command {
+	var phone, email, publicID string
+
+	cmd := &cobra.Command{
+		Use:   "lookup (--phone NUMBER | --email ADDRESS | --public-id ID)",
+		Short: "Show a user by phone number, email or public ID",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			set := 0
+			for _, key := range []string{phone, email, publicID} {
+				if key != "" {
+					set++
+				}
+			}
+			if set != 1 {
+				return fmt.Errorf("exactly one of --phone, --email or --public-id is required")
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			var user *pb.GetUserResponse
+			switch {
+			case phone != "":
+				user, err = c.GetUserByPhone(ctx, phone)
+			case email != "":
+				user, err = c.GetUserByEmail(ctx, email)
+			default:
+				user, err = c.GetUserByPublicID(ctx, publicID)
+			}
+			if err != nil {
+				return err
+			}
+			return printUsers(cmd.OutOrStdout(), flags.output, []*pb.GetUserResponse{user})
+		},
+	}
+	cmd.Flags().StringVar(&phone, "phone", "", "phone number, national or international")
+	cmd.Flags().StringVar(&email, "email", "", "email address, case is ignored")
+	cmd.Flags().StringVar(&publicID, "public-id", "", "public ID of the user")
+	return cmd
+}
+
+// userFields are the editable fields shared by create and update. The phone
+// number is only set on create, changes go through the phone commands.
+type userFields struct {
+	firstName, lastName, phone, gender, dateOfBirth, location, email, photoURL string
+
+	// attributes are the custom attributes given with --attr
+	attributes map[string]string
+}
+
+func (uf *userFields) register(cmd *cobra.Command) {
+	f := cmd.Flags()
+	f.StringVar(&uf.firstName, "first-name", "", "first name")
+	f.StringVar(&uf.lastName, "last-name", "", "last name")
+	f.StringVar(&uf.gender, "gender", "", "gender")
+	f.StringVar(&uf.dateOfBirth, "date-of-birth", "", "date of birth (YYYY-MM-DD)")
+	f.StringVar(&uf.location, "location", "", "location")
+	f.StringVar(&uf.email, "email", "", "email address")
+	f.StringVar(&uf.photoURL, "photo-url", "", "profile photo URL")
+	f.StringToStringVar(&uf.attributes, "attr", nil, "custom attribute, NAME=VALUE (repeatable)")
+}
+
+func newCreateCommand() *cobra.Command {
+	var uf userFields
+
+	cmd := &cobra.Command{
+		Use:   "create --phone NUMBER [flags]",
+		Short: "Create a user",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			dateOfBirth, err := parseDate(uf.dateOfBirth)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			created, err := c.CreateUser(ctx, &pb.CreateUserRequest{
+				FirstName:        uf.firstName,
+				LastName:         uf.lastName,
+				PhoneNumber:      uf.phone,
+				Gender:           uf.gender,
+				BirthDate:        dateOfBirth,
+				Location:         uf.location,
+				Email:            uf.email,
+				ProfilePhotoUrl:  uf.photoURL,
+				CustomAttributes: uf.attributes,
+			})
+			if err != nil {
+				return err
+			}
+			return printFreshUser(ctx, cmd, c, created.Id)
+		},
+	}
+
+	uf.register(cmd)
+	cmd.Flags().StringVar(&uf.phone, "phone", "", "phone number")
+	cmd.MarkFlagRequired("phone")
+	return cmd
+}
+
+func newUpdateCommand() *cobra.Command {
+	var uf userFields
+	var clear, removeAttributes []string
+
+	cmd := &cobra.Command{
+		Use:   "update ID [flags]",
+		Short: "Update a user",
+		Long:  "Update the fields given as flags. Use --clear to remove optional fields\nand --remove-attr to remove custom attributes.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+			dateOfBirth, err := parseDate(uf.dateOfBirth)
+			if err != nil {
+				return err
+			}
+
+			req := &pb.UpdateUserRequest{
+				Id:               ids[0],
+				FirstName:        uf.firstName,
+				LastName:         uf.lastName,
+				Gender:           uf.gender,
+				BirthDate:        dateOfBirth,
+				Location:         uf.location,
+				Email:            uf.email,
+				ProfilePhotoUrl:  uf.photoURL,
+				CustomAttributes: uf.attributes,
+			}
+
+			// UpdateUser clears a field when it is set to "null"
+			for _, field := range clear {
+				switch field {
+				case "first-name":
+					req.FirstName = "null"
+				case "last-name":
+					req.LastName = "null"
+				case "gender":
+					req.Gender = "null"
+				case "date-of-birth":
+					req.BirthDate = &date.Date{}
+				case "location":
+					req.Location = "null"
+				case "email":
+					req.Email = "null"
+				case "photo-url":
+					req.ProfilePhotoUrl = "null"
+				default:
+					return fmt.Errorf("field %q cannot be cleared", field)
+				}
+			}
+			for _, name := range removeAttributes {
+				if req.CustomAttributes == nil {
+					req.CustomAttributes = make(map[string]string)
+				}
+				req.CustomAttributes[name] = "null"
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			if _, err := c.UpdateUser(ctx, req); err != nil {
+				return err
+			}
+			return printFreshUser(ctx, cmd, c, req.Id)
+		},
+	}
+
+	uf.register(cmd)
+	cmd.Flags().StringSliceVar(&removeAttributes, "remove-attr", nil, "custom attributes to remove (repeatable)")
+	cmd.Flags().StringSliceVar(&clear, "clear", nil, "fields to clear: first-name, last-name, gender, date-of-birth, location, email, photo-url")
+	cmd.RegisterFlagCompletionFunc("clear", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"first-name", "last-name", "gender", "date-of-birth", "location", "email", "photo-url"}, cobra.ShellCompDirectiveNoFileComp
+	})
+	return cmd
+}
+
+func newDeleteCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "delete ID",
+		Short: "Delete a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDestructive(cmd, args, "Delete", func(ctx context.Context, c *client.Client, id int64) error {
+				return c.DeleteUser(ctx, id)
+			})
+		},
+	}
+}
+
+func newBlockCommand(block bool) *cobra.Command {
+	use, verb := "unblock ID", "Unblock"
+	if block {
+		use, verb = "block ID", "Block"
+	}
+
+	return &cobra.Command{
+		Use:   use,
+		Short: verb + " a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDestructive(cmd, args, verb, func(ctx context.Context, c *client.Client, id int64) error {
+				if block {
+					return c.BlockUser(ctx, id)
+				}
+				return c.UnblockUser(ctx, id)
+			})
+		},
+	}
+}
+
+func newVerifyEmailCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "verify-email ID",
+		Short: "Send an email verification link to a user",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ids, err := parseIDs(args)
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			c, err := connect(ctx)
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+
+			if err := c.SendEmailVerification(ctx, ids[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Verification email sent to user %d\n", ids[0])
+			return nil
+		},
+	}
+}
+
+// runDestructive shows the target user, asks for confirmation unless --yes is
+// given and then runs action.
+func runDestructive(cmd *cobra.Command, args []string, verb string, action func(context.Context, *client.Client, int64) error) error {
+	ids, err := parseIDs(args)
+	if err != nil {
+		return err
+	}
+	id := ids[0]
+
+	ctx := cmd.Context()
+	c, err := connect(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	user, err := c.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !flags.yes {
+		name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+		ok, err := confirm(cmd, fmt.Sprintf("%s user %d (%s %s)?", verb, id, name, user.PhoneNumber))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Aborted")
+			return nil
+		}
+	}
+
+	if err := action(ctx, c, id); err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s: user %d done\n", verb, id)
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal. Non-interactive input must use --yes.
+func confirm(cmd *cobra.Command, question string) (bool, error) {
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice == 0 {
+		return false, fmt.Errorf("refusing to continue without confirmation: stdin is not a terminal, pass --yes")
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s [y/N] ", question)
+	answer, err := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	if err != nil {
+		return false, nil
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes", nil
+}
+
+// printFreshUser fetches and prints the user so the output includes every field.
+func printFreshUser(ctx context.Context, cmd *cobra.Command, c *client.Client, id int64) error {
+	user, err := c.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	return printUsers(cmd.OutOrStdout(), flags.output, []*pb.GetUserResponse{user})
+}
+
+func parseIDs(args []string) ([]int64, error) {
+	ids := make([]int64, 0, len(args))
+	for _, arg := range args {
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid user ID %q", arg)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseDate(value string) (*date.Date, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return &date.Date{Year: int32(t.Year()), Month: int32(t.Month()), Day: int32(t.Day())}, nil
+}
@@ -0,0 +1,144 @@
+// Package apitime converts times and dates to their API messages and renders
+// the deprecated CustomTimestamp fields in the time zone requested by clients.
+package apitime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// TimeZoneHeader is the request header selecting the IANA time zone
+// CustomTimestamp fields are rendered in.
+const TimeZoneHeader = "x-time-zone"
+
+// Timestamp converts t to a Timestamp message.
+func Timestamp(t time.Time) *timestamppb.Timestamp {
+	return timestamppb.New(t)
+}
+
+// CustomTimestamp converts t to the deprecated CustomTimestamp message in UTC.
+func CustomTimestamp(t time.Time) *pb.CustomTimestamp {
+	return render(t.UTC())
+}
+
+func render(t time.Time) *pb.CustomTimestamp {
+	return &pb.CustomTimestamp{
+		Year:     int32(t.Year()),
+		Month:    int32(t.Month()),
+		Day:      int32(t.Day()),
+		Hour:     int32(t.Hour()),
+		Minute:   int32(t.Minute()),
+		Second:   int32(t.Second()),
+		Nanos:    int32(t.Nanosecond()),
+		TimeZone: t.Location().String(),
+	}
+}
+
+// Date converts the deprecated DateOfBirth message to a Date, nil for nil.
+func Date(d *pb.DateOfBirth) *date.Date {
+	if d == nil {
+		return nil
+	}
+	return &date.Date{Year: d.Year, Month: d.Month, Day: d.Day}
+}
+
+// DateOfBirth converts a Date to the deprecated DateOfBirth message, nil for nil.
+func DateOfBirth(d *date.Date) *pb.DateOfBirth {
+	if d == nil {
+		return nil
+	}
+	return &pb.DateOfBirth{Year: d.Year, Month: d.Month, Day: d.Day}
+}
+
+// Interceptor renders the CustomTimestamp fields of responses in the time
+// zone of the x-time-zone header. Unknown zones are rejected with
+// InvalidArgument before the handler runs.
+func Interceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		loc, err := requestedZone(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := handler(ctx, req)
+		if err == nil && loc != nil {
+			if msg, ok := resp.(proto.Message); ok {
+				Render(msg, loc)
+			}
+		}
+		return resp, err
+	}
+}
+
+func requestedZone(ctx context.Context) (*time.Location, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil, nil
+	}
+	values := md.Get(TimeZoneHeader)
+	if len(values) == 0 || values[0] == "" {
+		return nil, nil
+	}
+	loc, err := LoadZone(values[0])
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "Unknown time zone %q", values[0])
+	}
+	return loc, nil
+}
+
+// LoadZone returns the IANA time zone with the given name.
+func LoadZone(name string) (*time.Location, error) {
+	// time.LoadLocation also accepts "Local", which would leak the server's zone
+	if name == "" || name == "Local" {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return time.LoadLocation(name)
+}
+
+// Render converts every CustomTimestamp in msg, including nested and repeated
+// messages, from UTC to loc.
+func Render(msg proto.Message, loc *time.Location) {
+	renderMessage(msg.ProtoReflect(), loc)
+}
+
+var customTimestampName = (&pb.CustomTimestamp{}).ProtoReflect().Descriptor().FullName()
+
+func renderMessage(m protoreflect.Message, loc *time.Location) {
+	if m.Descriptor().FullName() == customTimestampName {
+		ts := m.Interface().(*pb.CustomTimestamp)
+		if ts.TimeZone != "" && ts.TimeZone != "UTC" {
+			return
+		}
+		t := time.Date(int(ts.Year), time.Month(ts.Month), int(ts.Day), int(ts.Hour), int(ts.Minute), int(ts.Second), int(ts.Nanos), time.UTC).In(loc)
+		ts.Year, ts.Month, ts.Day = int32(t.Year()), int32(t.Month()), int32(t.Day())
+		ts.Hour, ts.Minute, ts.Second, ts.Nanos = int32(t.Hour()), int32(t.Minute()), int32(t.Second()), int32(t.Nanosecond())
+		ts.TimeZone = loc.String()
+		return
+	}
+
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.Message() == nil || fd.IsMap() {
+			return true
+		}
+		if fd.IsList() {
+			list := v.List()
+			for i := 0; i < list.Len(); i++ {
+				renderMessage(list.Get(i).Message(), loc)
+			}
+			return true
+		}
+		renderMessage(v.Message(), loc)
+		return true
+	})
+}
@@ -0,0 +1,93 @@
+// Package audit records privileged actions together with who performed them.
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc/peer"
+)
+
+// Actions recorded in the audit log.
+const (
+	PhoneChanged    = "user.phone_changed"
+	PhoneOverridden = "user.phone_overridden"
+	UserReverted    = "user.reverted"
+	UserMerged      = "user.merged"
+	UserTagged      = "user.tagged"
+	UsersBulkTagged = "users.bulk_tagged"
+
+	AdminCreated          = "admin.created"
+	AdminDisabled         = "admin.disabled"
+	AdminEnabled          = "admin.enabled"
+	AdminRolesChanged     = "admin.roles_changed"
+	AdminCredentialsReset = "admin.credentials_reset"
+
+	APIKeyCreated = "apikey.created"
+	APIKeyRotated = "apikey.rotated"
+	APIKeyRevoked = "apikey.revoked"
+
+	AttributeDefined = "attribute.defined"
+	AttributeDeleted = "attribute.deleted"
+
+	TenantCreated  = "tenant.created"
+	TenantUpdated  = "tenant.updated"
+	TenantDisabled = "tenant.disabled"
+	TenantEnabled  = "tenant.enabled"
+)
+
+// Entry is a single audit log record.
+type Entry struct {
+	ID        int64
+	Actor     string
+	Action    string
+	UserID    int64
+	Reason    string
+	Details   interface{}
+	CreatedAt time.Time
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx so entries can be written
+// inside the same transaction as the action they describe.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Write stores an entry in the audit log. Details are encoded as JSON. A zero
+// UserID is stored as NULL, e.g. for actions on admins.
+func Write(ctx context.Context, db Execer, entry Entry) error {
+	details, err := json.Marshal(entry.Details)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s audit details: %v", entry.Action, err)
+	}
+
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO audit_log (actor, action, user_id, reason, details) VALUES ($1, $2, $3, $4, $5)",
+		entry.Actor, entry.Action, sql.NullInt64{Int64: entry.UserID, Valid: entry.UserID != 0}, entry.Reason, details)
+	if err != nil {
+		return fmt.Errorf("failed to write %s audit entry: %v", entry.Action, err)
+	}
+	return nil
+}
+
+type actorKey struct{}
+
+// WithActor returns a context carrying the authenticated caller.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// Actor returns the caller stored with WithActor. Unauthenticated calls are
+// attributed to the peer address, or "unknown" if that is not available either.
+func Actor(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		return "peer:" + p.Addr.String()
+	}
+	return "unknown"
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/jwt"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// adminSubjectPrefix marks the subject of admin access tokens, so they are
+// never mistaken for end-user tokens.
+const adminSubjectPrefix = "admin:"
+
+// AdminAudience is the audience of admin access tokens.
+const AdminAudience = "admin"
+
+type adminKey struct{}
+
+// Admin is an admin authenticated with an access token.
+type Admin struct {
+	ID       int64
+	Username string
+	Roles    []string
+}
+
+// AdminFromContext returns the admin authenticated by AdminInterceptor.
+func AdminFromContext(ctx context.Context) (Admin, bool) {
+	admin, ok := ctx.Value(adminKey{}).(Admin)
+	return admin, ok
+}
+
+// AdminSubject returns the token subject of the admin.
+func AdminSubject(id int64) string {
+	return adminSubjectPrefix + strconv.FormatInt(id, 10)
+}
+
+// AdminStore loads the admin an access token was issued to, or the API key
+// a caller presents.
+type AdminStore interface {
+	GetAdmin(ctx context.Context, id int64) (*store.Admin, error)
+	GetAPIKeyByHash(ctx context.Context, hash []byte) (*store.APIKey, error)
+	TouchAPIKey(ctx context.Context, id int64, now time.Time) error
+}
+
+// AdminInterceptor checks the admin access token in the "authorization"
+// header, or else the API key in the "x-api-key" header, of methods that
+// require a permission (see MethodPermission) and stores the admin or key in
+// the context. Calls without credentials are passed through unless required
+// is set, except for managing admins, API keys and tenants, which always
+// requires credentials. Authenticated calls are scoped to the tenant of the
+// admin or key.
+func AdminInterceptor(signer *jwt.Signer, admins AdminStore, required bool) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := authorizeAdmin(ctx, signer, admins, required, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// AdminStreamInterceptor is AdminInterceptor for streaming calls.
+func AdminStreamInterceptor(signer *jwt.Signer, admins AdminStore, required bool) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authorizeAdmin(ss.Context(), signer, admins, required, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func authorizeAdmin(ctx context.Context, signer *jwt.Signer, admins AdminStore, required bool, method string) (context.Context, error) {
+	permission, ok := MethodPermission(method)
+	if !ok {
+		return ctx, nil
+	}
+
+	token := bearerToken(ctx)
+	if token == "" {
+		if key := apiKeyHeader(ctx); key != "" {
+			return authorizeAPIKey(ctx, admins, key, permission)
+		}
+		if !required && permission != PermAdminsManage && permission != PermTenantsManage {
+			return ctx, nil
+		}
+		return nil, status.Errorf(codes.Unauthenticated, "Admin credentials are required")
+	}
+
+	now := time.Now()
+	claims, err := signer.Verify(token, AdminAudience, now)
+	if err == jwt.ErrExpired {
+		return nil, status.Errorf(codes.Unauthenticated, "Access token has expired")
+	}
+	if err != nil || !strings.HasPrefix(claims.Subject, adminSubjectPrefix) {
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid access token")
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(claims.Subject, adminSubjectPrefix), 10, 64)
+	if err != nil {
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid access token")
+	}
+
+	ctx = tenant.WithID(ctx, claimsTenant(claims))
+	// The admin is loaded on every call so disabling an admin or resetting
+	// the credentials takes effect before the token expires
+	admin, err := admins.GetAdmin(ctx, id)
+	if err == store.ErrNotFound {
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid access token")
+	}
+	if err != nil {
+		log.Printf("Error fetching admin: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	if admin.Disabled {
+		return nil, status.Errorf(codes.Unauthenticated, "Admin account is disabled")
+	}
+	// Tokens only carry whole seconds, so a token issued in the second of a
+	// reset is revoked as well
+	if admin.CredentialsChangedAt.After(admin.CreatedAt) && claims.IssuedAt <= admin.CredentialsChangedAt.Unix() {
+		return nil, status.Errorf(codes.Unauthenticated, "Access token has been revoked")
+	}
+	if !HasPermission(admin.Roles, permission) {
+		return nil, status.Errorf(codes.PermissionDenied, "Permission %s is required", permission)
+	}
+
+	ctx = context.WithValue(ctx, adminKey{}, Admin{ID: admin.ID, Username: admin.Username, Roles: admin.Roles})
+	ctx = audit.WithActor(ctx, "admin:"+admin.Username)
+	return ctx, nil
+}
+
+// contextStream replaces the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
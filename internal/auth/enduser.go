@@ -0,0 +1,110 @@
+// Package auth authenticates callers of the gRPC API.
+package auth
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/jwt"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// EndUserAudience is the audience of end-user access tokens.
+const EndUserAudience = "user"
+
+type endUserKey struct{}
+
+// EndUser is an end user authenticated with an access token.
+type EndUser struct {
+	UserID    int64
+	SessionID string
+}
+
+// EndUserFromContext returns the end user authenticated by EndUserInterceptor.
+func EndUserFromContext(ctx context.Context) (EndUser, bool) {
+	user, ok := ctx.Value(endUserKey{}).(EndUser)
+	return user, ok
+}
+
+// EndUserStore resolves the public ID in the subject of access tokens.
+type EndUserStore interface {
+	ResolvePublicIDs(ctx context.Context, publicIDs []string) (map[string]int64, error)
+}
+
+// EndUserInterceptor requires a valid access token in the "authorization"
+// header for methods of the given services, e.g. "user.ProfileService", and
+// stores the caller and its tenant in the context. Other methods are passed
+// through. The subject of the token is the public ID of the user, so the
+// internal ID is never handed to end users.
+func EndUserInterceptor(signer *jwt.Signer, users EndUserStore, services ...string) grpc.UnaryServerInterceptor {
+	prefixes := make([]string, len(services))
+	for i, service := range services {
+		prefixes[i] = "/" + service + "/"
+	}
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !hasPrefix(info.FullMethod, prefixes) {
+			return handler(ctx, req)
+		}
+
+		token := bearerToken(ctx)
+		if token == "" {
+			return nil, status.Errorf(codes.Unauthenticated, "Access token is required")
+		}
+		claims, err := signer.Verify(token, EndUserAudience, time.Now())
+		if err == jwt.ErrExpired {
+			return nil, status.Errorf(codes.Unauthenticated, "Access token has expired")
+		}
+		if err != nil {
+			return nil, status.Errorf(codes.Unauthenticated, "Invalid access token")
+		}
+		if claims.Subject == "" || strings.HasPrefix(claims.Subject, adminSubjectPrefix) {
+			return nil, status.Errorf(codes.Unauthenticated, "Invalid access token")
+		}
+
+		ctx = tenant.WithID(ctx, claimsTenant(claims))
+		ids, err := users.ResolvePublicIDs(ctx, []string{claims.Subject})
+		if err != nil {
+			log.Printf("Error resolving user of access token: %v", err)
+			return nil, status.Errorf(codes.Internal, "Internal server error")
+		}
+		userID, ok := ids[claims.Subject]
+		if !ok {
+			return nil, status.Errorf(codes.Unauthenticated, "Account no longer exists")
+		}
+
+		ctx = context.WithValue(ctx, endUserKey{}, EndUser{UserID: userID, SessionID: claims.SessionID})
+		ctx = audit.WithActor(ctx, "user:"+claims.Subject)
+		return handler(ctx, req)
+	}
+}
+
+// bearerToken extracts the token from an "authorization: Bearer <token>" header.
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, value := range md.Get("authorization") {
+		if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+func hasPrefix(method string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(method, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+)
+
+// Permissions granted by admin roles.
+const (
+	PermUsersRead      = "users:read"
+	PermUsersWrite     = "users:write"
+	PermUsersDelete    = "users:delete"
+	PermWebhooksManage = "webhooks:manage"
+	PermAdminsManage   = "admins:manage"
+	// PermTenantsManage only takes effect for admins of the default tenant.
+	PermTenantsManage = "tenants:manage"
+)
+
+// Admin roles.
+const (
+	RoleSuperadmin = "superadmin"
+	RoleAdmin      = "admin"
+	RoleSupport    = "support"
+	RoleViewer     = "viewer"
+)
+
+// rolePermissions lists the permissions of every role.
+var rolePermissions = map[string][]string{
+	RoleSuperadmin: {PermUsersRead, PermUsersWrite, PermUsersDelete, PermWebhooksManage, PermAdminsManage, PermTenantsManage},
+	RoleAdmin:      {PermUsersRead, PermUsersWrite, PermUsersDelete, PermWebhooksManage},
+	RoleSupport:    {PermUsersRead, PermUsersWrite},
+	RoleViewer:     {PermUsersRead},
+}
+
+// apiKeyScopes lists the permissions API keys can be granted. Managing admins,
+// API keys and tenants is left to admins.
+var apiKeyScopes = []string{PermUsersRead, PermUsersWrite, PermUsersDelete, PermWebhooksManage}
+
+// methodPermissions is the permission each admin method requires. Methods
+// not listed, such as logins and ProfileService, do not take admin
+// credentials.
+var methodPermissions = map[string]string{
+	"/user.UserService/GetAllUsers":            PermUsersRead,
+	"/user.UserService/GetUserById":            PermUsersRead,
+	"/user.UserService/GetUserByPhone":         PermUsersRead,
+	"/user.UserService/GetUserByEmail":         PermUsersRead,
+	"/user.UserService/LookupUser":             PermUsersRead,
+	"/user.UserService/CheckUsersStatus":       PermUsersRead,
+	"/user.UserService/ListUserRevisions":      PermUsersRead,
+	"/user.UserService/GetUserAsOf":            PermUsersRead,
+	"/user.UserService/GetUserTags":            PermUsersRead,
+	"/user.UserService/ListTags":               PermUsersRead,
+	"/user.UserService/ListUserNotes":          PermUsersRead,
+	"/user.UserService/GetUserStats":           PermUsersRead,
+	"/user.UserService/FindDuplicateUsers":     PermUsersRead,
+	"/user.UserService/CreateUser":             PermUsersWrite,
+	"/user.UserService/UpdateUser":             PermUsersWrite,
+	"/user.UserService/BlockUser":              PermUsersWrite,
+	"/user.UserService/UnblockUser":            PermUsersWrite,
+	"/user.UserService/UploadProfilePhoto":     PermUsersWrite,
+	"/user.UserService/SendEmailVerification":  PermUsersWrite,
+	"/user.UserService/StartPhoneChange":       PermUsersWrite,
+	"/user.UserService/ConfirmPhoneChange":     PermUsersWrite,
+	"/user.UserService/OverridePhoneNumber":    PermUsersWrite,
+	"/user.UserService/RevertUser":             PermUsersWrite,
+	"/user.UserService/AddUserTags":            PermUsersWrite,
+	"/user.UserService/RemoveUserTags":         PermUsersWrite,
+	"/user.UserService/BulkTagUsers":           PermUsersWrite,
+	"/user.UserService/AddUserNote":            PermUsersWrite,
+	"/user.UserService/DeleteUser":             PermUsersDelete,
+	"/user.UserService/MergeUsers":             PermUsersDelete,
+	"/user.SessionService/ListSessions":        PermUsersRead,
+	"/user.SessionService/RevokeSession":       PermUsersWrite,
+	"/user.WebhookService/":                    PermWebhooksManage,
+	"/user.AdminService/CreateAdmin":           PermAdminsManage,
+	"/user.AdminService/ListAdmins":            PermAdminsManage,
+	"/user.AdminService/DisableAdmin":          PermAdminsManage,
+	"/user.AdminService/EnableAdmin":           PermAdminsManage,
+	"/user.AdminService/SetAdminRoles":         PermAdminsManage,
+	"/user.AdminService/ResetAdminCredentials": PermAdminsManage,
+	"/user.ApiKeyService/":                     PermAdminsManage,
+	"/user.AttributeService/ListAttributes":    PermUsersRead,
+	"/user.AttributeService/DefineAttribute":   PermAdminsManage,
+	"/user.AttributeService/DeleteAttribute":   PermAdminsManage,
+	"/user.TenantService/":                     PermTenantsManage,
+}
+
+// MethodPermission returns the permission the method requires, or false if
+// the method does not take admin credentials. Entries ending in "/" cover
+// all methods of a service.
+func MethodPermission(method string) (string, bool) {
+	if permission, ok := methodPermissions[method]; ok {
+		return permission, true
+	}
+	for prefix, permission := range methodPermissions {
+		if strings.HasSuffix(prefix, "/") && strings.HasPrefix(method, prefix) {
+			return permission, true
+		}
+	}
+	return "", false
+}
+
+// ValidRole reports whether role is a known admin role.
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// Roles returns the known admin roles in sorted order.
+func Roles() []string {
+	roles := make([]string, 0, len(rolePermissions))
+	for role := range rolePermissions {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// HasPermission reports whether any of the roles grants the permission.
+func HasPermission(roles []string, permission string) bool {
+	for _, role := range roles {
+		for _, granted := range rolePermissions[role] {
+			if granted == permission {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidScope reports whether scope can be granted to an API key.
+func ValidScope(scope string) bool {
+	for _, s := range apiKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Scopes returns the permissions API keys can be granted.
+func Scopes() []string {
+	return append([]string(nil), apiKeyScopes...)
+}
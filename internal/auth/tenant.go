@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/jwt"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantStore loads the tenant of a call.
+type TenantStore interface {
+	GetTenant(ctx context.Context, id int64) (*store.Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (*store.Tenant, error)
+}
+
+// TenantInterceptor scopes every call to a tenant. It runs after the other
+// auth interceptors: calls they authenticated belong to the tenant of the
+// credentials, other calls to the tenant whose slug is sent in the
+// "x-tenant" header, or else to the default tenant. A header naming another
+// tenant than the credentials is rejected, as are calls of disabled tenants.
+func TenantInterceptor(tenants TenantStore) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := resolveTenant(ctx, tenants)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// TenantStreamInterceptor is TenantInterceptor for streaming calls.
+func TenantStreamInterceptor(tenants TenantStore) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := resolveTenant(ss.Context(), tenants)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+func resolveTenant(ctx context.Context, tenants TenantStore) (context.Context, error) {
+	var t *store.Tenant
+	var err error
+	slug := tenantHeader(ctx)
+	id, authenticated := tenant.FromContext(ctx)
+	switch {
+	case slug != "":
+		t, err = tenants.GetTenantBySlug(ctx, slug)
+	case authenticated:
+		t, err = tenants.GetTenant(ctx, id)
+	default:
+		t, err = tenants.GetTenant(ctx, tenant.Default)
+	}
+	if err == store.ErrNotFound {
+		if slug != "" {
+			return nil, status.Errorf(codes.InvalidArgument, "Unknown tenant %q", slug)
+		}
+		return nil, status.Errorf(codes.Unauthenticated, "Tenant of the credentials no longer exists")
+	}
+	if err != nil {
+		log.Printf("Error fetching tenant: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	if authenticated && t.ID != id {
+		return nil, status.Errorf(codes.PermissionDenied, "Credentials belong to another tenant than %q", slug)
+	}
+	if t.Disabled {
+		return nil, status.Errorf(codes.PermissionDenied, "Tenant %s is disabled", t.Slug)
+	}
+	return tenant.WithID(ctx, t.ID), nil
+}
+
+// claimsTenant returns the tenant an access token was issued for.
+func claimsTenant(claims *jwt.Claims) int64 {
+	if claims.Tenant == 0 {
+		return tenant.Default
+	}
+	return claims.Tenant
+}
+
+// tenantHeader returns the value of the "x-tenant" header.
+func tenantHeader(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if slugs := md.Get(tenant.Header); len(slugs) > 0 {
+		return strings.ToLower(strings.TrimSpace(slugs[0]))
+	}
+	return ""
+}
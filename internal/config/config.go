@@ -3,6 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/ratelimit"
 )
 
 // Config contains configuration settings for the application.
@@ -13,6 +18,70 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	GRPCPort   string
+
+	WebhookPollInterval time.Duration
+	WebhookMaxAttempts  int
+	WebhookBaseBackoff  time.Duration
+	WebhookMaxBackoff   time.Duration
+	WebhookTimeout      time.Duration
+	WebhookAllowPrivate bool
+
+	PhotoStorage  string // "filesystem" (default) or "s3"
+	PhotoMaxBytes int
+	PhotoDir      string
+	PhotoBaseURL  string
+	S3Endpoint    string
+	S3Region      string
+	S3Bucket      string
+	S3AccessKey   string
+	S3SecretKey   string
+	S3PublicURL   string
+
+	PhoneCountries      []string
+	PhoneDefaultCountry string
+	PhonePrefixes       map[string][]string
+
+	Mailer               string // "" (disabled), "smtp" or "file"
+	MailFrom             string
+	MailDir              string
+	SMTPHost             string
+	SMTPPort             string
+	SMTPUsername         string
+	SMTPPassword         string
+	EmailVerificationTTL time.Duration
+	EmailVerificationURL string
+
+	SMSSender           string // "" (disabled), "http" or "log"
+	SMSGatewayURL       string
+	SMSGatewayToken     string
+	PhoneOTPTTL         time.Duration
+	PhoneOTPMaxAttempts int
+	PhoneReuseCoolDown  time.Duration
+
+	HTTPPort          string
+	MetricsPort       string // serves /debug/vars, without authentication
+	SessionSigningKey string
+	SessionVerifyKeys []string
+	SessionIssuer     string
+	SessionAccessTTL  time.Duration
+	SessionRefreshTTL time.Duration
+
+	RateLimiter string // "" (disabled), "memory" or "postgres"
+	RateLimits  ratelimit.Rules
+
+	UserCacheTTL  time.Duration // 0 disables the cache
+	UserCacheSize int
+
+	UserStatsCacheTTL time.Duration // 0 disables the cache
+
+	AdminAuth            string // "optional" (default) or "required"
+	AdminTokenTTL        time.Duration
+	AdminMaxFailedLogins int
+	AdminLockoutDuration time.Duration
+	AdminTOTPIssuer      string
+
+	AdminUI              bool // serves the admin dashboard at /admin/ on HTTP_PORT
+	AdminUISecureCookies bool
 }
 
 // LoadConfig loads the configuration from environment variables and returns a Config instance.
@@ -26,6 +95,118 @@ func LoadConfig() (*Config, error) {
 		GRPCPort:   os.Getenv("GRPC_PORT"),
 	}
 
+	var err error
+	if cfg.WebhookPollInterval, err = getDuration("WEBHOOK_POLL_INTERVAL", 5*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookMaxAttempts, err = getInt("WEBHOOK_MAX_ATTEMPTS", 10); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookBaseBackoff, err = getDuration("WEBHOOK_BASE_BACKOFF", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookMaxBackoff, err = getDuration("WEBHOOK_MAX_BACKOFF", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookTimeout, err = getDuration("WEBHOOK_TIMEOUT", 10*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.WebhookAllowPrivate, err = getBool("WEBHOOK_ALLOW_PRIVATE", false); err != nil {
+		return nil, err
+	}
+
+	cfg.PhotoStorage = getString("PHOTO_STORAGE", "filesystem")
+	if cfg.PhotoMaxBytes, err = getInt("PHOTO_MAX_BYTES", 5<<20); err != nil {
+		return nil, err
+	}
+	cfg.PhotoDir = getString("PHOTO_DIR", "uploads")
+	cfg.PhotoBaseURL = getString("PHOTO_BASE_URL", "/uploads")
+	cfg.S3Endpoint = os.Getenv("S3_ENDPOINT")
+	cfg.S3Region = os.Getenv("S3_REGION")
+	cfg.S3Bucket = os.Getenv("S3_BUCKET")
+	cfg.S3AccessKey = os.Getenv("S3_ACCESS_KEY")
+	cfg.S3SecretKey = os.Getenv("S3_SECRET_KEY")
+	cfg.S3PublicURL = os.Getenv("S3_PUBLIC_URL")
+
+	cfg.PhoneCountries = strings.Split(getString("PHONE_COUNTRIES", "TM"), ",")
+	cfg.PhoneDefaultCountry = getString("PHONE_DEFAULT_COUNTRY", strings.TrimSpace(cfg.PhoneCountries[0]))
+	if cfg.PhonePrefixes, err = getPrefixes("PHONE_PREFIXES"); err != nil {
+		return nil, err
+	}
+
+	cfg.Mailer = os.Getenv("MAILER")
+	cfg.MailFrom = getString("MAIL_FROM", "no-reply@localhost")
+	cfg.MailDir = getString("MAIL_DIR", "mail")
+	cfg.SMTPHost = os.Getenv("SMTP_HOST")
+	cfg.SMTPPort = getString("SMTP_PORT", "587")
+	cfg.SMTPUsername = os.Getenv("SMTP_USERNAME")
+	cfg.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+	if cfg.EmailVerificationTTL, err = getDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour); err != nil {
+		return nil, err
+	}
+	cfg.EmailVerificationURL = os.Getenv("EMAIL_VERIFICATION_URL")
+
+	cfg.SMSSender = os.Getenv("SMS_SENDER")
+	cfg.SMSGatewayURL = os.Getenv("SMS_GATEWAY_URL")
+	cfg.SMSGatewayToken = os.Getenv("SMS_GATEWAY_TOKEN")
+	if cfg.PhoneOTPTTL, err = getDuration("PHONE_OTP_TTL", 10*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.PhoneOTPMaxAttempts, err = getInt("PHONE_OTP_MAX_ATTEMPTS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.PhoneReuseCoolDown, err = getDuration("PHONE_REUSE_COOLDOWN", 90*24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	cfg.HTTPPort = os.Getenv("HTTP_PORT")
+	cfg.MetricsPort = os.Getenv("METRICS_PORT")
+	cfg.SessionSigningKey = os.Getenv("SESSION_SIGNING_KEY")
+	if keys := os.Getenv("SESSION_VERIFY_KEYS"); keys != "" {
+		cfg.SessionVerifyKeys = strings.Split(keys, ",")
+	}
+	cfg.SessionIssuer = getString("SESSION_ISSUER", "user-admin-grpc-go")
+	if cfg.SessionAccessTTL, err = getDuration("SESSION_ACCESS_TTL", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	if cfg.SessionRefreshTTL, err = getDuration("SESSION_REFRESH_TTL", 30*24*time.Hour); err != nil {
+		return nil, err
+	}
+
+	cfg.RateLimiter = os.Getenv("RATE_LIMITER")
+	if cfg.RateLimits, err = ratelimit.ParseRules(getString("RATE_LIMITS", "default=10/s:20")); err != nil {
+		return nil, fmt.Errorf("invalid RATE_LIMITS: %v", err)
+	}
+
+	if cfg.UserCacheTTL, err = getDuration("USER_CACHE_TTL", 30*time.Second); err != nil {
+		return nil, err
+	}
+	if cfg.UserCacheSize, err = getInt("USER_CACHE_SIZE", 10000); err != nil {
+		return nil, err
+	}
+	if cfg.UserStatsCacheTTL, err = getDuration("USER_STATS_CACHE_TTL", time.Minute); err != nil {
+		return nil, err
+	}
+
+	cfg.AdminAuth = getString("ADMIN_AUTH", "optional")
+	if cfg.AdminTokenTTL, err = getDuration("ADMIN_TOKEN_TTL", time.Hour); err != nil {
+		return nil, err
+	}
+	if cfg.AdminMaxFailedLogins, err = getInt("ADMIN_MAX_FAILED_LOGINS", 5); err != nil {
+		return nil, err
+	}
+	if cfg.AdminLockoutDuration, err = getDuration("ADMIN_LOCKOUT_DURATION", 15*time.Minute); err != nil {
+		return nil, err
+	}
+	cfg.AdminTOTPIssuer = getString("ADMIN_TOTP_ISSUER", "User Admin")
+
+	if cfg.AdminUI, err = getBool("ADMIN_UI", false); err != nil {
+		return nil, err
+	}
+	if cfg.AdminUISecureCookies, err = getBool("ADMIN_UI_SECURE_COOKIES", true); err != nil {
+		return nil, err
+	}
+
 	missingFields := []string{}
 	if cfg.DBHost == "" {
 		missingFields = append(missingFields, "DB_HOST")
@@ -49,3 +230,73 @@ func LoadConfig() (*Config, error) {
 
 	return cfg, nil
 }
+
+// getString reads an optional string from the environment, falling back to def when unset.
+func getString(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getDuration reads an optional duration (e.g. "30s") from the environment, falling back to def when unset.
+func getDuration(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration for %s: %v", key, err)
+	}
+	return d, nil
+}
+
+// getBool reads an optional boolean (e.g. "true") from the environment, falling back to def when unset.
+func getBool(key string, def bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid boolean for %s: %v", key, err)
+	}
+	return b, nil
+}
+
+// getInt reads an optional integer from the environment, falling back to def when unset.
+func getInt(key string, def int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid integer for %s: %v", key, err)
+	}
+	return n, nil
+}
+
+// getPrefixes parses per-country phone prefixes in the form "TM=61,62,63;RU=9".
+func getPrefixes(key string) (map[string][]string, error) {
+	prefixes := map[string][]string{}
+	value := os.Getenv(key)
+	if value == "" {
+		return prefixes, nil
+	}
+	for _, entry := range strings.Split(value, ";") {
+		country, list, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(country) == "" {
+			return nil, fmt.Errorf("invalid entry %q in %s, expected COUNTRY=PREFIX,PREFIX", entry, key)
+		}
+		var values []string
+		for _, prefix := range strings.Split(list, ",") {
+			if prefix = strings.TrimSpace(prefix); prefix != "" {
+				values = append(values, prefix)
+			}
+		}
+		prefixes[strings.ToUpper(strings.TrimSpace(country))] = values
+	}
+	return prefixes, nil
+}
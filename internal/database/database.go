@@ -12,12 +12,15 @@ import (
 
 var db *sql.DB
 
-func InitDB(cfg *config.Config) (*sql.DB, error) {
-	connectionString := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+// ConnectionString returns the lib/pq connection string for the configured database.
+func ConnectionString(cfg *config.Config) string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 	cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+}
 
+func InitDB(cfg *config.Config) (*sql.DB, error) {
 	var err error
-	db, err = sql.Open("postgres", connectionString)
+	db, err = sql.Open("postgres", ConnectionString(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize database: %v", err)
 	}
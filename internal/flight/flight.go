@@ -0,0 +1,60 @@
+// Package flight collapses concurrent loads of the same key into one call
+// whose result every waiting caller shares.
+package flight
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultTimeout bounds a load when the Group has no Timeout.
+const DefaultTimeout = 10 * time.Second
+
+// Group runs one load per key at a time.
+type Group struct {
+	// Timeout bounds every load. Defaults to DefaultTimeout.
+	Timeout time.Duration
+
+	group singleflight.Group
+}
+
+// Do calls load once for concurrent calls with the same key and returns its
+// result. The load keeps the values of the context of the first caller, but
+// is not canceled with it, since other callers wait for the same result.
+// A caller whose context ends stops waiting and gets the context error.
+func (g *Group) Do(ctx context.Context, key string, load func(ctx context.Context) (interface{}, error)) (interface{}, error) {
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ch := g.group.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(detached{ctx}, timeout)
+		defer cancel()
+		return load(ctx)
+	})
+
+	select {
+	case res := <-ch:
+		return res.Val, res.Err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Forget makes the next call for key start a new load instead of waiting for
+// the one in flight.
+func (g *Group) Forget(key string) {
+	g.group.Forget(key)
+}
+
+// detached keeps the values of a context without its deadline and
+// cancellation.
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
@@ -0,0 +1,21 @@
+package jwt
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler serves the JWKS document, e.g. at /.well-known/jwks.json.
+func (s *Signer) Handler() http.Handler {
+	body, _ := json.Marshal(s.jwks)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(body)
+	})
+}
@@ -0,0 +1,248 @@
+// Package jwt issues and verifies ES256-signed access tokens and publishes the
+// verification keys as a JSON Web Key Set.
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalid is returned for malformed tokens and tokens with a bad signature.
+	ErrInvalid = errors.New("invalid token")
+	// ErrExpired is returned for tokens that are past their expiry time.
+	ErrExpired = errors.New("token expired")
+)
+
+// Claims are the claims of an access token.
+type Claims struct {
+	Issuer string `json:"iss"`
+	// Audience names the kind of caller the token was issued to, so a token
+	// of one kind is never accepted where another is expected.
+	Audience  string `json:"aud"`
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	// Tenant is the tenant the subject belongs to. Tokens issued before
+	// tenants existed have none and belong to the default tenant.
+	Tenant    int64 `json:"tid,omitempty"`
+	IssuedAt  int64 `json:"iat"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// JWK is a public P-256 key in JSON Web Key format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+// Signer signs tokens with the current key and verifies tokens signed with
+// the current or any previous key, so keys can be rotated without logging
+// users out.
+type Signer struct {
+	issuer string
+	key    *ecdsa.PrivateKey
+	kid    string
+	keys   map[string]*ecdsa.PublicKey
+	jwks   JWKS
+}
+
+// NewSigner creates a Signer for the given issuer. previous lists public keys
+// of earlier signing keys that are still accepted and published.
+func NewSigner(issuer string, key *ecdsa.PrivateKey, previous ...*ecdsa.PublicKey) (*Signer, error) {
+	if key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("signing key must use the P-256 curve")
+	}
+
+	s := &Signer{issuer: issuer, key: key, keys: map[string]*ecdsa.PublicKey{}}
+	for _, pub := range append([]*ecdsa.PublicKey{&key.PublicKey}, previous...) {
+		if pub.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("verification keys must use the P-256 curve")
+		}
+		jwk := toJWK(pub)
+		s.keys[jwk.Kid] = pub
+		s.jwks.Keys = append(s.jwks.Keys, jwk)
+	}
+	s.kid = s.jwks.Keys[0].Kid
+	return s, nil
+}
+
+// GenerateKey creates a new P-256 signing key.
+func GenerateKey() (*ecdsa.PrivateKey, error) {
+	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+}
+
+// Sign issues a token for the audience, subject and session of the tenant,
+// valid for ttl.
+func (s *Signer) Sign(audience, subject, sessionID string, tenantID int64, now time.Time, ttl time.Duration) (string, error) {
+	h, err := json.Marshal(header{Alg: "ES256", Typ: "JWT", Kid: s.kid})
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(Claims{
+		Issuer:    s.issuer,
+		Audience:  audience,
+		Subject:   subject,
+		SessionID: sessionID,
+		Tenant:    tenantID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := encode(h) + "." + encode(c)
+	digest := sha256.Sum256([]byte(signingInput))
+	r, sig, err := ecdsa.Sign(rand.Reader, s.key, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	// JWS uses the fixed-size concatenation of r and s instead of ASN.1
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	sig.FillBytes(signature[32:])
+	return signingInput + "." + encode(signature), nil
+}
+
+// Verify checks the signature, issuer, audience and expiry of a token and
+// returns its claims.
+func (s *Signer) Verify(token, audience string, now time.Time) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalid
+	}
+
+	var h header
+	if err := decodeJSON(parts[0], &h); err != nil || h.Alg != "ES256" {
+		return nil, ErrInvalid
+	}
+	pub, ok := s.keys[h.Kid]
+	if !ok {
+		return nil, ErrInvalid
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || len(signature) != 64 {
+		return nil, ErrInvalid
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r := new(big.Int).SetBytes(signature[:32])
+	sig := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(pub, digest[:], r, sig) {
+		return nil, ErrInvalid
+	}
+
+	var claims Claims
+	if err := decodeJSON(parts[1], &claims); err != nil || claims.Issuer != s.issuer || claims.Audience != audience {
+		return nil, ErrInvalid
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, ErrExpired
+	}
+	return &claims, nil
+}
+
+// JWKS returns the public keys tokens may be signed with.
+func (s *Signer) JWKS() JWKS {
+	return s.jwks
+}
+
+// LoadPrivateKey reads a PEM-encoded EC private key in SEC 1 or PKCS #8 form.
+func LoadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %v", path, err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an EC key", path)
+	}
+	return ecKey, nil
+}
+
+// LoadPublicKey reads a PEM-encoded EC public key in PKIX form.
+func LoadPublicKey(path string) (*ecdsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %v", path, err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an EC key", path)
+	}
+	return ecKey, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", path)
+	}
+	return block, nil
+}
+
+// toJWK converts a public key to a JWK whose key ID is its RFC 7638 thumbprint.
+func toJWK(pub *ecdsa.PublicKey) JWK {
+	x := encode(pub.X.FillBytes(make([]byte, 32)))
+	y := encode(pub.Y.FillBytes(make([]byte, 32)))
+
+	// The thumbprint input has the required members in lexicographic order
+	thumbprint := sha256.Sum256([]byte(`{"crv":"P-256","kty":"EC","x":"` + x + `","y":"` + y + `"}`))
+	return JWK{Kty: "EC", Crv: "P-256", X: x, Y: y, Kid: encode(thumbprint[:]), Use: "sig", Alg: "ES256"}
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeJSON(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
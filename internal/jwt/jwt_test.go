@@ -0,0 +1,216 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(t *testing.T, previous ...*ecdsa.PublicKey) *Signer {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSigner("user-admin", key, previous...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSignAndVerify(t *testing.T) {
+	s := newTestSigner(t)
+	now := time.Unix(1700000000, 0)
+	token, err := s.Sign("user", "01HF3Z8Q4V9KX2M7T5R6N0BWJD", "session-1", 2, now, 15*time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := s.Verify(token, "user", now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Claims{
+		Issuer:    "user-admin",
+		Audience:  "user",
+		Subject:   "01HF3Z8Q4V9KX2M7T5R6N0BWJD",
+		SessionID: "session-1",
+		Tenant:    2,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(15 * time.Minute).Unix(),
+	}
+	if *claims != want {
+		t.Errorf("got claims %+v, want %+v", *claims, want)
+	}
+
+	var h header
+	if err := decodeJSON(strings.Split(token, ".")[0], &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Alg != "ES256" || h.Typ != "JWT" || h.Kid != s.JWKS().Keys[0].Kid {
+		t.Errorf("got header %+v, want ES256 with the key ID of the signing key", h)
+	}
+}
+
+func TestVerifyRejectsExpiredTokens(t *testing.T) {
+	s := newTestSigner(t)
+	now := time.Unix(1700000000, 0)
+	token, err := s.Sign("user", "subject", "", 0, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Verify(token, "user", now.Add(time.Minute-time.Second)); err != nil {
+		t.Errorf("got %v a second before the expiry, want nil", err)
+	}
+	if _, err := s.Verify(token, "user", now.Add(time.Minute)); err != ErrExpired {
+		t.Errorf("got %v at the expiry, want %v", err, ErrExpired)
+	}
+	if _, err := s.Verify(token, "user", now.Add(time.Hour)); err != ErrExpired {
+		t.Errorf("got %v after the expiry, want %v", err, ErrExpired)
+	}
+}
+
+func TestVerifyRejectsWrongAudienceAndIssuer(t *testing.T) {
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSigner("user-admin", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	token, err := s.Sign("admin", "admin:1", "", 0, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Verify(token, "user", now); err != ErrInvalid {
+		t.Errorf("got %v for an admin token verified as an end-user token, want %v", err, ErrInvalid)
+	}
+	if _, err := s.Verify(token, "", now); err != ErrInvalid {
+		t.Errorf("got %v for an empty audience, want %v", err, ErrInvalid)
+	}
+	other, err := NewSigner("someone-else", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Verify(token, "admin", now); err != ErrInvalid {
+		t.Errorf("got %v for a token of another issuer, want %v", err, ErrInvalid)
+	}
+}
+
+func TestVerifyRejectsWrongSignatures(t *testing.T) {
+	s := newTestSigner(t)
+	now := time.Now()
+	token, err := s.Sign("user", "subject", "", 0, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	// Claims changed after signing
+	forged, err := json.Marshal(Claims{Issuer: "user-admin", Audience: "user", Subject: "someone-else", ExpiresAt: now.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := []byte(parts[2])
+	if signature[0] == 'A' {
+		signature[0] = 'B'
+	} else {
+		signature[0] = 'A'
+	}
+	tests := map[string]string{
+		"changed claims":        parts[0] + "." + encode(forged) + "." + parts[2],
+		"changed signature":     parts[0] + "." + parts[1] + "." + string(signature),
+		"truncated signature":   parts[0] + "." + parts[1] + "." + parts[2][:len(parts[2])-4],
+		"missing signature":     parts[0] + "." + parts[1] + ".",
+		"missing segment":       parts[0] + "." + parts[1],
+		"extra segment":         token + ".x",
+		"garbage":               "not-a-token",
+		"undecodable header":    "!!!." + parts[1] + "." + parts[2],
+		"undecodable claims":    parts[0] + ".!!!." + parts[2],
+		"undecodable signature": parts[0] + "." + parts[1] + ".!!!",
+	}
+	for name, token := range tests {
+		if _, err := s.Verify(token, "user", now); err != ErrInvalid {
+			t.Errorf("%s: got %v, want %v", name, err, ErrInvalid)
+		}
+	}
+
+	// Signed with a key the verifier does not know, under its key ID
+	stranger := newTestSigner(t)
+	stranger.kid = s.kid
+	token, err = stranger.Sign("user", "subject", "", 0, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(token, "user", now); err != ErrInvalid {
+		t.Errorf("got %v for a token signed with another key, want %v", err, ErrInvalid)
+	}
+}
+
+func TestVerifyRejectsOtherAlgorithms(t *testing.T) {
+	s := newTestSigner(t)
+	now := time.Now()
+	token, err := s.Sign("user", "subject", "", 0, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(token, ".")
+
+	for _, alg := range []string{"none", "None", "HS256", "ES384", "RS256", ""} {
+		h, err := json.Marshal(header{Alg: alg, Typ: "JWT", Kid: s.kid})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, signature := range []string{"", parts[2]} {
+			if _, err := s.Verify(encode(h)+"."+parts[1]+"."+signature, "user", now); err != ErrInvalid {
+				t.Errorf("alg %q: got %v, want %v", alg, err, ErrInvalid)
+			}
+		}
+	}
+}
+
+func TestVerifyAcceptsPreviousKeys(t *testing.T) {
+	old := newTestSigner(t)
+	now := time.Now()
+	token, err := old.Sign("user", "subject", "", 0, now, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestSigner(t, &old.key.PublicKey)
+	if _, err := rotated.Verify(token, "user", now); err != nil {
+		t.Errorf("got %v for a token of the previous key, want nil", err)
+	}
+	if keys := rotated.JWKS().Keys; len(keys) != 2 || keys[0].Kid != rotated.kid || keys[1].Kid != old.kid {
+		t.Errorf("got JWKS %+v, want the current key followed by the previous one", keys)
+	}
+	if _, err := newTestSigner(t).Verify(token, "user", now); err != ErrInvalid {
+		t.Errorf("got %v after the previous key was dropped, want %v", err, ErrInvalid)
+	}
+}
+
+func TestNewSignerRequiresP256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner("user-admin", key); err == nil {
+		t.Error("got nil for a P-384 signing key, want an error")
+	}
+	current, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner("user-admin", current, &key.PublicKey); err == nil {
+		t.Error("got nil for a P-384 previous key, want an error")
+	}
+}
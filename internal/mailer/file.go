@@ -0,0 +1,74 @@
+package mailer
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// File writes every email to a separate .eml file in a directory, for local
+// development without an SMTP server.
+type File struct {
+	dir  string
+	from string
+}
+
+// NewFile creates a File mailer writing to dir, which is created if missing.
+func NewFile(dir, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %v", err)
+	}
+	return &File{dir: dir, from: from}, nil
+}
+
+func (m *File) Send(ctx context.Context, msg Message) error {
+	if err := validateHeader("To", msg.To); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	now := time.Now()
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	if err := os.WriteFile(filepath.Join(m.dir, name), format(m.from, msg, now), 0o644); err != nil {
+		return fmt.Errorf("failed to write email: %v", err)
+	}
+	return nil
+}
+
+// Memory keeps sent emails in memory so tests can inspect them.
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemory creates an empty in-memory mailer.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(ctx context.Context, msg Message) error {
+	if err := validateHeader("To", msg.To); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the emails sent so far, oldest first.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig configures the SMTP mailer.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP sends emails through an SMTP relay. STARTTLS is used when the server
+// offers it, and authentication is only attempted when a username is set.
+type SMTP struct {
+	cfg  SMTPConfig
+	auth smtp.Auth
+}
+
+// NewSMTP creates an SMTP mailer.
+func NewSMTP(cfg SMTPConfig) (*SMTP, error) {
+	if cfg.Host == "" || cfg.From == "" {
+		return nil, fmt.Errorf("SMTP host and sender address are required")
+	}
+	if err := validateHeader("From", cfg.From); err != nil {
+		return nil, err
+	}
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+
+	m := &SMTP{cfg: cfg}
+	if cfg.Username != "" {
+		m.auth = smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)
+	}
+	return m, nil
+}
+
+func (m *SMTP) Send(ctx context.Context, msg Message) error {
+	if err := validateHeader("To", msg.To); err != nil {
+		return err
+	}
+
+	// net/smtp has no context support, so run the send in the background and
+	// stop waiting once the context is done
+	errc := make(chan error, 1)
+	go func() {
+		addr := net.JoinHostPort(m.cfg.Host, m.cfg.Port)
+		errc <- smtp.SendMail(addr, m.auth, m.cfg.From, []string{msg.To}, format(m.cfg.From, msg, time.Now()))
+	}()
+
+	select {
+	case err := <-errc:
+		if err != nil {
+			return fmt.Errorf("failed to send email: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
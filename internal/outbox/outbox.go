@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event types published by UserService mutations.
+const (
+	UserCreated   = "user.created"
+	UserUpdated   = "user.updated"
+	UserDeleted   = "user.deleted"
+	UserBlocked   = "user.blocked"
+	UserUnblocked = "user.unblocked"
+)
+
+// Event is a domain event stored in the outbox table.
+type Event struct {
+	ID          int64
+	Type        string
+	AggregateID int64
+	Payload     json.RawMessage
+	CreatedAt   time.Time
+}
+
+// Execer is satisfied by both *sql.DB and *sql.Tx so events can be written
+// inside the same transaction as the mutation that produced them.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Write stores an event in the outbox. The payload is encoded as JSON.
+func Write(ctx context.Context, db Execer, eventType string, aggregateID int64, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event: %v", eventType, err)
+	}
+
+	_, err = db.ExecContext(ctx,
+		"INSERT INTO outbox_events (event_type, aggregate_id, payload) VALUES ($1, $2, $3)",
+		eventType, aggregateID, data)
+	if err != nil {
+		return fmt.Errorf("failed to write %s event: %v", eventType, err)
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var (
+	// ErrInvalid is returned for input that is not a valid phone number of any known country.
+	ErrInvalid = errors.New("invalid phone number")
+	// ErrCountryNotAllowed is returned for valid numbers of a country that is not enabled.
+	ErrCountryNotAllowed = errors.New("phone number country is not allowed")
+)
+
+// Country describes the numbering rules of one country.
+type Country struct {
+	// Code is the ISO 3166-1 alpha-2 country code, e.g. "TM".
+	Code string
+	// CallingCode is the international calling code without "+", e.g. "993".
+	CallingCode string
+	// TrunkPrefix is dialled before national numbers, e.g. "8" in Turkmenistan.
+	TrunkPrefix string
+	// Lengths lists the valid lengths of the national significant number.
+	Lengths []int
+	// Prefixes restricts the national significant number to these prefixes. Empty allows any.
+	Prefixes []string
+}
+
+// Countries contains the built-in numbering rules keyed by ISO code.
+var Countries = map[string]Country{
+	"TM": {Code: "TM", CallingCode: "993", TrunkPrefix: "8", Lengths: []int{8}},
+	"RU": {Code: "RU", CallingCode: "7", TrunkPrefix: "8", Lengths: []int{10}, Prefixes: []string{"3", "4", "8", "9"}},
+	"KZ": {Code: "KZ", CallingCode: "7", TrunkPrefix: "8", Lengths: []int{10}, Prefixes: []string{"6", "7"}},
+	"UZ": {Code: "UZ", CallingCode: "998", Lengths: []int{9}},
+	"AZ": {Code: "AZ", CallingCode: "994", TrunkPrefix: "0", Lengths: []int{9}},
+	"TR": {Code: "TR", CallingCode: "90", TrunkPrefix: "0", Lengths: []int{10}},
+	"IR": {Code: "IR", CallingCode: "98", TrunkPrefix: "0", Lengths: []int{10}},
+	"AE": {Code: "AE", CallingCode: "971", TrunkPrefix: "0", Lengths: []int{8, 9}},
+	"CN": {Code: "CN", CallingCode: "86", TrunkPrefix: "0", Lengths: []int{11}},
+	"DE": {Code: "DE", CallingCode: "49", TrunkPrefix: "0", Lengths: []int{10, 11}},
+	"GB": {Code: "GB", CallingCode: "44", TrunkPrefix: "0", Lengths: []int{10}},
+}
+
+// Number is a normalized phone number.
+type Number struct {
+	// E164 is the canonical form, e.g. "+99365123456".
+	E164 string
+	// Country is the ISO code of the country the number belongs to.
+	Country string
+}
+
+// Normalizer converts user input into E.164 numbers of the allowed countries.
+type Normalizer struct {
+	allowed        []Country
+	defaultCountry Country
+}
+
+// NewNormalizer creates a Normalizer accepting numbers of the given countries.
+// National numbers without a calling code are interpreted as numbers of
+// defaultCountry, which must be one of the allowed countries. prefixes
+// optionally overrides the allowed prefixes per country.
+func NewNormalizer(allowed []string, defaultCountry string, prefixes map[string][]string) (*Normalizer, error) {
+	n := &Normalizer{}
+	for _, code := range allowed {
+		code = strings.ToUpper(strings.TrimSpace(code))
+		country, ok := Countries[code]
+		if !ok {
+			return nil, fmt.Errorf("unsupported phone country %q", code)
+		}
+		if p, ok := prefixes[code]; ok {
+			country.Prefixes = p
+		}
+		n.allowed = append(n.allowed, country)
+		if code == strings.ToUpper(defaultCountry) {
+			n.defaultCountry = country
+		}
+	}
+
+	if len(n.allowed) == 0 {
+		return nil, fmt.Errorf("at least one phone country must be allowed")
+	}
+	if n.defaultCountry.Code == "" {
+		return nil, fmt.Errorf("default phone country %q is not allowed", defaultCountry)
+	}
+
+	// Try longer calling codes first so "+993..." is never matched by a shorter code
+	sort.SliceStable(n.allowed, func(i, j int) bool {
+		return len(n.allowed[i].CallingCode) > len(n.allowed[j].CallingCode)
+	})
+	return n, nil
+}
+
+// Allowed returns the ISO codes of the allowed countries.
+func (n *Normalizer) Allowed() []string {
+	codes := make([]string, 0, len(n.allowed))
+	for _, c := range n.allowed {
+		codes = append(codes, c.Code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Normalize parses input such as "+993 65-12-34-56", "0099365123456" or the
+// national "8 65 123456" and returns the E.164 number.
+func (n *Normalizer) Normalize(input string) (Number, error) {
+	digits, international, err := clean(input)
+	if err != nil {
+		return Number{}, err
+	}
+
+	if !international {
+		nsn := digits
+		trunk := n.defaultCountry.TrunkPrefix
+		if trunk != "" && strings.HasPrefix(nsn, trunk) && !n.defaultCountry.valid(nsn) {
+			nsn = strings.TrimPrefix(nsn, trunk)
+		}
+		if !n.defaultCountry.valid(nsn) {
+			return Number{}, ErrInvalid
+		}
+		return Number{E164: "+" + n.defaultCountry.CallingCode + nsn, Country: n.defaultCountry.Code}, nil
+	}
+
+	if country, ok := match(n.allowed, digits); ok {
+		return Number{E164: "+" + digits, Country: country.Code}, nil
+	}
+
+	// Distinguish numbers of disabled countries from garbage
+	if _, ok := match(sortedCountries(), digits); ok {
+		return Number{}, ErrCountryNotAllowed
+	}
+	return Number{}, ErrInvalid
+}
+
+// CountryOf returns the ISO code of the country an E.164 number belongs to,
+// using the built-in rules, or "" when it matches no known country.
+func CountryOf(e164 string) string {
+	if !strings.HasPrefix(e164, "+") {
+		return ""
+	}
+	if country, ok := match(sortedCountries(), e164[1:]); ok {
+		return country.Code
+	}
+	return ""
+}
+
+// clean strips formatting characters and international prefixes ("+" or "00").
+func clean(input string) (digits string, international bool, err error) {
+	input = strings.TrimSpace(input)
+	if strings.HasPrefix(input, "+") {
+		international = true
+		input = input[1:]
+	}
+
+	var b strings.Builder
+	for _, r := range input {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '(' || r == ')' || r == '.' || r == '/':
+		default:
+			return "", false, ErrInvalid
+		}
+	}
+
+	digits = b.String()
+	if !international && strings.HasPrefix(digits, "00") {
+		international = true
+		digits = digits[2:]
+	}
+	if digits == "" || len(digits) > 15 {
+		return "", false, ErrInvalid
+	}
+	return digits, international, nil
+}
+
+// match finds the first country whose calling code and national number rules accept digits.
+func match(countries []Country, digits string) (Country, bool) {
+	for _, c := range countries {
+		if strings.HasPrefix(digits, c.CallingCode) && c.valid(digits[len(c.CallingCode):]) {
+			return c, true
+		}
+	}
+	return Country{}, false
+}
+
+// valid checks the length and prefix rules of a national significant number.
+func (c Country) valid(nsn string) bool {
+	lengthOK := false
+	for _, l := range c.Lengths {
+		if len(nsn) == l {
+			lengthOK = true
+			break
+		}
+	}
+	if !lengthOK {
+		return false
+	}
+
+	if len(c.Prefixes) == 0 {
+		return true
+	}
+	for _, p := range c.Prefixes {
+		if strings.HasPrefix(nsn, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedCountries returns the built-in countries with the longest calling codes first.
+func sortedCountries() []Country {
+	countries := make([]Country, 0, len(Countries))
+	for _, c := range Countries {
+		countries = append(countries, c)
+	}
+	sort.Slice(countries, func(i, j int) bool {
+		if len(countries[i].CallingCode) != len(countries[j].CallingCode) {
+			return len(countries[i].CallingCode) > len(countries[j].CallingCode)
+		}
+		return countries[i].Code < countries[j].Code
+	})
+	return countries
+}
@@ -0,0 +1,136 @@
+package phone
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	n, err := NewNormalizer([]string{"TM", "RU", "KZ", "TR"}, "TM", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input   string
+		want    string
+		country string
+		err     error
+	}{
+		// National numbers of the default country, with and without the trunk prefix
+		{"65123456", "+99365123456", "TM", nil},
+		{"865123456", "+99365123456", "TM", nil},
+		{"8 65 123456", "+99365123456", "TM", nil},
+		{"8 (12) 34-56-78", "+99312345678", "TM", nil},
+		// International numbers with "+" or "00"
+		{"+99365123456", "+99365123456", "TM", nil},
+		{"+993 65-12-34-56", "+99365123456", "TM", nil},
+		{"0099365123456", "+99365123456", "TM", nil},
+		{"00 993 65 12 34 56", "+99365123456", "TM", nil},
+		{"+7 (912) 345-67-89", "+79123456789", "RU", nil},
+		{"+7 701 234 5678", "+77012345678", "KZ", nil},
+		{"+90 532 123 45 67", "+905321234567", "TR", nil},
+		// Separators
+		{"  +993.65.12.34.56  ", "+99365123456", "TM", nil},
+		{"+993/65/123456", "+99365123456", "TM", nil},
+		{"+993 65_12_34_56", "", "", ErrInvalid},
+		{"+993 65 12 34 5x", "", "", ErrInvalid},
+		{"++99365123456", "", "", ErrInvalid},
+		// Countries that are not allowed or not known
+		{"+998 90 123 45 67", "", "", ErrCountryNotAllowed},
+		{"+44 20 7946 0958", "", "", ErrCountryNotAllowed},
+		{"+1 212 555 0100", "", "", ErrInvalid},
+		{"+7 512 345 6789", "", "", ErrInvalid},
+		// Numbers of the wrong length
+		{"6512345", "", "", ErrInvalid},
+		{"651234567", "", "", ErrInvalid},
+		{"8651234567", "", "", ErrInvalid},
+		{"+9936512345", "", "", ErrInvalid},
+		{"+993651234567", "", "", ErrInvalid},
+		{"+9936512345678901", "", "", ErrInvalid},
+		{"0099365123456789012", "", "", ErrInvalid},
+		{"", "", "", ErrInvalid},
+		{"+", "", "", ErrInvalid},
+	}
+	for _, tt := range tests {
+		got, err := n.Normalize(tt.input)
+		if err != tt.err || got.E164 != tt.want || got.Country != tt.country {
+			t.Errorf("Normalize(%q) = %q, %q, %v, want %q, %q, %v", tt.input, got.E164, got.Country, err, tt.want, tt.country, tt.err)
+		}
+	}
+}
+
+func TestNormalizeWithOverriddenPrefixes(t *testing.T) {
+	n, err := NewNormalizer([]string{"TM"}, "TM", map[string][]string{"TM": {"6", "7"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := n.Normalize("+99371234567"); err != nil || got.E164 != "+99371234567" {
+		t.Errorf("got %q, %v for an allowed prefix", got.E164, err)
+	}
+	// The number is valid under the built-in rules, so it is refused like one of a disabled country
+	if _, err := n.Normalize("+99312345678"); err != ErrCountryNotAllowed {
+		t.Errorf("got %v for a prefix that is not allowed, want %v", err, ErrCountryNotAllowed)
+	}
+	if _, err := n.Normalize("812345678"); err != ErrInvalid {
+		t.Errorf("got %v for a national number with a prefix that is not allowed, want %v", err, ErrInvalid)
+	}
+}
+
+func TestNewNormalizer(t *testing.T) {
+	tests := []struct {
+		allowed        []string
+		defaultCountry string
+		ok             bool
+	}{
+		{[]string{"TM"}, "TM", true},
+		{[]string{" tm ", "ru"}, "tm", true},
+		{[]string{"TM", "XX"}, "TM", false},
+		{[]string{"TM"}, "RU", false},
+		{[]string{"TM"}, "", false},
+		{nil, "TM", false},
+	}
+	for _, tt := range tests {
+		if _, err := NewNormalizer(tt.allowed, tt.defaultCountry, nil); (err == nil) != tt.ok {
+			t.Errorf("NewNormalizer(%q, %q) = %v, want ok %v", tt.allowed, tt.defaultCountry, err, tt.ok)
+		}
+	}
+}
+
+// The 001_phone_e164.sql migration sets the country of every existing number
+// to TM. Before it, numbers had to match ^\+993\d{8}$, and all of them must
+// keep their stored form when normalized so lookups still find them.
+func TestExistingTurkmenNumbersKeepTheirForm(t *testing.T) {
+	n, err := NewNormalizer([]string{"TM", "RU", "KZ", "UZ", "AZ", "TR", "IR", "AE", "CN", "DE", "GB"}, "TM", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for first := 0; first <= 9; first++ {
+		for _, rest := range []string{"0000000", "1234567", "9999999"} {
+			stored := fmt.Sprintf("+993%d%s", first, rest)
+			if got, err := n.Normalize(stored); err != nil || got.E164 != stored || got.Country != "TM" {
+				t.Errorf("Normalize(%q) = %q, %q, %v, want it unchanged in TM", stored, got.E164, got.Country, err)
+			}
+			if got := CountryOf(stored); got != "TM" {
+				t.Errorf("CountryOf(%q) = %q, want TM", stored, got)
+			}
+		}
+	}
+}
+
+func TestCountryOf(t *testing.T) {
+	tests := map[string]string{
+		"+99365123456":   "TM",
+		"+79123456789":   "RU",
+		"+77012345678":   "KZ",
+		"+998901234567":  "UZ",
+		"+4915123456789": "DE",
+		"99365123456":    "",
+		"+12125550100":   "",
+		"+9936512345":    "",
+	}
+	for e164, want := range tests {
+		if got := CountryOf(e164); got != want {
+			t.Errorf("CountryOf(%q) = %q, want %q", e164, got, want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package photo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register the WebP decoder
+)
+
+// Supported MIME types.
+const (
+	JPEG = "image/jpeg"
+	PNG  = "image/png"
+	WebP = "image/webp"
+)
+
+// ErrUnsupportedType is returned when the upload is not a JPEG, PNG or WebP image.
+var ErrUnsupportedType = errors.New("unsupported image type")
+
+// ErrTooLarge is returned when the image dimensions exceed the configured limit.
+var ErrTooLarge = errors.New("image dimensions too large")
+
+// Options control how uploads are validated and processed.
+type Options struct {
+	// MaxPixels caps width*height to protect against decompression bombs.
+	MaxPixels int
+	// ThumbnailSizes lists the edge lengths of the square thumbnails to generate.
+	ThumbnailSizes []int
+	// JPEGQuality is used when re-encoding JPEG and WebP images.
+	JPEGQuality int
+}
+
+// DefaultOptions are used for profile photos.
+var DefaultOptions = Options{
+	MaxPixels:      40_000_000,
+	ThumbnailSizes: []int{64, 256},
+	JPEGQuality:    90,
+}
+
+// Thumbnail is a square, resized copy of the photo.
+type Thumbnail struct {
+	Size int
+	Data []byte
+}
+
+// Processed is a verified photo re-encoded without metadata.
+type Processed struct {
+	Data        []byte
+	ContentType string
+	Extension   string
+	Thumbnails  []Thumbnail
+}
+
+// DetectType sniffs the MIME type of data and returns ErrUnsupportedType for
+// anything other than JPEG, PNG or WebP.
+func DetectType(data []byte) (string, error) {
+	contentType := http.DetectContentType(data)
+	switch contentType {
+	case JPEG, PNG, WebP:
+		return contentType, nil
+	}
+	return "", ErrUnsupportedType
+}
+
+// Process verifies that data is a decodable image, re-encodes it to strip EXIF
+// and any other embedded metadata, and generates thumbnails. JPEG orientation is
+// applied to the pixels before the metadata is dropped. WebP images are
+// re-encoded as JPEG since only a decoder is available.
+func Process(data []byte, opts Options) (*Processed, error) {
+	contentType, err := DetectType(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > opts.MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %v", err)
+	}
+
+	if contentType == JPEG {
+		img = applyOrientation(img, jpegOrientation(data))
+	}
+
+	outputType := JPEG
+	if contentType == PNG {
+		outputType = PNG
+	}
+
+	encoded, err := encode(img, outputType, opts.JPEGQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	processed := &Processed{
+		Data:        encoded,
+		ContentType: outputType,
+		Extension:   extension(outputType),
+	}
+
+	for _, size := range opts.ThumbnailSizes {
+		thumb, err := encode(thumbnail(img, size), outputType, opts.JPEGQuality)
+		if err != nil {
+			return nil, err
+		}
+		processed.Thumbnails = append(processed.Thumbnails, Thumbnail{Size: size, Data: thumb})
+	}
+
+	return processed, nil
+}
+
+// thumbnail center-crops img to a square and scales it to size x size.
+func thumbnail(img image.Image, size int) image.Image {
+	bounds := img.Bounds()
+	edge := bounds.Dx()
+	if bounds.Dy() < edge {
+		edge = bounds.Dy()
+	}
+	crop := image.Rect(0, 0, edge, edge).Add(image.Pt(
+		bounds.Min.X+(bounds.Dx()-edge)/2,
+		bounds.Min.Y+(bounds.Dy()-edge)/2,
+	))
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, crop, draw.Src, nil)
+	return dst
+}
+
+func encode(img image.Image, contentType string, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if contentType == PNG {
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %v", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func extension(contentType string) string {
+	if contentType == PNG {
+		return ".png"
+	}
+	return ".jpg"
+}
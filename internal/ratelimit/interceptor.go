@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/inprocess"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// UnaryInterceptor rejects calls exceeding the rule matched by the method with
+// ResourceExhausted. It must run after the authentication interceptors so
+// authenticated callers are limited by identity rather than address.
+func UnaryInterceptor(limiter Limiter, rules Rules) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := check(ctx, limiter, rules, info.FullMethod, func(md metadata.MD) { grpc.SetTrailer(ctx, md) }); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamInterceptor limits streaming calls when they are opened.
+func StreamInterceptor(limiter Limiter, rules Rules) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := check(ss.Context(), limiter, rules, info.FullMethod, ss.SetTrailer); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func check(ctx context.Context, limiter Limiter, rules Rules, fullMethod string, setTrailer func(metadata.MD)) error {
+	pattern, rule, ok := rules.Match(fullMethod)
+	if !ok {
+		return nil
+	}
+
+	result, err := limiter.Allow(ctx, Identity(ctx)+"|"+pattern, rule)
+	if err != nil {
+		// An unavailable limiter must not take the API down with it
+		log.Printf("Error checking rate limit: %v", err)
+		return nil
+	}
+	if result.Allowed {
+		return nil
+	}
+
+	seconds := int(math.Ceil(result.RetryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	setTrailer(metadata.Pairs("retry-after", strconv.Itoa(seconds)))
+
+	st := status.New(codes.ResourceExhausted, "Too many requests, please retry later")
+	if detailed, err := st.WithDetails(&errdetails.RetryInfo{RetryDelay: durationpb.New(time.Duration(seconds) * time.Second)}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
+
+// Identity returns the key callers are limited by: the authenticated end user,
+// admin or API key, else the address forwarded by an in-process caller, else
+// the peer IP address. Unverified credentials are ignored, as a caller could
+// send a new value with every request to get a fresh bucket.
+func Identity(ctx context.Context) string {
+	if user, ok := auth.EndUserFromContext(ctx); ok {
+		return "user:" + strconv.FormatInt(user.UserID, 10)
+	}
+	if admin, ok := auth.AdminFromContext(ctx); ok {
+		return "admin:" + strconv.FormatInt(admin.ID, 10)
+	}
+	// Authenticated keys are limited by ID, so rotating a key keeps its buckets
+	if key, ok := auth.APIKeyFromContext(ctx); ok {
+		return "apikey:" + strconv.FormatInt(key.ID, 10)
+	}
+	// The admin dashboard calls on behalf of browsers, which are limited by
+	// their own address
+	if ip, ok := inprocess.ClientIP(ctx); ok {
+		return "ip:" + ip
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		addr := p.Addr.String()
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		return "ip:" + strings.TrimSpace(addr)
+	}
+	return "unknown"
+}
@@ -0,0 +1,32 @@
+package ratelimit_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/usertest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnverifiedAPIKeysShareTheAddressBucket(t *testing.T) {
+	srv := usertest.New(t, usertest.WithRateLimits("user.SessionService/VerifyLoginCode=3/m"))
+	sessions := pb.NewSessionServiceClient(srv.Conn)
+
+	for i := 0; i < 4; i++ {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-api-key", "uak_random-"+strconv.Itoa(i))
+		_, err := sessions.VerifyLoginCode(ctx, &pb.VerifyLoginCodeRequest{PhoneNumber: "+99365123456", Code: "000000"})
+		if i < 3 {
+			if status.Code(err) == codes.ResourceExhausted {
+				t.Fatalf("call %d: rate limited before the burst was used up", i)
+			}
+			continue
+		}
+		if status.Code(err) != codes.ResourceExhausted {
+			t.Fatalf("call %d: got %v, want ResourceExhausted", i, err)
+		}
+	}
+}
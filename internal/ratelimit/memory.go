@@ -0,0 +1,69 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Memory keeps buckets in process memory. Each replica limits on its own, so
+// use Postgres to share limits between replicas.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rule    Rule
+}
+
+// NewMemory creates an in-memory Limiter.
+func NewMemory() *Memory {
+	return &Memory{buckets: make(map[string]*bucket), now: time.Now}
+}
+
+func (m *Memory) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rule.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.rule = rule
+
+	if elapsed := now.Sub(b.updated).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(rule.Burst), b.tokens+elapsed*rule.Rate)
+	}
+	b.updated = now
+
+	if b.tokens < 1 {
+		return Result{RetryAfter: retryAfter(b.tokens, rule)}, nil
+	}
+	b.tokens--
+	return Result{Allowed: true, Remaining: int(b.tokens)}, nil
+}
+
+// sweep drops buckets that have refilled completely, since they are
+// equivalent to new ones. It runs at most once a minute.
+func (m *Memory) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+
+	for key, b := range m.buckets {
+		if b.tokens+now.Sub(b.updated).Seconds()*b.rule.Rate >= float64(b.rule.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+}
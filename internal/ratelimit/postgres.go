@@ -0,0 +1,72 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// refill is the bucket level after refilling it for the time since the last
+// request. It uses the database clock so replicas with skewed clocks agree.
+const refill = "LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM clock_timestamp() - b.updated_at), 0) * $3::float8)"
+
+const allowQuery = `
+	INSERT INTO rate_limit_buckets AS b (key, tokens, allowed, updated_at)
+	VALUES ($1, $2::float8 - 1, true, clock_timestamp())
+	ON CONFLICT (key) DO UPDATE
+	SET tokens = CASE WHEN ` + refill + ` >= 1 THEN ` + refill + ` - 1 ELSE ` + refill + ` END,
+		allowed = ` + refill + ` >= 1,
+		updated_at = clock_timestamp()
+	RETURNING tokens, allowed`
+
+// Postgres shares buckets between replicas through the rate_limit_buckets
+// table. Every check is a single upsert, so concurrent requests for the same
+// key are serialized by the row lock.
+type Postgres struct {
+	db *sql.DB
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewPostgres creates a Limiter backed by the given database connection.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+func (p *Postgres) Allow(ctx context.Context, key string, rule Rule) (Result, error) {
+	p.cleanup()
+
+	var tokens float64
+	var allowed bool
+	if err := p.db.QueryRowContext(ctx, allowQuery, key, rule.Burst, rule.Rate).Scan(&tokens, &allowed); err != nil {
+		return Result{}, fmt.Errorf("failed to update rate limit bucket: %v", err)
+	}
+
+	if !allowed {
+		return Result{RetryAfter: retryAfter(tokens, rule)}, nil
+	}
+	return Result{Allowed: true, Remaining: int(tokens)}, nil
+}
+
+// cleanup deletes buckets unused for an hour in the background, at most every ten minutes.
+func (p *Postgres) cleanup() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.lastCleanup) < 10*time.Minute {
+		return
+	}
+	p.lastCleanup = time.Now()
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := p.db.ExecContext(ctx, "DELETE FROM rate_limit_buckets WHERE updated_at < clock_timestamp() - INTERVAL '1 hour'"); err != nil {
+			log.Printf("Error cleaning up rate limit buckets: %v", err)
+		}
+	}()
+}
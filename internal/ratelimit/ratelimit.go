@@ -0,0 +1,122 @@
+// Package ratelimit limits the request rate of clients with token buckets.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rule allows Burst requests at once, refilled at Rate requests per second.
+type Rule struct {
+	Rate  float64
+	Burst int
+}
+
+// Result is the outcome of a rate limit check.
+type Result struct {
+	Allowed bool
+	// Remaining is the number of requests that may be made right away.
+	Remaining int
+	// RetryAfter is how long to wait before the next request is allowed, zero if allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter takes a token from the bucket identified by key.
+type Limiter interface {
+	Allow(ctx context.Context, key string, rule Rule) (Result, error)
+}
+
+// Rules maps gRPC methods to rules. Patterns are full method names such as
+// "user.UserService/CreateUser", whole services such as "user.UserService/*"
+// or "default" for everything else.
+type Rules map[string]Rule
+
+// Match returns the rule for a full method name ("/package.Service/Method")
+// and the pattern it matched, which identifies the bucket.
+func (r Rules) Match(fullMethod string) (string, Rule, bool) {
+	method := strings.TrimPrefix(fullMethod, "/")
+	if rule, ok := r[method]; ok {
+		return method, rule, true
+	}
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		pattern := method[:i] + "/*"
+		if rule, ok := r[pattern]; ok {
+			return pattern, rule, true
+		}
+	}
+	if rule, ok := r["default"]; ok {
+		return "default", rule, true
+	}
+	return "", Rule{}, false
+}
+
+// ParseRules parses rules in the form
+// "default=10/s:20;user.UserService/CreateUser=30/m:5", where each rule is
+// PATTERN=RATE/UNIT:BURST with UNIT one of s, m or h. The burst defaults to
+// the rate rounded up.
+func ParseRules(value string) (Rules, error) {
+	rules := Rules{}
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		pattern, spec, ok := strings.Cut(entry, "=")
+		pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid rate limit %q, expected PATTERN=RATE/UNIT:BURST", entry)
+		}
+
+		rule, err := parseRule(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q: %v", entry, err)
+		}
+		rules[pattern] = rule
+	}
+	return rules, nil
+}
+
+func parseRule(spec string) (Rule, error) {
+	rateSpec, burstSpec, hasBurst := strings.Cut(spec, ":")
+	count, unit, ok := strings.Cut(rateSpec, "/")
+	if !ok {
+		return Rule{}, fmt.Errorf("missing unit")
+	}
+
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 {
+		return Rule{}, fmt.Errorf("invalid rate %q", count)
+	}
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Rule{}, fmt.Errorf("invalid unit %q", unit)
+	}
+
+	rule := Rule{Rate: n / per.Seconds(), Burst: int(math.Ceil(n))}
+	if hasBurst {
+		if rule.Burst, err = strconv.Atoi(burstSpec); err != nil || rule.Burst < 1 {
+			return Rule{}, fmt.Errorf("invalid burst %q", burstSpec)
+		}
+	}
+	return rule, nil
+}
+
+// retryAfter returns how long it takes to refill from tokens to one token.
+func retryAfter(tokens float64, rule Rule) time.Duration {
+	if tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - tokens) / rule.Rate * float64(time.Second))
+}
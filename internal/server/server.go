@@ -2,62 +2,447 @@ package server
 
 import (
 	"context"
+	"crypto/ecdsa"
 	"database/sql"
+	"expvar"
 	"fmt"
 	"log"
 	"net"
+	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	_ "github.com/lib/pq"
 
 	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
 	"github.com/hojamuhammet/user-admin-grpc-go/internal/config"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/database"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/inprocess"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/jwt"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/mailer"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/ratelimit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/sms"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/storage"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/web"
 
 	service "github.com/hojamuhammet/user-admin-grpc-go/internal/service"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
 
+// tenantCacheTTL is how long tenants and their settings are cached.
+const tenantCacheTTL = 30 * time.Second
+
 type Server struct {
-	ctx context.Context
-	cfg *config.Config
-	server *grpc.Server
-	db *sql.DB
+	ctx           context.Context
+	cfg           *config.Config
+	server        *grpc.Server
+	httpServer    *http.Server
+	metricsServer *http.Server
+	db            *sql.DB
+	store         store.Store
+	storage       storage.Storage
+	mailer        mailer.Mailer
+	sms           sms.Sender
+	signer        *jwt.Signer
+	limiter       ratelimit.Limiter
+	listener      net.Listener
+	dashboardConn *grpc.ClientConn
+	serverOptions []grpc.ServerOption
+	mu            sync.Mutex
+	stopped       bool
 	pb.UnimplementedUserServiceServer
 }
 
-func NewServer(ctx context.Context, cfg *config.Config, db *sql.DB) *Server {
-	return &Server {
+// Option customizes the Server, mainly to run it in-process for tests.
+type Option func(*Server)
+
+// WithStore replaces the Postgres user store.
+func WithStore(store store.Store) Option {
+	return func(s *Server) { s.store = store }
+}
+
+// WithStorage replaces the photo storage selected by the configuration.
+func WithStorage(storage storage.Storage) Option {
+	return func(s *Server) { s.storage = storage }
+}
+
+// WithMailer replaces the mailer selected by the configuration.
+func WithMailer(mailer mailer.Mailer) Option {
+	return func(s *Server) { s.mailer = mailer }
+}
+
+// WithSMS replaces the SMS sender selected by the configuration.
+func WithSMS(sender sms.Sender) Option {
+	return func(s *Server) { s.sms = sender }
+}
+
+// WithSigner replaces the access token signer loaded from the configuration.
+func WithSigner(signer *jwt.Signer) Option {
+	return func(s *Server) { s.signer = signer }
+}
+
+// WithRateLimiter replaces the rate limiter selected by the configuration.
+func WithRateLimiter(limiter ratelimit.Limiter) Option {
+	return func(s *Server) { s.limiter = limiter }
+}
+
+// WithListener serves on the given listener instead of the configured TCP port.
+func WithListener(lis net.Listener) Option {
+	return func(s *Server) { s.listener = lis }
+}
+
+// WithServerOptions adds gRPC server options such as interceptors.
+func WithServerOptions(opts ...grpc.ServerOption) Option {
+	return func(s *Server) { s.serverOptions = append(s.serverOptions, opts...) }
+}
+
+// NewServer creates a Server. Services that still depend on the database
+// directly are only registered when db is not nil.
+func NewServer(ctx context.Context, cfg *config.Config, db *sql.DB, opts ...Option) *Server {
+	s := &Server{
 		ctx: ctx,
 		cfg: cfg,
-		db: db,
+		db:  db,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func (s *Server) Start() error {
-	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", s.cfg.GRPCPort))
+	var err error
+	lis := s.listener
+	if lis == nil {
+		lis, err = net.Listen("tcp", fmt.Sprintf(":%s", s.cfg.GRPCPort))
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.store == nil {
+		s.store = store.NewPostgres(s.db)
+		if s.cfg.UserCacheTTL > 0 {
+			cached := store.NewCached(s.store, s.cfg.UserCacheTTL, s.cfg.UserCacheSize)
+			// Other replicas announce their changes through the users table trigger
+			if err := cached.Listen(s.ctx, database.ConnectionString(s.cfg)); err != nil {
+				return fmt.Errorf("failed to listen for user changes: %v", err)
+			}
+			s.store = cached
+		}
+	}
+	if s.storage == nil {
+		photoStorage, err := newPhotoStorage(s.cfg)
+		if err != nil {
+			return err
+		}
+		s.storage = photoStorage
+	}
+	if s.mailer == nil {
+		m, err := newMailer(s.cfg)
+		if err != nil {
+			return err
+		}
+		s.mailer = m
+	}
+	if s.sms == nil {
+		sender, err := newSMSSender(s.cfg)
+		if err != nil {
+			return err
+		}
+		s.sms = sender
+	}
+
+	phones, err := phone.NewNormalizer(s.cfg.PhoneCountries, s.cfg.PhoneDefaultCountry, s.cfg.PhonePrefixes)
 	if err != nil {
 		return err
 	}
+	// Every call looks up its tenant, changes of other replicas apply within the TTL
+	tenants := store.NewTenantCache(s.store, tenantCacheTTL)
+	settings := service.NewSettings(s.cfg, tenants, phones)
+
+	if s.signer == nil {
+		signer, err := newSigner(s.cfg)
+		if err != nil {
+			return err
+		}
+		s.signer = signer
+	}
+
+	if s.limiter == nil {
+		limiter, err := newRateLimiter(s.cfg, s.db)
+		if err != nil {
+			return err
+		}
+		s.limiter = limiter
+	}
+
+	if s.cfg.AdminUI && s.cfg.HTTPPort == "" {
+		return fmt.Errorf("ADMIN_UI requires HTTP_PORT")
+	}
+
+	var requireAdmin bool
+	switch s.cfg.AdminAuth {
+	case "", "optional":
+	case "required":
+		requireAdmin = true
+	default:
+		return fmt.Errorf("unknown admin auth mode %q", s.cfg.AdminAuth)
+	}
+
+	// End-user services authenticate with access tokens instead of admin credentials
+	unary := []grpc.UnaryServerInterceptor{
+		apitime.Interceptor(),
+		auth.EndUserInterceptor(s.signer, s.store, "user.ProfileService"),
+		auth.AdminInterceptor(s.signer, s.store, requireAdmin),
+		auth.TenantInterceptor(tenants),
+	}
+	stream := []grpc.StreamServerInterceptor{
+		auth.AdminStreamInterceptor(s.signer, s.store, requireAdmin),
+		auth.TenantStreamInterceptor(tenants),
+	}
+	if s.limiter != nil {
+		// Limits are applied after authentication so they follow the caller's identity
+		unary = append(unary, ratelimit.UnaryInterceptor(s.limiter, s.cfg.RateLimits))
+		stream = append(stream, ratelimit.StreamInterceptor(s.limiter, s.cfg.RateLimits))
+	}
+	serverOptions := append([]grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}, s.serverOptions...)
+
+	grpcServer := grpc.NewServer(serverOptions...)
+
+	userService := service.NewUserService(s.cfg, s.store, s.storage, settings, s.mailer, s.sms)
+	pb.RegisterUserServiceServer(grpcServer, userService)
+
+	profileService := service.NewProfileService(userService, s.store, settings)
+	pb.RegisterProfileServiceServer(grpcServer, profileService)
+
+	sessionService := service.NewSessionService(s.cfg, s.store, settings, s.sms, s.signer)
+	pb.RegisterSessionServiceServer(grpcServer, sessionService)
+
+	adminService := service.NewAdminService(s.cfg, s.store, s.signer)
+	pb.RegisterAdminServiceServer(grpcServer, adminService)
+
+	apiKeyService := service.NewAPIKeyService(s.store)
+	pb.RegisterApiKeyServiceServer(grpcServer, apiKeyService)
+
+	attributeService := service.NewAttributeService(s.store)
+	pb.RegisterAttributeServiceServer(grpcServer, attributeService)
+
+	tenantService := service.NewTenantService(s.cfg, s.store, tenants)
+	pb.RegisterTenantServiceServer(grpcServer, tenantService)
+
+	if s.db != nil {
+		webhookService := service.NewWebhookService(s.db, s.cfg.WebhookAllowPrivate)
+		pb.RegisterWebhookServiceServer(grpcServer, webhookService)
+	}
 
-	s.server = grpc.NewServer()
+	reflection.Register(grpcServer)
 
-	userService := service.NewUserService(s.cfg, s.db)
-	pb.RegisterUserServiceServer(s.server, userService)
+	// The dashboard calls the services through the interceptors like any client
+	var dashboard http.Handler
+	var dashboardLis *inprocess.Listener
+	if s.cfg.AdminUI {
+		dashboardLis = inprocess.Listen()
+		conn, err := dashboardLis.Dial(s.ctx)
+		if err != nil {
+			return fmt.Errorf("failed to connect admin dashboard: %v", err)
+		}
+		d, err := web.New(conn, s.cfg.AdminUISecureCookies)
+		if err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to create admin dashboard: %v", err)
+		}
+		s.dashboardConn, dashboard = conn, d
+	}
+
+	// Stop may be called concurrently, e.g. by a test cleanup
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		return nil
+	}
+	s.server = grpcServer
+	if s.cfg.HTTPPort != "" {
+		s.httpServer = newHTTPServer(s.cfg.HTTPPort, s.signer, dashboard)
+	}
+	if s.cfg.MetricsPort != "" {
+		s.metricsServer = newMetricsServer(s.cfg.MetricsPort)
+	}
+	if dashboardLis != nil {
+		go grpcServer.Serve(dashboardLis)
+	}
+	s.mu.Unlock()
 
-	reflection.Register(s.server)
+	if s.httpServer != nil {
+		go serveHTTP("HTTP", s.httpServer)
+	}
+	if s.metricsServer != nil {
+		go serveHTTP("Metrics", s.metricsServer)
+	}
 
-	log.Printf("gRPC server started on port %s", s.cfg.GRPCPort)
-	return s.server.Serve(lis)
+	log.Printf("gRPC server started on %s", lis.Addr())
+	return grpcServer.Serve(lis)
+}
+
+// newPhotoStorage creates the storage backend for profile photos selected by PHOTO_STORAGE.
+func newPhotoStorage(cfg *config.Config) (storage.Storage, error) {
+	switch cfg.PhotoStorage {
+	case "filesystem":
+		return storage.NewFilesystem(cfg.PhotoDir, cfg.PhotoBaseURL)
+	case "s3":
+		return storage.NewS3(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PublicURL: cfg.S3PublicURL,
+		})
+	}
+	return nil, fmt.Errorf("unknown photo storage %q", cfg.PhotoStorage)
+}
+
+// newMailer creates the mailer selected by MAILER, or nil when email delivery is disabled.
+func newMailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case "":
+		return nil, nil
+	case "smtp":
+		return mailer.NewSMTP(mailer.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.MailFrom,
+		})
+	case "file":
+		return mailer.NewFile(cfg.MailDir, cfg.MailFrom)
+	}
+	return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
+}
+
+// newSMSSender creates the SMS sender selected by SMS_SENDER, or nil when SMS delivery is disabled.
+func newSMSSender(cfg *config.Config) (sms.Sender, error) {
+	switch cfg.SMSSender {
+	case "":
+		return nil, nil
+	case "http":
+		return sms.NewHTTP(cfg.SMSGatewayURL, cfg.SMSGatewayToken)
+	case "log":
+		return sms.Log{}, nil
+	}
+	return nil, fmt.Errorf("unknown SMS sender %q", cfg.SMSSender)
+}
+
+// newRateLimiter creates the rate limiter selected by RATE_LIMITER, or nil when rate limiting is disabled.
+func newRateLimiter(cfg *config.Config, db *sql.DB) (ratelimit.Limiter, error) {
+	switch cfg.RateLimiter {
+	case "":
+		return nil, nil
+	case "memory":
+		return ratelimit.NewMemory(), nil
+	case "postgres":
+		if db == nil {
+			return nil, fmt.Errorf("rate limiter %q requires a database", cfg.RateLimiter)
+		}
+		return ratelimit.NewPostgres(db), nil
+	}
+	return nil, fmt.Errorf("unknown rate limiter %q", cfg.RateLimiter)
+}
+
+// newSigner loads the access token signing keys. Without SESSION_SIGNING_KEY
+// a temporary key is generated, so tokens do not survive a restart.
+func newSigner(cfg *config.Config) (*jwt.Signer, error) {
+	var key *ecdsa.PrivateKey
+	var err error
+	if cfg.SessionSigningKey != "" {
+		key, err = jwt.LoadPrivateKey(cfg.SessionSigningKey)
+	} else {
+		log.Printf("SESSION_SIGNING_KEY is not set, signing access tokens with a temporary key")
+		key, err = jwt.GenerateKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []*ecdsa.PublicKey
+	for _, path := range cfg.SessionVerifyKeys {
+		pub, err := jwt.LoadPublicKey(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, pub)
+	}
+	return jwt.NewSigner(cfg.SessionIssuer, key, previous...)
+}
+
+// newHTTPServer serves the HTTP endpoints next to the gRPC API, and the
+// admin dashboard unless it is nil.
+func newHTTPServer(port string, signer *jwt.Signer, dashboard http.Handler) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/.well-known/jwks.json", signer.Handler())
+	if dashboard != nil {
+		mux.Handle(web.Prefix, dashboard)
+	}
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%s", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// newMetricsServer serves the expvar counters at /debug/vars. It has no
+// authentication, so the port must only be reachable from the monitoring
+// network.
+func newMetricsServer(port string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%s", port),
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+func serveHTTP(name string, srv *http.Server) {
+	log.Printf("%s server started on %s", name, srv.Addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Printf("%s server failed: %v", name, err)
+	}
 }
 
 func (s *Server) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if s.httpServer != nil {
+		s.httpServer.Shutdown(ctx)
+	}
+	if s.metricsServer != nil {
+		s.metricsServer.Shutdown(ctx)
+	}
+	// The dashboard has no calls left once the HTTP server is shut down
+	if s.dashboardConn != nil {
+		s.dashboardConn.Close()
+	}
 	if s.server != nil {
 		s.server.GracefulStop()
 	}
 }
 
 func (s *Server) Wait() {
-    var wg sync.WaitGroup
+	var wg sync.WaitGroup
 	wg.Wait()
-}
\ No newline at end of file
+}
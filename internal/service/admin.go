@@ -0,0 +1,332 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/config"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/jwt"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/password"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/totp"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9._-]{3,64}$`)
+
+// errInvalidLogin is returned for every wrong username, password or code, so
+// callers cannot tell which part was wrong.
+var errInvalidLogin = status.Errorf(codes.Unauthenticated, "Invalid username, password or code")
+
+// dummyHash is verified against for unknown usernames, so they take as long
+// to reject as wrong passwords.
+var dummyHash struct {
+	once sync.Once
+	hash string
+}
+
+// AdminService manages the admins of the API and signs them in with a
+// password and a TOTP code.
+type AdminService struct {
+	cfg    *config.Config
+	store  store.Store
+	signer *jwt.Signer
+	pb.UnimplementedAdminServiceServer
+}
+
+// NewAdminService creates a new instance of AdminService.
+func NewAdminService(cfg *config.Config, store store.Store, signer *jwt.Signer) pb.AdminServiceServer {
+	return &AdminService{
+		cfg:    cfg,
+		store:  store,
+		signer: signer,
+	}
+}
+
+// AdminLogin checks the password and TOTP code of an admin and returns an
+// access token for the admin methods. Wrong passwords and codes count
+// towards the lockout, and a code is only accepted once.
+func (as *AdminService) AdminLogin(ctx context.Context, req *pb.AdminLoginRequest) (*pb.AdminLoginResponse, error) {
+	username := strings.ToLower(strings.TrimSpace(req.Username))
+	if username == "" || req.Password == "" || req.TotpCode == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "Username, password and code are required")
+	}
+
+	now := time.Now()
+	admin, err := as.store.GetAdminByUsername(ctx, username)
+	if err == store.ErrNotFound {
+		dummyHash.once.Do(func() { dummyHash.hash, _ = password.Hash("dummy") })
+		password.Verify(req.Password, dummyHash.hash)
+		return nil, errInvalidLogin
+	}
+	if err != nil {
+		log.Printf("Error fetching admin: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	ok, err := password.Verify(req.Password, admin.PasswordHash)
+	if err != nil {
+		log.Printf("Error verifying password of admin %s: %v", admin.Username, err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	// A locked admin is rejected like a wrong password, after the same work,
+	// so the lockout does not reveal that the username exists
+	if now.Before(admin.LockedUntil) {
+		return nil, errInvalidLogin
+	}
+	var step int64
+	if ok {
+		step, ok = totp.Validate(admin.TOTPSecret, req.TotpCode, now, admin.TOTPStep)
+	}
+	if !ok {
+		policy := store.LockoutPolicy{MaxFailures: as.cfg.AdminMaxFailedLogins, Duration: as.cfg.AdminLockoutDuration, Now: now}
+		failed, err := as.store.RecordAdminLoginFailure(ctx, admin.ID, policy)
+		if err != nil {
+			log.Printf("Error recording failed admin login: %v", err)
+		} else if now.Before(failed.LockedUntil) {
+			log.Printf("Admin %s locked out until %s", admin.Username, failed.LockedUntil.Format(time.RFC3339))
+		}
+		return nil, errInvalidLogin
+	}
+	if admin.Disabled {
+		return nil, status.Errorf(codes.PermissionDenied, "Admin account is disabled")
+	}
+
+	if err := as.store.RecordAdminLogin(ctx, admin.ID, step, now); err != nil {
+		if err == store.ErrInvalidToken {
+			return nil, errInvalidLogin
+		}
+		log.Printf("Error recording admin login: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	admin.LastLoginAt = now
+
+	token, err := as.signer.Sign(auth.AdminAudience, auth.AdminSubject(admin.ID), "", tenant.ID(ctx), now, as.cfg.AdminTokenTTL)
+	if err != nil {
+		log.Printf("Error signing admin token: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("Admin %s logged in", admin.Username)
+	return &pb.AdminLoginResponse{
+		AccessToken:           token,
+		TokenType:             "Bearer",
+		AccessTokenExpireTime: apitime.Timestamp(now.Add(as.cfg.AdminTokenTTL)),
+		Admin:                 toAdminMessage(admin, now),
+	}, nil
+}
+
+// CreateAdmin creates an admin with a generated password and TOTP secret,
+// which are only returned in the response.
+func (as *AdminService) CreateAdmin(ctx context.Context, req *pb.CreateAdminRequest) (*pb.AdminCredentials, error) {
+	username := strings.ToLower(strings.TrimSpace(req.Username))
+	if !usernamePattern.MatchString(username) {
+		return nil, invalidField("username", "Username must be 3 to 64 lowercase letters, digits, '.', '_' or '-'")
+	}
+	if err := validateRoles(req.Roles); err != nil {
+		return nil, err
+	}
+	return createAdmin(ctx, as.cfg, as.store, username, req.Roles)
+}
+
+// ListAdmins returns all admins ordered by username.
+func (as *AdminService) ListAdmins(ctx context.Context, req *pb.Empty) (*pb.AdminsList, error) {
+	admins, err := as.store.ListAdmins(ctx)
+	if err != nil {
+		log.Printf("Error listing admins: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	now := time.Now()
+	resp := &pb.AdminsList{}
+	for _, admin := range admins {
+		resp.Admins = append(resp.Admins, toAdminMessage(admin, now))
+	}
+	return resp, nil
+}
+
+// DisableAdmin disables an admin. Tokens of the admin stop working at once.
+func (as *AdminService) DisableAdmin(ctx context.Context, req *pb.AdminID) (*pb.Admin, error) {
+	if caller, ok := auth.AdminFromContext(ctx); ok && caller.ID == req.Id {
+		return nil, status.Errorf(codes.FailedPrecondition, "Admins cannot disable themselves")
+	}
+	return as.setDisabled(ctx, req.Id, true, audit.AdminDisabled)
+}
+
+// EnableAdmin enables a disabled admin.
+func (as *AdminService) EnableAdmin(ctx context.Context, req *pb.AdminID) (*pb.Admin, error) {
+	return as.setDisabled(ctx, req.Id, false, audit.AdminEnabled)
+}
+
+func (as *AdminService) setDisabled(ctx context.Context, id int64, disabled bool, action string) (*pb.Admin, error) {
+	admin, err := as.store.SetAdminDisabled(ctx, id, disabled, adminEntry(ctx, action, id, nil))
+	if err != nil {
+		return nil, adminError(err)
+	}
+	log.Printf("Admin %s %s by %s", admin.Username, strings.TrimPrefix(action, "admin."), audit.Actor(ctx))
+	return toAdminMessage(admin, time.Now()), nil
+}
+
+// SetAdminRoles replaces the roles of an admin. Admins cannot change their
+// own roles, so the last superadmin cannot demote itself by accident.
+func (as *AdminService) SetAdminRoles(ctx context.Context, req *pb.SetAdminRolesRequest) (*pb.Admin, error) {
+	if err := validateRoles(req.Roles); err != nil {
+		return nil, err
+	}
+	if caller, ok := auth.AdminFromContext(ctx); ok && caller.ID == req.Id {
+		return nil, status.Errorf(codes.FailedPrecondition, "Admins cannot change their own roles")
+	}
+
+	entry := adminEntry(ctx, audit.AdminRolesChanged, req.Id, map[string]interface{}{"roles": req.Roles})
+	admin, err := as.store.SetAdminRoles(ctx, req.Id, req.Roles, entry)
+	if err != nil {
+		return nil, adminError(err)
+	}
+	log.Printf("Roles of admin %s set to %s by %s", admin.Username, strings.Join(admin.Roles, ","), entry.Actor)
+	return toAdminMessage(admin, time.Now()), nil
+}
+
+// ResetAdminCredentials replaces the password and TOTP secret of an admin,
+// e.g. after a lost phone, and lifts a lockout. Existing tokens of the admin
+// stop working.
+func (as *AdminService) ResetAdminCredentials(ctx context.Context, req *pb.ResetAdminCredentialsRequest) (*pb.AdminCredentials, error) {
+	creds, hash, err := newAdminCredentials(as.cfg)
+	if err != nil {
+		log.Printf("Error generating admin credentials: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	entry := adminEntry(ctx, audit.AdminCredentialsReset, req.Id, nil)
+	entry.Reason = strings.TrimSpace(req.Reason)
+	admin, err := as.store.ResetAdminCredentials(ctx, req.Id, hash, creds.TotpSecret, time.Now(), entry)
+	if err != nil {
+		return nil, adminError(err)
+	}
+
+	log.Printf("Credentials of admin %s reset by %s", admin.Username, entry.Actor)
+	creds.Admin = toAdminMessage(admin, time.Now())
+	creds.TotpUrl = totp.URL(as.cfg.AdminTOTPIssuer, admin.Username, creds.TotpSecret)
+	return creds, nil
+}
+
+// BootstrapAdmin creates the first admin, a superadmin, from the command
+// line. It fails once any admin exists.
+func BootstrapAdmin(ctx context.Context, cfg *config.Config, users store.Store, username string) (*pb.AdminCredentials, error) {
+	username = strings.ToLower(strings.TrimSpace(username))
+	if !usernamePattern.MatchString(username) {
+		return nil, fmt.Errorf("username must be 3 to 64 lowercase letters, digits, '.', '_' or '-'")
+	}
+	admins, err := users.ListAdmins(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(admins) > 0 {
+		return nil, fmt.Errorf("admins already exist, create further admins with CreateAdmin")
+	}
+
+	creds, err := createAdmin(audit.WithActor(ctx, "cli:bootstrap"), cfg, users, username, []string{auth.RoleSuperadmin})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create admin: %v", err)
+	}
+	return creds, nil
+}
+
+func createAdmin(ctx context.Context, cfg *config.Config, users store.Store, username string, roles []string) (*pb.AdminCredentials, error) {
+	creds, hash, err := newAdminCredentials(cfg)
+	if err != nil {
+		log.Printf("Error generating admin credentials: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	entry := adminEntry(ctx, audit.AdminCreated, 0, map[string]interface{}{"username": username, "roles": roles})
+	admin, err := users.CreateAdmin(ctx, store.Admin{Username: username, Roles: roles, PasswordHash: hash, TOTPSecret: creds.TotpSecret}, entry)
+	if err != nil {
+		if err == store.ErrAlreadyExists {
+			return nil, status.Errorf(codes.AlreadyExists, "Admin with this username already exists")
+		}
+		log.Printf("Error creating admin: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("Admin %s created by %s", admin.Username, entry.Actor)
+	creds.Admin = toAdminMessage(admin, time.Now())
+	creds.TotpUrl = totp.URL(cfg.AdminTOTPIssuer, admin.Username, creds.TotpSecret)
+	return creds, nil
+}
+
+// newAdminCredentials generates a password and TOTP secret and returns them
+// with the hash of the password.
+func newAdminCredentials(cfg *config.Config) (*pb.AdminCredentials, string, error) {
+	pass, err := password.Generate()
+	if err != nil {
+		return nil, "", err
+	}
+	hash, err := password.Hash(pass)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, "", err
+	}
+	return &pb.AdminCredentials{Password: pass, TotpSecret: secret}, hash, nil
+}
+
+func validateRoles(roles []string) error {
+	if len(roles) == 0 {
+		return invalidField("roles", "At least one role is required")
+	}
+	for _, role := range roles {
+		if !auth.ValidRole(role) {
+			return invalidField("roles", fmt.Sprintf("Unknown role %q, expected one of %s", role, strings.Join(auth.Roles(), ", ")))
+		}
+	}
+	return nil
+}
+
+// adminEntry returns the audit entry of an action on an admin.
+func adminEntry(ctx context.Context, action string, adminID int64, details map[string]interface{}) audit.Entry {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	if adminID != 0 {
+		details["admin_id"] = adminID
+	}
+	return audit.Entry{Actor: audit.Actor(ctx), Action: action, Details: details}
+}
+
+func adminError(err error) error {
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "Admin not found")
+	}
+	log.Printf("Error updating admin: %v", err)
+	return status.Errorf(codes.Internal, "Internal server error")
+}
+
+func toAdminMessage(admin *store.Admin, now time.Time) *pb.Admin {
+	msg := &pb.Admin{
+		Id:         admin.ID,
+		Username:   admin.Username,
+		Roles:      admin.Roles,
+		Disabled:   admin.Disabled,
+		CreateTime: apitime.Timestamp(admin.CreatedAt),
+	}
+	if now.Before(admin.LockedUntil) {
+		msg.LockExpireTime = apitime.Timestamp(admin.LockedUntil)
+	}
+	if !admin.LastLoginAt.IsZero() {
+		msg.LastLoginTime = apitime.Timestamp(admin.LastLoginAt)
+	}
+	return msg
+}
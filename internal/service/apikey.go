@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// apiKeyPrefixLength is the number of characters of a key kept to tell keys
+// apart, including auth.APIKeyPrefix.
+const apiKeyPrefixLength = 12
+
+// APIKeyService manages the API keys backend services authenticate with.
+type APIKeyService struct {
+	store store.Store
+	pb.UnimplementedApiKeyServiceServer
+}
+
+// NewAPIKeyService creates a new instance of APIKeyService.
+func NewAPIKeyService(store store.Store) pb.ApiKeyServiceServer {
+	return &APIKeyService{store: store}
+}
+
+// CreateApiKey creates an API key with the given scopes. The key is only
+// returned in the response.
+func (ks *APIKeyService) CreateApiKey(ctx context.Context, req *pb.CreateApiKeyRequest) (*pb.ApiKeySecret, error) {
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if !usernamePattern.MatchString(name) {
+		return nil, invalidField("name", "Name must be 3 to 64 lowercase letters, digits, '.', '_' or '-'")
+	}
+	if err := validateScopes(req.Scopes); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var expiresAt time.Time
+	if req.ExpireTime != nil {
+		if err := req.ExpireTime.CheckValid(); err != nil {
+			return nil, invalidField("expire_time", "Invalid expire time")
+		}
+		expiresAt = req.ExpireTime.AsTime()
+		if !expiresAt.After(now) {
+			return nil, invalidField("expire_time", "Expire time must be in the future")
+		}
+	}
+
+	secret, hash, err := newAPIKey()
+	if err != nil {
+		log.Printf("Error generating API key: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	entry := apiKeyEntry(ctx, audit.APIKeyCreated, 0, map[string]interface{}{"name": name, "scopes": req.Scopes})
+	key, err := ks.store.CreateAPIKey(ctx, store.APIKey{
+		Name:      name,
+		Prefix:    secret[:apiKeyPrefixLength],
+		Scopes:    req.Scopes,
+		ExpiresAt: expiresAt,
+		CreatedBy: entry.Actor,
+	}, hash, entry)
+	if err != nil {
+		if err == store.ErrAlreadyExists {
+			return nil, status.Errorf(codes.AlreadyExists, "API key with this name already exists")
+		}
+		log.Printf("Error creating API key: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("API key %s created by %s", key.Name, entry.Actor)
+	return &pb.ApiKeySecret{ApiKey: toAPIKeyMessage(key), Key: secret}, nil
+}
+
+// ListApiKeys returns all API keys ordered by name, including revoked and
+// expired keys.
+func (ks *APIKeyService) ListApiKeys(ctx context.Context, req *pb.Empty) (*pb.ApiKeysList, error) {
+	keys, err := ks.store.ListAPIKeys(ctx)
+	if err != nil {
+		log.Printf("Error listing API keys: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	resp := &pb.ApiKeysList{}
+	for _, key := range keys {
+		resp.ApiKeys = append(resp.ApiKeys, toAPIKeyMessage(key))
+	}
+	return resp, nil
+}
+
+// RotateApiKey replaces the secret of an API key, keeping its name, scopes
+// and expiry. The previous secret stops working at once.
+func (ks *APIKeyService) RotateApiKey(ctx context.Context, req *pb.ApiKeyID) (*pb.ApiKeySecret, error) {
+	key, err := ks.store.GetAPIKey(ctx, req.Id)
+	if err != nil {
+		return nil, apiKeyError(err)
+	}
+	if !key.RevokedAt.IsZero() {
+		return nil, status.Errorf(codes.FailedPrecondition, "API key has been revoked")
+	}
+	now := time.Now()
+	if !key.ExpiresAt.IsZero() && !now.Before(key.ExpiresAt) {
+		return nil, status.Errorf(codes.FailedPrecondition, "API key has expired")
+	}
+
+	secret, hash, err := newAPIKey()
+	if err != nil {
+		log.Printf("Error generating API key: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	entry := apiKeyEntry(ctx, audit.APIKeyRotated, key.ID, nil)
+	key, err = ks.store.RotateAPIKey(ctx, key.ID, secret[:apiKeyPrefixLength], hash, now, entry)
+	if err != nil {
+		return nil, apiKeyError(err)
+	}
+
+	log.Printf("API key %s rotated by %s", key.Name, entry.Actor)
+	return &pb.ApiKeySecret{ApiKey: toAPIKeyMessage(key), Key: secret}, nil
+}
+
+// RevokeApiKey revokes an API key for good. Revoking a revoked key returns it
+// unchanged.
+func (ks *APIKeyService) RevokeApiKey(ctx context.Context, req *pb.RevokeApiKeyRequest) (*pb.ApiKey, error) {
+	key, err := ks.store.GetAPIKey(ctx, req.Id)
+	if err != nil {
+		return nil, apiKeyError(err)
+	}
+	if !key.RevokedAt.IsZero() {
+		return toAPIKeyMessage(key), nil
+	}
+
+	entry := apiKeyEntry(ctx, audit.APIKeyRevoked, key.ID, nil)
+	entry.Reason = strings.TrimSpace(req.Reason)
+	key, err = ks.store.RevokeAPIKey(ctx, key.ID, time.Now(), entry)
+	if err != nil {
+		return nil, apiKeyError(err)
+	}
+
+	log.Printf("API key %s revoked by %s", key.Name, entry.Actor)
+	return toAPIKeyMessage(key), nil
+}
+
+// newAPIKey returns a random API key and the hash stored in its place.
+func newAPIKey() (string, []byte, error) {
+	token, _, err := newToken()
+	if err != nil {
+		return "", nil, err
+	}
+	key := auth.APIKeyPrefix + token
+	return key, auth.HashAPIKey(key), nil
+}
+
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return invalidField("scopes", "At least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !auth.ValidScope(scope) {
+			return invalidField("scopes", fmt.Sprintf("Unknown scope %q, expected one of %s", scope, strings.Join(auth.Scopes(), ", ")))
+		}
+	}
+	return nil
+}
+
+// apiKeyEntry returns the audit entry of an action on an API key.
+func apiKeyEntry(ctx context.Context, action string, keyID int64, details map[string]interface{}) audit.Entry {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	if keyID != 0 {
+		details["api_key_id"] = keyID
+	}
+	return audit.Entry{Actor: audit.Actor(ctx), Action: action, Details: details}
+}
+
+func apiKeyError(err error) error {
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "API key not found")
+	}
+	log.Printf("Error updating API key: %v", err)
+	return status.Errorf(codes.Internal, "Internal server error")
+}
+
+func toAPIKeyMessage(key *store.APIKey) *pb.ApiKey {
+	msg := &pb.ApiKey{
+		Id:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		Scopes:     key.Scopes,
+		CreateTime: apitime.Timestamp(key.CreatedAt),
+		CreatedBy:  key.CreatedBy,
+	}
+	if !key.ExpiresAt.IsZero() {
+		msg.ExpireTime = apitime.Timestamp(key.ExpiresAt)
+	}
+	if !key.LastUsedAt.IsZero() {
+		msg.LastUseTime = apitime.Timestamp(key.LastUsedAt)
+	}
+	if !key.RotatedAt.IsZero() {
+		msg.RotateTime = apitime.Timestamp(key.RotatedAt)
+	}
+	if !key.RevokedAt.IsZero() {
+		msg.RevokeTime = apitime.Timestamp(key.RevokedAt)
+	}
+	return msg
+}
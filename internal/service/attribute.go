@@ -0,0 +1,325 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxAttributeValueLength  = 500
+	maxAllowedValues         = 100
+	maxAttributeDescLength   = 500
+	attributeDateFormat      = "2006-01-02"
+	attributeNameDescription = "Name must start with a lowercase letter followed by up to 49 lowercase letters, digits or '_'"
+)
+
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// attributeTypes maps the types of the API to the stored ones.
+var attributeTypes = map[pb.AttributeType]string{
+	pb.AttributeType_ATTRIBUTE_TYPE_STRING:  store.AttributeString,
+	pb.AttributeType_ATTRIBUTE_TYPE_INTEGER: store.AttributeInteger,
+	pb.AttributeType_ATTRIBUTE_TYPE_NUMBER:  store.AttributeNumber,
+	pb.AttributeType_ATTRIBUTE_TYPE_BOOLEAN: store.AttributeBoolean,
+	pb.AttributeType_ATTRIBUTE_TYPE_DATE:    store.AttributeDate,
+}
+
+// AttributeService manages the schema of the custom attributes of users.
+type AttributeService struct {
+	store store.Store
+	pb.UnimplementedAttributeServiceServer
+}
+
+// NewAttributeService creates a new instance of AttributeService.
+func NewAttributeService(store store.Store) pb.AttributeServiceServer {
+	return &AttributeService{store: store}
+}
+
+// DefineAttribute defines a custom attribute or replaces the definition of an
+// existing one. The type of an attribute cannot be changed, and values users
+// already have are not checked against the new definition.
+func (as *AttributeService) DefineAttribute(ctx context.Context, req *pb.AttributeDefinition) (*pb.AttributeDefinition, error) {
+	name := strings.TrimSpace(req.Name)
+	if !attributeNamePattern.MatchString(name) {
+		return nil, invalidField("name", attributeNameDescription)
+	}
+	attributeType, ok := attributeTypes[req.Type]
+	if !ok {
+		return nil, invalidField("type", "Type is required")
+	}
+	allowed, err := allowedValues(attributeType, req.AllowedValues)
+	if err != nil {
+		return nil, err
+	}
+	description := strings.TrimSpace(req.Description)
+	if utf8.RuneCountInString(description) > maxAttributeDescLength {
+		return nil, invalidField("description", fmt.Sprintf("Description must be at most %d characters", maxAttributeDescLength))
+	}
+
+	defs, err := as.store.ListAttributeDefinitions(ctx)
+	if err != nil {
+		log.Printf("Error listing attribute definitions: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	// Stored values would no longer match a changed type
+	for _, def := range defs {
+		if def.Name == name && def.Type != attributeType {
+			return nil, status.Errorf(codes.FailedPrecondition, "Type of attribute %s cannot be changed from %s, delete and define it again", name, def.Type)
+		}
+	}
+
+	entry := audit.Entry{
+		Actor:  audit.Actor(ctx),
+		Action: audit.AttributeDefined,
+		Details: map[string]interface{}{
+			"name":           name,
+			"type":           attributeType,
+			"allowed_values": allowed,
+			"required":       req.Required,
+			"pii":            req.Pii,
+		},
+	}
+	def, err := as.store.PutAttributeDefinition(ctx, store.AttributeDefinition{
+		Name:          name,
+		Type:          attributeType,
+		AllowedValues: allowed,
+		Required:      req.Required,
+		PII:           req.Pii,
+		Description:   description,
+		CreatedBy:     entry.Actor,
+	}, entry)
+	if err != nil {
+		log.Printf("Error defining attribute: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("Attribute %s defined by %s", def.Name, entry.Actor)
+	return toAttributeMessage(def), nil
+}
+
+// ListAttributes returns all attribute definitions ordered by name.
+func (as *AttributeService) ListAttributes(ctx context.Context, req *pb.Empty) (*pb.AttributeDefinitionsList, error) {
+	defs, err := as.store.ListAttributeDefinitions(ctx)
+	if err != nil {
+		log.Printf("Error listing attribute definitions: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	resp := &pb.AttributeDefinitionsList{}
+	for _, def := range defs {
+		resp.Attributes = append(resp.Attributes, toAttributeMessage(def))
+	}
+	return resp, nil
+}
+
+// DeleteAttribute deletes an attribute definition. Users keep their values,
+// which can still be removed with UpdateUser but no longer be set.
+func (as *AttributeService) DeleteAttribute(ctx context.Context, req *pb.AttributeName) (*pb.Empty, error) {
+	name := strings.TrimSpace(req.Name)
+	entry := audit.Entry{Actor: audit.Actor(ctx), Action: audit.AttributeDeleted, Details: map[string]string{"name": name}}
+	if err := as.store.DeleteAttributeDefinition(ctx, name, entry); err != nil {
+		if err == store.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "Attribute not found")
+		}
+		log.Printf("Error deleting attribute: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("Attribute %s deleted by %s", name, entry.Actor)
+	return &pb.Empty{}, nil
+}
+
+// customAttributes checks the attribute values of CreateUser or UpdateUser
+// against the definitions and returns them in canonical form. On create,
+// required attributes must be given and "null" values are dropped. On
+// update, "null" removes an attribute unless it is required.
+func (us *UserService) customAttributes(ctx context.Context, values map[string]string, create bool) (map[string]string, error) {
+	if len(values) == 0 && !create {
+		return nil, nil
+	}
+	defs, err := us.attributeDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(values))
+	for name, value := range values {
+		field := "custom_attributes." + name
+		def, ok := defs[name]
+		if value == "null" {
+			if ok && def.Required {
+				return nil, invalidField(field, fmt.Sprintf("Attribute %s is required", name))
+			}
+			if !create {
+				result[name] = value
+			}
+			continue
+		}
+		if !ok {
+			return nil, invalidField(field, fmt.Sprintf("Unknown attribute %q", name))
+		}
+		if result[name], err = attributeValue(def, field, value); err != nil {
+			return nil, err
+		}
+	}
+	if create {
+		for name, def := range defs {
+			if _, ok := result[name]; def.Required && !ok {
+				return nil, invalidField("custom_attributes."+name, fmt.Sprintf("Attribute %s is required", name))
+			}
+		}
+	}
+	if len(result) == 0 {
+		return nil, nil
+	}
+	return result, nil
+}
+
+// attributeFilter checks the custom attribute values of a user filter and
+// returns them in canonical form, so they compare equal to stored values.
+func (us *UserService) attributeFilter(ctx context.Context, values map[string]string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	defs, err := us.attributeDefinitions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(values))
+	for name, value := range values {
+		field := "filter.custom_attributes." + name
+		def, ok := defs[name]
+		if !ok {
+			return nil, invalidField(field, fmt.Sprintf("Unknown attribute %q", name))
+		}
+		if result[name], err = attributeValue(def, field, value); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// attributeDefinitions returns the attribute definitions by name.
+func (us *UserService) attributeDefinitions(ctx context.Context) (map[string]*store.AttributeDefinition, error) {
+	defs, err := us.store.ListAttributeDefinitions(ctx)
+	if err != nil {
+		log.Printf("Error listing attribute definitions: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	byName := make(map[string]*store.AttributeDefinition, len(defs))
+	for _, def := range defs {
+		byName[def.Name] = def
+	}
+	return byName, nil
+}
+
+// attributeValue checks a value against the type and allowed values of the
+// attribute and returns its canonical form, e.g. "7" for "007".
+func attributeValue(def *store.AttributeDefinition, field, value string) (string, error) {
+	value = strings.TrimSpace(value)
+	switch def.Type {
+	case store.AttributeString:
+		if value == "" {
+			return "", invalidField(field, "Value must not be empty")
+		}
+		if utf8.RuneCountInString(value) > maxAttributeValueLength {
+			return "", invalidField(field, fmt.Sprintf("Value must be at most %d characters", maxAttributeValueLength))
+		}
+		if len(def.AllowedValues) > 0 && !containsString(def.AllowedValues, value) {
+			return "", invalidField(field, fmt.Sprintf("Value must be one of %s", strings.Join(def.AllowedValues, ", ")))
+		}
+		return value, nil
+	case store.AttributeInteger:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return "", invalidField(field, "Value must be an integer")
+		}
+		return strconv.FormatInt(n, 10), nil
+	case store.AttributeNumber:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", invalidField(field, "Value must be a number")
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	case store.AttributeBoolean:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return "", invalidField(field, "Value must be true or false")
+		}
+		return strconv.FormatBool(b), nil
+	case store.AttributeDate:
+		d, err := time.Parse(attributeDateFormat, value)
+		if err != nil {
+			return "", invalidField(field, "Value must be a date in the form YYYY-MM-DD")
+		}
+		return d.Format(attributeDateFormat), nil
+	}
+	return "", invalidField(field, fmt.Sprintf("Attribute %s has unknown type %q", def.Name, def.Type))
+}
+
+// allowedValues checks and deduplicates the allowed values of a definition.
+func allowedValues(attributeType string, values []string) ([]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	if attributeType != store.AttributeString {
+		return nil, invalidField("allowed_values", "Allowed values are only supported for string attributes")
+	}
+	if len(values) > maxAllowedValues {
+		return nil, invalidField("allowed_values", fmt.Sprintf("At most %d allowed values are supported", maxAllowedValues))
+	}
+	var allowed []string
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || utf8.RuneCountInString(value) > maxAttributeValueLength {
+			return nil, invalidField("allowed_values", fmt.Sprintf("Allowed values must be 1 to %d characters", maxAttributeValueLength))
+		}
+		if !containsString(allowed, value) {
+			allowed = append(allowed, value)
+		}
+	}
+	return allowed, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func toAttributeMessage(def *store.AttributeDefinition) *pb.AttributeDefinition {
+	msg := &pb.AttributeDefinition{
+		Name:          def.Name,
+		AllowedValues: def.AllowedValues,
+		Required:      def.Required,
+		Pii:           def.PII,
+		Description:   def.Description,
+		CreatedBy:     def.CreatedBy,
+		CreateTime:    apitime.Timestamp(def.CreatedAt),
+		UpdateTime:    apitime.Timestamp(def.UpdatedAt),
+	}
+	for apiType, storedType := range attributeTypes {
+		if storedType == def.Type {
+			msg.Type = apiType
+		}
+	}
+	return msg
+}
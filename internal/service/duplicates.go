@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultDuplicateScore = 0.5
+	defaultDuplicateLimit = 50
+	maxDuplicateLimit     = 200
+	// duplicateScanLimit bounds the candidate pairs scored per request, the
+	// most recently registered duplicates first.
+	duplicateScanLimit = 5000
+	// similarNameRatio is the share of a name that must match for names
+	// with typos or transliterations to count as similar.
+	similarNameRatio = 0.8
+)
+
+// duplicateSignals are the weights of what two users have in common. They
+// are combined as independent evidence, so no signal alone reaches 1.
+var duplicateSignals = map[string]float64{
+	"same_email":      0.9,
+	"same_name":       0.4,
+	"similar_name":    0.3,
+	"same_first_name": 0.1,
+	"same_last_name":  0.15,
+	"same_birth_date": 0.3,
+	"same_location":   0.1,
+}
+
+// duplicatePenalties halve the score of users that differ where the same
+// person would not.
+var duplicatePenalties = map[string]float64{
+	"different_birth_date": 0.5,
+	"different_gender":     0.5,
+}
+
+// FindDuplicateUsers proposes pairs of users that are likely the same person,
+// e.g. after a customer registered again with a new phone number. Users are
+// paired by a shared email, first and last name, or birth date, and scored by
+// everything they have in common, so similar names with the same birth date
+// are found as well.
+func (us *UserService) FindDuplicateUsers(ctx context.Context, req *pb.FindDuplicateUsersRequest) (*pb.DuplicateUsersList, error) {
+	minScore := req.MinScore
+	if minScore < 0 || minScore > 1 || math.IsNaN(minScore) {
+		return nil, invalidField("min_score", "Minimum score must be between 0 and 1")
+	}
+	if minScore == 0 {
+		minScore = defaultDuplicateScore
+	}
+	limit := req.Limit
+	if limit < 0 || limit > maxDuplicateLimit {
+		return nil, invalidField("limit", "Limit must be between 1 and 200")
+	}
+	if limit == 0 {
+		limit = defaultDuplicateLimit
+	}
+	id, err := resolveUserID(ctx, us.store, req.UserId, req.UserPublicId, "user_public_id")
+	if err != nil {
+		return nil, err
+	}
+	if id != 0 {
+		if _, err := lookupResult(us.store.GetUser(ctx, id)); err != nil {
+			return nil, err
+		}
+	}
+
+	candidates, err := us.store.FindDuplicateCandidates(ctx, id, duplicateScanLimit)
+	if err != nil {
+		log.Printf("Error finding duplicate users: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	resp := &pb.DuplicateUsersList{}
+	for _, c := range candidates {
+		score, reasons := duplicateScore(c.User, c.Duplicate)
+		if score < minScore {
+			continue
+		}
+		resp.Duplicates = append(resp.Duplicates, &pb.DuplicateUsers{
+			User:      c.User,
+			Duplicate: c.Duplicate,
+			Score:     score,
+			Reasons:   reasons,
+		})
+	}
+	sort.SliceStable(resp.Duplicates, func(i, j int) bool { return resp.Duplicates[i].Score > resp.Duplicates[j].Score })
+	if len(resp.Duplicates) > int(limit) {
+		resp.Duplicates = resp.Duplicates[:limit]
+	}
+	return resp, nil
+}
+
+// duplicateScore rates how likely two users are the same person, rounded to
+// two decimals, and lists the reasons in the order they were found.
+func duplicateScore(a, b *pb.GetUserResponse) (float64, []string) {
+	var reasons []string
+	if a.Email != "" && strings.EqualFold(a.Email, b.Email) {
+		reasons = append(reasons, "same_email")
+	}
+
+	firstA, firstB := normalizeName(a.FirstName), normalizeName(b.FirstName)
+	lastA, lastB := normalizeName(a.LastName), normalizeName(b.LastName)
+	fullA, fullB := strings.TrimSpace(firstA+" "+lastA), strings.TrimSpace(firstB+" "+lastB)
+	switch {
+	case fullA == "" || fullB == "":
+	case fullA == fullB, firstA != "" && lastA != "" && firstA == lastB && lastA == firstB:
+		reasons = append(reasons, "same_name")
+	case nameSimilarity(fullA, fullB) >= similarNameRatio:
+		reasons = append(reasons, "similar_name")
+	case firstA != "" && firstA == firstB:
+		reasons = append(reasons, "same_first_name")
+	case lastA != "" && lastA == lastB:
+		reasons = append(reasons, "same_last_name")
+	}
+
+	if a.DateOfBirth != nil && b.DateOfBirth != nil {
+		if proto.Equal(a.DateOfBirth, b.DateOfBirth) {
+			reasons = append(reasons, "same_birth_date")
+		} else {
+			reasons = append(reasons, "different_birth_date")
+		}
+	}
+	if location := normalizeName(a.Location); location != "" && location == normalizeName(b.Location) {
+		reasons = append(reasons, "same_location")
+	}
+	if genderA, genderB := normalizeName(a.Gender), normalizeName(b.Gender); genderA != "" && genderB != "" && genderA != genderB {
+		reasons = append(reasons, "different_gender")
+	}
+
+	unlikely := 1.0
+	for _, reason := range reasons {
+		unlikely *= 1 - duplicateSignals[reason]
+	}
+	score := 1 - unlikely
+	for _, reason := range reasons {
+		if penalty, ok := duplicatePenalties[reason]; ok {
+			score *= penalty
+		}
+	}
+	return math.Round(score*100) / 100, reasons
+}
+
+// normalizeName lowercases a name and collapses its whitespace.
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), " ")
+}
+
+// nameSimilarity is the share of the longer name left unchanged by the
+// edit distance between the names.
+func nameSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// editDistance is the Levenshtein distance of two strings.
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
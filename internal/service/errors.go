@@ -0,0 +1,22 @@
+package service
+
+import (
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// invalidField returns an InvalidArgument status carrying a BadRequest detail
+// for the given field, so clients can tell which field was rejected.
+func invalidField(field, description string) error {
+	st := status.New(codes.InvalidArgument, description)
+	detailed, err := st.WithDetails(&errdetails.BadRequest{
+		FieldViolations: []*errdetails.BadRequest_FieldViolation{
+			{Field: field, Description: description},
+		},
+	})
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"log"
+	"net/mail"
+	"strings"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (us *UserService) GetUserByPhone(ctx context.Context, req *pb.GetUserByPhoneRequest) (*pb.GetUserResponse, error) {
+	// Numbers are stored in E.164 form, so national and formatted input matches too
+	phoneNumber, err := us.settings.normalizePhone(ctx, req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	return lookupResult(us.store.GetUserByPhone(ctx, phoneNumber))
+}
+
+func (us *UserService) GetUserByEmail(ctx context.Context, req *pb.GetUserByEmailRequest) (*pb.GetUserResponse, error) {
+	email, err := normalizeEmail(req.Email)
+	if err != nil {
+		return nil, err
+	}
+	return lookupResult(us.store.GetUserByEmail(ctx, email))
+}
+
+// LookupUser finds a user by whichever unique key is set.
+func (us *UserService) LookupUser(ctx context.Context, req *pb.LookupUserRequest) (*pb.GetUserResponse, error) {
+	switch key := req.Key.(type) {
+	case *pb.LookupUserRequest_Id:
+		return us.GetUserById(ctx, &pb.UserID{Id: key.Id})
+	case *pb.LookupUserRequest_PublicId:
+		return us.GetUserById(ctx, &pb.UserID{PublicId: key.PublicId})
+	case *pb.LookupUserRequest_PhoneNumber:
+		return us.GetUserByPhone(ctx, &pb.GetUserByPhoneRequest{PhoneNumber: key.PhoneNumber})
+	case *pb.LookupUserRequest_Email:
+		return us.GetUserByEmail(ctx, &pb.GetUserByEmailRequest{Email: key.Email})
+	}
+	return nil, invalidField("key", "One of id, phone_number, email or public_id is required")
+}
+
+// lookupResult maps store errors the same way GetUserById does.
+func lookupResult(user *pb.GetUserResponse, err error) (*pb.GetUserResponse, error) {
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "User not found")
+		}
+		log.Printf("Error looking up user: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	return user, nil
+}
+
+// normalizeEmail trims the address and rejects input that is not a plain
+// email address. Case is kept, matching ignores it.
+func normalizeEmail(input string) (string, error) {
+	email := strings.TrimSpace(input)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", invalidField("email", "Invalid email address")
+	}
+	return email, nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const attributeFieldPrefix = "custom_attributes."
+
+// mergeFields are the fields MergeUsers resolves, named like in the change
+// history.
+var mergeFields = []struct {
+	name string
+	// keep is set for fields that keep the value of the target unless the
+	// source is chosen, even if the target has none
+	keep  bool
+	value func(*pb.GetUserResponse) string
+	take  func(merged, source *pb.GetUserResponse)
+}{
+	{"first_name", false, func(u *pb.GetUserResponse) string { return u.FirstName },
+		func(m, s *pb.GetUserResponse) { m.FirstName = s.FirstName }},
+	{"last_name", false, func(u *pb.GetUserResponse) string { return u.LastName },
+		func(m, s *pb.GetUserResponse) { m.LastName = s.LastName }},
+	{"phone_number", true, func(u *pb.GetUserResponse) string { return u.PhoneNumber },
+		func(m, s *pb.GetUserResponse) { m.PhoneNumber, m.Country = s.PhoneNumber, s.Country }},
+	// The email stays verified if it was verified on the user it comes from
+	{"email", false, func(u *pb.GetUserResponse) string { return u.Email },
+		func(m, s *pb.GetUserResponse) { m.Email, m.EmailVerified = s.Email, s.EmailVerified }},
+	{"gender", false, func(u *pb.GetUserResponse) string { return u.Gender },
+		func(m, s *pb.GetUserResponse) { m.Gender = s.Gender }},
+	{"birth_date", false, func(u *pb.GetUserResponse) string { return formatDate(u.BirthDate) },
+		func(m, s *pb.GetUserResponse) { m.DateOfBirth, m.BirthDate = s.DateOfBirth, s.BirthDate }},
+	{"location", false, func(u *pb.GetUserResponse) string { return u.Location },
+		func(m, s *pb.GetUserResponse) { m.Location = s.Location }},
+	{"profile_photo_url", false, func(u *pb.GetUserResponse) string { return u.ProfilePhotoUrl },
+		func(m, s *pb.GetUserResponse) { m.ProfilePhotoUrl = s.ProfilePhotoUrl }},
+	{"blocked", true, func(u *pb.GetUserResponse) string { return strconv.FormatBool(u.Blocked) },
+		func(m, s *pb.GetUserResponse) { m.Blocked = s.Blocked }},
+}
+
+// MergeUsers merges a duplicate source user into the target. The target
+// gets the resolved fields and the tags, notes, active sessions and phone
+// history of the source. The source is then marked as merged into the
+// target: its row is kept, but it is served like a deleted user. The change
+// history of the source stays available under its ID.
+func (us *UserService) MergeUsers(ctx context.Context, req *pb.MergeUsersRequest) (*pb.UpdateUserResponse, error) {
+	sourceID, err := resolveUserID(ctx, us.store, req.SourceId, req.SourcePublicId, "source_public_id")
+	if err != nil {
+		return nil, err
+	}
+	targetID, err := resolveUserID(ctx, us.store, req.TargetId, req.TargetPublicId, "target_public_id")
+	if err != nil {
+		return nil, err
+	}
+	if sourceID == 0 {
+		return nil, invalidField("source_id", "Source user is required")
+	}
+	if targetID == 0 {
+		return nil, invalidField("target_id", "Target user is required")
+	}
+	if sourceID == targetID {
+		return nil, invalidField("target_id", "Source and target must be different users")
+	}
+	if err := validateMergeFields(req.Fields); err != nil {
+		return nil, err
+	}
+
+	source, err := lookupResult(us.store.GetUser(ctx, sourceID))
+	if err != nil {
+		return nil, err
+	}
+	target, err := lookupResult(us.store.GetUser(ctx, targetID))
+	if err != nil {
+		return nil, err
+	}
+	merged, taken := mergeUser(source, target, req.Fields)
+	// Values of deleted or changed definitions are rejected like in CreateUser
+	if merged.CustomAttributes, err = us.customAttributes(ctx, merged.CustomAttributes, true); err != nil {
+		return nil, err
+	}
+
+	entry := audit.Entry{
+		Actor:  audit.Actor(ctx),
+		Action: audit.UserMerged,
+		Reason: strings.TrimSpace(req.Reason),
+		Details: map[string]interface{}{
+			"source_id":        source.Id,
+			"source_public_id": source.PublicId,
+			"from_source":      taken,
+		},
+	}
+	policy, err := us.phonePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The store rejects the merge if either user changed since it was read,
+	// as merged would overwrite the change
+	user, err := us.store.MergeUsers(ctx, source, target, merged, policy, entry)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			return nil, status.Errorf(codes.NotFound, "User not found")
+		case store.ErrConflict:
+			return nil, status.Errorf(codes.Aborted, "User changed during the merge, please retry")
+		case store.ErrAlreadyExists:
+			return nil, status.Errorf(codes.AlreadyExists, "User with this phone number or email already exists")
+		case store.ErrInCoolDown:
+			return nil, status.Errorf(codes.FailedPrecondition, "Phone number was recently used by another account")
+		}
+		log.Printf("Error merging users: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("User with ID %d merged into user with ID %d by %s", sourceID, targetID, entry.Actor)
+	return user, nil
+}
+
+func validateMergeFields(fields map[string]pb.MergeResolution) error {
+	for name, resolution := range fields {
+		if _, ok := pb.MergeResolution_name[int32(resolution)]; !ok {
+			return invalidField("fields", fmt.Sprintf("Unknown resolution of %s", name))
+		}
+		if strings.HasPrefix(name, attributeFieldPrefix) && len(name) > len(attributeFieldPrefix) {
+			continue
+		}
+		known := false
+		for _, field := range mergeFields {
+			known = known || field.name == name
+		}
+		if !known {
+			return invalidField("fields", fmt.Sprintf("Unknown field %s", name))
+		}
+	}
+	return nil
+}
+
+// mergeUser resolves the fields of the merged user, starting from the
+// target. It also returns the names of the fields taken from the source,
+// sorted.
+func mergeUser(source, target *pb.GetUserResponse, fields map[string]pb.MergeResolution) (*pb.GetUserResponse, []string) {
+	merged := proto.Clone(target).(*pb.GetUserResponse)
+	taken := []string{}
+	fromSource := func(name string, keep, targetEmpty bool) bool {
+		switch fields[name] {
+		case pb.MergeResolution_MERGE_RESOLUTION_SOURCE:
+			return true
+		case pb.MergeResolution_MERGE_RESOLUTION_TARGET:
+			return false
+		}
+		return !keep && targetEmpty
+	}
+
+	for _, field := range mergeFields {
+		sourceValue, targetValue := field.value(source), field.value(target)
+		if sourceValue == targetValue || !fromSource(field.name, field.keep, targetValue == "") {
+			continue
+		}
+		field.take(merged, source)
+		taken = append(taken, field.name)
+	}
+	// The same email verified on the source stays verified
+	if strings.EqualFold(merged.Email, source.Email) && source.EmailVerified {
+		merged.EmailVerified = true
+	}
+
+	if merged.CustomAttributes == nil {
+		merged.CustomAttributes = make(map[string]string)
+	}
+	for name, value := range source.CustomAttributes {
+		current, ok := target.CustomAttributes[name]
+		if value == current || !fromSource(attributeFieldPrefix+name, false, !ok) {
+			continue
+		}
+		merged.CustomAttributes[name] = value
+		taken = append(taken, attributeFieldPrefix+name)
+	}
+	sort.Strings(taken)
+	return merged, taken
+}
@@ -0,0 +1,73 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/usertest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMergeUsersRetiresTheSource(t *testing.T) {
+	srv := usertest.New(t)
+	users := srv.Seed(t,
+		&pb.CreateUserRequest{FirstName: "Aman", LastName: "Amanow", PhoneNumber: "+99365000001", Email: "aman@example.com"},
+		&pb.CreateUserRequest{FirstName: "Aman", LastName: "Amanow", PhoneNumber: "+99365000002", Location: "Mary"},
+	)
+	source, target := users[0], users[1]
+	client := pb.NewUserServiceClient(srv.Conn)
+	ctx := context.Background()
+
+	merged, err := client.MergeUsers(ctx, &pb.MergeUsersRequest{
+		SourceId: source.Id,
+		TargetId: target.Id,
+		Fields:   map[string]pb.MergeResolution{"phone_number": pb.MergeResolution_MERGE_RESOLUTION_SOURCE},
+		Reason:   "registered twice",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.Id != target.Id || merged.PhoneNumber != source.PhoneNumber || merged.Email != source.Email || merged.Location != "Mary" {
+		t.Errorf("got merged user %v, want the target with the phone number and email of the source", merged)
+	}
+
+	if _, err := client.GetUserById(ctx, &pb.UserID{Id: source.Id}); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v for the source, want NotFound", err)
+	}
+	if _, err := client.GetUserById(ctx, &pb.UserID{PublicId: source.PublicId}); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v for the public ID of the source, want NotFound", err)
+	}
+	revisions, err := client.ListUserRevisions(ctx, &pb.ListUserRevisionsRequest{UserId: source.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions.Revisions) == 0 || revisions.Revisions[0].Action != "user.deleted" {
+		t.Errorf("got revisions %v of the source, want them to end with user.deleted", revisions.Revisions)
+	}
+}
+
+func TestFindDuplicateUsersMatchesSimilarNamesBornTheSameDay(t *testing.T) {
+	birthday := &pb.DateOfBirth{Year: 1990, Month: 5, Day: 17}
+	srv := usertest.New(t)
+	users := srv.Seed(t,
+		&pb.CreateUserRequest{FirstName: "John", LastName: "Smith", PhoneNumber: "+99365000001", DateOfBirth: birthday},
+		&pb.CreateUserRequest{FirstName: "Jon", LastName: "Smyth", PhoneNumber: "+99365000002", DateOfBirth: birthday},
+		&pb.CreateUserRequest{FirstName: "Merdan", LastName: "Orazow", PhoneNumber: "+99365000003", DateOfBirth: birthday},
+	)
+	client := pb.NewUserServiceClient(srv.Conn)
+
+	resp, err := client.FindDuplicateUsers(context.Background(), &pb.FindDuplicateUsersRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Duplicates) != 1 {
+		t.Fatalf("got %d pairs, want 1: %v", len(resp.Duplicates), resp.Duplicates)
+	}
+	pair := resp.Duplicates[0]
+	ids := map[int64]bool{pair.User.Id: true, pair.Duplicate.Id: true}
+	if !ids[users[0].Id] || !ids[users[1].Id] {
+		t.Errorf("got pair %d and %d, want %d and %d", pair.User.Id, pair.Duplicate.Id, users[0].Id, users[1].Id)
+	}
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// StartPhoneChange sends a one-time code to the new phone number. The number
+// is only changed once the code is confirmed with ConfirmPhoneChange.
+func (us *UserService) StartPhoneChange(ctx context.Context, req *pb.StartPhoneChangeRequest) (*pb.StartPhoneChangeResponse, error) {
+	if us.sms == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "SMS delivery is not configured")
+	}
+
+	settings, err := us.settings.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	phoneNumber, err := normalizePhone(settings.phones, req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.UserId, err = resolveUserID(ctx, us.store, req.UserId, req.UserPublicId, "user_public_id"); err != nil {
+		return nil, err
+	}
+
+	user, err := us.store.GetUser(ctx, req.UserId)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "User not found")
+		}
+		log.Printf("Error fetching user by ID: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	if user.PhoneNumber == phoneNumber {
+		return nil, invalidField("phone_number", "Phone number is already the user's phone number")
+	}
+
+	now := time.Now()
+	if err := us.store.CheckPhoneAvailable(ctx, phoneNumber, user.Id, now.Add(-us.cfg.PhoneReuseCoolDown)); err != nil {
+		return nil, phoneChangeError(err)
+	}
+
+	code, err := newCode()
+	if err != nil {
+		log.Printf("Error generating phone change code: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	change := store.PhoneChange{
+		UserID:      user.Id,
+		PhoneNumber: phoneNumber,
+		CodeHash:    hashCode(user.Id, code),
+		ExpiresAt:   now.Add(settings.otpTTL),
+	}
+	if err := us.store.StartPhoneChange(ctx, change); err != nil {
+		if err == store.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "User not found")
+		}
+		log.Printf("Error storing phone change: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	text := fmt.Sprintf("Your confirmation code is %s. It expires in %s.", code, settings.otpTTL)
+	if err := us.sms.Send(ctx, phoneNumber, text); err != nil {
+		log.Printf("Error sending phone change code to user %d: %v", user.Id, err)
+		return nil, status.Errorf(codes.Unavailable, "Failed to send confirmation code")
+	}
+
+	log.Printf("Phone change started for user with ID %d", user.Id)
+	return &pb.StartPhoneChangeResponse{
+		PhoneNumber: phoneNumber,
+		ExpiresAt:   apitime.CustomTimestamp(change.ExpiresAt),
+		ExpireTime:  apitime.Timestamp(change.ExpiresAt),
+	}, nil
+}
+
+// ConfirmPhoneChange applies the pending phone change of a user once the code
+// sent to the new number is confirmed.
+func (us *UserService) ConfirmPhoneChange(ctx context.Context, req *pb.ConfirmPhoneChangeRequest) (*pb.UpdateUserResponse, error) {
+	if req.Code == "" {
+		return nil, invalidField("code", "Code is required")
+	}
+	var err error
+	if req.UserId, err = resolveUserID(ctx, us.store, req.UserId, req.UserPublicId, "user_public_id"); err != nil {
+		return nil, err
+	}
+
+	entry := audit.Entry{Actor: audit.Actor(ctx), Action: audit.PhoneChanged}
+	policy, err := us.phonePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := us.store.ConfirmPhoneChange(ctx, req.UserId, hashCode(req.UserId, req.Code), policy, entry)
+	if err != nil {
+		return nil, phoneChangeError(err)
+	}
+
+	log.Printf("Phone number of user with ID %d changed", user.Id)
+	return user, nil
+}
+
+// OverridePhoneNumber changes the phone number without verification, for
+// support cases where the user cannot receive the code. A reason is required
+// and recorded in the audit log together with the caller.
+func (us *UserService) OverridePhoneNumber(ctx context.Context, req *pb.OverridePhoneNumberRequest) (*pb.UpdateUserResponse, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, invalidField("reason", "Reason is required")
+	}
+
+	phoneNumber, err := us.settings.normalizePhone(ctx, req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+	if req.UserId, err = resolveUserID(ctx, us.store, req.UserId, req.UserPublicId, "user_public_id"); err != nil {
+		return nil, err
+	}
+
+	entry := audit.Entry{Actor: audit.Actor(ctx), Action: audit.PhoneOverridden, Reason: reason}
+	policy, err := us.phonePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := us.store.ChangePhoneNumber(ctx, req.UserId, phoneNumber, policy, entry)
+	if err != nil {
+		return nil, phoneChangeError(err)
+	}
+
+	log.Printf("Phone number of user with ID %d overridden by %s: %s", user.Id, entry.Actor, reason)
+	return user, nil
+}
+
+func (us *UserService) phonePolicy(ctx context.Context) (store.PhonePolicy, error) {
+	settings, err := us.settings.of(ctx)
+	if err != nil {
+		return store.PhonePolicy{}, err
+	}
+	now := time.Now()
+	return store.PhonePolicy{
+		MaxAttempts:   settings.otpMaxAttempts,
+		CoolDownSince: now.Add(-us.cfg.PhoneReuseCoolDown),
+		Now:           now,
+	}, nil
+}
+
+// phoneChangeError maps store errors of phone number changes to statuses.
+func phoneChangeError(err error) error {
+	switch err {
+	case store.ErrNotFound:
+		return status.Errorf(codes.NotFound, "User not found")
+	case store.ErrAlreadyExists:
+		return status.Errorf(codes.AlreadyExists, "User with this phone number already exists")
+	case store.ErrInCoolDown:
+		return status.Errorf(codes.FailedPrecondition, "Phone number was recently used by another account")
+	case store.ErrNoPendingChange:
+		return status.Errorf(codes.FailedPrecondition, "No phone change is pending")
+	case store.ErrExpired:
+		return status.Errorf(codes.FailedPrecondition, "Code has expired, start the phone change again")
+	case store.ErrTooManyAttempts:
+		return status.Errorf(codes.FailedPrecondition, "Too many failed attempts, start the phone change again")
+	case store.ErrCodeMismatch:
+		return invalidField("code", "Invalid code")
+	}
+	log.Printf("Error changing phone number: %v", err)
+	return status.Errorf(codes.Internal, "Internal server error")
+}
+
+// newCode returns a random six-digit one-time code.
+func newCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// hashCode hashes a one-time code together with the user ID, so equal codes
+// of different users are stored differently.
+func hashCode(userID int64, code string) []byte {
+	sum := sha256.Sum256([]byte(strconv.FormatInt(userID, 10) + ":" + code))
+	return sum[:]
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/photo"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/storage"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UploadProfilePhoto receives an image in chunks, verifies and re-encodes it, stores
+// the photo and its thumbnails and points the user's profile_photo_url at it.
+func (us *UserService) UploadProfilePhoto(stream pb.UserService_UploadProfilePhotoServer) error {
+	ctx := stream.Context()
+
+	if us.storage == nil {
+		return status.Errorf(codes.Unimplemented, "Photo storage is not configured")
+	}
+
+	// The first message must describe the upload
+	first, err := stream.Recv()
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Missing upload info")
+	}
+	info := first.GetInfo()
+	if info == nil || info.UserId <= 0 && info.UserPublicId == "" {
+		return status.Errorf(codes.InvalidArgument, "The first message must contain upload info with a user ID")
+	}
+	if info.UserId, err = resolveUserID(ctx, us.store, info.UserId, info.UserPublicId, "user_public_id"); err != nil {
+		return err
+	}
+	if info.ContentType != "" && info.ContentType != photo.JPEG && info.ContentType != photo.PNG && info.ContentType != photo.WebP {
+		return status.Errorf(codes.InvalidArgument, "Unsupported content type: %s", info.ContentType)
+	}
+
+	if _, err := us.store.GetUser(ctx, info.UserId); err != nil {
+		if err == store.ErrNotFound {
+			return status.Errorf(codes.NotFound, "User not found")
+		}
+		log.Printf("Error checking user: %v", err)
+		return status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	// Collect the chunks, rejecting the upload as soon as it exceeds the size limit
+	var buf bytes.Buffer
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if buf.Len()+len(req.GetChunk()) > us.cfg.PhotoMaxBytes {
+			return status.Errorf(codes.InvalidArgument, "Photo exceeds the maximum size of %d bytes", us.cfg.PhotoMaxBytes)
+		}
+		buf.Write(req.GetChunk())
+	}
+	if buf.Len() == 0 {
+		return status.Errorf(codes.InvalidArgument, "Photo is empty")
+	}
+
+	contentType, err := photo.DetectType(buf.Bytes())
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "Unsupported image type")
+	}
+	if info.ContentType != "" && info.ContentType != contentType {
+		return status.Errorf(codes.InvalidArgument, "Content type %s does not match the uploaded data (%s)", info.ContentType, contentType)
+	}
+
+	processed, err := photo.Process(buf.Bytes(), photo.DefaultOptions)
+	if err != nil {
+		if err == photo.ErrTooLarge {
+			return status.Errorf(codes.InvalidArgument, "Photo dimensions are too large")
+		}
+		return status.Errorf(codes.InvalidArgument, "Invalid image: %v", err)
+	}
+
+	// Use a random name so caches never serve a previous photo
+	name := make([]byte, 8)
+	if _, err := rand.Read(name); err != nil {
+		log.Printf("Error generating photo name: %v", err)
+		return status.Errorf(codes.Internal, "Internal server error")
+	}
+	keyPrefix := fmt.Sprintf("users/%d/%s", info.UserId, hex.EncodeToString(name))
+
+	photoURL, err := us.storage.Put(ctx, keyPrefix+processed.Extension, processed.Data, processed.ContentType)
+	if err != nil {
+		log.Printf("Error storing profile photo: %v", err)
+		return status.Errorf(codes.Internal, "Failed to store photo")
+	}
+
+	response := &pb.UploadProfilePhotoResponse{ProfilePhotoUrl: photoURL}
+	for _, thumb := range processed.Thumbnails {
+		key := fmt.Sprintf("%s_%d%s", keyPrefix, thumb.Size, processed.Extension)
+		thumbURL, err := us.storage.Put(ctx, key, thumb.Data, processed.ContentType)
+		if err != nil {
+			log.Printf("Error storing thumbnail: %v", err)
+			return status.Errorf(codes.Internal, "Failed to store photo")
+		}
+		response.Thumbnails = append(response.Thumbnails, &pb.ProfilePhotoThumbnail{Size: int32(thumb.Size), Url: thumbURL})
+	}
+
+	_, previous, err := us.store.SetProfilePhotoURL(ctx, info.UserId, photoURL)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return status.Errorf(codes.NotFound, "User not found")
+		}
+		log.Printf("Error updating profile photo: %v", err)
+		return status.Errorf(codes.Internal, "Internal server error")
+	}
+	us.deletePhoto(ctx, info.UserId, previous)
+
+	log.Printf("Profile photo of user %d updated", info.UserId)
+	return stream.SendAndClose(response)
+}
+
+// deletePhoto removes a replaced photo of the user and its thumbnails once
+// the new URL is committed. URLs that do not point at an upload of the user,
+// e.g. set with UpdateUser, are left alone. A failure only leaves an unused
+// object behind, so it is logged.
+func (us *UserService) deletePhoto(ctx context.Context, userID int64, photoURL string) {
+	key, ok := us.storage.Key(photoURL)
+	if !ok || !strings.HasPrefix(key, fmt.Sprintf("users/%d/", userID)) {
+		return
+	}
+	ext := path.Ext(key)
+	keys := []string{key}
+	for _, size := range photo.DefaultOptions.ThumbnailSizes {
+		keys = append(keys, fmt.Sprintf("%s_%d%s", strings.TrimSuffix(key, ext), size, ext))
+	}
+	for _, key := range keys {
+		if err := us.storage.Delete(ctx, key); err != nil && err != storage.ErrNotFound {
+			log.Printf("Error deleting replaced photo %s: %v", key, err)
+		}
+	}
+}
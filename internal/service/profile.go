@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ProfileService lets end users manage their own account. The user is taken
+// from the access token checked by auth.EndUserInterceptor, and every call is
+// delegated to UserService so validation and storage rules are the same.
+// Responses carry the public ID of the user only.
+type ProfileService struct {
+	users    pb.UserServiceServer
+	store    store.Store
+	settings *Settings
+	pb.UnimplementedProfileServiceServer
+}
+
+// NewProfileService creates a new instance of ProfileService on top of the given UserService.
+func NewProfileService(users pb.UserServiceServer, store store.Store, settings *Settings) pb.ProfileServiceServer {
+	return &ProfileService{
+		users:    users,
+		store:    store,
+		settings: settings,
+	}
+}
+
+func (ps *ProfileService) GetMyProfile(ctx context.Context, req *pb.Empty) (*pb.GetUserResponse, error) {
+	user, err := ps.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user.Id = 0
+	return user, nil
+}
+
+func (ps *ProfileService) UpdateMyProfile(ctx context.Context, req *pb.UpdateMyProfileRequest) (*pb.UpdateUserResponse, error) {
+	user, err := ps.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := ps.users.UpdateUser(ctx, &pb.UpdateUserRequest{
+		Id:          user.Id,
+		FirstName:   req.FirstName,
+		LastName:    req.LastName,
+		Gender:      req.Gender,
+		DateOfBirth: req.DateOfBirth,
+		BirthDate:   req.BirthDate,
+		Location:    req.Location,
+		Email:       req.Email,
+	})
+	if err != nil {
+		return nil, err
+	}
+	updated.Id = 0
+	return updated, nil
+}
+
+func (ps *ProfileService) DeleteMyAccount(ctx context.Context, req *pb.DeleteMyAccountRequest) (*pb.Empty, error) {
+	user, err := ps.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The phone number is required as confirmation, so a leaked token alone
+	// is not enough to delete the account by accident
+	confirmation, err := ps.settings.normalizePhone(ctx, req.ConfirmPhoneNumber)
+	if err != nil || confirmation != user.PhoneNumber {
+		return nil, invalidField("confirm_phone_number", "Phone number does not match the account")
+	}
+
+	log.Printf("User with ID %d requested deletion of their account", user.Id)
+	return ps.users.DeleteUser(ctx, &pb.UserID{Id: user.Id})
+}
+
+func (ps *ProfileService) ExportMyData(ctx context.Context, req *pb.Empty) (*pb.ExportMyDataResponse, error) {
+	user, err := ps.currentUser(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions, err := ps.store.ListSessions(ctx, user.Id, now)
+	if err != nil {
+		log.Printf("Error listing sessions: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	user.Id = 0
+	resp := &pb.ExportMyDataResponse{Profile: user, ExportedAt: apitime.CustomTimestamp(now), ExportTime: apitime.Timestamp(now)}
+	for _, s := range sessions {
+		msg := toSessionMessage(s)
+		msg.UserId = 0
+		resp.Sessions = append(resp.Sessions, msg)
+	}
+	return resp, nil
+}
+
+// currentUser loads the authenticated user. Blocked users keep valid access
+// tokens until they expire, so they are rejected here.
+func (ps *ProfileService) currentUser(ctx context.Context) (*pb.GetUserResponse, error) {
+	caller, ok := auth.EndUserFromContext(ctx)
+	if !ok {
+		return nil, status.Errorf(codes.Unauthenticated, "Access token is required")
+	}
+
+	user, err := ps.users.GetUserById(ctx, &pb.UserID{Id: caller.UserID})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, status.Errorf(codes.Unauthenticated, "Account no longer exists")
+		}
+		return nil, err
+	}
+	if user.Blocked {
+		return nil, status.Errorf(codes.PermissionDenied, "User is blocked")
+	}
+	return user, nil
+}
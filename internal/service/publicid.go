@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/ulid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// resolveUserID returns the internal ID of the user a request names by id,
+// by publicID or by both. field is the name of the public ID field, used in
+// errors. Unknown public IDs are NotFound and conflicting IDs are rejected.
+func resolveUserID(ctx context.Context, users store.Store, id int64, publicID, field string) (int64, error) {
+	if publicID == "" {
+		return id, nil
+	}
+	// Crockford base32 is case-insensitive, IDs are stored upper case
+	publicID = strings.ToUpper(publicID)
+	if !ulid.Valid(publicID) {
+		return 0, invalidField(field, "Invalid public ID")
+	}
+
+	ids, err := users.ResolvePublicIDs(ctx, []string{publicID})
+	if err != nil {
+		log.Printf("Error resolving public ID: %v", err)
+		return 0, status.Errorf(codes.Internal, "Internal server error")
+	}
+	resolved, ok := ids[publicID]
+	if !ok {
+		return 0, status.Errorf(codes.NotFound, "User not found")
+	}
+	if id != 0 && id != resolved {
+		return 0, invalidField(field, "Public ID does not match the user ID")
+	}
+	return resolved, nil
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/outbox"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// revisionFields are the fields compared between revisions, in the order
+// changes are listed.
+var revisionFields = []struct {
+	name  string
+	value func(*pb.GetUserResponse) string
+}{
+	{"first_name", func(u *pb.GetUserResponse) string { return u.FirstName }},
+	{"last_name", func(u *pb.GetUserResponse) string { return u.LastName }},
+	{"phone_number", func(u *pb.GetUserResponse) string { return u.PhoneNumber }},
+	{"email", func(u *pb.GetUserResponse) string { return u.Email }},
+	{"email_verified", func(u *pb.GetUserResponse) string { return strconv.FormatBool(u.EmailVerified) }},
+	{"gender", func(u *pb.GetUserResponse) string { return u.Gender }},
+	{"birth_date", func(u *pb.GetUserResponse) string { return formatDate(u.BirthDate) }},
+	{"location", func(u *pb.GetUserResponse) string { return u.Location }},
+	{"profile_photo_url", func(u *pb.GetUserResponse) string { return u.ProfilePhotoUrl }},
+	{"country", func(u *pb.GetUserResponse) string { return u.Country }},
+	{"blocked", func(u *pb.GetUserResponse) string { return strconv.FormatBool(u.Blocked) }},
+}
+
+// ListUserRevisions returns the change history of a user with the fields
+// each revision changed. Deleted users keep their history.
+func (us *UserService) ListUserRevisions(ctx context.Context, req *pb.ListUserRevisionsRequest) (*pb.UserRevisionsList, error) {
+	id, err := resolveUserID(ctx, us.store, req.UserId, req.UserPublicId, "user_public_id")
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * pageSize
+
+	// One more revision than requested is the base of the oldest diff
+	revisions, err := us.store.ListUserRevisions(ctx, id, pageSize+1, offset)
+	if err != nil {
+		log.Printf("Error listing revisions: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	if len(revisions) == 0 && page == 1 {
+		return nil, status.Errorf(codes.NotFound, "User not found")
+	}
+
+	resp := &pb.UserRevisionsList{PreviousPage: page - 1}
+	for i, revision := range revisions {
+		if i == int(pageSize) {
+			resp.NextPage = page + 1
+			break
+		}
+		var previous *pb.GetUserResponse
+		if i+1 < len(revisions) {
+			previous = revisions[i+1].User
+		}
+		resp.Revisions = append(resp.Revisions, toRevisionMessage(revision, previous))
+	}
+	return resp, nil
+}
+
+// GetUserAsOf returns the user as it was at the given time.
+func (us *UserService) GetUserAsOf(ctx context.Context, req *pb.GetUserAsOfRequest) (*pb.GetUserResponse, error) {
+	if req.Time == nil {
+		return nil, invalidField("time", "Time is required")
+	}
+	if err := req.Time.CheckValid(); err != nil {
+		return nil, invalidField("time", "Invalid time")
+	}
+	id, err := resolveUserID(ctx, us.store, req.UserId, req.UserPublicId, "user_public_id")
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := us.store.GetUserAsOf(ctx, id, req.Time.AsTime())
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "User did not exist at that time")
+		}
+		log.Printf("Error fetching revision: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	if revision.Action == outbox.UserDeleted {
+		return nil, status.Errorf(codes.NotFound, "User did not exist at that time")
+	}
+	return revision.User, nil
+}
+
+// RevertUser restores a user to an earlier revision. The phone number of the
+// revision has to pass the current phone rules, and the revert is written to
+// the audit log.
+func (us *UserService) RevertUser(ctx context.Context, req *pb.RevertUserRequest) (*pb.UpdateUserResponse, error) {
+	if req.Revision <= 0 {
+		return nil, invalidField("revision", "Revision is required")
+	}
+	id, err := resolveUserID(ctx, us.store, req.UserId, req.UserPublicId, "user_public_id")
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := us.store.GetUserRevision(ctx, id, req.Revision)
+	if err != nil {
+		if err == store.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "Revision not found")
+		}
+		log.Printf("Error fetching revision: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	if revision.Action == outbox.UserDeleted {
+		return nil, status.Errorf(codes.FailedPrecondition, "Cannot revert to a deletion")
+	}
+
+	// Numbers of countries no longer allowed are rejected like in CreateUser,
+	// and attributes are checked against the current definitions
+	target := revision.User
+	if target.PhoneNumber, err = us.settings.normalizePhone(ctx, target.PhoneNumber); err != nil {
+		return nil, err
+	}
+	if target.CustomAttributes, err = us.customAttributes(ctx, target.CustomAttributes, true); err != nil {
+		return nil, err
+	}
+
+	entry := audit.Entry{
+		Actor:   audit.Actor(ctx),
+		Action:  audit.UserReverted,
+		Reason:  strings.TrimSpace(req.Reason),
+		Details: map[string]int64{"revision": req.Revision},
+	}
+	policy, err := us.phonePolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user, err := us.store.RevertUser(ctx, id, target, policy, entry)
+	if err != nil {
+		if err == store.ErrAlreadyExists {
+			return nil, status.Errorf(codes.AlreadyExists, "User with this phone number or email already exists")
+		}
+		return nil, phoneChangeError(err)
+	}
+
+	log.Printf("User with ID %d reverted to revision %d by %s", id, req.Revision, entry.Actor)
+	return user, nil
+}
+
+func toRevisionMessage(revision *store.UserRevision, previous *pb.GetUserResponse) *pb.UserRevision {
+	msg := &pb.UserRevision{
+		Revision:     revision.Revision,
+		UserId:       revision.UserID,
+		UserPublicId: revision.User.PublicId,
+		Actor:        revision.Actor,
+		Action:       revision.Action,
+		CreateTime:   apitime.Timestamp(revision.CreatedAt),
+		User:         revision.User,
+	}
+	if previous != nil {
+		for _, field := range revisionFields {
+			if oldValue, newValue := field.value(previous), field.value(revision.User); oldValue != newValue {
+				msg.Changes = append(msg.Changes, &pb.FieldChange{Field: field.name, OldValue: oldValue, NewValue: newValue})
+			}
+		}
+		msg.Changes = append(msg.Changes, attributeChanges(previous.CustomAttributes, revision.User.CustomAttributes)...)
+	}
+	return msg
+}
+
+// attributeChanges lists the custom attributes that differ, ordered by name.
+// Removed attributes have an empty new value.
+func attributeChanges(previous, current map[string]string) []*pb.FieldChange {
+	names := make([]string, 0, len(previous)+len(current))
+	for name := range previous {
+		names = append(names, name)
+	}
+	for name := range current {
+		if _, ok := previous[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []*pb.FieldChange
+	for _, name := range names {
+		if oldValue, newValue := previous[name], current[name]; oldValue != newValue {
+			changes = append(changes, &pb.FieldChange{Field: "custom_attributes." + name, OldValue: oldValue, NewValue: newValue})
+		}
+	}
+	return changes
+}
+
+func formatDate(d *date.Date) string {
+	if d == nil {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
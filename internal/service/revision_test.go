@@ -0,0 +1,69 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/usertest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestRevertUserRestoresARevision(t *testing.T) {
+	srv := usertest.New(t)
+	users := srv.Seed(t, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001", Location: "Mary"})
+	client := pb.NewUserServiceClient(srv.Conn)
+	ctx := context.Background()
+
+	if _, err := client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: users[0].Id, FirstName: "Myrat", Location: "Balkan"}); err != nil {
+		t.Fatal(err)
+	}
+	reverted, err := client.RevertUser(ctx, &pb.RevertUserRequest{UserId: users[0].Id, Revision: 1, Reason: "wrong user edited"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.FirstName != "Aman" || reverted.Location != "Mary" {
+		t.Errorf("got %q from %q, want Aman from Mary", reverted.FirstName, reverted.Location)
+	}
+
+	revisions, err := client.ListUserRevisions(ctx, &pb.ListUserRevisionsRequest{UserId: users[0].Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions.Revisions) != 3 || revisions.Revisions[0].Action != "user.reverted" {
+		t.Errorf("got revisions %v, want the revert recorded as the third revision", revisions.Revisions)
+	}
+}
+
+func TestRevertUserChecksAttributesAgainstTheCurrentDefinitions(t *testing.T) {
+	srv := usertest.New(t)
+	users := srv.Seed(t, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001"})
+	attributes := pb.NewAttributeServiceClient(srv.Conn)
+	client := pb.NewUserServiceClient(srv.Conn)
+	ctx := context.Background()
+
+	// Revision 1 predates the required attribute
+	adminCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+srv.AdminToken(t, "superadmin"))
+	_, err := attributes.DefineAttribute(adminCtx, &pb.AttributeDefinition{Name: "tier", Type: pb.AttributeType_ATTRIBUTE_TYPE_STRING, Required: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.UpdateUser(ctx, &pb.UpdateUserRequest{Id: users[0].Id, CustomAttributes: map[string]string{"tier": "gold"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.RevertUser(ctx, &pb.RevertUserRequest{UserId: users[0].Id, Revision: 1})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("got %v, want InvalidArgument for the missing required attribute", err)
+	}
+	user, err := client.GetUserById(ctx, &pb.UserID{Id: users[0].Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.CustomAttributes["tier"] != "gold" {
+		t.Errorf("got attributes %v after the rejected revert, want tier gold", user.CustomAttributes)
+	}
+}
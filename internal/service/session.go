@@ -0,0 +1,311 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/config"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/jwt"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/sms"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// SessionService signs end users in with a one-time code sent to their phone
+// and issues access and refresh tokens.
+type SessionService struct {
+	cfg      *config.Config
+	store    store.Store
+	settings *Settings
+	sms      sms.Sender
+	signer   *jwt.Signer
+	pb.UnimplementedSessionServiceServer
+}
+
+// NewSessionService creates a new instance of SessionService. Login codes
+// cannot be sent when sms is nil, but existing sessions keep working.
+func NewSessionService(cfg *config.Config, store store.Store, settings *Settings, sms sms.Sender, signer *jwt.Signer) pb.SessionServiceServer {
+	return &SessionService{
+		cfg:      cfg,
+		store:    store,
+		settings: settings,
+		sms:      sms,
+		signer:   signer,
+	}
+}
+
+// SendLoginCode sends a one-time login code to the phone number. The response
+// is the same whether or not a user has the number, so it cannot be used to
+// find out which numbers are registered.
+func (ss *SessionService) SendLoginCode(ctx context.Context, req *pb.SendLoginCodeRequest) (*pb.SendLoginCodeResponse, error) {
+	if ss.sms == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "SMS delivery is not configured")
+	}
+
+	settings, err := ss.settings.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	phoneNumber, err := normalizePhone(settings.phones, req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(settings.otpTTL)
+	resp := &pb.SendLoginCodeResponse{ExpiresAt: apitime.CustomTimestamp(expiresAt), ExpireTime: apitime.Timestamp(expiresAt)}
+
+	user, err := ss.store.GetUserByPhone(ctx, phoneNumber)
+	if err == store.ErrNotFound {
+		log.Printf("Login code requested for unknown phone number")
+		return resp, nil
+	}
+	if err != nil {
+		log.Printf("Error fetching user by phone: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	if user.Blocked {
+		log.Printf("Login code requested for blocked user with ID %d", user.Id)
+		return resp, nil
+	}
+
+	code, err := newCode()
+	if err != nil {
+		log.Printf("Error generating login code: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	loginCode := store.LoginCode{UserID: user.Id, CodeHash: hashCode(user.Id, code), ExpiresAt: expiresAt}
+	if err := ss.store.StartLogin(ctx, loginCode); err != nil {
+		log.Printf("Error storing login code: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	text := fmt.Sprintf("Your login code is %s. It expires in %s.", code, settings.otpTTL)
+	if err := ss.sms.Send(ctx, phoneNumber, text); err != nil {
+		log.Printf("Error sending login code to user %d: %v", user.Id, err)
+		return nil, status.Errorf(codes.Unavailable, "Failed to send login code")
+	}
+
+	return resp, nil
+}
+
+// VerifyLoginCode checks the login code and starts a session on the device.
+func (ss *SessionService) VerifyLoginCode(ctx context.Context, req *pb.VerifyLoginCodeRequest) (*pb.SessionTokens, error) {
+	if req.Code == "" {
+		return nil, invalidField("code", "Code is required")
+	}
+	if req.DeviceId == "" || len(req.DeviceId) > 100 {
+		return nil, invalidField("device_id", "Device ID is required and must be at most 100 characters")
+	}
+	if len(req.DeviceName) > 100 {
+		return nil, invalidField("device_name", "Device name must be at most 100 characters")
+	}
+
+	settings, err := ss.settings.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	phoneNumber, err := normalizePhone(settings.phones, req.PhoneNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := ss.store.GetUserByPhone(ctx, phoneNumber)
+	if err == store.ErrNotFound {
+		return nil, status.Errorf(codes.Unauthenticated, "Invalid phone number or code")
+	}
+	if err != nil {
+		log.Printf("Error fetching user by phone: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	now := time.Now()
+	if err := ss.store.ConsumeLoginCode(ctx, user.Id, hashCode(user.Id, req.Code), settings.otpMaxAttempts, now); err != nil {
+		switch err {
+		case store.ErrInvalidToken, store.ErrCodeMismatch:
+			return nil, status.Errorf(codes.Unauthenticated, "Invalid phone number or code")
+		case store.ErrExpired:
+			return nil, status.Errorf(codes.FailedPrecondition, "Code has expired, request a new code")
+		case store.ErrTooManyAttempts:
+			return nil, status.Errorf(codes.FailedPrecondition, "Too many failed attempts, request a new code")
+		}
+		log.Printf("Error checking login code: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	// Checked only after the code so the response does not reveal blocked accounts
+	if user.Blocked {
+		return nil, status.Errorf(codes.PermissionDenied, "User is blocked")
+	}
+
+	sessionID, err := newSessionID()
+	if err != nil {
+		log.Printf("Error generating session ID: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	refreshToken, refreshHash, err := newToken()
+	if err != nil {
+		log.Printf("Error generating refresh token: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	session := store.Session{
+		ID:           sessionID,
+		UserID:       user.Id,
+		UserPublicID: user.PublicId,
+		DeviceID:     req.DeviceId,
+		DeviceName:   req.DeviceName,
+		CreatedAt:    now,
+		LastUsedAt:   now,
+		ExpiresAt:    now.Add(ss.cfg.SessionRefreshTTL),
+	}
+	if err := ss.store.CreateSession(ctx, session, refreshHash); err != nil {
+		log.Printf("Error creating session: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("Session %s started for user with ID %d", session.ID, user.Id)
+	return ss.issueTokens(ctx, &session, refreshToken, now)
+}
+
+// RefreshSession exchanges a refresh token for a new access token and a new
+// refresh token. Presenting an already used refresh token revokes the session.
+func (ss *SessionService) RefreshSession(ctx context.Context, req *pb.RefreshSessionRequest) (*pb.SessionTokens, error) {
+	if req.RefreshToken == "" {
+		return nil, invalidField("refresh_token", "Refresh token is required")
+	}
+
+	refreshToken, refreshHash, err := newToken()
+	if err != nil {
+		log.Printf("Error generating refresh token: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	now := time.Now()
+	session, err := ss.store.RotateSession(ctx, hashToken(req.RefreshToken), refreshHash, now, now.Add(ss.cfg.SessionRefreshTTL))
+	if err != nil {
+		switch err {
+		case store.ErrInvalidToken:
+			return nil, status.Errorf(codes.Unauthenticated, "Invalid refresh token")
+		case store.ErrExpired:
+			return nil, status.Errorf(codes.Unauthenticated, "Session has expired")
+		case store.ErrTokenReused:
+			log.Printf("Refresh token reused, session revoked")
+			return nil, status.Errorf(codes.Unauthenticated, "Refresh token was already used, the session has been revoked")
+		}
+		log.Printf("Error refreshing session: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	return ss.issueTokens(ctx, session, refreshToken, now)
+}
+
+func (ss *SessionService) ListSessions(ctx context.Context, req *pb.UserID) (*pb.SessionsList, error) {
+	id, err := resolveUserID(ctx, ss.store, req.Id, req.PublicId, "public_id")
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := ss.store.ListSessions(ctx, id, time.Now())
+	if err != nil {
+		log.Printf("Error listing sessions: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	resp := &pb.SessionsList{}
+	for _, s := range sessions {
+		resp.Sessions = append(resp.Sessions, toSessionMessage(s))
+	}
+	return resp, nil
+}
+
+// RevokeSession ends a session. Access tokens already issued for it stay valid until they expire.
+func (ss *SessionService) RevokeSession(ctx context.Context, req *pb.RevokeSessionRequest) (*pb.Empty, error) {
+	var err error
+	if req.UserId, err = resolveUserID(ctx, ss.store, req.UserId, req.UserPublicId, "user_public_id"); err != nil {
+		return nil, err
+	}
+	if err := ss.store.RevokeSession(ctx, req.UserId, req.SessionId, time.Now()); err != nil {
+		if err == store.ErrNotFound {
+			return nil, status.Errorf(codes.NotFound, "Session not found")
+		}
+		log.Printf("Error revoking session: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("Session %s of user with ID %d revoked", req.SessionId, req.UserId)
+	return &pb.Empty{}, nil
+}
+
+// GetJWKS returns the public keys access tokens are signed with.
+func (ss *SessionService) GetJWKS(ctx context.Context, req *pb.Empty) (*pb.JWKS, error) {
+	resp := &pb.JWKS{}
+	for _, key := range ss.signer.JWKS().Keys {
+		resp.Keys = append(resp.Keys, &pb.JWK{
+			Kty: key.Kty,
+			Crv: key.Crv,
+			X:   key.X,
+			Y:   key.Y,
+			Kid: key.Kid,
+			Use: key.Use,
+			Alg: key.Alg,
+		})
+	}
+	return resp, nil
+}
+
+// issueTokens signs an access token for the session in the tenant of ctx and returns it with the refresh token.
+// The token and the response only carry the public ID of the user.
+func (ss *SessionService) issueTokens(ctx context.Context, session *store.Session, refreshToken string, now time.Time) (*pb.SessionTokens, error) {
+	accessToken, err := ss.signer.Sign(auth.EndUserAudience, session.UserPublicID, session.ID, tenant.ID(ctx), now, ss.cfg.SessionAccessTTL)
+	if err != nil {
+		log.Printf("Error signing access token: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	accessExpiresAt := now.Add(ss.cfg.SessionAccessTTL)
+	return &pb.SessionTokens{
+		AccessToken:            accessToken,
+		AccessTokenExpiresAt:   apitime.CustomTimestamp(accessExpiresAt),
+		AccessTokenExpireTime:  apitime.Timestamp(accessExpiresAt),
+		RefreshToken:           refreshToken,
+		RefreshTokenExpiresAt:  apitime.CustomTimestamp(session.ExpiresAt),
+		RefreshTokenExpireTime: apitime.Timestamp(session.ExpiresAt),
+		SessionId:              session.ID,
+		UserPublicId:           session.UserPublicID,
+		TokenType:              "Bearer",
+	}, nil
+}
+
+func toSessionMessage(s *store.Session) *pb.Session {
+	return &pb.Session{
+		Id:           s.ID,
+		UserId:       s.UserID,
+		UserPublicId: s.UserPublicID,
+		DeviceId:     s.DeviceID,
+		DeviceName:   s.DeviceName,
+		CreatedAt:    apitime.CustomTimestamp(s.CreatedAt),
+		LastUsedAt:   apitime.CustomTimestamp(s.LastUsedAt),
+		ExpiresAt:    apitime.CustomTimestamp(s.ExpiresAt),
+		CreateTime:   apitime.Timestamp(s.CreatedAt),
+		LastUseTime:  apitime.Timestamp(s.LastUsedAt),
+		ExpireTime:   apitime.Timestamp(s.ExpiresAt),
+	}
+}
+
+func newSessionID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
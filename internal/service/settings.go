@@ -0,0 +1,123 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/config"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Settings resolves the settings a tenant may override, falling back to the
+// server configuration for those it does not.
+type Settings struct {
+	cfg     *config.Config
+	tenants auth.TenantStore
+	phones  *phone.Normalizer
+
+	mu          sync.Mutex
+	normalizers map[string]*phone.Normalizer
+}
+
+// tenantSettings are the settings of the tenant of a call.
+type tenantSettings struct {
+	// slug is empty in installations without tenants
+	slug           string
+	phones         *phone.Normalizer
+	otpTTL         time.Duration
+	otpMaxAttempts int
+}
+
+// NewSettings creates Settings on top of the server configuration and its
+// phone number rules. Without tenants every call gets the server settings.
+func NewSettings(cfg *config.Config, tenants auth.TenantStore, phones *phone.Normalizer) *Settings {
+	return &Settings{
+		cfg:         cfg,
+		tenants:     tenants,
+		phones:      phones,
+		normalizers: make(map[string]*phone.Normalizer),
+	}
+}
+
+// of returns the settings of the tenant of ctx.
+func (s *Settings) of(ctx context.Context) (*tenantSettings, error) {
+	settings := &tenantSettings{
+		phones:         s.phones,
+		otpTTL:         s.cfg.PhoneOTPTTL,
+		otpMaxAttempts: s.cfg.PhoneOTPMaxAttempts,
+	}
+	if s.tenants == nil {
+		return settings, nil
+	}
+
+	t, err := s.tenants.GetTenant(ctx, tenant.ID(ctx))
+	if err != nil {
+		log.Printf("Error fetching tenant settings: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	settings.slug = t.Slug
+	if t.Config.PhoneOTPTTL > 0 {
+		settings.otpTTL = t.Config.PhoneOTPTTL
+	}
+	if t.Config.PhoneOTPMaxAttempts > 0 {
+		settings.otpMaxAttempts = t.Config.PhoneOTPMaxAttempts
+	}
+	if len(t.Config.PhoneCountries) > 0 || t.Config.PhoneDefaultCountry != "" {
+		if settings.phones, err = s.normalizer(t.Config); err != nil {
+			log.Printf("Error applying phone rules of tenant %d: %v", t.ID, err)
+			return nil, status.Errorf(codes.Internal, "Internal server error")
+		}
+	}
+	return settings, nil
+}
+
+// normalizer returns the phone number rules of a tenant overriding them.
+// Normalizers are shared by tenants with the same overrides.
+func (s *Settings) normalizer(c store.TenantConfig) (*phone.Normalizer, error) {
+	countries, defaultCountry := phoneRules(s.cfg, c)
+	key := strings.Join(countries, ",") + "/" + defaultCountry
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if n, ok := s.normalizers[key]; ok {
+		return n, nil
+	}
+	n, err := phone.NewNormalizer(countries, defaultCountry, s.cfg.PhonePrefixes)
+	if err != nil {
+		return nil, err
+	}
+	s.normalizers[key] = n
+	return n, nil
+}
+
+// phoneRules returns the allowed and default phone countries of a tenant.
+// Like PHONE_DEFAULT_COUNTRY, the default country is the first allowed
+// country unless it is set.
+func phoneRules(cfg *config.Config, c store.TenantConfig) (countries []string, defaultCountry string) {
+	countries, defaultCountry = cfg.PhoneCountries, cfg.PhoneDefaultCountry
+	if len(c.PhoneCountries) > 0 {
+		countries, defaultCountry = c.PhoneCountries, c.PhoneCountries[0]
+	}
+	if c.PhoneDefaultCountry != "" {
+		defaultCountry = c.PhoneDefaultCountry
+	}
+	return countries, defaultCountry
+}
+
+// normalizePhone validates a phone number against the rules of the tenant of
+// ctx and returns it in E.164 form.
+func (s *Settings) normalizePhone(ctx context.Context, input string) (string, error) {
+	settings, err := s.of(ctx)
+	if err != nil {
+		return "", err
+	}
+	return normalizePhone(settings.phones, input)
+}
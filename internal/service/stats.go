@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/flight"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	defaultStatsRange         = 30 * 24 * time.Hour
+	defaultStatsLocationLimit = 10
+	maxStatsLocationLimit     = 100
+	// maxStatsBuckets bounds the registration buckets of a range
+	maxStatsBuckets = 400
+)
+
+// statsIntervals maps intervals to their store name and longest bucket.
+var statsIntervals = map[pb.StatsInterval]struct {
+	name   string
+	length time.Duration
+}{
+	pb.StatsInterval_STATS_INTERVAL_UNSPECIFIED: {store.StatsDay, 24 * time.Hour},
+	pb.StatsInterval_STATS_INTERVAL_DAY:         {store.StatsDay, 24 * time.Hour},
+	pb.StatsInterval_STATS_INTERVAL_WEEK:        {store.StatsWeek, 7 * 24 * time.Hour},
+	pb.StatsInterval_STATS_INTERVAL_MONTH:       {store.StatsMonth, 31 * 24 * time.Hour},
+}
+
+// GetUserStats returns counts of the users matching the filter. Results are
+// cached per tenant and request for USER_STATS_CACHE_TTL, so requests
+// without a range share the counts of the range computed first.
+func (us *UserService) GetUserStats(ctx context.Context, req *pb.GetUserStatsRequest) (*pb.UserStats, error) {
+	key, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		log.Printf("Error encoding stats request: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	return us.stats.get(ctx, strconv.FormatInt(tenant.ID(ctx), 10)+"/"+string(key), func(ctx context.Context) (*pb.UserStats, error) {
+		return us.userStats(ctx, req)
+	})
+}
+
+func (us *UserService) userStats(ctx context.Context, req *pb.GetUserStatsRequest) (*pb.UserStats, error) {
+	filter, err := us.userFilter(ctx, req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	interval, ok := statsIntervals[req.Interval]
+	if !ok {
+		return nil, invalidField("interval", "Unknown interval")
+	}
+	loc := time.UTC
+	if req.TimeZone != "" {
+		if loc, err = apitime.LoadZone(req.TimeZone); err != nil {
+			return nil, invalidField("time_zone", fmt.Sprintf("Unknown time zone %q", req.TimeZone))
+		}
+	}
+	locationLimit := int(req.LocationLimit)
+	if locationLimit == 0 {
+		locationLimit = defaultStatsLocationLimit
+	}
+	if locationLimit < 0 || locationLimit > maxStatsLocationLimit {
+		return nil, invalidField("location_limit", fmt.Sprintf("Location limit must be between 1 and %d", maxStatsLocationLimit))
+	}
+
+	now := time.Now()
+	to := now
+	if req.EndTime != nil {
+		if err := req.EndTime.CheckValid(); err != nil {
+			return nil, invalidField("end_time", "Invalid time")
+		}
+		to = req.EndTime.AsTime()
+	}
+	from := to.Add(-defaultStatsRange)
+	if req.StartTime != nil {
+		if err := req.StartTime.CheckValid(); err != nil {
+			return nil, invalidField("start_time", "Invalid time")
+		}
+		from = req.StartTime.AsTime()
+	}
+	if !from.Before(to) {
+		return nil, invalidField("start_time", "Start time must be before end time")
+	}
+	if to.Sub(from) > maxStatsBuckets*interval.length {
+		return nil, invalidField("start_time", fmt.Sprintf("Range must have at most %d buckets of the interval", maxStatsBuckets))
+	}
+
+	stats, err := us.store.UserStats(ctx, store.UserStatsQuery{
+		Filter:        filter,
+		From:          from,
+		To:            to,
+		Interval:      interval.name,
+		Location:      loc,
+		Now:           now,
+		LocationLimit: locationLimit,
+	})
+	if err != nil {
+		log.Printf("Error computing user stats: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	return toStatsMessage(stats, now), nil
+}
+
+func toStatsMessage(stats *store.UserStats, now time.Time) *pb.UserStats {
+	msg := &pb.UserStats{
+		TotalUsers:      stats.Total,
+		BlockedUsers:    stats.Blocked,
+		DeletedUsers:    stats.Deleted,
+		RegisteredUsers: stats.Registered,
+		Genders:         toStatsCounts(stats.Genders),
+		AgeBands:        toStatsCounts(stats.AgeBands),
+		Locations:       toStatsCounts(stats.Locations),
+		GenerateTime:    apitime.Timestamp(now),
+	}
+	for _, b := range stats.Registrations {
+		msg.Registrations = append(msg.Registrations, &pb.StatsBucket{StartTime: apitime.Timestamp(b.Start), Count: b.Count})
+	}
+	return msg
+}
+
+func toStatsCounts(counts []store.StatsCount) []*pb.StatsCount {
+	msgs := make([]*pb.StatsCount, 0, len(counts))
+	for _, c := range counts {
+		msgs = append(msgs, &pb.StatsCount{Key: c.Key, Count: c.Count})
+	}
+	return msgs
+}
+
+// statsCache keeps computed stats for a short time so dashboards can poll
+// them cheaply. Concurrent misses for the same key share one computation.
+type statsCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]statsEntry
+	group   flight.Group
+}
+
+type statsEntry struct {
+	stats     *pb.UserStats
+	expiresAt time.Time
+}
+
+// newStatsCache creates a cache keeping stats for ttl, 0 disables it.
+func newStatsCache(ttl time.Duration) *statsCache {
+	c := &statsCache{ttl: ttl, entries: make(map[string]statsEntry)}
+	// Counting a large tenant takes longer than a lookup
+	c.group.Timeout = time.Minute
+	return c
+}
+
+// get returns a copy of the cached stats of key, computing them with load
+// when they are missing or expired. Errors are not cached.
+func (c *statsCache) get(ctx context.Context, key string, load func(ctx context.Context) (*pb.UserStats, error)) (*pb.UserStats, error) {
+	if c.ttl <= 0 {
+		return load(ctx)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return proto.Clone(entry.stats).(*pb.UserStats), nil
+	}
+
+	value, err := c.group.Do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		stats, err := load(ctx)
+		if err != nil {
+			return nil, err
+		}
+		c.put(key, stats)
+		return stats, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(value.(*pb.UserStats)).(*pb.UserStats), nil
+}
+
+// put stores stats and drops the expired entries.
+func (c *statsCache) put(key string, stats *pb.UserStats) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = statsEntry{stats: stats, expiresAt: now.Add(c.ttl)}
+}
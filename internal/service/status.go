@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/ulid"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxStatusBatch limits the IDs, phone numbers and public IDs of one CheckUsersStatus call.
+const maxStatusBatch = 100
+
+// CheckUsersStatus tells whether users exist and are not blocked, without
+// returning their personal data. Phone numbers that cannot be normalized and
+// malformed public IDs are reported as not found.
+func (us *UserService) CheckUsersStatus(ctx context.Context, req *pb.CheckUsersStatusRequest) (*pb.CheckUsersStatusResponse, error) {
+	total := len(req.Ids) + len(req.PhoneNumbers) + len(req.PublicIds)
+	if total == 0 {
+		return nil, invalidField("ids", "At least one ID, phone number or public ID is required")
+	}
+	if total > maxStatusBatch {
+		return nil, invalidField("ids", fmt.Sprintf("At most %d IDs, phone numbers and public IDs can be checked at once", maxStatusBatch))
+	}
+
+	settings, err := us.settings.of(ctx)
+	if err != nil {
+		return nil, err
+	}
+	normalized := make([]string, len(req.PhoneNumbers))
+	var lookup []string
+	for i, input := range req.PhoneNumbers {
+		if number, err := settings.phones.Normalize(input); err == nil {
+			normalized[i] = number.E164
+			lookup = append(lookup, number.E164)
+		}
+	}
+
+	publicIDs := make([]string, len(req.PublicIds))
+	var validPublicIDs []string
+	for i, input := range req.PublicIds {
+		if publicID := strings.ToUpper(input); ulid.Valid(publicID) {
+			publicIDs[i] = publicID
+			validPublicIDs = append(validPublicIDs, publicID)
+		}
+	}
+	resolved := map[string]int64{}
+	if len(validPublicIDs) > 0 {
+		var err error
+		if resolved, err = us.store.ResolvePublicIDs(ctx, validPublicIDs); err != nil {
+			log.Printf("Error resolving public IDs: %v", err)
+			return nil, status.Errorf(codes.Internal, "Internal server error")
+		}
+	}
+	ids := append([]int64(nil), req.Ids...)
+	for _, id := range resolved {
+		ids = append(ids, id)
+	}
+
+	statuses, err := us.store.GetUsersStatus(ctx, ids, lookup)
+	if err != nil {
+		log.Printf("Error checking users status: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	byID := make(map[int64]pb.UserStatus, len(statuses))
+	byPhone := make(map[string]int64, len(statuses))
+	publicIDOf := make(map[int64]string, len(statuses))
+	for _, s := range statuses {
+		switch {
+		case s.Deleted:
+			byID[s.ID] = pb.UserStatus_USER_STATUS_DELETED
+		case s.Blocked:
+			byID[s.ID] = pb.UserStatus_USER_STATUS_BLOCKED
+		default:
+			byID[s.ID] = pb.UserStatus_USER_STATUS_ACTIVE
+		}
+		if s.PhoneNumber != "" {
+			byPhone[s.PhoneNumber] = s.ID
+		}
+		if s.PublicID != "" {
+			publicIDOf[s.ID] = s.PublicID
+		}
+	}
+
+	users := make([]*pb.UserStatusEntry, 0, total)
+	for _, id := range req.Ids {
+		entry := &pb.UserStatusEntry{Id: id, PublicId: publicIDOf[id], Status: pb.UserStatus_USER_STATUS_NOT_FOUND}
+		if s, ok := byID[id]; ok {
+			entry.Status = s
+		}
+		users = append(users, entry)
+	}
+	for i, input := range req.PhoneNumbers {
+		entry := &pb.UserStatusEntry{PhoneNumber: input, Status: pb.UserStatus_USER_STATUS_NOT_FOUND}
+		if id, ok := byPhone[normalized[i]]; ok && normalized[i] != "" {
+			entry.Id = id
+			entry.PublicId = publicIDOf[id]
+			entry.Status = byID[id]
+		}
+		users = append(users, entry)
+	}
+	for i, input := range req.PublicIds {
+		entry := &pb.UserStatusEntry{PublicId: input, Status: pb.UserStatus_USER_STATUS_NOT_FOUND}
+		if id, ok := resolved[publicIDs[i]]; ok && publicIDs[i] != "" {
+			entry.Id = id
+			if s, ok := byID[id]; ok {
+				entry.Status = s
+			}
+		}
+		users = append(users, entry)
+	}
+
+	return &pb.CheckUsersStatusResponse{Users: users}, nil
+}
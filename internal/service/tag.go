@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	maxTagsPerRequest = 20
+	maxFilterIDs      = 1000
+	maxNoteLength     = 4000
+)
+
+var (
+	tagPattern         = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+	countryPattern     = regexp.MustCompile(`^[A-Z]{2}$`)
+	phonePrefixPattern = regexp.MustCompile(`^\+[0-9]{1,15}$`)
+)
+
+// GetUserTags returns the tags of a user.
+func (us *UserService) GetUserTags(ctx context.Context, req *pb.UserID) (*pb.UserTags, error) {
+	user, err := us.taggedUser(ctx, req.Id, req.PublicId, "public_id")
+	if err != nil {
+		return nil, err
+	}
+	tags, err := us.store.GetUserTags(ctx, user.Id)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return &pb.UserTags{UserId: user.Id, UserPublicId: user.PublicId, Tags: tags}, nil
+}
+
+// AddUserTags tags a user. Tags the user already has are ignored.
+func (us *UserService) AddUserTags(ctx context.Context, req *pb.UserTagsRequest) (*pb.UserTags, error) {
+	return us.changeUserTags(ctx, req, true)
+}
+
+// RemoveUserTags removes tags from a user. Tags the user does not have are ignored.
+func (us *UserService) RemoveUserTags(ctx context.Context, req *pb.UserTagsRequest) (*pb.UserTags, error) {
+	return us.changeUserTags(ctx, req, false)
+}
+
+func (us *UserService) changeUserTags(ctx context.Context, req *pb.UserTagsRequest, add bool) (*pb.UserTags, error) {
+	tags, err := normalizeTags("tags", req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	if len(tags) == 0 {
+		return nil, invalidField("tags", "At least one tag is required")
+	}
+	user, err := us.taggedUser(ctx, req.UserId, req.UserPublicId, "user_public_id")
+	if err != nil {
+		return nil, err
+	}
+
+	entry := audit.Entry{Actor: audit.Actor(ctx), Action: audit.UserTagged, UserID: user.Id}
+	var result []string
+	if add {
+		entry.Details = map[string]interface{}{"added": tags}
+		result, err = us.store.AddUserTags(ctx, user.Id, tags, entry)
+	} else {
+		entry.Details = map[string]interface{}{"removed": tags}
+		result, err = us.store.RemoveUserTags(ctx, user.Id, tags, entry)
+	}
+	if err != nil {
+		return nil, tagError(err)
+	}
+
+	log.Printf("Tags of user with ID %d changed by %s", user.Id, entry.Actor)
+	return &pb.UserTags{UserId: user.Id, UserPublicId: user.PublicId, Tags: result}, nil
+}
+
+// BulkTagUsers adds and removes tags on all users matching a filter, e.g.
+// all blocked users with the "beta" tag.
+func (us *UserService) BulkTagUsers(ctx context.Context, req *pb.BulkTagUsersRequest) (*pb.BulkTagUsersResponse, error) {
+	filter, err := us.userFilter(ctx, req.Filter)
+	if err != nil {
+		return nil, err
+	}
+	if filter.Empty() {
+		return nil, invalidField("filter", "Filter is required, it must not match every user")
+	}
+	add, err := normalizeTags("add_tags", req.AddTags)
+	if err != nil {
+		return nil, err
+	}
+	remove, err := normalizeTags("remove_tags", req.RemoveTags)
+	if err != nil {
+		return nil, err
+	}
+	if len(add) == 0 && len(remove) == 0 {
+		return nil, invalidField("add_tags", "At least one tag to add or remove is required")
+	}
+	for _, tag := range add {
+		for _, removed := range remove {
+			if tag == removed {
+				return nil, invalidField("remove_tags", fmt.Sprintf("Tag %q cannot be added and removed at once", tag))
+			}
+		}
+	}
+
+	entry := audit.Entry{
+		Actor:   audit.Actor(ctx),
+		Action:  audit.UsersBulkTagged,
+		Details: map[string]interface{}{"filter": filter, "added": add, "removed": remove},
+	}
+	matched, err := us.store.BulkTagUsers(ctx, filter, add, remove, entry)
+	if err != nil {
+		log.Printf("Error bulk tagging users: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("Tags of %d users changed by %s", matched, entry.Actor)
+	return &pb.BulkTagUsersResponse{MatchedUsers: matched}, nil
+}
+
+// ListTags returns the tag catalog with the number of users of every tag.
+func (us *UserService) ListTags(ctx context.Context, req *pb.Empty) (*pb.TagsList, error) {
+	tags, err := us.store.ListTags(ctx)
+	if err != nil {
+		log.Printf("Error listing tags: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	resp := &pb.TagsList{}
+	for _, tag := range tags {
+		resp.Tags = append(resp.Tags, &pb.Tag{
+			Name:       tag.Name,
+			UserCount:  tag.UserCount,
+			CreatedBy:  tag.CreatedBy,
+			CreateTime: apitime.Timestamp(tag.CreatedAt),
+		})
+	}
+	return resp, nil
+}
+
+// AddUserNote appends a note to the timeline of a user. The caller is
+// recorded as the author.
+func (us *UserService) AddUserNote(ctx context.Context, req *pb.AddUserNoteRequest) (*pb.UserNote, error) {
+	body := strings.TrimSpace(req.Body)
+	if body == "" {
+		return nil, invalidField("body", "Note must not be empty")
+	}
+	if utf8.RuneCountInString(body) > maxNoteLength {
+		return nil, invalidField("body", fmt.Sprintf("Note must be at most %d characters", maxNoteLength))
+	}
+	id, err := resolveUserID(ctx, us.store, req.UserId, req.UserPublicId, "user_public_id")
+	if err != nil {
+		return nil, err
+	}
+
+	note, err := us.store.AddUserNote(ctx, store.UserNote{UserID: id, Author: audit.Actor(ctx), Body: body})
+	if err != nil {
+		return nil, tagError(err)
+	}
+
+	log.Printf("Note added to user with ID %d by %s", id, note.Author)
+	return toNoteMessage(note), nil
+}
+
+// ListUserNotes returns the notes of a user, newest first.
+func (us *UserService) ListUserNotes(ctx context.Context, req *pb.ListUserNotesRequest) (*pb.UserNotesList, error) {
+	user, err := us.taggedUser(ctx, req.UserId, req.UserPublicId, "user_public_id")
+	if err != nil {
+		return nil, err
+	}
+
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 20
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * pageSize
+
+	// One more note than requested tells whether there is a next page
+	notes, err := us.store.ListUserNotes(ctx, user.Id, pageSize+1, offset)
+	if err != nil {
+		log.Printf("Error listing notes: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	resp := &pb.UserNotesList{PreviousPage: page - 1}
+	for i, note := range notes {
+		if i == int(pageSize) {
+			resp.NextPage = page + 1
+			break
+		}
+		resp.Notes = append(resp.Notes, toNoteMessage(note))
+	}
+	return resp, nil
+}
+
+// taggedUser resolves and loads the user tags and notes are requested for.
+func (us *UserService) taggedUser(ctx context.Context, id int64, publicID, field string) (*pb.GetUserResponse, error) {
+	id, err := resolveUserID(ctx, us.store, id, publicID, field)
+	if err != nil {
+		return nil, err
+	}
+	user, err := us.store.GetUser(ctx, id)
+	if err != nil {
+		return nil, tagError(err)
+	}
+	return user, nil
+}
+
+// normalizeTags lowercases and deduplicates tags and checks their format.
+func normalizeTags(field string, tags []string) ([]string, error) {
+	if len(tags) > maxTagsPerRequest {
+		return nil, invalidField(field, fmt.Sprintf("At most %d tags are allowed", maxTagsPerRequest))
+	}
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !tagPattern.MatchString(tag) {
+			return nil, invalidField(field, fmt.Sprintf("Invalid tag %q, tags are up to 50 lowercase letters, digits, '-' or '_'", tag))
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized, nil
+}
+
+// userFilter converts and validates a filter of the API. A nil filter
+// matches every user.
+func (us *UserService) userFilter(ctx context.Context, filter *pb.UserFilter) (store.UserFilter, error) {
+	if filter == nil {
+		return store.UserFilter{}, nil
+	}
+	if len(filter.Ids) > maxFilterIDs {
+		return store.UserFilter{}, invalidField("filter.ids", fmt.Sprintf("At most %d IDs are allowed", maxFilterIDs))
+	}
+	tags, err := normalizeTags("filter.tags", filter.Tags)
+	if err != nil {
+		return store.UserFilter{}, err
+	}
+	country := strings.ToUpper(strings.TrimSpace(filter.Country))
+	if country != "" && !countryPattern.MatchString(country) {
+		return store.UserFilter{}, invalidField("filter.country", "Country must be an ISO 3166-1 alpha-2 code")
+	}
+	phonePrefix := strings.TrimSpace(filter.PhonePrefix)
+	if phonePrefix != "" && !phonePrefixPattern.MatchString(phonePrefix) {
+		return store.UserFilter{}, invalidField("filter.phone_prefix", "Phone prefix must be '+' followed by digits")
+	}
+
+	attributes, err := us.attributeFilter(ctx, filter.CustomAttributes)
+	if err != nil {
+		return store.UserFilter{}, err
+	}
+
+	result := store.UserFilter{IDs: filter.Ids, Tags: tags, Country: country, PhonePrefix: phonePrefix, CustomAttributes: attributes}
+	switch filter.Blocked {
+	case pb.BlockedFilter_BLOCKED_FILTER_ANY:
+	case pb.BlockedFilter_BLOCKED_FILTER_BLOCKED, pb.BlockedFilter_BLOCKED_FILTER_NOT_BLOCKED:
+		blocked := filter.Blocked == pb.BlockedFilter_BLOCKED_FILTER_BLOCKED
+		result.Blocked = &blocked
+	default:
+		return store.UserFilter{}, invalidField("filter.blocked", "Unknown blocked filter")
+	}
+	return result, nil
+}
+
+func tagError(err error) error {
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "User not found")
+	}
+	log.Printf("Error updating tags or notes: %v", err)
+	return status.Errorf(codes.Internal, "Internal server error")
+}
+
+func toNoteMessage(note *store.UserNote) *pb.UserNote {
+	return &pb.UserNote{
+		Id:         note.ID,
+		UserId:     note.UserID,
+		Author:     note.Author,
+		Body:       note.Body,
+		CreateTime: apitime.Timestamp(note.CreatedAt),
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/config"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,31}$`)
+
+// Bounds of the one-time code TTL a tenant can set.
+const (
+	minTenantOTPTTL = 30 * time.Second
+	maxTenantOTPTTL = 24 * time.Hour
+)
+
+// TenantService manages tenants. Only admins of the default tenant may use
+// it, so admins of a tenant cannot see or change other tenants.
+type TenantService struct {
+	cfg     *config.Config
+	store   store.Store
+	tenants *store.TenantCache
+	pb.UnimplementedTenantServiceServer
+}
+
+// NewTenantService creates a new instance of TenantService. Changed tenants
+// are dropped from tenants so the change applies at once.
+func NewTenantService(cfg *config.Config, store store.Store, tenants *store.TenantCache) pb.TenantServiceServer {
+	return &TenantService{
+		cfg:     cfg,
+		store:   store,
+		tenants: tenants,
+	}
+}
+
+// CreateTenant creates a tenant and, if admin_username is set, its first
+// superadmin. The tenant is kept when creating the admin fails, the admin can
+// then be created with the bootstrap command.
+func (ts *TenantService) CreateTenant(ctx context.Context, req *pb.CreateTenantRequest) (*pb.CreateTenantResponse, error) {
+	if err := requireDefaultTenant(ctx); err != nil {
+		return nil, err
+	}
+	slug := strings.ToLower(strings.TrimSpace(req.Slug))
+	if !tenantSlugPattern.MatchString(slug) {
+		return nil, invalidField("slug", "Slug must be 2 to 32 lowercase letters, digits or '-' and start with a letter or digit")
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len(name) > 100 {
+		return nil, invalidField("name", "Name is required and must be at most 100 characters")
+	}
+	config, err := ts.tenantConfig(req.Config)
+	if err != nil {
+		return nil, err
+	}
+	username := strings.ToLower(strings.TrimSpace(req.AdminUsername))
+	if username != "" && !usernamePattern.MatchString(username) {
+		return nil, invalidField("admin_username", "Username must be 3 to 64 lowercase letters, digits, '.', '_' or '-'")
+	}
+
+	entry := tenantEntry(ctx, audit.TenantCreated, 0, map[string]interface{}{"slug": slug, "name": name})
+	t, err := ts.store.CreateTenant(ctx, store.Tenant{Slug: slug, Name: name, Config: config, CreatedBy: entry.Actor}, entry)
+	if err != nil {
+		if err == store.ErrAlreadyExists {
+			return nil, status.Errorf(codes.AlreadyExists, "Tenant with this slug already exists")
+		}
+		log.Printf("Error creating tenant: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	log.Printf("Tenant %s created by %s", t.Slug, entry.Actor)
+
+	resp := &pb.CreateTenantResponse{Tenant: toTenantMessage(t)}
+	if username != "" {
+		if resp.Admin, err = createAdmin(tenant.WithID(ctx, t.ID), ts.cfg, ts.store, username, []string{auth.RoleSuperadmin}); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// GetTenant returns a tenant by ID or slug.
+func (ts *TenantService) GetTenant(ctx context.Context, req *pb.TenantID) (*pb.Tenant, error) {
+	if err := requireDefaultTenant(ctx); err != nil {
+		return nil, err
+	}
+	t, err := ts.getTenant(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return toTenantMessage(t), nil
+}
+
+// ListTenants returns all tenants ordered by ID.
+func (ts *TenantService) ListTenants(ctx context.Context, req *pb.Empty) (*pb.TenantsList, error) {
+	if err := requireDefaultTenant(ctx); err != nil {
+		return nil, err
+	}
+	tenants, err := ts.store.ListTenants(ctx)
+	if err != nil {
+		log.Printf("Error listing tenants: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	resp := &pb.TenantsList{}
+	for _, t := range tenants {
+		resp.Tenants = append(resp.Tenants, toTenantMessage(t))
+	}
+	return resp, nil
+}
+
+// UpdateTenant renames a tenant and replaces its overrides.
+func (ts *TenantService) UpdateTenant(ctx context.Context, req *pb.UpdateTenantRequest) (*pb.Tenant, error) {
+	if err := requireDefaultTenant(ctx); err != nil {
+		return nil, err
+	}
+	t, err := ts.getTenant(ctx, &pb.TenantID{Id: req.Id})
+	if err != nil {
+		return nil, err
+	}
+	if name := strings.TrimSpace(req.Name); name != "" {
+		if len(name) > 100 {
+			return nil, invalidField("name", "Name must be at most 100 characters")
+		}
+		t.Name = name
+	}
+	if req.Config != nil {
+		if t.Config, err = ts.tenantConfig(req.Config); err != nil {
+			return nil, err
+		}
+	}
+
+	entry := tenantEntry(ctx, audit.TenantUpdated, t.ID, map[string]interface{}{"name": t.Name})
+	return ts.updateTenant(ctx, *t, entry)
+}
+
+// DisableTenant rejects all calls of a tenant. The default tenant cannot be
+// disabled since it manages the others.
+func (ts *TenantService) DisableTenant(ctx context.Context, req *pb.TenantID) (*pb.Tenant, error) {
+	return ts.setDisabled(ctx, req, true, audit.TenantDisabled)
+}
+
+// EnableTenant accepts the calls of a disabled tenant again.
+func (ts *TenantService) EnableTenant(ctx context.Context, req *pb.TenantID) (*pb.Tenant, error) {
+	return ts.setDisabled(ctx, req, false, audit.TenantEnabled)
+}
+
+func (ts *TenantService) setDisabled(ctx context.Context, req *pb.TenantID, disabled bool, action string) (*pb.Tenant, error) {
+	if err := requireDefaultTenant(ctx); err != nil {
+		return nil, err
+	}
+	t, err := ts.getTenant(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if disabled && t.ID == tenant.Default {
+		return nil, status.Errorf(codes.FailedPrecondition, "The default tenant cannot be disabled")
+	}
+	if t.Disabled == disabled {
+		return toTenantMessage(t), nil
+	}
+	t.Disabled = disabled
+	return ts.updateTenant(ctx, *t, tenantEntry(ctx, action, t.ID, nil))
+}
+
+func (ts *TenantService) updateTenant(ctx context.Context, t store.Tenant, entry audit.Entry) (*pb.Tenant, error) {
+	updated, err := ts.store.UpdateTenant(ctx, t, entry)
+	if err != nil {
+		return nil, tenantError(err)
+	}
+	ts.tenants.Forget(updated.ID)
+
+	log.Printf("Tenant %s: %s by %s", updated.Slug, entry.Action, entry.Actor)
+	return toTenantMessage(updated), nil
+}
+
+func (ts *TenantService) getTenant(ctx context.Context, req *pb.TenantID) (*store.Tenant, error) {
+	var t *store.Tenant
+	var err error
+	switch {
+	case req.Id != 0:
+		t, err = ts.store.GetTenant(ctx, req.Id)
+	case req.Slug != "":
+		t, err = ts.store.GetTenantBySlug(ctx, strings.ToLower(strings.TrimSpace(req.Slug)))
+	default:
+		return nil, invalidField("id", "ID or slug is required")
+	}
+	if err != nil {
+		return nil, tenantError(err)
+	}
+	return t, nil
+}
+
+// tenantConfig validates the overrides of a tenant. The phone countries are
+// checked together with the server settings they are combined with.
+func (ts *TenantService) tenantConfig(msg *pb.TenantConfig) (store.TenantConfig, error) {
+	var c store.TenantConfig
+	if msg == nil {
+		return c, nil
+	}
+	for _, code := range msg.PhoneCountries {
+		if code = strings.ToUpper(strings.TrimSpace(code)); code != "" {
+			c.PhoneCountries = append(c.PhoneCountries, code)
+		}
+	}
+	c.PhoneDefaultCountry = strings.ToUpper(strings.TrimSpace(msg.PhoneDefaultCountry))
+	if len(c.PhoneCountries) > 0 || c.PhoneDefaultCountry != "" {
+		countries, defaultCountry := phoneRules(ts.cfg, c)
+		if _, err := phone.NewNormalizer(countries, defaultCountry, ts.cfg.PhonePrefixes); err != nil {
+			return c, invalidField("config.phone_countries", fmt.Sprintf("Invalid phone countries: %v", err))
+		}
+	}
+
+	if msg.PhoneOtpTtl != nil {
+		if err := msg.PhoneOtpTtl.CheckValid(); err != nil {
+			return c, invalidField("config.phone_otp_ttl", "Invalid duration")
+		}
+		c.PhoneOTPTTL = msg.PhoneOtpTtl.AsDuration()
+		if c.PhoneOTPTTL < minTenantOTPTTL || c.PhoneOTPTTL > maxTenantOTPTTL || c.PhoneOTPTTL%time.Second != 0 {
+			return c, invalidField("config.phone_otp_ttl", fmt.Sprintf("Code TTL must be whole seconds between %s and %s", minTenantOTPTTL, maxTenantOTPTTL))
+		}
+	}
+	if msg.PhoneOtpMaxAttempts < 0 || msg.PhoneOtpMaxAttempts > 100 {
+		return c, invalidField("config.phone_otp_max_attempts", "Max attempts must be between 0 and 100")
+	}
+	c.PhoneOTPMaxAttempts = int(msg.PhoneOtpMaxAttempts)
+	return c, nil
+}
+
+// requireDefaultTenant rejects callers of other tenants than the default one.
+func requireDefaultTenant(ctx context.Context) error {
+	if tenant.ID(ctx) != tenant.Default {
+		return status.Errorf(codes.PermissionDenied, "Tenants can only be managed from the default tenant")
+	}
+	return nil
+}
+
+// tenantEntry returns the audit entry of an action on a tenant.
+func tenantEntry(ctx context.Context, action string, tenantID int64, details map[string]interface{}) audit.Entry {
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	if tenantID != 0 {
+		details["tenant_id"] = tenantID
+	}
+	return audit.Entry{Actor: audit.Actor(ctx), Action: action, Details: details}
+}
+
+func tenantError(err error) error {
+	if err == store.ErrNotFound {
+		return status.Errorf(codes.NotFound, "Tenant not found")
+	}
+	log.Printf("Error updating tenant: %v", err)
+	return status.Errorf(codes.Internal, "Internal server error")
+}
+
+func toTenantMessage(t *store.Tenant) *pb.Tenant {
+	msg := &pb.Tenant{
+		Id:         t.ID,
+		Slug:       t.Slug,
+		Name:       t.Name,
+		Disabled:   t.Disabled,
+		CreatedBy:  t.CreatedBy,
+		CreateTime: apitime.Timestamp(t.CreatedAt),
+		UpdateTime: apitime.Timestamp(t.UpdatedAt),
+		Config: &pb.TenantConfig{
+			PhoneCountries:      t.Config.PhoneCountries,
+			PhoneDefaultCountry: t.Config.PhoneDefaultCountry,
+			PhoneOtpMaxAttempts: int32(t.Config.PhoneOTPMaxAttempts),
+		},
+	}
+	if t.Config.PhoneOTPTTL > 0 {
+		msg.Config.PhoneOtpTtl = durationpb.New(t.Config.PhoneOTPTTL)
+	}
+	return msg
+}
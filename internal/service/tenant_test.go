@@ -0,0 +1,51 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/usertest"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestTenantsDoNotSeeEachOthersUsers(t *testing.T) {
+	srv := usertest.New(t)
+	ctx := context.Background()
+	superadmin := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+srv.AdminToken(t, "superadmin"))
+	if _, err := pb.NewTenantServiceClient(srv.Conn).CreateTenant(superadmin, &pb.CreateTenantRequest{Slug: "acme", Name: "Acme"}); err != nil {
+		t.Fatal(err)
+	}
+	client := pb.NewUserServiceClient(srv.Conn)
+	acme := metadata.AppendToOutgoingContext(ctx, "x-tenant", "acme")
+
+	created, err := client.CreateUser(acme, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetUserById(acme, &pb.UserID{PublicId: created.PublicId}); err != nil {
+		t.Fatalf("got %v in the tenant of the user", err)
+	}
+
+	if _, err := client.GetUserById(ctx, &pb.UserID{PublicId: created.PublicId}); status.Code(err) != codes.NotFound {
+		t.Errorf("got %v in the default tenant, want NotFound", err)
+	}
+	list, err := client.GetAllUsers(superadmin, &pb.PaginationRequest{Page: 1, PageSize: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Users) != 0 {
+		t.Errorf("got %d users in the default tenant, want none", len(list.Users))
+	}
+	// Credentials of the default tenant cannot be pointed at another tenant
+	elsewhere := metadata.AppendToOutgoingContext(superadmin, "x-tenant", "acme")
+	if _, err := client.GetUserById(elsewhere, &pb.UserID{PublicId: created.PublicId}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("got %v with credentials of the default tenant, want PermissionDenied", err)
+	}
+	// The phone number is only taken within its tenant
+	if _, err := client.CreateUser(ctx, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001"}); err != nil {
+		t.Errorf("got %v creating the same number in the default tenant", err)
+	}
+}
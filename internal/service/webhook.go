@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"log"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/outbox"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/webhook"
+	"github.com/lib/pq"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// knownEventTypes lists the event types a webhook can subscribe to.
+var knownEventTypes = map[string]bool{
+	outbox.UserCreated:   true,
+	outbox.UserUpdated:   true,
+	outbox.UserDeleted:   true,
+	outbox.UserBlocked:   true,
+	outbox.UserUnblocked: true,
+}
+
+// WebhookService manages webhook subscriptions. The webhook tables are not
+// covered by row-level security since the dispatcher serves all tenants, so
+// every statement filters by the tenant of the call.
+type WebhookService struct {
+	db           *sql.DB
+	allowPrivate bool
+	pb.UnimplementedWebhookServiceServer
+}
+
+// NewWebhookService creates a new instance of WebhookService with the provided database connection.
+// Unless allowPrivate is set, webhook URLs must use https and public hosts.
+func NewWebhookService(db *sql.DB, allowPrivate bool) pb.WebhookServiceServer {
+	return &WebhookService{db: db, allowPrivate: allowPrivate}
+}
+
+func (ws *WebhookService) RegisterWebhook(ctx context.Context, req *pb.RegisterWebhookRequest) (*pb.Webhook, error) {
+	if err := webhook.ValidateURL(req.Url, ws.allowPrivate); err != nil {
+		switch err {
+		case webhook.ErrInsecure:
+			return nil, status.Errorf(codes.InvalidArgument, "Webhook URL must use https")
+		case webhook.ErrPrivateTarget:
+			return nil, status.Errorf(codes.InvalidArgument, "Webhook URL must not point at a private address")
+		}
+		return nil, status.Errorf(codes.InvalidArgument, "Invalid webhook URL")
+	}
+
+	for _, eventType := range req.EventTypes {
+		if !knownEventTypes[eventType] {
+			return nil, status.Errorf(codes.InvalidArgument, "Unknown event type: %s", eventType)
+		}
+	}
+
+	// Generate a secret when the caller did not provide one
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			log.Printf("Error generating webhook secret: %v", err)
+			return nil, status.Errorf(codes.Internal, "Internal server error")
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	eventTypes := req.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	var hook pb.Webhook
+	var createdAt time.Time
+	err := ws.db.QueryRowContext(ctx,
+		"INSERT INTO webhook_subscriptions (tenant_id, url, secret, event_types) VALUES ($1, $2, $3, $4) RETURNING id, created_at",
+		tenant.ID(ctx), req.Url, secret, pq.Array(eventTypes)).Scan(&hook.Id, &createdAt)
+	if err != nil {
+		log.Printf("Error registering webhook: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	hook.Url = req.Url
+	hook.EventTypes = eventTypes
+	hook.CreatedAt = apitime.CustomTimestamp(createdAt)
+	hook.CreateTime = apitime.Timestamp(createdAt)
+	hook.Secret = secret
+
+	log.Printf("Webhook %d registered for %s", hook.Id, hook.Url)
+	return &hook, nil
+}
+
+func (ws *WebhookService) ListWebhooks(ctx context.Context, req *pb.Empty) (*pb.WebhooksList, error) {
+	rows, err := ws.db.QueryContext(ctx, "SELECT id, url, event_types, created_at FROM webhook_subscriptions WHERE tenant_id = $1 ORDER BY id", tenant.ID(ctx))
+	if err != nil {
+		log.Printf("Error querying webhooks: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	defer rows.Close()
+
+	var hooks []*pb.Webhook
+	for rows.Next() {
+		var hook pb.Webhook
+		var createdAt time.Time
+		if err := rows.Scan(&hook.Id, &hook.Url, pq.Array(&hook.EventTypes), &createdAt); err != nil {
+			log.Printf("Error scanning webhooks: %v", err)
+			return nil, status.Errorf(codes.Internal, "Internal server error")
+		}
+		hook.CreatedAt = apitime.CustomTimestamp(createdAt)
+		hook.CreateTime = apitime.Timestamp(createdAt)
+		hooks = append(hooks, &hook)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over webhooks: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	return &pb.WebhooksList{Webhooks: hooks}, nil
+}
+
+func (ws *WebhookService) DeleteWebhook(ctx context.Context, req *pb.WebhookID) (*pb.Empty, error) {
+	result, err := ws.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1 AND tenant_id = $2", req.Id, tenant.ID(ctx))
+	if err != nil {
+		log.Printf("Error deleting webhook: %v", err)
+		return nil, status.Error(codes.Internal, "Failed to delete webhook")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected: %v", err)
+		return nil, status.Error(codes.Internal, "Failed to delete webhook")
+	}
+
+	if rowsAffected == 0 {
+		return nil, status.Error(codes.NotFound, "Webhook not found")
+	}
+
+	log.Printf("Webhook with ID %d successfully deleted", req.Id)
+	return &pb.Empty{}, nil
+}
+
+// ListFailedDeliveries returns the dead-letter list: deliveries that exhausted all retry attempts.
+func (ws *WebhookService) ListFailedDeliveries(ctx context.Context, req *pb.ListFailedDeliveriesRequest) (*pb.WebhookDeliveriesList, error) {
+	pageSize := req.PageSize
+	if pageSize <= 0 {
+		pageSize = 50
+	}
+	page := req.Page
+	if page <= 0 {
+		page = 1
+	}
+	offset := (page - 1) * pageSize
+
+	query := `
+		SELECT dl.id, dl.event_id, e.event_type, dl.subscription_id, dl.status, dl.attempts, dl.last_error, dl.last_status_code, dl.created_at
+		FROM webhook_deliveries dl
+		JOIN outbox_events e ON e.id = dl.event_id
+		WHERE dl.status = $1 AND ($2 = 0 OR dl.subscription_id = $2) AND e.tenant_id = $5
+		ORDER BY dl.id
+		LIMIT $3 OFFSET $4
+	`
+	rows, err := ws.db.QueryContext(ctx, query, webhook.StatusDead, req.WebhookId, pageSize, offset, tenant.ID(ctx))
+	if err != nil {
+		log.Printf("Error querying failed deliveries: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+	defer rows.Close()
+
+	var deliveries []*pb.WebhookDelivery
+	for rows.Next() {
+		var delivery pb.WebhookDelivery
+		var lastError sql.NullString
+		var lastStatusCode sql.NullInt32
+		var createdAt time.Time
+		if err := rows.Scan(
+			&delivery.Id,
+			&delivery.EventId,
+			&delivery.EventType,
+			&delivery.WebhookId,
+			&delivery.Status,
+			&delivery.Attempts,
+			&lastError,
+			&lastStatusCode,
+			&createdAt,
+		); err != nil {
+			log.Printf("Error scanning failed deliveries: %v", err)
+			return nil, status.Errorf(codes.Internal, "Internal server error")
+		}
+		delivery.LastError = lastError.String
+		delivery.LastStatusCode = lastStatusCode.Int32
+		delivery.CreatedAt = apitime.CustomTimestamp(createdAt)
+		delivery.CreateTime = apitime.Timestamp(createdAt)
+		deliveries = append(deliveries, &delivery)
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating over failed deliveries: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	return &pb.WebhookDeliveriesList{Deliveries: deliveries, PreviousPage: page - 1, NextPage: page + 1}, nil
+}
+
+// ReplayDeliveries moves dead deliveries back to the pending queue with a fresh
+// attempt counter. Either explicit delivery IDs or a webhook ID must be given.
+func (ws *WebhookService) ReplayDeliveries(ctx context.Context, req *pb.ReplayDeliveriesRequest) (*pb.ReplayDeliveriesResponse, error) {
+	if len(req.DeliveryIds) == 0 && req.WebhookId == 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "Either delivery_ids or webhook_id is required")
+	}
+
+	query := `
+		UPDATE webhook_deliveries
+		SET status = $1, attempts = 0, next_attempt_at = CURRENT_TIMESTAMP
+		WHERE status = $2 AND (cardinality($3::BIGINT[]) = 0 OR id = ANY($3)) AND ($4 = 0 OR subscription_id = $4)
+			AND subscription_id IN (SELECT id FROM webhook_subscriptions WHERE tenant_id = $5)
+	`
+	result, err := ws.db.ExecContext(ctx, query, webhook.StatusPending, webhook.StatusDead, pq.Array(req.DeliveryIds), req.WebhookId, tenant.ID(ctx))
+	if err != nil {
+		log.Printf("Error replaying deliveries: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		log.Printf("Error getting rows affected: %v", err)
+		return nil, status.Errorf(codes.Internal, "Internal server error")
+	}
+
+	log.Printf("Replayed %d webhook deliveries", rowsAffected)
+	return &pb.ReplayDeliveriesResponse{Replayed: int32(rowsAffected)}, nil
+}
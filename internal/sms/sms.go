@@ -0,0 +1,103 @@
+// Package sms delivers text messages such as one-time codes.
+package sms
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Sender delivers a text message to an E.164 phone number.
+type Sender interface {
+	Send(ctx context.Context, to, text string) error
+}
+
+// HTTP posts messages as JSON {"to": ..., "text": ...} to an SMS gateway,
+// authenticating with a bearer token when one is set.
+type HTTP struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// NewHTTP creates a Sender for the gateway at url.
+func NewHTTP(url, token string) (*HTTP, error) {
+	if url == "" {
+		return nil, fmt.Errorf("SMS gateway URL is required")
+	}
+	return &HTTP{url: url, token: token, client: &http.Client{Timeout: 10 * time.Second}}, nil
+}
+
+func (s *HTTP) Send(ctx context.Context, to, text string) error {
+	body, err := json.Marshal(map[string]string{"to": to, "text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send SMS: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("SMS gateway responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// Log writes messages to the application log instead of sending them. It is
+// meant for local development only, since codes end up in the log.
+type Log struct{}
+
+func (Log) Send(ctx context.Context, to, text string) error {
+	log.Printf("SMS to %s: %s", to, text)
+	return nil
+}
+
+// Message is a text message recorded by Memory.
+type Message struct {
+	To   string
+	Text string
+}
+
+// Memory keeps sent messages in memory so tests can inspect them.
+type Memory struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemory creates an empty in-memory sender.
+func NewMemory() *Memory {
+	return &Memory{}
+}
+
+func (m *Memory) Send(ctx context.Context, to, text string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, Message{To: to, Text: text})
+	return nil
+}
+
+// Messages returns the messages sent so far, oldest first.
+func (m *Memory) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Message(nil), m.messages...)
+}
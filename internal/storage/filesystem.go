@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Filesystem stores objects in a local directory that is published under BaseURL,
+// for example by a reverse proxy serving static files.
+type Filesystem struct {
+	Dir     string
+	BaseURL string
+}
+
+// NewFilesystem creates a Filesystem storage rooted at dir.
+func NewFilesystem(dir, baseURL string) (*Filesystem, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %v", err)
+	}
+	return &Filesystem{Dir: dir, BaseURL: baseURL}, nil
+}
+
+func (fs *Filesystem) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(fs.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+
+	// Write to a temporary file first so readers never see a partial object
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to write file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write file: %v", err)
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return "", fmt.Errorf("failed to set file permissions: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", fmt.Errorf("failed to store file: %v", err)
+	}
+
+	return joinURL(fs.BaseURL, key), nil
+}
+
+func (fs *Filesystem) Key(url string) (string, bool) {
+	return keyOf(fs.BaseURL, url)
+}
+
+func (fs *Filesystem) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	err := os.Remove(filepath.Join(fs.Dir, filepath.FromSlash(key)))
+	if os.IsNotExist(err) {
+		return ErrNotFound
+	}
+	return err
+}
@@ -0,0 +1,189 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3Config holds the connection settings of an S3-compatible object store
+// (AWS S3, MinIO, Ceph RGW, ...).
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.eu-central-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PublicURL is the base URL objects are served from. Defaults to Endpoint/Bucket.
+	PublicURL string
+}
+
+// S3 stores objects in an S3-compatible bucket using path-style requests signed
+// with AWS Signature Version 4.
+type S3 struct {
+	cfg    S3Config
+	client *http.Client
+}
+
+// NewS3 creates an S3 storage from the given configuration.
+func NewS3(cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("S3 endpoint, bucket, access key and secret key are required")
+	}
+	if _, err := url.Parse(cfg.Endpoint); err != nil {
+		return nil, fmt.Errorf("invalid S3 endpoint: %v", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	if cfg.PublicURL == "" {
+		cfg.PublicURL = joinURL(cfg.Endpoint, cfg.Bucket)
+	}
+
+	return &S3{cfg: cfg, client: &http.Client{Timeout: 30 * time.Second}}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, data, time.Now().UTC())
+
+	if err := s.do(req, http.StatusOK); err != nil {
+		return "", err
+	}
+	return joinURL(s.cfg.PublicURL, key), nil
+}
+
+func (s *S3) Key(url string) (string, bool) {
+	return keyOf(s.cfg.PublicURL, url)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	s.sign(req, nil, time.Now().UTC())
+
+	return s.do(req, http.StatusNoContent, http.StatusOK)
+}
+
+func (s *S3) newRequest(ctx context.Context, method, key string, data []byte) (*http.Request, error) {
+	objectURL := joinURL(s.cfg.Endpoint, s.cfg.Bucket+"/"+escapePath(key))
+	req, err := http.NewRequestWithContext(ctx, method, objectURL, bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 request: %v", err)
+	}
+	return req, nil
+}
+
+func (s *S3) do(req *http.Request, expected ...int) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("S3 request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	for _, code := range expected {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("S3 request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
+
+// sign adds AWS Signature Version 4 headers to the request.
+func (s *S3) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signedHeaders = append([]string{"content-type"}, signedHeaders...)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), date)
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.cfg.AccessKey, scope, strings.Join(signedHeaders, ";"), signature))
+}
+
+// escapePath URI-encodes every segment of the key as required by SigV4.
+func escapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Storage persists uploaded files and returns the URL they are served from.
+type Storage interface {
+	// Put stores data under key, replacing any existing object, and returns its public URL.
+	Put(ctx context.Context, key string, data []byte, contentType string) (string, error)
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+	// Key returns the key of the object a URL returned by Put points at, or
+	// false for URLs of other origins.
+	Key(url string) (string, bool)
+}
+
+// validateKey rejects keys that could escape the storage root.
+func validateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "..") || strings.Contains(key, "\\") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
+
+// keyOf returns the key of an object URL under base, see joinURL.
+func keyOf(base, url string) (string, bool) {
+	prefix := strings.TrimSuffix(base, "/") + "/"
+	if !strings.HasPrefix(url, prefix) {
+		return "", false
+	}
+	key := strings.TrimPrefix(url, prefix)
+	if validateKey(key) != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// joinURL appends key to a base URL, avoiding duplicate slashes.
+func joinURL(base, key string) string {
+	return strings.TrimSuffix(base, "/") + "/" + key
+}
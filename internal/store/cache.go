@@ -0,0 +1,274 @@
+package store
+
+import (
+	"container/list"
+	"context"
+	"expvar"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"google.golang.org/protobuf/proto"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/flight"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+)
+
+// UserChangedChannel is the Postgres channel the users table trigger notifies
+// with the ID of every updated or deleted user.
+const UserChangedChannel = "user_changed"
+
+// cacheMetrics is published at /debug/vars as "user_cache".
+var cacheMetrics = expvar.NewMap("user_cache")
+
+// CacheStats counts the lookups and invalidations of a Cached store.
+type CacheStats struct {
+	Hits          int64
+	Misses        int64
+	Evictions     int64
+	Invalidations int64
+}
+
+// Cached is a Store that caches GetUser results. Entries expire after the TTL
+// and the least recently used entries are evicted beyond the size limit.
+// Entries are only returned to calls of the tenant that loaded them.
+// Mutations made through it invalidate the user, and Listen invalidates users
+// changed by other replicas.
+type Cached struct {
+	Store
+
+	ttl  time.Duration
+	size int
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[int64]*list.Element
+	lru     *list.List
+	// tenants are the tenants users were loaded for, to find their loads in flight
+	tenants map[int64]bool
+	// epoch changes with every invalidation, so a lookup that started before
+	// an invalidation does not cache the value it loaded.
+	epoch uint64
+	stats CacheStats
+
+	group flight.Group
+}
+
+type cacheEntry struct {
+	id        int64
+	tenantID  int64
+	user      *pb.GetUserResponse
+	expiresAt time.Time
+}
+
+// NewCached wraps store with a cache of up to size users kept for ttl.
+func NewCached(store Store, ttl time.Duration, size int) *Cached {
+	return &Cached{
+		Store:   store,
+		ttl:     ttl,
+		size:    size,
+		now:     time.Now,
+		entries: make(map[int64]*list.Element),
+		lru:     list.New(),
+		tenants: make(map[int64]bool),
+	}
+}
+
+// GetUser returns the cached user or loads it, collapsing concurrent loads of
+// the same user into one query.
+func (c *Cached) GetUser(ctx context.Context, id int64) (*pb.GetUserResponse, error) {
+	tenantID := tenant.ID(ctx)
+	c.mu.Lock()
+	if elem, ok := c.entries[id]; ok && elem.Value.(*cacheEntry).tenantID == tenantID {
+		entry := elem.Value.(*cacheEntry)
+		if c.now().Before(entry.expiresAt) {
+			c.lru.MoveToFront(elem)
+			c.count(&c.stats.Hits, "hits")
+			c.mu.Unlock()
+			return proto.Clone(entry.user).(*pb.GetUserResponse), nil
+		}
+		c.remove(elem)
+	}
+	c.count(&c.stats.Misses, "misses")
+	epoch := c.epoch
+	c.tenants[tenantID] = true
+	c.mu.Unlock()
+
+	value, err := c.group.Do(ctx, flightKey(tenantID, id), func(ctx context.Context) (interface{}, error) {
+		user, err := c.Store.GetUser(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		c.add(tenantID, id, user, epoch)
+		return user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(value.(*pb.GetUserResponse)).(*pb.GetUserResponse), nil
+}
+
+func (c *Cached) add(tenantID, id int64, user *pb.GetUserResponse, epoch uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.epoch != epoch {
+		return
+	}
+	if elem, ok := c.entries[id]; ok {
+		c.remove(elem)
+	}
+	c.entries[id] = c.lru.PushFront(&cacheEntry{id: id, tenantID: tenantID, user: user, expiresAt: c.now().Add(c.ttl)})
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+		c.count(&c.stats.Evictions, "evictions")
+	}
+}
+
+func (c *Cached) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).id)
+}
+
+func (c *Cached) count(counter *int64, name string) {
+	*counter++
+	cacheMetrics.Add(name, 1)
+}
+
+// Invalidate drops the cached user.
+func (c *Cached) Invalidate(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// A load in flight may have read the old row, so later lookups must not join it
+	for tenantID := range c.tenants {
+		c.group.Forget(flightKey(tenantID, id))
+	}
+	c.epoch++
+	if elem, ok := c.entries[id]; ok {
+		c.remove(elem)
+	}
+	c.count(&c.stats.Invalidations, "invalidations")
+}
+
+// Purge drops all cached users.
+func (c *Cached) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, elem := range c.entries {
+		entry := elem.Value.(*cacheEntry)
+		c.group.Forget(flightKey(entry.tenantID, entry.id))
+	}
+	c.epoch++
+	c.entries = make(map[int64]*list.Element)
+	c.lru.Init()
+}
+
+// flightKey identifies the load of a user for a tenant.
+func flightKey(tenantID, id int64) string {
+	return strconv.FormatInt(tenantID, 10) + "/" + strconv.FormatInt(id, 10)
+}
+
+// Stats returns the counters of this cache.
+func (c *Cached) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Listen invalidates users announced on UserChangedChannel until ctx is done.
+// The cache is purged whenever the connection is re-established, since
+// notifications sent in the meantime are lost.
+func (c *Cached) Listen(ctx context.Context, connectionString string) error {
+	listener := pq.NewListener(connectionString, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("User cache listener: %v", err)
+		}
+	})
+	if err := listener.Listen(UserChangedChannel); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		defer listener.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case notification := <-listener.Notify:
+				if notification == nil {
+					c.Purge()
+					continue
+				}
+				id, err := strconv.ParseInt(notification.Extra, 10, 64)
+				if err != nil {
+					log.Printf("Invalid user cache notification %q", notification.Extra)
+					continue
+				}
+				c.Invalidate(id)
+			case <-time.After(90 * time.Second):
+				// Detects dead connections the driver has not noticed yet
+				go listener.Ping()
+			}
+		}
+	}()
+	return nil
+}
+
+func (c *Cached) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	defer c.Invalidate(req.Id)
+	return c.Store.UpdateUser(ctx, req)
+}
+
+func (c *Cached) DeleteUser(ctx context.Context, id int64) error {
+	defer c.Invalidate(id)
+	return c.Store.DeleteUser(ctx, id)
+}
+
+func (c *Cached) SetBlocked(ctx context.Context, id int64, blocked bool) error {
+	defer c.Invalidate(id)
+	return c.Store.SetBlocked(ctx, id, blocked)
+}
+
+func (c *Cached) SetProfilePhotoURL(ctx context.Context, id int64, url string) (*pb.UpdateUserResponse, string, error) {
+	defer c.Invalidate(id)
+	return c.Store.SetProfilePhotoURL(ctx, id, url)
+}
+
+func (c *Cached) ConfirmEmail(ctx context.Context, tokenHash []byte, now time.Time) (*pb.UpdateUserResponse, error) {
+	user, err := c.Store.ConfirmEmail(ctx, tokenHash, now)
+	if err == nil {
+		c.Invalidate(user.Id)
+	}
+	return user, err
+}
+
+func (c *Cached) ConfirmPhoneChange(ctx context.Context, userID int64, codeHash []byte, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	defer c.Invalidate(userID)
+	return c.Store.ConfirmPhoneChange(ctx, userID, codeHash, policy, entry)
+}
+
+func (c *Cached) ChangePhoneNumber(ctx context.Context, userID int64, phoneNumber string, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	defer c.Invalidate(userID)
+	return c.Store.ChangePhoneNumber(ctx, userID, phoneNumber, policy, entry)
+}
+
+func (c *Cached) RevertUser(ctx context.Context, userID int64, target *pb.GetUserResponse, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	defer c.Invalidate(userID)
+	return c.Store.RevertUser(ctx, userID, target, policy, entry)
+}
+
+// MergeUsers also drops both users on conflicts, so a retry does not read
+// the same stale copies.
+func (c *Cached) MergeUsers(ctx context.Context, source, target, merged *pb.GetUserResponse, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	defer c.Invalidate(target.Id)
+	defer c.Invalidate(source.Id)
+	return c.Store.MergeUsers(ctx, source, target, merged, policy, entry)
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+)
+
+func TestCachedDropsUsersChangedThroughIt(t *testing.T) {
+	ctx := context.Background()
+	c := NewCached(NewMemory(), time.Hour, 10)
+	created, err := c.CreateUser(ctx, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetUser(ctx, created.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UpdateUser(ctx, &pb.UpdateUserRequest{Id: created.Id, FirstName: "Myrat"}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := c.GetUser(ctx, created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.FirstName != "Myrat" {
+		t.Errorf("got first name %q after the update, want %q", user.FirstName, "Myrat")
+	}
+	if c.Stats().Invalidations == 0 {
+		t.Error("the update did not invalidate the user")
+	}
+}
+
+func TestCachedDropsUsersChangedElsewhere(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemory()
+	c := NewCached(memory, time.Hour, 10)
+	created, err := memory.CreateUser(ctx, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetUser(ctx, created.Id); err != nil {
+		t.Fatal(err)
+	}
+	// Another replica changes the user and announces it
+	if _, err := memory.UpdateUser(ctx, &pb.UpdateUserRequest{Id: created.Id, FirstName: "Myrat"}); err != nil {
+		t.Fatal(err)
+	}
+	if user, _ := c.GetUser(ctx, created.Id); user.FirstName != "Aman" {
+		t.Fatalf("got first name %q before the notification, want the cached %q", user.FirstName, "Aman")
+	}
+	c.Invalidate(created.Id)
+	if user, _ := c.GetUser(ctx, created.Id); user.FirstName != "Myrat" {
+		t.Errorf("got first name %q after the notification, want %q", user.FirstName, "Myrat")
+	}
+}
+
+func TestCachedKeepsTenantsApart(t *testing.T) {
+	c := NewCached(NewMemory(), time.Hour, 10)
+	first := tenant.WithID(context.Background(), tenant.Default)
+	second := tenant.WithID(context.Background(), tenant.Default+1)
+	created, err := c.CreateUser(first, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetUser(first, created.Id); err != nil {
+		t.Fatal(err)
+	}
+	if user, err := c.GetUser(second, created.Id); err != ErrNotFound {
+		t.Errorf("got %v, %v from another tenant, want ErrNotFound", user, err)
+	}
+}
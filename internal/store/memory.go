@@ -0,0 +1,609 @@
+package store
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/outbox"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/ulid"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Memory is an in-memory Store for tests and local development. It enforces
+// the same uniqueness rules as the database schema. Events are recorded in
+// order instead of being written to an outbox table. Every tenant has a
+// Memory of its own, created on first use, so tenants cannot see each
+// other's data.
+type Memory struct {
+	mu          sync.RWMutex
+	tenantID    int64
+	users       map[int64]*pb.GetUserResponse
+	merged      map[int64]mergedUser
+	emailTokens map[string]emailToken
+	phoneChange map[int64]*phoneChange
+	released    []releasedPhone
+	loginCodes  map[int64]*loginCode
+	sessions    map[string]*memorySession
+	revisions   map[int64][]*UserRevision
+	admins      map[int64]*Admin
+	apiKeys     map[int64]*memoryAPIKey
+	attributes  map[string]*AttributeDefinition
+	tags        map[string]*Tag
+	userTags    map[int64]map[string]bool
+	notes       map[int64][]*UserNote
+	events      []outbox.Event
+	audit       []audit.Entry
+	shared      *memoryShared
+	now         func() time.Time
+}
+
+// memoryShared is the state shared by the stores of all tenants. Like
+// sequences in the database, IDs are unique across tenants.
+type memoryShared struct {
+	mu      sync.Mutex
+	stores  map[int64]*Memory
+	tenants map[int64]*Tenant
+	lastIDs map[string]int64
+}
+
+type phoneChange struct {
+	PhoneChange
+	attempts int
+}
+
+type releasedPhone struct {
+	userID      int64
+	phoneNumber string
+	releasedAt  time.Time
+	deleted     bool
+}
+
+type emailToken struct {
+	userID    int64
+	email     string
+	expiresAt time.Time
+}
+
+// NewMemory creates an empty in-memory store with the default tenant.
+func NewMemory() *Memory {
+	shared := &memoryShared{
+		stores:  make(map[int64]*Memory),
+		tenants: make(map[int64]*Tenant),
+		lastIDs: make(map[string]int64),
+	}
+	m := newTenantMemory(tenant.Default, shared)
+	shared.stores[tenant.Default] = m
+	now := m.now()
+	shared.tenants[tenant.Default] = &Tenant{ID: tenant.Default, Slug: "default", Name: "Default", CreatedBy: "system", CreatedAt: now, UpdatedAt: now}
+	shared.lastIDs["tenants"] = tenant.Default
+	return m
+}
+
+func newTenantMemory(tenantID int64, shared *memoryShared) *Memory {
+	return &Memory{
+		tenantID:    tenantID,
+		users:       make(map[int64]*pb.GetUserResponse),
+		merged:      make(map[int64]mergedUser),
+		emailTokens: make(map[string]emailToken),
+		phoneChange: make(map[int64]*phoneChange),
+		loginCodes:  make(map[int64]*loginCode),
+		sessions:    make(map[string]*memorySession),
+		revisions:   make(map[int64][]*UserRevision),
+		admins:      make(map[int64]*Admin),
+		apiKeys:     make(map[int64]*memoryAPIKey),
+		attributes:  make(map[string]*AttributeDefinition),
+		tags:        make(map[string]*Tag),
+		userTags:    make(map[int64]map[string]bool),
+		notes:       make(map[int64][]*UserNote),
+		shared:      shared,
+		now:         time.Now,
+	}
+}
+
+// scope returns the store of the tenant of ctx.
+func (m *Memory) scope(ctx context.Context) *Memory {
+	id := tenant.ID(ctx)
+	if id == m.tenantID {
+		return m
+	}
+
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+	scoped, ok := m.shared.stores[id]
+	if !ok {
+		scoped = newTenantMemory(id, m.shared)
+		scoped.now = m.now
+		m.shared.stores[id] = scoped
+	}
+	return scoped
+}
+
+// nextID returns the next ID of the table, unique across tenants.
+func (m *Memory) nextID(table string) int64 {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+	m.shared.lastIDs[table]++
+	return m.shared.lastIDs[table]
+}
+
+// Events returns the events recorded so far in the tenant of the store.
+func (m *Memory) Events() []outbox.Event {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]outbox.Event(nil), m.events...)
+}
+
+func (m *Memory) ListUsers(ctx context.Context, filter UserFilter, limit, offset int32) ([]*pb.GetUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := m.matchUsers(filter)
+
+	var users []*pb.GetUserResponse
+	for i := int(offset); i < len(ids) && len(users) < int(limit); i++ {
+		users = append(users, proto.Clone(m.users[ids[i]]).(*pb.GetUserResponse))
+	}
+	return users, nil
+}
+
+func (m *Memory) GetUser(ctx context.Context, id int64) (*pb.GetUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return proto.Clone(user).(*pb.GetUserResponse), nil
+}
+
+func (m *Memory) ResolvePublicIDs(ctx context.Context, publicIDs []string) (map[string]int64, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := make(map[string]bool, len(publicIDs))
+	for _, publicID := range publicIDs {
+		wanted[publicID] = true
+	}
+	ids := make(map[string]int64, len(publicIDs))
+	for _, user := range m.users {
+		if wanted[user.PublicId] {
+			ids[user.PublicId] = user.Id
+		}
+	}
+	return ids, nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (*pb.GetUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// Same order as the database: an exact match first, then the lowest ID
+	var found *pb.GetUserResponse
+	for _, user := range m.users {
+		if user.Email == "" || !strings.EqualFold(user.Email, email) {
+			continue
+		}
+		exact, foundExact := user.Email == email, found != nil && found.Email == email
+		if found == nil || exact && !foundExact || exact == foundExact && user.Id < found.Id {
+			found = user
+		}
+	}
+	if found == nil {
+		return nil, ErrNotFound
+	}
+	return proto.Clone(found).(*pb.GetUserResponse), nil
+}
+
+func (m *Memory) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.taken(0, req.PhoneNumber, req.Email) {
+		return nil, ErrAlreadyExists
+	}
+
+	now := m.now()
+	publicID, err := ulid.New(now)
+	if err != nil {
+		return nil, err
+	}
+	user := &pb.GetUserResponse{
+		Id:               m.nextID("users"),
+		PublicId:         publicID,
+		FirstName:        req.FirstName,
+		LastName:         req.LastName,
+		PhoneNumber:      req.PhoneNumber,
+		RegistrationDate: apitime.CustomTimestamp(now),
+		RegistrationTime: apitime.Timestamp(now),
+		Gender:           req.Gender,
+		Location:         req.Location,
+		Email:            req.Email,
+		ProfilePhotoUrl:  req.ProfilePhotoUrl,
+		Country:          phone.CountryOf(req.PhoneNumber),
+		CustomAttributes: cloneAttributes(req.CustomAttributes),
+	}
+	if req.DateOfBirth != nil {
+		user.DateOfBirth = proto.Clone(req.DateOfBirth).(*pb.DateOfBirth)
+		user.BirthDate = apitime.Date(user.DateOfBirth)
+	}
+	m.users[user.Id] = user
+
+	created := &pb.CreateUserResponse{
+		Id:               user.Id,
+		PublicId:         user.PublicId,
+		FirstName:        user.FirstName,
+		LastName:         user.LastName,
+		PhoneNumber:      user.PhoneNumber,
+		Blocked:          user.Blocked,
+		Gender:           user.Gender,
+		DateOfBirth:      user.DateOfBirth,
+		Location:         user.Location,
+		Email:            user.Email,
+		ProfilePhotoUrl:  user.ProfilePhotoUrl,
+		Country:          user.Country,
+		EmailVerified:    user.EmailVerified,
+		BirthDate:        user.BirthDate,
+		RegistrationTime: user.RegistrationTime,
+		CustomAttributes: user.CustomAttributes,
+	}
+	m.record(ctx, outbox.UserCreated, user.Id)
+	return proto.Clone(created).(*pb.CreateUserResponse), nil
+}
+
+func (m *Memory) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[req.Id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	email := user.Email
+	if req.Email == "null" {
+		email = ""
+	} else if req.Email != "" {
+		email = req.Email
+	}
+	phoneNumber := user.PhoneNumber
+	if req.PhoneNumber != "" {
+		phoneNumber = req.PhoneNumber
+	}
+	if m.taken(user.Id, phoneNumber, email) {
+		return nil, ErrAlreadyExists
+	}
+
+	setField(&user.FirstName, req.FirstName)
+	setField(&user.LastName, req.LastName)
+	if phoneNumber != user.PhoneNumber {
+		user.PhoneNumber = phoneNumber
+		user.Country = phone.CountryOf(phoneNumber)
+	}
+	setField(&user.Gender, req.Gender)
+	if req.DateOfBirth != nil && req.DateOfBirth.Year == 0 && req.DateOfBirth.Month == 0 && req.DateOfBirth.Day == 0 {
+		user.DateOfBirth = nil
+		user.BirthDate = nil
+	} else if req.DateOfBirth != nil {
+		user.DateOfBirth = proto.Clone(req.DateOfBirth).(*pb.DateOfBirth)
+		user.BirthDate = apitime.Date(user.DateOfBirth)
+	}
+	setField(&user.Location, req.Location)
+	if email != user.Email {
+		user.EmailVerified = false
+	}
+	user.Email = email
+	setField(&user.ProfilePhotoUrl, req.ProfilePhotoUrl)
+	user.CustomAttributes = mergeAttributes(user.CustomAttributes, req.CustomAttributes)
+
+	m.record(ctx, outbox.UserUpdated, user.Id)
+	return toUpdateResponse(user), nil
+}
+
+func (m *Memory) DeleteUser(ctx context.Context, id int64) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrNotFound
+	}
+	m.recordRevision(ctx, id, outbox.UserDeleted)
+	m.released = append(m.released, releasedPhone{userID: id, phoneNumber: m.users[id].PhoneNumber, releasedAt: m.now(), deleted: true})
+	delete(m.users, id)
+	delete(m.phoneChange, id)
+	delete(m.loginCodes, id)
+	delete(m.userTags, id)
+	delete(m.notes, id)
+	m.revokeSessions(id)
+	m.record(ctx, outbox.UserDeleted, id)
+	return nil
+}
+
+func (m *Memory) SetBlocked(ctx context.Context, id int64, blocked bool) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.Blocked = blocked
+
+	if blocked {
+		m.revokeSessions(id)
+		m.record(ctx, outbox.UserBlocked, id)
+	} else {
+		m.record(ctx, outbox.UserUnblocked, id)
+	}
+	return nil
+}
+
+func (m *Memory) GetUsersStatus(ctx context.Context, ids []int64, phoneNumbers []string) ([]UserStatus, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	wanted := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+	phones := make(map[string]bool, len(phoneNumbers))
+	for _, number := range phoneNumbers {
+		phones[number] = true
+	}
+
+	var statuses []UserStatus
+	for _, user := range m.users {
+		if wanted[user.Id] || phones[user.PhoneNumber] {
+			statuses = append(statuses, UserStatus{ID: user.Id, PublicID: user.PublicId, PhoneNumber: user.PhoneNumber, Blocked: user.Blocked})
+		}
+	}
+	for _, r := range m.released {
+		if _, exists := m.users[r.userID]; r.deleted && wanted[r.userID] && !exists {
+			statuses = append(statuses, UserStatus{ID: r.userID, Deleted: true})
+			wanted[r.userID] = false
+		}
+	}
+	return statuses, nil
+}
+
+func (m *Memory) SetProfilePhotoURL(ctx context.Context, id int64, url string) (*pb.UpdateUserResponse, string, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+	previous := user.ProfilePhotoUrl
+	user.ProfilePhotoUrl = url
+
+	m.record(ctx, outbox.UserUpdated, id)
+	return toUpdateResponse(user), previous, nil
+}
+
+func (m *Memory) CreateEmailVerification(ctx context.Context, userID int64, email string, tokenHash []byte, expiresAt time.Time) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, token := range m.emailTokens {
+		if token.userID == userID {
+			delete(m.emailTokens, hash)
+		}
+	}
+	m.emailTokens[string(tokenHash)] = emailToken{userID: userID, email: email, expiresAt: expiresAt}
+	return nil
+}
+
+func (m *Memory) ConfirmEmail(ctx context.Context, tokenHash []byte, now time.Time) (*pb.UpdateUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.emailTokens[string(tokenHash)]
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	if !now.Before(token.expiresAt) {
+		return nil, ErrExpired
+	}
+	delete(m.emailTokens, string(tokenHash))
+
+	user, ok := m.users[token.userID]
+	if !ok || user.Email != token.email {
+		return nil, ErrInvalidToken
+	}
+	user.EmailVerified = true
+
+	m.record(ctx, outbox.UserUpdated, user.Id)
+	return toUpdateResponse(user), nil
+}
+
+func (m *Memory) CheckPhoneAvailable(ctx context.Context, phoneNumber string, userID int64, since time.Time) error {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.checkPhoneAvailable(phoneNumber, userID, since)
+}
+
+func (m *Memory) StartPhoneChange(ctx context.Context, change PhoneChange) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[change.UserID]; !ok {
+		return ErrNotFound
+	}
+	m.phoneChange[change.UserID] = &phoneChange{PhoneChange: change}
+	return nil
+}
+
+func (m *Memory) ConfirmPhoneChange(ctx context.Context, userID int64, codeHash []byte, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	change, ok := m.phoneChange[userID]
+	if !ok {
+		return nil, ErrNoPendingChange
+	}
+	if !policy.Now.Before(change.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	if change.attempts >= policy.MaxAttempts {
+		return nil, ErrTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare(change.CodeHash, codeHash) != 1 {
+		change.attempts++
+		if change.attempts >= policy.MaxAttempts {
+			return nil, ErrTooManyAttempts
+		}
+		return nil, ErrCodeMismatch
+	}
+
+	user, err := m.changePhone(ctx, userID, change.PhoneNumber, policy, entry)
+	if err != nil {
+		return nil, err
+	}
+	delete(m.phoneChange, userID)
+	return user, nil
+}
+
+func (m *Memory) ChangePhoneNumber(ctx context.Context, userID int64, phoneNumber string, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, err := m.changePhone(ctx, userID, phoneNumber, policy, entry)
+	if err != nil {
+		return nil, err
+	}
+	delete(m.phoneChange, userID)
+	return user, nil
+}
+
+// AuditLog returns the audit entries recorded so far in the tenant of the store.
+func (m *Memory) AuditLog() []audit.Entry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]audit.Entry(nil), m.audit...)
+}
+
+func (m *Memory) changePhone(ctx context.Context, userID int64, phoneNumber string, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	user, ok := m.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if err := m.checkPhoneAvailable(phoneNumber, userID, policy.CoolDownSince); err != nil {
+		return nil, err
+	}
+
+	oldNumber := user.PhoneNumber
+	user.PhoneNumber = phoneNumber
+	user.Country = phone.CountryOf(phoneNumber)
+	m.released = append(m.released, releasedPhone{userID: userID, phoneNumber: oldNumber, releasedAt: policy.Now})
+
+	entry.ID = int64(len(m.audit) + 1)
+	entry.UserID = int64(userID)
+	entry.Details = map[string]string{"old_phone_number": oldNumber, "new_phone_number": phoneNumber}
+	entry.CreatedAt = m.now()
+	m.audit = append(m.audit, entry)
+
+	m.record(ctx, outbox.UserUpdated, userID)
+	return toUpdateResponse(user), nil
+}
+
+func (m *Memory) checkPhoneAvailable(phoneNumber string, userID int64, since time.Time) error {
+	if m.taken(userID, phoneNumber, "") {
+		return ErrAlreadyExists
+	}
+	for _, r := range m.released {
+		if r.phoneNumber == phoneNumber && r.userID != userID && r.releasedAt.After(since) {
+			return ErrInCoolDown
+		}
+	}
+	return nil
+}
+
+// taken reports whether another user than id already uses the phone number or email.
+func (m *Memory) taken(id int64, phoneNumber, email string) bool {
+	for _, user := range m.users {
+		if user.Id == id {
+			continue
+		}
+		if user.PhoneNumber == phoneNumber || (email != "" && user.Email == email) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) record(ctx context.Context, eventType string, id int64) {
+	m.publish(eventType, id)
+	m.recordRevision(ctx, id, eventType)
+}
+
+// publish records an event without a revision.
+func (m *Memory) publish(eventType string, id int64) {
+	m.events = append(m.events, outbox.Event{
+		ID:          int64(len(m.events) + 1),
+		Type:        eventType,
+		AggregateID: id,
+		CreatedAt:   m.now(),
+	})
+}
+
+// setField applies the UpdateUser convention: "" keeps the value, "null" clears it.
+func setField(field *string, value string) {
+	if value == "null" {
+		*field = ""
+	} else if value != "" {
+		*field = value
+	}
+}
+
+func toUpdateResponse(user *pb.GetUserResponse) *pb.UpdateUserResponse {
+	updated := &pb.UpdateUserResponse{
+		Id:              user.Id,
+		PublicId:        user.PublicId,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		PhoneNumber:     user.PhoneNumber,
+		Blocked:         user.Blocked,
+		Gender:          user.Gender,
+		Location:        user.Location,
+		Email:           user.Email,
+		ProfilePhotoUrl: user.ProfilePhotoUrl,
+		Country:         user.Country,
+		EmailVerified:   user.EmailVerified,
+		// Copied since the stored user is changed in place
+		CustomAttributes: cloneAttributes(user.CustomAttributes),
+	}
+	if user.DateOfBirth != nil {
+		updated.DateOfBirth = proto.Clone(user.DateOfBirth).(*pb.DateOfBirth)
+		updated.BirthDate = apitime.Date(user.DateOfBirth)
+	}
+	if user.RegistrationTime != nil {
+		updated.RegistrationTime = proto.Clone(user.RegistrationTime).(*timestamppb.Timestamp)
+	}
+	return updated
+}
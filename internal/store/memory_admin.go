@@ -0,0 +1,154 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+)
+
+func (m *Memory) CreateAdmin(ctx context.Context, admin Admin, entry audit.Entry) (*Admin, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.admins {
+		if existing.Username == admin.Username {
+			return nil, ErrAlreadyExists
+		}
+	}
+
+	now := m.now()
+	created := &Admin{
+		ID:                   m.nextID("admins"),
+		Username:             admin.Username,
+		Roles:                append([]string(nil), admin.Roles...),
+		PasswordHash:         admin.PasswordHash,
+		TOTPSecret:           admin.TOTPSecret,
+		CredentialsChangedAt: now,
+		CreatedAt:            now,
+	}
+	m.admins[created.ID] = created
+	m.writeAudit(entry)
+	return cloneAdmin(created), nil
+}
+
+func (m *Memory) GetAdmin(ctx context.Context, id int64) (*Admin, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	admin, ok := m.admins[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cloneAdmin(admin), nil
+}
+
+func (m *Memory) GetAdminByUsername(ctx context.Context, username string) (*Admin, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, admin := range m.admins {
+		if admin.Username == username {
+			return cloneAdmin(admin), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) ListAdmins(ctx context.Context) ([]*Admin, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	admins := make([]*Admin, 0, len(m.admins))
+	for _, admin := range m.admins {
+		admins = append(admins, cloneAdmin(admin))
+	}
+	sort.Slice(admins, func(i, j int) bool { return admins[i].Username < admins[j].Username })
+	return admins, nil
+}
+
+func (m *Memory) SetAdminDisabled(ctx context.Context, id int64, disabled bool, entry audit.Entry) (*Admin, error) {
+	m = m.scope(ctx)
+	return m.mutateAdmin(id, entry, func(admin *Admin) { admin.Disabled = disabled })
+}
+
+func (m *Memory) SetAdminRoles(ctx context.Context, id int64, roles []string, entry audit.Entry) (*Admin, error) {
+	m = m.scope(ctx)
+	return m.mutateAdmin(id, entry, func(admin *Admin) { admin.Roles = append([]string(nil), roles...) })
+}
+
+func (m *Memory) ResetAdminCredentials(ctx context.Context, id int64, passwordHash, totpSecret string, now time.Time, entry audit.Entry) (*Admin, error) {
+	m = m.scope(ctx)
+	return m.mutateAdmin(id, entry, func(admin *Admin) {
+		admin.PasswordHash = passwordHash
+		admin.TOTPSecret = totpSecret
+		admin.TOTPStep = 0
+		admin.FailedLogins = 0
+		admin.LockedUntil = time.Time{}
+		admin.CredentialsChangedAt = now
+	})
+}
+
+func (m *Memory) mutateAdmin(id int64, entry audit.Entry, change func(*Admin)) (*Admin, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	admin, ok := m.admins[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	change(admin)
+	m.writeAudit(entry)
+	return cloneAdmin(admin), nil
+}
+
+func (m *Memory) RecordAdminLogin(ctx context.Context, id, totpStep int64, now time.Time) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	admin, ok := m.admins[id]
+	if !ok || admin.TOTPStep >= totpStep {
+		return ErrInvalidToken
+	}
+	admin.FailedLogins = 0
+	admin.LockedUntil = time.Time{}
+	admin.TOTPStep = totpStep
+	admin.LastLoginAt = now
+	return nil
+}
+
+func (m *Memory) RecordAdminLoginFailure(ctx context.Context, id int64, policy LockoutPolicy) (*Admin, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	admin, ok := m.admins[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	admin.FailedLogins++
+	if admin.FailedLogins >= policy.MaxFailures {
+		admin.FailedLogins = 0
+		admin.LockedUntil = policy.Now.Add(policy.Duration)
+	}
+	return cloneAdmin(admin), nil
+}
+
+// writeAudit appends entry to the audit log. The caller holds the lock.
+func (m *Memory) writeAudit(entry audit.Entry) {
+	entry.ID = int64(len(m.audit) + 1)
+	entry.CreatedAt = m.now()
+	m.audit = append(m.audit, entry)
+}
+
+func cloneAdmin(admin *Admin) *Admin {
+	clone := *admin
+	clone.Roles = append([]string(nil), admin.Roles...)
+	return &clone
+}
@@ -0,0 +1,149 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+)
+
+type memoryAPIKey struct {
+	APIKey
+	hash []byte
+}
+
+func (m *Memory) CreateAPIKey(ctx context.Context, key APIKey, hash []byte, entry audit.Entry) (*APIKey, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.apiKeys {
+		if existing.Name == key.Name {
+			return nil, ErrAlreadyExists
+		}
+	}
+
+	created := &memoryAPIKey{
+		APIKey: APIKey{
+			ID:        m.nextID("api_keys"),
+			TenantID:  m.tenantID,
+			Name:      key.Name,
+			Prefix:    key.Prefix,
+			Scopes:    append([]string(nil), key.Scopes...),
+			ExpiresAt: key.ExpiresAt,
+			CreatedBy: key.CreatedBy,
+			CreatedAt: m.now(),
+		},
+		hash: hash,
+	}
+	m.apiKeys[created.ID] = created
+	m.writeAudit(entry)
+	return cloneAPIKey(&created.APIKey), nil
+}
+
+func (m *Memory) GetAPIKey(ctx context.Context, id int64) (*APIKey, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cloneAPIKey(&key.APIKey), nil
+}
+
+func (m *Memory) GetAPIKeyByHash(ctx context.Context, hash []byte) (*APIKey, error) {
+	// Keys are looked up before the tenant of a call is known
+	m.shared.mu.Lock()
+	stores := make([]*Memory, 0, len(m.shared.stores))
+	for _, store := range m.shared.stores {
+		stores = append(stores, store)
+	}
+	m.shared.mu.Unlock()
+
+	for _, store := range stores {
+		if key := store.apiKeyByHash(hash); key != nil {
+			return key, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) apiKeyByHash(hash []byte) *APIKey {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.apiKeys {
+		if bytes.Equal(key.hash, hash) {
+			return cloneAPIKey(&key.APIKey)
+		}
+	}
+	return nil
+}
+
+func (m *Memory) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(m.apiKeys))
+	for _, key := range m.apiKeys {
+		keys = append(keys, cloneAPIKey(&key.APIKey))
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Name < keys[j].Name })
+	return keys, nil
+}
+
+func (m *Memory) RotateAPIKey(ctx context.Context, id int64, prefix string, hash []byte, now time.Time, entry audit.Entry) (*APIKey, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok || !key.RevokedAt.IsZero() {
+		return nil, ErrNotFound
+	}
+	key.Prefix = prefix
+	key.hash = hash
+	key.RotatedAt = now
+	m.writeAudit(entry)
+	return cloneAPIKey(&key.APIKey), nil
+}
+
+func (m *Memory) RevokeAPIKey(ctx context.Context, id int64, now time.Time, entry audit.Entry) (*APIKey, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if key.RevokedAt.IsZero() {
+		key.RevokedAt = now
+	}
+	m.writeAudit(entry)
+	return cloneAPIKey(&key.APIKey), nil
+}
+
+func (m *Memory) TouchAPIKey(ctx context.Context, id int64, now time.Time) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[id]
+	if !ok {
+		return ErrNotFound
+	}
+	key.LastUsedAt = now
+	return nil
+}
+
+func cloneAPIKey(key *APIKey) *APIKey {
+	clone := *key
+	clone.Scopes = append([]string(nil), key.Scopes...)
+	return &clone
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+)
+
+func (m *Memory) PutAttributeDefinition(ctx context.Context, def AttributeDefinition, entry audit.Entry) (*AttributeDefinition, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	stored := cloneAttributeDefinition(&def)
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+	if existing, ok := m.attributes[def.Name]; ok {
+		stored.CreatedBy = existing.CreatedBy
+		stored.CreatedAt = existing.CreatedAt
+	}
+	m.attributes[def.Name] = stored
+	m.writeAudit(entry)
+	return cloneAttributeDefinition(stored), nil
+}
+
+func (m *Memory) ListAttributeDefinitions(ctx context.Context) ([]*AttributeDefinition, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	defs := make([]*AttributeDefinition, 0, len(m.attributes))
+	for _, def := range m.attributes {
+		defs = append(defs, cloneAttributeDefinition(def))
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
+
+func (m *Memory) DeleteAttributeDefinition(ctx context.Context, name string, entry audit.Entry) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.attributes[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m.attributes, name)
+	m.writeAudit(entry)
+	return nil
+}
+
+// hasAttributes reports whether attributes contains all values of want.
+func hasAttributes(attributes, want map[string]string) bool {
+	for name, value := range want {
+		if current, ok := attributes[name]; !ok || current != value {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeAttributes applies the changes of UpdateUser to attributes: values
+// are set, the value "null" removes an attribute. A new map is returned.
+func mergeAttributes(attributes, changes map[string]string) map[string]string {
+	merged := cloneAttributes(attributes)
+	for name, value := range changes {
+		if value == "null" {
+			delete(merged, name)
+		} else {
+			if merged == nil {
+				merged = make(map[string]string)
+			}
+			merged[name] = value
+		}
+	}
+	return merged
+}
+
+func cloneAttributes(attributes map[string]string) map[string]string {
+	if len(attributes) == 0 {
+		return nil
+	}
+	clone := make(map[string]string, len(attributes))
+	for name, value := range attributes {
+		clone[name] = value
+	}
+	return clone
+}
+
+func cloneAttributeDefinition(def *AttributeDefinition) *AttributeDefinition {
+	clone := *def
+	clone.AllowedValues = append([]string(nil), def.AllowedValues...)
+	return &clone
+}
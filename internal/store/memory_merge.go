@@ -0,0 +1,171 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/outbox"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"google.golang.org/protobuf/proto"
+)
+
+func (m *Memory) FindDuplicateCandidates(ctx context.Context, userID int64, limit int32) ([]DuplicateCandidate, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	ids := make([]int64, 0, len(m.users))
+	for id := range m.users {
+		ids = append(ids, id)
+	}
+	// Newest duplicates first, like in the database
+	sort.Slice(ids, func(i, j int) bool { return ids[i] > ids[j] })
+
+	var candidates []DuplicateCandidate
+	for i, b := range ids {
+		for _, a := range ids[i+1:] {
+			if int32(len(candidates)) == limit {
+				return candidates, nil
+			}
+			if userID != 0 && a != userID && b != userID {
+				continue
+			}
+			if !duplicateCandidates(m.users[a], m.users[b]) {
+				continue
+			}
+			first, second := a, b
+			if b == userID {
+				first, second = b, a
+			}
+			candidates = append(candidates, DuplicateCandidate{
+				User:      proto.Clone(m.users[first]).(*pb.GetUserResponse),
+				Duplicate: proto.Clone(m.users[second]).(*pb.GetUserResponse),
+			})
+		}
+	}
+	return candidates, nil
+}
+
+// duplicateCandidates matches users like the query of Postgres. Empty
+// values match nothing, like NULL columns.
+func duplicateCandidates(a, b *pb.GetUserResponse) bool {
+	equal := func(x, y string) bool { return x != "" && strings.EqualFold(x, y) }
+	sameName := equal(a.FirstName, b.FirstName) && equal(a.LastName, b.LastName)
+	sameBirthDate := a.DateOfBirth != nil && b.DateOfBirth != nil && proto.Equal(a.DateOfBirth, b.DateOfBirth)
+	return equal(a.Email, b.Email) || sameName || sameBirthDate
+}
+
+// mergedUser is a user merged into another user. Like in the database, it is
+// kept but no longer served.
+type mergedUser struct {
+	user *pb.GetUserResponse
+	into int64
+	at   time.Time
+}
+
+func (m *Memory) MergeUsers(ctx context.Context, source, target, merged *pb.GetUserResponse, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	sourceID, targetID := source.Id, target.Id
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	stored, ok := m.users[sourceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	user, ok := m.users[targetID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if !proto.Equal(stored, source) || !proto.Equal(user, target) {
+		return nil, ErrConflict
+	}
+	phoneChanged := merged.PhoneNumber != user.PhoneNumber
+	if phoneChanged && merged.PhoneNumber != source.PhoneNumber {
+		if err := m.checkPhoneAvailable(merged.PhoneNumber, targetID, policy.CoolDownSince); err != nil {
+			return nil, err
+		}
+	}
+	for _, other := range m.users {
+		if other.Id == sourceID || other.Id == targetID {
+			continue
+		}
+		if other.PhoneNumber == merged.PhoneNumber || (merged.Email != "" && other.Email == merged.Email) {
+			return nil, ErrAlreadyExists
+		}
+	}
+
+	m.tagUser(targetID, m.sortedTags(sourceID), entry.Actor)
+	m.notes[targetID] = append(m.notes[targetID], m.notes[sourceID]...)
+	notes := m.notes[targetID]
+	sort.Slice(notes, func(i, j int) bool { return notes[i].ID < notes[j].ID })
+	for _, s := range m.sessions {
+		if s.UserID == sourceID && !s.revoked {
+			s.UserID = targetID
+			s.UserPublicID = user.PublicId
+		}
+	}
+	for i := range m.released {
+		if m.released[i].userID == sourceID {
+			m.released[i].userID = targetID
+		}
+	}
+
+	// The history of the source ends like that of a deleted user
+	m.recordRevision(ctx, sourceID, outbox.UserDeleted)
+	m.released = append(m.released, releasedPhone{userID: sourceID, phoneNumber: source.PhoneNumber, releasedAt: policy.Now, deleted: true})
+	m.merged[sourceID] = mergedUser{user: stored, into: targetID, at: policy.Now}
+	delete(m.users, sourceID)
+	delete(m.phoneChange, sourceID)
+	delete(m.loginCodes, sourceID)
+	delete(m.userTags, sourceID)
+	delete(m.notes, sourceID)
+	m.revokeSessions(sourceID)
+
+	if phoneChanged {
+		m.released = append(m.released, releasedPhone{userID: targetID, phoneNumber: user.PhoneNumber, releasedAt: policy.Now})
+		delete(m.phoneChange, targetID)
+	}
+	if merged.Blocked != user.Blocked {
+		eventType := outbox.UserUnblocked
+		if merged.Blocked {
+			eventType = outbox.UserBlocked
+		}
+		m.publish(eventType, targetID)
+	}
+	if merged.Blocked {
+		m.revokeSessions(targetID)
+	}
+
+	user.FirstName = merged.FirstName
+	user.LastName = merged.LastName
+	user.PhoneNumber = merged.PhoneNumber
+	user.Country = phone.CountryOf(merged.PhoneNumber)
+	user.Gender = merged.Gender
+	user.DateOfBirth, user.BirthDate = nil, nil
+	if merged.DateOfBirth != nil {
+		user.DateOfBirth = proto.Clone(merged.DateOfBirth).(*pb.DateOfBirth)
+		user.BirthDate = apitime.Date(user.DateOfBirth)
+	}
+	user.Location = merged.Location
+	user.Email = merged.Email
+	user.EmailVerified = merged.Email != "" && merged.EmailVerified
+	user.ProfilePhotoUrl = merged.ProfilePhotoUrl
+	user.CustomAttributes = cloneAttributes(merged.CustomAttributes)
+	user.Blocked = merged.Blocked
+
+	entry.ID = int64(len(m.audit) + 1)
+	entry.UserID = targetID
+	entry.CreatedAt = m.now()
+	m.audit = append(m.audit, entry)
+
+	m.publish(outbox.UserDeleted, sourceID)
+	m.publish(outbox.UserUpdated, targetID)
+	m.recordRevision(ctx, targetID, audit.UserMerged)
+	return toUpdateResponse(user), nil
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/outbox"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"google.golang.org/protobuf/proto"
+)
+
+func (m *Memory) ListUserRevisions(ctx context.Context, userID int64, limit, offset int32) ([]*UserRevision, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	all := m.revisions[userID]
+	var revisions []*UserRevision
+	for i := len(all) - 1 - int(offset); i >= 0 && len(revisions) < int(limit); i-- {
+		revisions = append(revisions, cloneRevision(all[i]))
+	}
+	return revisions, nil
+}
+
+func (m *Memory) GetUserRevision(ctx context.Context, userID, revision int64) (*UserRevision, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	all := m.revisions[userID]
+	if revision < 1 || revision > int64(len(all)) {
+		return nil, ErrNotFound
+	}
+	return cloneRevision(all[revision-1]), nil
+}
+
+func (m *Memory) GetUserAsOf(ctx context.Context, userID int64, at time.Time) (*UserRevision, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	all := m.revisions[userID]
+	for i := len(all) - 1; i >= 0; i-- {
+		if !all[i].CreatedAt.After(at) {
+			return cloneRevision(all[i]), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) RevertUser(ctx context.Context, userID int64, target *pb.GetUserResponse, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	phoneChanged := target.PhoneNumber != user.PhoneNumber
+	if phoneChanged {
+		if err := m.checkPhoneAvailable(target.PhoneNumber, userID, policy.CoolDownSince); err != nil {
+			return nil, err
+		}
+	}
+	if m.taken(userID, target.PhoneNumber, target.Email) {
+		return nil, ErrAlreadyExists
+	}
+
+	if phoneChanged {
+		m.released = append(m.released, releasedPhone{userID: userID, phoneNumber: user.PhoneNumber, releasedAt: policy.Now})
+		delete(m.phoneChange, userID)
+	}
+	if target.Blocked != user.Blocked {
+		eventType := outbox.UserUnblocked
+		if target.Blocked {
+			eventType = outbox.UserBlocked
+			m.revokeSessions(userID)
+		}
+		m.publish(eventType, userID)
+	}
+
+	if target.Email != user.Email {
+		user.EmailVerified = false
+	}
+	user.FirstName = target.FirstName
+	user.LastName = target.LastName
+	user.PhoneNumber = target.PhoneNumber
+	user.Country = phone.CountryOf(target.PhoneNumber)
+	user.Gender = target.Gender
+	user.DateOfBirth, user.BirthDate = nil, nil
+	if target.DateOfBirth != nil {
+		user.DateOfBirth = proto.Clone(target.DateOfBirth).(*pb.DateOfBirth)
+		user.BirthDate = apitime.Date(user.DateOfBirth)
+	}
+	user.Location = target.Location
+	user.Email = target.Email
+	user.ProfilePhotoUrl = target.ProfilePhotoUrl
+	user.CustomAttributes = cloneAttributes(target.CustomAttributes)
+	user.Blocked = target.Blocked
+
+	entry.ID = int64(len(m.audit) + 1)
+	entry.UserID = userID
+	entry.CreatedAt = m.now()
+	m.audit = append(m.audit, entry)
+
+	m.publish(outbox.UserUpdated, userID)
+	m.recordRevision(ctx, userID, audit.UserReverted)
+	return toUpdateResponse(user), nil
+}
+
+// recordRevision snapshots the user as its next revision. The caller holds
+// the lock. Like in the database, nothing is recorded for deleted users.
+func (m *Memory) recordRevision(ctx context.Context, userID int64, action string) {
+	user, ok := m.users[userID]
+	if !ok {
+		return
+	}
+	m.revisions[userID] = append(m.revisions[userID], &UserRevision{
+		UserID:    userID,
+		Revision:  int64(len(m.revisions[userID]) + 1),
+		Actor:     audit.Actor(ctx),
+		Action:    action,
+		User:      proto.Clone(user).(*pb.GetUserResponse),
+		CreatedAt: m.now(),
+	})
+}
+
+func cloneRevision(revision *UserRevision) *UserRevision {
+	clone := *revision
+	clone.User = proto.Clone(revision.User).(*pb.GetUserResponse)
+	return &clone
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"sort"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"google.golang.org/protobuf/proto"
+)
+
+type loginCode struct {
+	LoginCode
+	attempts int
+}
+
+type memorySession struct {
+	Session
+	refreshHash  []byte
+	previousHash []byte
+	revoked      bool
+}
+
+func (m *Memory) GetUserByPhone(ctx context.Context, phoneNumber string) (*pb.GetUserResponse, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, user := range m.users {
+		if user.PhoneNumber == phoneNumber {
+			return proto.Clone(user).(*pb.GetUserResponse), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) StartLogin(ctx context.Context, code LoginCode) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[code.UserID]; !ok {
+		return ErrNotFound
+	}
+	m.loginCodes[code.UserID] = &loginCode{LoginCode: code}
+	return nil
+}
+
+func (m *Memory) ConsumeLoginCode(ctx context.Context, userID int64, codeHash []byte, maxAttempts int, now time.Time) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code, ok := m.loginCodes[userID]
+	if !ok {
+		return ErrInvalidToken
+	}
+	if !now.Before(code.ExpiresAt) {
+		return ErrExpired
+	}
+	if code.attempts >= maxAttempts {
+		return ErrTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare(code.CodeHash, codeHash) != 1 {
+		code.attempts++
+		if code.attempts >= maxAttempts {
+			return ErrTooManyAttempts
+		}
+		return ErrCodeMismatch
+	}
+
+	delete(m.loginCodes, userID)
+	return nil
+}
+
+func (m *Memory) CreateSession(ctx context.Context, session Session, refreshHash []byte) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[session.UserID]
+	if !ok {
+		return ErrNotFound
+	}
+	session.UserPublicID = user.PublicId
+	for _, s := range m.sessions {
+		if s.UserID == session.UserID && s.DeviceID == session.DeviceID {
+			s.revoked = true
+		}
+	}
+	m.sessions[session.ID] = &memorySession{Session: session, refreshHash: refreshHash}
+	return nil
+}
+
+func (m *Memory) RotateSession(ctx context.Context, refreshHash, newRefreshHash []byte, now, expiresAt time.Time) (*Session, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, s := range m.sessions {
+		if s.previousHash != nil && bytes.Equal(s.previousHash, refreshHash) && !s.revoked {
+			s.revoked = true
+			return nil, ErrTokenReused
+		}
+		if !bytes.Equal(s.refreshHash, refreshHash) {
+			continue
+		}
+
+		if s.revoked {
+			return nil, ErrInvalidToken
+		}
+		if !now.Before(s.ExpiresAt) {
+			return nil, ErrExpired
+		}
+		s.previousHash = s.refreshHash
+		s.refreshHash = newRefreshHash
+		s.LastUsedAt = now
+		s.ExpiresAt = expiresAt
+		session := s.Session
+		return &session, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+func (m *Memory) ListSessions(ctx context.Context, userID int64, now time.Time) ([]*Session, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var sessions []*Session
+	for _, s := range m.sessions {
+		if s.UserID == userID && !s.revoked && now.Before(s.ExpiresAt) {
+			session := s.Session
+			sessions = append(sessions, &session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt) })
+	return sessions, nil
+}
+
+func (m *Memory) RevokeSession(ctx context.Context, userID int64, sessionID string, now time.Time) error {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, ok := m.sessions[sessionID]
+	if !ok || s.UserID != userID || s.revoked {
+		return ErrNotFound
+	}
+	s.revoked = true
+	return nil
+}
+
+// revokeSessions revokes all sessions of a user. The caller holds the lock.
+func (m *Memory) revokeSessions(userID int64) {
+	for _, s := range m.sessions {
+		if s.UserID == userID {
+			s.revoked = true
+		}
+	}
+}
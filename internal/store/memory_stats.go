@@ -0,0 +1,66 @@
+package store
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+func (m *Memory) UserStats(ctx context.Context, q UserStatsQuery) (*UserStats, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	today := q.Now.In(q.Location)
+	stats := &UserStats{}
+	registrations := make(map[int64]int64)
+	genders := make(map[string]int64)
+	bands := make(map[string]int64)
+	locations := make(map[string]int64)
+	for _, id := range m.matchUsers(q.Filter) {
+		user := m.users[id]
+		stats.Total++
+		if user.Blocked {
+			stats.Blocked++
+		}
+		if user.RegistrationTime != nil {
+			registered := user.RegistrationTime.AsTime()
+			if !registered.Before(q.From) && registered.Before(q.To) {
+				stats.Registered++
+				registrations[bucketStart(registered, q.Interval, q.Location).Unix()]++
+			}
+		}
+		genders[statsKey(strings.ToLower(user.Gender))]++
+		if d := user.BirthDate; d != nil {
+			bands[ageBand(age(int(d.Year), time.Month(d.Month), int(d.Day), today))]++
+		} else {
+			bands[statsUnknown]++
+		}
+		locations[statsKey(user.Location)]++
+	}
+
+	deleted := make(map[int64]bool)
+	for _, r := range m.released {
+		if _, exists := m.users[r.userID]; r.deleted && !exists {
+			deleted[r.userID] = true
+		}
+	}
+	stats.Deleted = int64(len(deleted))
+
+	stats.Registrations = registrationBuckets(q, registrations)
+	stats.Genders = sortedCounts(genders)
+	stats.AgeBands = ageBandCounts(bands)
+	stats.Locations = sortedCounts(locations)
+	if len(stats.Locations) > q.LocationLimit {
+		stats.Locations = appendOther(stats.Locations[:q.LocationLimit], stats.Total)
+	}
+	return stats, nil
+}
+
+// statsKey trims a value, counting empty ones as "unknown".
+func statsKey(value string) string {
+	if value = strings.TrimSpace(value); value != "" {
+		return value
+	}
+	return statsUnknown
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+)
+
+// matchUsers returns the IDs of the users matching the filter in ascending
+// order. The caller holds the lock.
+func (m *Memory) matchUsers(filter UserFilter) []int64 {
+	var ids []int64
+	for id, user := range m.users {
+		if len(filter.IDs) > 0 && !containsID(filter.IDs, id) {
+			continue
+		}
+		if filter.Country != "" && user.Country != filter.Country {
+			continue
+		}
+		if filter.PhonePrefix != "" && !strings.HasPrefix(user.PhoneNumber, filter.PhonePrefix) {
+			continue
+		}
+		if filter.Blocked != nil && user.Blocked != *filter.Blocked {
+			continue
+		}
+		if !hasAttributes(user.CustomAttributes, filter.CustomAttributes) {
+			continue
+		}
+		if !m.hasTags(id, filter.Tags) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (m *Memory) hasTags(userID int64, tags []string) bool {
+	for _, tag := range tags {
+		if !m.userTags[userID][tag] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsID(ids []int64, id int64) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) GetUserTags(ctx context.Context, userID int64) ([]string, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrNotFound
+	}
+	return m.sortedTags(userID), nil
+}
+
+func (m *Memory) AddUserTags(ctx context.Context, userID int64, tags []string, entry audit.Entry) ([]string, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrNotFound
+	}
+	m.tagUser(userID, tags, entry.Actor)
+	m.writeAudit(entry)
+	return m.sortedTags(userID), nil
+}
+
+func (m *Memory) RemoveUserTags(ctx context.Context, userID int64, tags []string, entry audit.Entry) ([]string, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return nil, ErrNotFound
+	}
+	m.untagUser(userID, tags)
+	m.writeAudit(entry)
+	return m.sortedTags(userID), nil
+}
+
+func (m *Memory) BulkTagUsers(ctx context.Context, filter UserFilter, add, remove []string, entry audit.Entry) (int64, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	ids := m.matchUsers(filter)
+	for _, id := range ids {
+		m.tagUser(id, add, entry.Actor)
+		m.untagUser(id, remove)
+	}
+	if details, ok := entry.Details.(map[string]interface{}); ok {
+		details["matched_users"] = len(ids)
+	}
+	m.writeAudit(entry)
+	return int64(len(ids)), nil
+}
+
+func (m *Memory) ListTags(ctx context.Context) ([]*Tag, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	tags := make([]*Tag, 0, len(m.tags))
+	for _, tag := range m.tags {
+		clone := *tag
+		for _, userTags := range m.userTags {
+			if userTags[tag.Name] {
+				clone.UserCount++
+			}
+		}
+		tags = append(tags, &clone)
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// tagUser adds tags to the user and unknown tags to the catalog. The caller
+// holds the lock.
+func (m *Memory) tagUser(userID int64, tags []string, actor string) {
+	if len(tags) == 0 {
+		return
+	}
+	if m.userTags[userID] == nil {
+		m.userTags[userID] = make(map[string]bool)
+	}
+	for _, tag := range tags {
+		if _, ok := m.tags[tag]; !ok {
+			m.tags[tag] = &Tag{Name: tag, CreatedBy: actor, CreatedAt: m.now()}
+		}
+		m.userTags[userID][tag] = true
+	}
+}
+
+// untagUser removes tags from the user. The caller holds the lock.
+func (m *Memory) untagUser(userID int64, tags []string) {
+	for _, tag := range tags {
+		delete(m.userTags[userID], tag)
+	}
+}
+
+func (m *Memory) sortedTags(userID int64) []string {
+	tags := make([]string, 0, len(m.userTags[userID]))
+	for tag := range m.userTags[userID] {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+func (m *Memory) AddUserNote(ctx context.Context, note UserNote) (*UserNote, error) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[note.UserID]; !ok {
+		return nil, ErrNotFound
+	}
+	note.ID = m.nextID("user_notes")
+	note.CreatedAt = m.now()
+	m.notes[note.UserID] = append(m.notes[note.UserID], &note)
+	clone := note
+	return &clone, nil
+}
+
+func (m *Memory) ListUserNotes(ctx context.Context, userID int64, limit, offset int32) ([]*UserNote, error) {
+	m = m.scope(ctx)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	notes := m.notes[userID]
+	var page []*UserNote
+	for i := len(notes) - 1 - int(offset); i >= 0 && len(page) < int(limit); i-- {
+		clone := *notes[i]
+		page = append(page, &clone)
+	}
+	return page, nil
+}
@@ -0,0 +1,112 @@
+package store
+
+import (
+	"context"
+	"sort"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+)
+
+func (m *Memory) CreateTenant(ctx context.Context, t Tenant, entry audit.Entry) (*Tenant, error) {
+	created, err := m.putTenant(func(tenants map[int64]*Tenant) (*Tenant, error) {
+		for _, existing := range tenants {
+			if existing.Slug == t.Slug {
+				return nil, ErrAlreadyExists
+			}
+		}
+		now := m.now()
+		created := cloneTenant(&t)
+		created.ID = m.shared.lastIDs["tenants"] + 1
+		created.CreatedAt = now
+		created.UpdatedAt = now
+		m.shared.lastIDs["tenants"] = created.ID
+		tenants[created.ID] = created
+		return created, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.writeTenantAudit(ctx, entry)
+	return created, nil
+}
+
+func (m *Memory) GetTenant(ctx context.Context, id int64) (*Tenant, error) {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	t, ok := m.shared.tenants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return cloneTenant(t), nil
+}
+
+func (m *Memory) GetTenantBySlug(ctx context.Context, slug string) (*Tenant, error) {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	for _, t := range m.shared.tenants {
+		if t.Slug == slug {
+			return cloneTenant(t), nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *Memory) ListTenants(ctx context.Context) ([]*Tenant, error) {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	tenants := make([]*Tenant, 0, len(m.shared.tenants))
+	for _, t := range m.shared.tenants {
+		tenants = append(tenants, cloneTenant(t))
+	}
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].ID < tenants[j].ID })
+	return tenants, nil
+}
+
+func (m *Memory) UpdateTenant(ctx context.Context, t Tenant, entry audit.Entry) (*Tenant, error) {
+	updated, err := m.putTenant(func(tenants map[int64]*Tenant) (*Tenant, error) {
+		existing, ok := tenants[t.ID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		existing.Name = t.Name
+		existing.Config = cloneTenant(&t).Config
+		existing.Disabled = t.Disabled
+		existing.UpdatedAt = m.now()
+		return existing, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.writeTenantAudit(ctx, entry)
+	return updated, nil
+}
+
+// putTenant changes the tenants under the lock of the shared state and
+// returns a copy of the changed tenant.
+func (m *Memory) putTenant(change func(map[int64]*Tenant) (*Tenant, error)) (*Tenant, error) {
+	m.shared.mu.Lock()
+	defer m.shared.mu.Unlock()
+
+	t, err := change(m.shared.tenants)
+	if err != nil {
+		return nil, err
+	}
+	return cloneTenant(t), nil
+}
+
+// writeTenantAudit writes entry to the audit log of the calling tenant.
+func (m *Memory) writeTenantAudit(ctx context.Context, entry audit.Entry) {
+	m = m.scope(ctx)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.writeAudit(entry)
+}
+
+func cloneTenant(t *Tenant) *Tenant {
+	clone := *t
+	clone.Config.PhoneCountries = append([]string(nil), t.Config.PhoneCountries...)
+	return &clone
+}
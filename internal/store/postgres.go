@@ -0,0 +1,851 @@
+package store
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/outbox"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/utils"
+	"github.com/lib/pq"
+)
+
+const userColumns = "id, public_id, first_name, last_name, phone_number, blocked, registration_date, gender, date_of_birth, location, email, profile_photo_url, country, email_verified, custom_attributes"
+
+const mutatedUserColumns = "id, public_id, first_name, last_name, phone_number, blocked, gender, date_of_birth, location, email, profile_photo_url, country, email_verified, registration_date, custom_attributes"
+
+// Postgres is the PostgreSQL implementation of Store.
+type Postgres struct {
+	db *sql.DB
+}
+
+// NewPostgres creates a Store backed by the given database connection.
+func NewPostgres(db *sql.DB) *Postgres {
+	return &Postgres{db: db}
+}
+
+// begin starts a transaction scoped to the tenant of ctx. The row-level
+// security policies of the schema only let it see rows of that tenant, and
+// rows it inserts take the tenant's ID. Reads use a transaction too, so the
+// setting never outlives the statements it was made for.
+func (p *Postgres) begin(ctx context.Context) (*sql.Tx, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", strconv.FormatInt(tenant.ID(ctx), 10)); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	return tx, nil
+}
+
+// exec runs a single statement in a transaction started with begin.
+func (p *Postgres) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *Postgres) ListUsers(ctx context.Context, filter UserFilter, limit, offset int32) ([]*pb.GetUserResponse, error) {
+	where, args := userFilterClause(filter, 2)
+	query := "SELECT " + userColumns + " FROM users WHERE " + where + " ORDER BY id LIMIT $1 OFFSET $2"
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, append([]interface{}{limit, offset}, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %v", err)
+	}
+	defer rows.Close()
+
+	// Iterate over the rows returned by the query
+	var users []*pb.GetUserResponse
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %v", err)
+		}
+		users = append(users, user)
+	}
+
+	// Check for any errors that occurred during iteration
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over users: %v", err)
+	}
+	return users, nil
+}
+
+func (p *Postgres) GetUser(ctx context.Context, id int64) (*pb.GetUserResponse, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE id = $1 AND merged_into IS NULL"
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Execute the query with the user's ID
+	user, err := scanUser(tx.QueryRowContext(ctx, query, id))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %v", err)
+	}
+	return user, nil
+}
+
+func (p *Postgres) ResolvePublicIDs(ctx context.Context, publicIDs []string) (map[string]int64, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT public_id, id FROM users WHERE public_id = ANY($1) AND merged_into IS NULL", pq.Array(publicIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve public IDs: %v", err)
+	}
+	defer rows.Close()
+
+	ids := make(map[string]int64, len(publicIDs))
+	for rows.Next() {
+		var publicID string
+		var id int64
+		if err := rows.Scan(&publicID, &id); err != nil {
+			return nil, fmt.Errorf("failed to scan public ID: %v", err)
+		}
+		ids[publicID] = id
+	}
+	return ids, rows.Err()
+}
+
+func (p *Postgres) GetUserByEmail(ctx context.Context, email string) (*pb.GetUserResponse, error) {
+	// Older rows may differ only in case, an exact match wins then
+	query := "SELECT " + userColumns + " FROM users WHERE LOWER(email) = LOWER($1) AND merged_into IS NULL ORDER BY email = $1 DESC, id LIMIT 1"
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRowContext(ctx, query, email))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %v", err)
+	}
+	return user, nil
+}
+
+func (p *Postgres) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	var dateOfBirth pq.NullTime
+	if req.DateOfBirth != nil {
+		dateOfBirth.Time = utils.ToDate(req.DateOfBirth.Year, req.DateOfBirth.Month, req.DateOfBirth.Day)
+		dateOfBirth.Valid = true
+	}
+
+	query := `
+		INSERT INTO users (first_name, last_name, phone_number, blocked, gender, date_of_birth, location, email, profile_photo_url, country, custom_attributes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING ` + mutatedUserColumns
+
+	// Insert the user and its outbox event in a single transaction
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, query,
+		utils.CreateNullString(req.FirstName),
+		utils.CreateNullString(req.LastName),
+		req.PhoneNumber,
+		false,
+		utils.CreateNullString(req.Gender),
+		dateOfBirth,
+		utils.CreateNullString(req.Location),
+		utils.CreateNullString(req.Email),
+		utils.CreateNullString(req.ProfilePhotoUrl),
+		utils.CreateNullString(phone.CountryOf(req.PhoneNumber)),
+		attributesJSON(req.CustomAttributes),
+	)
+	updated, err := scanMutatedUser(row)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	user := &pb.CreateUserResponse{
+		Id:               updated.Id,
+		PublicId:         updated.PublicId,
+		FirstName:        updated.FirstName,
+		LastName:         updated.LastName,
+		PhoneNumber:      updated.PhoneNumber,
+		Blocked:          updated.Blocked,
+		Gender:           updated.Gender,
+		DateOfBirth:      updated.DateOfBirth,
+		Location:         updated.Location,
+		Email:            updated.Email,
+		ProfilePhotoUrl:  updated.ProfilePhotoUrl,
+		Country:          updated.Country,
+		EmailVerified:    updated.EmailVerified,
+		BirthDate:        updated.BirthDate,
+		RegistrationTime: updated.RegistrationTime,
+		CustomAttributes: updated.CustomAttributes,
+	}
+
+	if err := commitWithEvent(ctx, tx, outbox.UserCreated, user.Id, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (p *Postgres) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	// Build the UPDATE query
+	query := "UPDATE users SET "
+	var args []interface{}
+
+	// Check and add fields to the query and args based on the provided fields in the request
+	argCount := 1
+	setField := func(column, value string, nullable bool) {
+		if nullable && value == "null" {
+			query += column + " = NULL, "
+		} else if value != "" {
+			query += column + " = $" + strconv.Itoa(argCount) + ", "
+			args = append(args, value)
+			argCount++
+		}
+	}
+
+	setField("first_name", req.FirstName, true)
+	setField("last_name", req.LastName, true)
+	setField("phone_number", req.PhoneNumber, false)
+	if req.PhoneNumber != "" {
+		setField("country", phone.CountryOf(req.PhoneNumber), false)
+	}
+	setField("gender", req.Gender, true)
+
+	if req.DateOfBirth != nil && req.DateOfBirth.Year == 0 && req.DateOfBirth.Month == 0 && req.DateOfBirth.Day == 0 {
+		query += "date_of_birth = NULL, "
+	} else if req.DateOfBirth != nil {
+		query += "date_of_birth = $" + strconv.Itoa(argCount) + ", "
+		args = append(args, utils.ToDate(req.DateOfBirth.Year, req.DateOfBirth.Month, req.DateOfBirth.Day))
+		argCount++
+	}
+
+	setField("location", req.Location, true)
+	// A changed email address has to be verified again
+	if req.Email == "null" {
+		query += "email_verified = false, "
+	} else if req.Email != "" {
+		query += "email_verified = email_verified AND email IS NOT DISTINCT FROM $" + strconv.Itoa(argCount) + ", "
+		args = append(args, req.Email)
+		argCount++
+	}
+	setField("email", req.Email, true)
+	setField("profile_photo_url", req.ProfilePhotoUrl, true)
+
+	// Custom attributes are merged into the stored ones, "null" removes one
+	if len(req.CustomAttributes) > 0 {
+		set := make(map[string]string, len(req.CustomAttributes))
+		removed := []string{}
+		for name, value := range req.CustomAttributes {
+			if value == "null" {
+				removed = append(removed, name)
+			} else {
+				set[name] = value
+			}
+		}
+		query += "custom_attributes = (custom_attributes || $" + strconv.Itoa(argCount) + "::jsonb) - $" + strconv.Itoa(argCount+1) + "::text[], "
+		args = append(args, attributesJSON(set), pq.Array(removed))
+		argCount += 2
+	}
+
+	// Nothing to change: keep the row as is but still return it
+	if query == "UPDATE users SET " {
+		query += "id = id, "
+	}
+
+	// Remove the trailing comma and space from the query
+	query = strings.TrimSuffix(query, ", ")
+
+	// Add the WHERE clause to identify the user by ID
+	query += " WHERE id = $" + strconv.Itoa(argCount) + " AND merged_into IS NULL"
+	args = append(args, req.Id)
+
+	// Define the SQL query to return the updated user details
+	query += " RETURNING " + mutatedUserColumns
+
+	// Update the user and write its outbox event in a single transaction
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	user, err := scanMutatedUser(tx.QueryRowContext(ctx, query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to update user: %v", err)
+	}
+
+	if err := commitWithEvent(ctx, tx, outbox.UserUpdated, user.Id, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (p *Postgres) DeleteUser(ctx context.Context, id int64) error {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The last state is kept as the deletion revision, the row is gone afterwards
+	if err := recordRevision(ctx, tx, id, outbox.UserDeleted); err != nil {
+		return err
+	}
+
+	// Execute a DELETE query with a WHERE clause to remove the user with the given ID.
+	var phoneNumber, publicID string
+	err = tx.QueryRowContext(ctx, "DELETE FROM users WHERE id=$1 AND merged_into IS NULL RETURNING phone_number, public_id", id).Scan(&phoneNumber, &publicID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	// Keep the number in the history so it is not handed to another account right away
+	if err := releasePhone(ctx, tx, id, phoneNumber, "", phoneReleasedDeleted, time.Now()); err != nil {
+		return err
+	}
+
+	return commitWithEvent(ctx, tx, outbox.UserDeleted, id, &pb.UserID{Id: id, PublicId: publicID})
+}
+
+func (p *Postgres) GetUsersStatus(ctx context.Context, ids []int64, phoneNumbers []string) ([]UserStatus, error) {
+	// Deleted users only remain in the phone history, so both are read in one statement
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, public_id, phone_number, blocked, false FROM users
+		WHERE (id = ANY($1) OR phone_number = ANY($2)) AND merged_into IS NULL
+		UNION ALL
+		SELECT DISTINCT h.user_id, '', '', false, true FROM phone_number_history h
+		WHERE h.change_type = $3 AND h.user_id = ANY($1)
+			AND NOT EXISTS (SELECT 1 FROM users u WHERE u.id = h.user_id AND u.merged_into IS NULL)`,
+		pq.Array(ids), pq.Array(phoneNumbers), phoneReleasedDeleted)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []UserStatus
+	for rows.Next() {
+		var s UserStatus
+		if err := rows.Scan(&s.ID, &s.PublicID, &s.PhoneNumber, &s.Blocked, &s.Deleted); err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, rows.Err()
+}
+
+func (p *Postgres) SetBlocked(ctx context.Context, id int64, blocked bool) error {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Execute an UPDATE query with a WHERE clause to set the "blocked" field to the specified status for the given user ID.
+	var publicID string
+	err = tx.QueryRowContext(ctx, "UPDATE users SET blocked=$1 WHERE id=$2 AND merged_into IS NULL RETURNING public_id", blocked, id).Scan(&publicID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	eventType := outbox.UserUnblocked
+	if blocked {
+		eventType = outbox.UserBlocked
+		if err := revokeSessions(ctx, tx, id, time.Now()); err != nil {
+			return err
+		}
+	}
+	return commitWithEvent(ctx, tx, eventType, id, &pb.UserID{Id: id, PublicId: publicID})
+}
+
+func (p *Postgres) SetProfilePhotoURL(ctx context.Context, id int64, url string) (*pb.UpdateUserResponse, string, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var previous sql.NullString
+	err = tx.QueryRowContext(ctx, "SELECT profile_photo_url FROM users WHERE id = $1 AND merged_into IS NULL FOR UPDATE", id).Scan(&previous)
+	if err == sql.ErrNoRows {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to lock user: %v", err)
+	}
+
+	query := "UPDATE users SET profile_photo_url = $1 WHERE id = $2 RETURNING " + mutatedUserColumns
+	user, err := scanMutatedUser(tx.QueryRowContext(ctx, query, url, id))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to update profile photo: %v", err)
+	}
+
+	if err := commitWithEvent(ctx, tx, outbox.UserUpdated, user.Id, user); err != nil {
+		return nil, "", err
+	}
+	return user, previous.String, nil
+}
+
+func (p *Postgres) CreateEmailVerification(ctx context.Context, userID int64, email string, tokenHash []byte, expiresAt time.Time) error {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Only the most recent token of a user stays valid
+	if _, err := tx.ExecContext(ctx, "DELETE FROM email_verification_tokens WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete pending tokens: %v", err)
+	}
+
+	query := "INSERT INTO email_verification_tokens (token_hash, user_id, email, expires_at) VALUES ($1, $2, $3, $4)"
+	if _, err := tx.ExecContext(ctx, query, tokenHash, userID, email, expiresAt.UTC()); err != nil {
+		return fmt.Errorf("failed to store verification token: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func (p *Postgres) ConfirmEmail(ctx context.Context, tokenHash []byte, now time.Time) (*pb.UpdateUserResponse, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Deleting the token makes it single-use even under concurrent confirmations
+	var userID int64
+	var email string
+	var expiresAt time.Time
+	query := "DELETE FROM email_verification_tokens WHERE token_hash = $1 RETURNING user_id, email, expires_at"
+	err = tx.QueryRowContext(ctx, query, tokenHash).Scan(&userID, &email, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to consume verification token: %v", err)
+	}
+	if !now.UTC().Before(expiresAt) {
+		return nil, ErrExpired
+	}
+
+	query = "UPDATE users SET email_verified = true WHERE id = $1 AND email = $2 AND merged_into IS NULL RETURNING " + mutatedUserColumns
+	user, err := scanMutatedUser(tx.QueryRowContext(ctx, query, userID, email))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify email: %v", err)
+	}
+
+	if err := commitWithEvent(ctx, tx, outbox.UserUpdated, user.Id, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (p *Postgres) CheckPhoneAvailable(ctx context.Context, phoneNumber string, userID int64, since time.Time) error {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	return checkPhoneAvailable(ctx, tx, phoneNumber, userID, since)
+}
+
+func (p *Postgres) StartPhoneChange(ctx context.Context, change PhoneChange) error {
+	query := `
+		INSERT INTO phone_change_requests (user_id, phone_number, code_hash, attempts, expires_at)
+		VALUES ($1, $2, $3, 0, $4)
+		ON CONFLICT (user_id) DO UPDATE
+		SET phone_number = EXCLUDED.phone_number, code_hash = EXCLUDED.code_hash,
+			attempts = 0, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP`
+
+	_, err := p.exec(ctx, query, change.UserID, change.PhoneNumber, change.CodeHash, change.ExpiresAt.UTC())
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to store phone change: %v", err)
+	}
+	return nil
+}
+
+func (p *Postgres) ConfirmPhoneChange(ctx context.Context, userID int64, codeHash []byte, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var change PhoneChange
+	var attempts int
+	query := "SELECT phone_number, code_hash, attempts, expires_at FROM phone_change_requests WHERE user_id = $1 FOR UPDATE"
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&change.PhoneNumber, &change.CodeHash, &attempts, &change.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoPendingChange
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch phone change: %v", err)
+	}
+
+	if !policy.Now.UTC().Before(change.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	if attempts >= policy.MaxAttempts {
+		return nil, ErrTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare(change.CodeHash, codeHash) != 1 {
+		// The failed attempt has to be persisted, so commit before reporting it
+		if _, err := tx.ExecContext(ctx, "UPDATE phone_change_requests SET attempts = attempts + 1 WHERE user_id = $1", userID); err != nil {
+			return nil, fmt.Errorf("failed to count attempt: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		if attempts+1 >= policy.MaxAttempts {
+			return nil, ErrTooManyAttempts
+		}
+		return nil, ErrCodeMismatch
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM phone_change_requests WHERE user_id = $1", userID); err != nil {
+		return nil, fmt.Errorf("failed to delete phone change: %v", err)
+	}
+
+	user, err := changePhone(ctx, tx, userID, change.PhoneNumber, phoneReleasedVerified, policy, entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := commitWithEvent(ctx, tx, outbox.UserUpdated, user.Id, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (p *Postgres) ChangePhoneNumber(ctx context.Context, userID int64, phoneNumber string, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// A pending verified change would otherwise overwrite the override later
+	if _, err := tx.ExecContext(ctx, "DELETE FROM phone_change_requests WHERE user_id = $1", userID); err != nil {
+		return nil, fmt.Errorf("failed to delete phone change: %v", err)
+	}
+
+	user, err := changePhone(ctx, tx, userID, phoneNumber, phoneReleasedOverride, policy, entry)
+	if err != nil {
+		return nil, err
+	}
+	if err := commitWithEvent(ctx, tx, outbox.UserUpdated, user.Id, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// How a phone number was released, recorded in the phone history.
+const (
+	phoneReleasedVerified = "verified"
+	phoneReleasedOverride = "override"
+	phoneReleasedDeleted  = "deleted"
+)
+
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// changePhone sets the phone number of a user inside tx, moving the old
+// number to the phone history and writing the audit entry.
+func changePhone(ctx context.Context, tx *sql.Tx, userID int64, phoneNumber, changeType string, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	var oldNumber string
+	err := tx.QueryRowContext(ctx, "SELECT phone_number FROM users WHERE id = $1 AND merged_into IS NULL FOR UPDATE", userID).Scan(&oldNumber)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %v", err)
+	}
+
+	if err := checkPhoneAvailable(ctx, tx, phoneNumber, userID, policy.CoolDownSince); err != nil {
+		return nil, err
+	}
+
+	query := "UPDATE users SET phone_number = $1, country = $2 WHERE id = $3 RETURNING " + mutatedUserColumns
+	user, err := scanMutatedUser(tx.QueryRowContext(ctx, query, phoneNumber, utils.CreateNullString(phone.CountryOf(phoneNumber)), userID))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to change phone number: %v", err)
+	}
+
+	if err := releasePhone(ctx, tx, userID, oldNumber, phoneNumber, changeType, policy.Now); err != nil {
+		return nil, err
+	}
+
+	entry.UserID = int64(userID)
+	entry.Details = map[string]string{"old_phone_number": oldNumber, "new_phone_number": phoneNumber}
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkPhoneAvailable implements CheckPhoneAvailable on a connection or transaction.
+func checkPhoneAvailable(ctx context.Context, db rowQueryer, phoneNumber string, userID int64, since time.Time) error {
+	var taken, coolingDown bool
+	query := `
+		SELECT
+			EXISTS (SELECT 1 FROM users WHERE phone_number = $1 AND id <> $2 AND merged_into IS NULL),
+			EXISTS (SELECT 1 FROM phone_number_history WHERE phone_number = $1 AND user_id <> $2 AND released_at > $3)`
+
+	if err := db.QueryRowContext(ctx, query, phoneNumber, userID, since.UTC()).Scan(&taken, &coolingDown); err != nil {
+		return fmt.Errorf("failed to check phone number: %v", err)
+	}
+	if taken {
+		return ErrAlreadyExists
+	}
+	if coolingDown {
+		return ErrInCoolDown
+	}
+	return nil
+}
+
+// releasePhone records that a user gave up a phone number.
+func releasePhone(ctx context.Context, tx *sql.Tx, userID int64, phoneNumber, replacedBy, changeType string, releasedAt time.Time) error {
+	query := "INSERT INTO phone_number_history (user_id, phone_number, replaced_by, change_type, released_at) VALUES ($1, $2, $3, $4, $5)"
+	if _, err := tx.ExecContext(ctx, query, userID, phoneNumber, utils.CreateNullString(replacedBy), changeType, releasedAt.UTC()); err != nil {
+		return fmt.Errorf("failed to record phone history: %v", err)
+	}
+	return nil
+}
+
+// commitWithEvent writes a domain event to the outbox, records the user as a
+// new revision and commits the transaction, so the event is published if and
+// only if the mutation is persisted.
+func commitWithEvent(ctx context.Context, tx *sql.Tx, eventType string, userID int64, payload interface{}) error {
+	if err := outbox.Write(ctx, tx, eventType, userID, payload); err != nil {
+		return err
+	}
+	if err := recordRevision(ctx, tx, userID, eventType); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// checkAffected maps a statement that changed no rows to ErrNotFound.
+func checkAffected(result sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %v", err)
+	}
+	if rowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23503"
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// scanUser scans a row selected with userColumns.
+func scanUser(row rowScanner) (*pb.GetUserResponse, error) {
+	var user pb.GetUserResponse
+	var firstName, lastName, gender, location, email, profilePhotoUrl, country sql.NullString
+	var registrationDate time.Time
+	var dateOfBirth sql.NullTime
+	var attributes []byte
+
+	if err := row.Scan(
+		&user.Id,
+		&user.PublicId,
+		&firstName,
+		&lastName,
+		&user.PhoneNumber,
+		&user.Blocked,
+		&registrationDate,
+		&gender,
+		&dateOfBirth,
+		&location,
+		&email,
+		&profilePhotoUrl,
+		&country,
+		&user.EmailVerified,
+		&attributes,
+	); err != nil {
+		return nil, err
+	}
+
+	user.RegistrationTime = apitime.Timestamp(registrationDate)
+	user.RegistrationDate = apitime.CustomTimestamp(registrationDate)
+
+	user.FirstName = utils.NullableStringToString(firstName.Valid, firstName.String)
+	user.LastName = utils.NullableStringToString(lastName.Valid, lastName.String)
+	user.Gender = utils.NullableStringToString(gender.Valid, gender.String)
+	user.DateOfBirth = toDateOfBirth(dateOfBirth)
+	user.BirthDate = apitime.Date(user.DateOfBirth)
+	user.Location = utils.NullableStringToString(location.Valid, location.String)
+	user.Email = utils.NullableStringToString(email.Valid, email.String)
+	user.ProfilePhotoUrl = utils.NullableStringToString(profilePhotoUrl.Valid, profilePhotoUrl.String)
+	user.Country = utils.NullableStringToString(country.Valid, country.String)
+
+	customAttributes, err := scanAttributes(attributes)
+	if err != nil {
+		return nil, err
+	}
+	user.CustomAttributes = customAttributes
+
+	return &user, nil
+}
+
+// scanMutatedUser scans a row returned with mutatedUserColumns.
+func scanMutatedUser(row rowScanner) (*pb.UpdateUserResponse, error) {
+	var user pb.UpdateUserResponse
+	var firstName, lastName, gender, location, email, profilePhotoUrl, country sql.NullString
+	var dateOfBirth sql.NullTime
+	var attributes []byte
+	var registrationDate time.Time
+
+	if err := row.Scan(
+		&user.Id,
+		&user.PublicId,
+		&firstName,
+		&lastName,
+		&user.PhoneNumber,
+		&user.Blocked,
+		&gender,
+		&dateOfBirth,
+		&location,
+		&email,
+		&profilePhotoUrl,
+		&country,
+		&user.EmailVerified,
+		&registrationDate,
+		&attributes,
+	); err != nil {
+		return nil, err
+	}
+
+	user.RegistrationTime = apitime.Timestamp(registrationDate)
+
+	user.FirstName = utils.NullableStringToString(firstName.Valid, firstName.String)
+	user.LastName = utils.NullableStringToString(lastName.Valid, lastName.String)
+	user.Gender = utils.NullableStringToString(gender.Valid, gender.String)
+	user.DateOfBirth = toDateOfBirth(dateOfBirth)
+	user.BirthDate = apitime.Date(user.DateOfBirth)
+	user.Location = utils.NullableStringToString(location.Valid, location.String)
+	user.Email = utils.NullableStringToString(email.Valid, email.String)
+	user.ProfilePhotoUrl = utils.NullableStringToString(profilePhotoUrl.Valid, profilePhotoUrl.String)
+	user.Country = utils.NullableStringToString(country.Valid, country.String)
+
+	customAttributes, err := scanAttributes(attributes)
+	if err != nil {
+		return nil, err
+	}
+	user.CustomAttributes = customAttributes
+
+	return &user, nil
+}
+
+// toDateOfBirth converts a nullable DATE column to the DateOfBirth message, nil when NULL.
+func toDateOfBirth(date sql.NullTime) *pb.DateOfBirth {
+	if !date.Valid {
+		return nil
+	}
+	return &pb.DateOfBirth{
+		Year:  int32(date.Time.Year()),
+		Month: int32(date.Time.Month()),
+		Day:   int32(date.Time.Day()),
+	}
+}
@@ -0,0 +1,196 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/lib/pq"
+)
+
+const adminColumns = `id, username, roles, password_hash, totp_secret, totp_step, disabled, failed_logins,
+	locked_until, credentials_changed_at, last_login_at, created_at`
+
+func (p *Postgres) CreateAdmin(ctx context.Context, admin Admin, entry audit.Entry) (*Admin, error) {
+	query := `
+		INSERT INTO admins (username, roles, password_hash, totp_secret)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + adminColumns
+	return p.mutateAdmin(ctx, entry, query, admin.Username, pq.Array(admin.Roles), admin.PasswordHash, admin.TOTPSecret)
+}
+
+func (p *Postgres) GetAdmin(ctx context.Context, id int64) (*Admin, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	return getAdmin(tx.QueryRowContext(ctx, "SELECT "+adminColumns+" FROM admins WHERE id = $1", id))
+}
+
+func (p *Postgres) GetAdminByUsername(ctx context.Context, username string) (*Admin, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	return getAdmin(tx.QueryRowContext(ctx, "SELECT "+adminColumns+" FROM admins WHERE username = $1", username))
+}
+
+func getAdmin(row *sql.Row) (*Admin, error) {
+	admin, err := scanAdmin(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch admin: %v", err)
+	}
+	return admin, nil
+}
+
+func (p *Postgres) ListAdmins(ctx context.Context) ([]*Admin, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+adminColumns+" FROM admins ORDER BY username")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query admins: %v", err)
+	}
+	defer rows.Close()
+
+	var admins []*Admin
+	for rows.Next() {
+		admin, err := scanAdmin(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan admin: %v", err)
+		}
+		admins = append(admins, admin)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over admins: %v", err)
+	}
+	return admins, nil
+}
+
+func (p *Postgres) SetAdminDisabled(ctx context.Context, id int64, disabled bool, entry audit.Entry) (*Admin, error) {
+	query := "UPDATE admins SET disabled = $2 WHERE id = $1 RETURNING " + adminColumns
+	return p.mutateAdmin(ctx, entry, query, id, disabled)
+}
+
+func (p *Postgres) SetAdminRoles(ctx context.Context, id int64, roles []string, entry audit.Entry) (*Admin, error) {
+	query := "UPDATE admins SET roles = $2 WHERE id = $1 RETURNING " + adminColumns
+	return p.mutateAdmin(ctx, entry, query, id, pq.Array(roles))
+}
+
+func (p *Postgres) ResetAdminCredentials(ctx context.Context, id int64, passwordHash, totpSecret string, now time.Time, entry audit.Entry) (*Admin, error) {
+	query := `
+		UPDATE admins SET password_hash = $2, totp_secret = $3, totp_step = 0, failed_logins = 0,
+			locked_until = NULL, credentials_changed_at = $4
+		WHERE id = $1
+		RETURNING ` + adminColumns
+	return p.mutateAdmin(ctx, entry, query, id, passwordHash, totpSecret, now.UTC())
+}
+
+// mutateAdmin runs a statement returning adminColumns and writes entry to
+// the audit log in the same transaction.
+func (p *Postgres) mutateAdmin(ctx context.Context, entry audit.Entry, query string, args ...interface{}) (*Admin, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	admin, err := scanAdmin(tx.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to %s: %v", entry.Action, err)
+	}
+
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return admin, nil
+}
+
+func (p *Postgres) RecordAdminLogin(ctx context.Context, id, totpStep int64, now time.Time) error {
+	query := `
+		UPDATE admins SET failed_logins = 0, locked_until = NULL, totp_step = $2, last_login_at = $3
+		WHERE id = $1 AND totp_step < $2`
+	result, err := p.exec(ctx, query, id, totpStep, now.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record admin login: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (p *Postgres) RecordAdminLoginFailure(ctx context.Context, id int64, policy LockoutPolicy) (*Admin, error) {
+	// Reaching the limit locks the admin and starts counting from zero again
+	query := `
+		UPDATE admins SET
+			failed_logins = CASE WHEN failed_logins + 1 >= $2 THEN 0 ELSE failed_logins + 1 END,
+			locked_until = CASE WHEN failed_logins + 1 >= $2 THEN $3 ELSE locked_until END
+		WHERE id = $1
+		RETURNING ` + adminColumns
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	admin, err := scanAdmin(tx.QueryRowContext(ctx, query, id, policy.MaxFailures, policy.Now.Add(policy.Duration).UTC()))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to record failed admin login: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return admin, nil
+}
+
+// scanAdmin scans a row selected with adminColumns.
+func scanAdmin(row rowScanner) (*Admin, error) {
+	var admin Admin
+	var lockedUntil, lastLoginAt sql.NullTime
+	err := row.Scan(
+		&admin.ID,
+		&admin.Username,
+		pq.Array(&admin.Roles),
+		&admin.PasswordHash,
+		&admin.TOTPSecret,
+		&admin.TOTPStep,
+		&admin.Disabled,
+		&admin.FailedLogins,
+		&lockedUntil,
+		&admin.CredentialsChangedAt,
+		&lastLoginAt,
+		&admin.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	admin.LockedUntil = lockedUntil.Time
+	admin.LastLoginAt = lastLoginAt.Time
+	return &admin, nil
+}
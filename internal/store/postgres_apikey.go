@@ -0,0 +1,147 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"github.com/lib/pq"
+)
+
+// api_keys is not covered by row-level security since keys are looked up by
+// their hash before the tenant of a call is known. The statements below
+// filter by tenant instead.
+const apiKeyColumns = `id, tenant_id, name, key_prefix, scopes, expires_at, last_used_at, rotated_at, revoked_at,
+	created_by, created_at`
+
+func (p *Postgres) CreateAPIKey(ctx context.Context, key APIKey, hash []byte, entry audit.Entry) (*APIKey, error) {
+	query := `
+		INSERT INTO api_keys (tenant_id, name, key_prefix, key_hash, scopes, expires_at, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + apiKeyColumns
+	expiresAt := sql.NullTime{Time: key.ExpiresAt.UTC(), Valid: !key.ExpiresAt.IsZero()}
+	return p.mutateAPIKey(ctx, entry, query, tenant.ID(ctx), key.Name, key.Prefix, hash, pq.Array(key.Scopes), expiresAt, key.CreatedBy)
+}
+
+func (p *Postgres) GetAPIKey(ctx context.Context, id int64) (*APIKey, error) {
+	return getAPIKey(p.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE id = $1 AND tenant_id = $2", id, tenant.ID(ctx)))
+}
+
+func (p *Postgres) GetAPIKeyByHash(ctx context.Context, hash []byte) (*APIKey, error) {
+	return getAPIKey(p.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1", hash))
+}
+
+func getAPIKey(row *sql.Row) (*APIKey, error) {
+	key, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch API key: %v", err)
+	}
+	return key, nil
+}
+
+func (p *Postgres) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE tenant_id = $1 ORDER BY name", tenant.ID(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query API keys: %v", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan API key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over API keys: %v", err)
+	}
+	return keys, nil
+}
+
+func (p *Postgres) RotateAPIKey(ctx context.Context, id int64, prefix string, hash []byte, now time.Time, entry audit.Entry) (*APIKey, error) {
+	query := `
+		UPDATE api_keys SET key_prefix = $2, key_hash = $3, rotated_at = $4
+		WHERE id = $1 AND tenant_id = $5 AND revoked_at IS NULL
+		RETURNING ` + apiKeyColumns
+	return p.mutateAPIKey(ctx, entry, query, id, prefix, hash, now.UTC(), tenant.ID(ctx))
+}
+
+func (p *Postgres) RevokeAPIKey(ctx context.Context, id int64, now time.Time, entry audit.Entry) (*APIKey, error) {
+	query := "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $2) WHERE id = $1 AND tenant_id = $3 RETURNING " + apiKeyColumns
+	return p.mutateAPIKey(ctx, entry, query, id, now.UTC(), tenant.ID(ctx))
+}
+
+// mutateAPIKey runs a statement returning apiKeyColumns and writes entry to
+// the audit log in the same transaction.
+func (p *Postgres) mutateAPIKey(ctx context.Context, entry audit.Entry, query string, args ...interface{}) (*APIKey, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	key, err := scanAPIKey(tx.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to %s: %v", entry.Action, err)
+	}
+
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return key, nil
+}
+
+func (p *Postgres) TouchAPIKey(ctx context.Context, id int64, now time.Time) error {
+	result, err := p.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $2 WHERE id = $1 AND tenant_id = $3", id, now.UTC(), tenant.ID(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to record API key use: %v", err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// scanAPIKey scans a row selected with apiKeyColumns.
+func scanAPIKey(row rowScanner) (*APIKey, error) {
+	var key APIKey
+	var expiresAt, lastUsedAt, rotatedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&key.ID,
+		&key.TenantID,
+		&key.Name,
+		&key.Prefix,
+		pq.Array(&key.Scopes),
+		&expiresAt,
+		&lastUsedAt,
+		&rotatedAt,
+		&revokedAt,
+		&key.CreatedBy,
+		&key.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	key.ExpiresAt = expiresAt.Time
+	key.LastUsedAt = lastUsedAt.Time
+	key.RotatedAt = rotatedAt.Time
+	key.RevokedAt = revokedAt.Time
+	return &key, nil
+}
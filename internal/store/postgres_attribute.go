@@ -0,0 +1,130 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/lib/pq"
+)
+
+const attributeColumns = "name, type, allowed_values, required, pii, description, created_by, created_at, updated_at"
+
+func (p *Postgres) PutAttributeDefinition(ctx context.Context, def AttributeDefinition, entry audit.Entry) (*AttributeDefinition, error) {
+	query := `
+		INSERT INTO attribute_definitions (name, type, allowed_values, required, pii, description, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (tenant_id, name) DO UPDATE
+		SET type = EXCLUDED.type, allowed_values = EXCLUDED.allowed_values, required = EXCLUDED.required,
+			pii = EXCLUDED.pii, description = EXCLUDED.description, updated_at = CURRENT_TIMESTAMP
+		RETURNING ` + attributeColumns
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	stored, err := scanAttributeDefinition(tx.QueryRowContext(ctx, query,
+		def.Name, def.Type, pq.Array(def.AllowedValues), def.Required, def.PII, def.Description, def.CreatedBy))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store attribute definition: %v", err)
+	}
+
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return stored, nil
+}
+
+func (p *Postgres) ListAttributeDefinitions(ctx context.Context) ([]*AttributeDefinition, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT "+attributeColumns+" FROM attribute_definitions ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query attribute definitions: %v", err)
+	}
+	defer rows.Close()
+
+	var defs []*AttributeDefinition
+	for rows.Next() {
+		def, err := scanAttributeDefinition(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attribute definition: %v", err)
+		}
+		defs = append(defs, def)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over attribute definitions: %v", err)
+	}
+	return defs, nil
+}
+
+func (p *Postgres) DeleteAttributeDefinition(ctx context.Context, name string, entry audit.Entry) error {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := checkAffected(tx.ExecContext(ctx, "DELETE FROM attribute_definitions WHERE name = $1", name)); err != nil {
+		return err
+	}
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+// scanAttributeDefinition scans a row selected with attributeColumns.
+func scanAttributeDefinition(row rowScanner) (*AttributeDefinition, error) {
+	var def AttributeDefinition
+	err := row.Scan(
+		&def.Name,
+		&def.Type,
+		pq.Array(&def.AllowedValues),
+		&def.Required,
+		&def.PII,
+		&def.Description,
+		&def.CreatedBy,
+		&def.CreatedAt,
+		&def.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// attributesJSON encodes custom attributes for the custom_attributes column.
+func attributesJSON(attributes map[string]string) string {
+	if len(attributes) == 0 {
+		return "{}"
+	}
+	// Encoding a map of strings cannot fail
+	data, _ := json.Marshal(attributes)
+	return string(data)
+}
+
+// scanAttributes decodes the custom_attributes column.
+func scanAttributes(data []byte) (map[string]string, error) {
+	var attributes map[string]string
+	if err := json.Unmarshal(data, &attributes); err != nil {
+		return nil, fmt.Errorf("invalid custom attributes: %v", err)
+	}
+	if len(attributes) == 0 {
+		return nil, nil
+	}
+	return attributes, nil
+}
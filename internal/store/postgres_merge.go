@@ -0,0 +1,261 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/outbox"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/utils"
+	"github.com/lib/pq"
+	"google.golang.org/protobuf/proto"
+)
+
+// phoneReleasedMerged marks numbers the target of a merge gave up for the
+// number of the source.
+const phoneReleasedMerged = "merged"
+
+// duplicateMatches are the conditions pairing users a and b as duplicate
+// candidates. Each is joined on its own so it can use its index. Names are
+// compared in Go, so users with the same birth date and similar names are
+// found too.
+var duplicateMatches = []string{
+	"LOWER(b.email) = LOWER(a.email)",
+	"LOWER(b.last_name) = LOWER(a.last_name) AND LOWER(b.first_name) = LOWER(a.first_name)",
+	"b.date_of_birth = a.date_of_birth",
+}
+
+func (p *Postgres) FindDuplicateCandidates(ctx context.Context, userID int64, limit int32) ([]DuplicateCandidate, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// The pairs of one user are found from that user alone. Otherwise every
+	// join stops at the limit on its own, walking the newest users first.
+	branches := make([]string, 0, len(duplicateMatches))
+	args := []interface{}{limit}
+	for _, match := range duplicateMatches {
+		if userID != 0 {
+			branches = append(branches, `(SELECT LEAST(a.id, b.id) AS a_id, GREATEST(a.id, b.id) AS b_id FROM users a
+				JOIN users b ON b.tenant_id = a.tenant_id AND `+match+` AND b.id <> a.id AND b.merged_into IS NULL
+				WHERE a.id = $2 AND a.merged_into IS NULL)`)
+		} else {
+			branches = append(branches, `(SELECT a.id AS a_id, b.id AS b_id FROM users b
+				JOIN users a ON a.tenant_id = b.tenant_id AND `+match+` AND a.id < b.id AND a.merged_into IS NULL
+				WHERE b.merged_into IS NULL
+				ORDER BY b.id DESC, a.id DESC LIMIT $1)`)
+		}
+	}
+	if userID != 0 {
+		args = append(args, userID)
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT a_id, b_id FROM ("+strings.Join(branches, " UNION ")+") pairs ORDER BY b_id DESC, a_id DESC LIMIT $1", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicate candidates: %v", err)
+	}
+	defer rows.Close()
+
+	var pairs [][2]int64
+	var ids []int64
+	for rows.Next() {
+		var pair [2]int64
+		if err := rows.Scan(&pair[0], &pair[1]); err != nil {
+			return nil, fmt.Errorf("failed to scan duplicate candidate: %v", err)
+		}
+		pairs = append(pairs, pair)
+		ids = append(ids, pair[0], pair[1])
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over duplicate candidates: %v", err)
+	}
+	if len(pairs) == 0 {
+		return nil, nil
+	}
+
+	users := make(map[int64]*pb.GetUserResponse)
+	userRows, err := tx.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE id = ANY($1)", pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query users: %v", err)
+	}
+	defer userRows.Close()
+	for userRows.Next() {
+		user, err := scanUser(userRows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %v", err)
+		}
+		users[user.Id] = user
+	}
+	if err := userRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over users: %v", err)
+	}
+
+	candidates := make([]DuplicateCandidate, 0, len(pairs))
+	for _, pair := range pairs {
+		if pair[1] == userID {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		candidates = append(candidates, DuplicateCandidate{User: users[pair[0]], Duplicate: users[pair[1]]})
+	}
+	return candidates, nil
+}
+
+func (p *Postgres) MergeUsers(ctx context.Context, source, target, merged *pb.GetUserResponse, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	sourceID, targetID := source.Id, target.Id
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Both rows are locked in the same order by every merge, then compared
+	// with the users merged was computed from
+	rows, err := tx.QueryContext(ctx, "SELECT "+userColumns+" FROM users WHERE id IN ($1, $2) AND merged_into IS NULL ORDER BY id FOR UPDATE",
+		sourceID, targetID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %v", err)
+	}
+	locked := make(map[int64]*pb.GetUserResponse, 2)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan user: %v", err)
+		}
+		locked[user.Id] = user
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over users: %v", err)
+	}
+	if locked[sourceID] == nil || locked[targetID] == nil {
+		return nil, ErrNotFound
+	}
+	if !proto.Equal(locked[sourceID], source) || !proto.Equal(locked[targetID], target) {
+		return nil, ErrConflict
+	}
+
+	phoneChanged := merged.PhoneNumber != target.PhoneNumber
+	if phoneChanged && merged.PhoneNumber != source.PhoneNumber {
+		if err := checkPhoneAvailable(ctx, tx, merged.PhoneNumber, targetID, policy.CoolDownSince); err != nil {
+			return nil, err
+		}
+	}
+
+	// Tags the target already has keep their creator
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_tags (user_id, tag_id, created_by, created_at)
+		SELECT $2, tag_id, created_by, created_at FROM user_tags WHERE user_id = $1
+		ON CONFLICT DO NOTHING`, sourceID, targetID); err != nil {
+		return nil, fmt.Errorf("failed to move tags: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE user_notes SET user_id = $2 WHERE user_id = $1", sourceID, targetID); err != nil {
+		return nil, fmt.Errorf("failed to move notes: %v", err)
+	}
+	// Devices signed in to the source stay signed in as the target
+	if _, err := tx.ExecContext(ctx, "UPDATE sessions SET user_id = $2 WHERE user_id = $1 AND revoked_at IS NULL", sourceID, targetID); err != nil {
+		return nil, fmt.Errorf("failed to move sessions: %v", err)
+	}
+	// Numbers the source gave up count as the target's own for the cool-down
+	if _, err := tx.ExecContext(ctx, "UPDATE phone_number_history SET user_id = $2 WHERE user_id = $1", sourceID, targetID); err != nil {
+		return nil, fmt.Errorf("failed to move phone history: %v", err)
+	}
+
+	// The source is marked first, so the target can take its phone number and
+	// email. Its row is kept with the user it was merged into, but no longer served.
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET merged_into = $2, merged_at = $3 WHERE id = $1",
+		sourceID, targetID, policy.Now.UTC()); err != nil {
+		return nil, fmt.Errorf("failed to mark source user: %v", err)
+	}
+	for _, table := range []string{"user_tags", "email_verification_tokens", "phone_change_requests", "login_codes"} {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE user_id = $1", sourceID); err != nil {
+			return nil, fmt.Errorf("failed to clean up %s of source user: %v", table, err)
+		}
+	}
+	// The history of the source ends like that of a deleted user
+	if err := recordRevision(ctx, tx, sourceID, outbox.UserDeleted); err != nil {
+		return nil, err
+	}
+	if err := releasePhone(ctx, tx, sourceID, source.PhoneNumber, "", phoneReleasedDeleted, policy.Now); err != nil {
+		return nil, err
+	}
+
+	var dateOfBirth pq.NullTime
+	if merged.DateOfBirth != nil {
+		dateOfBirth.Time = utils.ToDate(merged.DateOfBirth.Year, merged.DateOfBirth.Month, merged.DateOfBirth.Day)
+		dateOfBirth.Valid = true
+	}
+	query := `
+		UPDATE users SET first_name = $1, last_name = $2, phone_number = $3, country = $4, gender = $5,
+			date_of_birth = $6, location = $7, email = $8, email_verified = $9, profile_photo_url = $10,
+			blocked = $11, custom_attributes = $12
+		WHERE id = $13
+		RETURNING ` + mutatedUserColumns
+	user, err := scanMutatedUser(tx.QueryRowContext(ctx, query,
+		utils.CreateNullString(merged.FirstName),
+		utils.CreateNullString(merged.LastName),
+		merged.PhoneNumber,
+		utils.CreateNullString(phone.CountryOf(merged.PhoneNumber)),
+		utils.CreateNullString(merged.Gender),
+		dateOfBirth,
+		utils.CreateNullString(merged.Location),
+		utils.CreateNullString(merged.Email),
+		merged.Email != "" && merged.EmailVerified,
+		utils.CreateNullString(merged.ProfilePhotoUrl),
+		merged.Blocked,
+		attributesJSON(merged.CustomAttributes),
+		targetID,
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to merge user: %v", err)
+	}
+
+	if phoneChanged {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM phone_change_requests WHERE user_id = $1", targetID); err != nil {
+			return nil, fmt.Errorf("failed to delete phone change: %v", err)
+		}
+		if err := releasePhone(ctx, tx, targetID, target.PhoneNumber, merged.PhoneNumber, phoneReleasedMerged, policy.Now); err != nil {
+			return nil, err
+		}
+	}
+	if merged.Blocked != target.Blocked {
+		eventType := outbox.UserUnblocked
+		if merged.Blocked {
+			eventType = outbox.UserBlocked
+		}
+		if err := outbox.Write(ctx, tx, eventType, targetID, &pb.UserID{Id: targetID, PublicId: user.PublicId}); err != nil {
+			return nil, err
+		}
+	}
+	// Sessions moved from the source are revoked too
+	if merged.Blocked {
+		if err := revokeSessions(ctx, tx, targetID, policy.Now); err != nil {
+			return nil, err
+		}
+	}
+
+	entry.UserID = targetID
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := outbox.Write(ctx, tx, outbox.UserDeleted, sourceID, &pb.UserID{Id: sourceID, PublicId: source.PublicId}); err != nil {
+		return nil, err
+	}
+	if err := outbox.Write(ctx, tx, outbox.UserUpdated, targetID, user); err != nil {
+		return nil, err
+	}
+	if err := recordRevision(ctx, tx, targetID, audit.UserMerged); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return user, nil
+}
@@ -0,0 +1,260 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/apitime"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/outbox"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/phone"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/utils"
+	"github.com/lib/pq"
+)
+
+const revisionColumns = "user_id, revision, actor, action, snapshot, created_at"
+
+// phoneReleasedRevert marks numbers given up by reverting a user.
+const phoneReleasedRevert = "revert"
+
+func (p *Postgres) ListUserRevisions(ctx context.Context, userID int64, limit, offset int32) ([]*UserRevision, error) {
+	query := "SELECT " + revisionColumns + " FROM user_revisions WHERE user_id = $1 ORDER BY revision DESC LIMIT $2 OFFSET $3"
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query revisions: %v", err)
+	}
+	defer rows.Close()
+
+	var revisions []*UserRevision
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan revision: %v", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over revisions: %v", err)
+	}
+	return revisions, nil
+}
+
+func (p *Postgres) GetUserRevision(ctx context.Context, userID, revision int64) (*UserRevision, error) {
+	query := "SELECT " + revisionColumns + " FROM user_revisions WHERE user_id = $1 AND revision = $2"
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	return getRevision(tx.QueryRowContext(ctx, query, userID, revision))
+}
+
+func (p *Postgres) GetUserAsOf(ctx context.Context, userID int64, at time.Time) (*UserRevision, error) {
+	query := "SELECT " + revisionColumns + " FROM user_revisions WHERE user_id = $1 AND created_at <= $2 ORDER BY revision DESC LIMIT 1"
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	return getRevision(tx.QueryRowContext(ctx, query, userID, at.UTC()))
+}
+
+func getRevision(row *sql.Row) (*UserRevision, error) {
+	revision, err := scanRevision(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch revision: %v", err)
+	}
+	return revision, nil
+}
+
+func (p *Postgres) RevertUser(ctx context.Context, userID int64, target *pb.GetUserResponse, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var oldNumber string
+	var wasBlocked bool
+	err = tx.QueryRowContext(ctx, "SELECT phone_number, blocked FROM users WHERE id = $1 AND merged_into IS NULL FOR UPDATE", userID).Scan(&oldNumber, &wasBlocked)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %v", err)
+	}
+
+	phoneChanged := target.PhoneNumber != oldNumber
+	if phoneChanged {
+		if err := checkPhoneAvailable(ctx, tx, target.PhoneNumber, userID, policy.CoolDownSince); err != nil {
+			return nil, err
+		}
+	}
+
+	var dateOfBirth pq.NullTime
+	if target.DateOfBirth != nil {
+		dateOfBirth.Time = utils.ToDate(target.DateOfBirth.Year, target.DateOfBirth.Month, target.DateOfBirth.Day)
+		dateOfBirth.Valid = true
+	}
+
+	// A changed email address has to be verified again, like in UpdateUser
+	query := `
+		UPDATE users SET first_name = $1, last_name = $2, phone_number = $3, country = $4, gender = $5,
+			date_of_birth = $6, location = $7, email_verified = email_verified AND email IS NOT DISTINCT FROM $8,
+			email = $8, profile_photo_url = $9, blocked = $10, custom_attributes = $12
+		WHERE id = $11
+		RETURNING ` + mutatedUserColumns
+	user, err := scanMutatedUser(tx.QueryRowContext(ctx, query,
+		utils.CreateNullString(target.FirstName),
+		utils.CreateNullString(target.LastName),
+		target.PhoneNumber,
+		utils.CreateNullString(phone.CountryOf(target.PhoneNumber)),
+		utils.CreateNullString(target.Gender),
+		dateOfBirth,
+		utils.CreateNullString(target.Location),
+		utils.CreateNullString(target.Email),
+		utils.CreateNullString(target.ProfilePhotoUrl),
+		target.Blocked,
+		userID,
+		attributesJSON(target.CustomAttributes),
+	))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to revert user: %v", err)
+	}
+
+	if phoneChanged {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM phone_change_requests WHERE user_id = $1", userID); err != nil {
+			return nil, fmt.Errorf("failed to delete phone change: %v", err)
+		}
+		if err := releasePhone(ctx, tx, userID, oldNumber, target.PhoneNumber, phoneReleasedRevert, policy.Now); err != nil {
+			return nil, err
+		}
+	}
+	if target.Blocked != wasBlocked {
+		eventType := outbox.UserUnblocked
+		if target.Blocked {
+			eventType = outbox.UserBlocked
+			if err := revokeSessions(ctx, tx, userID, policy.Now); err != nil {
+				return nil, err
+			}
+		}
+		if err := outbox.Write(ctx, tx, eventType, userID, &pb.UserID{Id: userID, PublicId: user.PublicId}); err != nil {
+			return nil, err
+		}
+	}
+
+	entry.UserID = userID
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := outbox.Write(ctx, tx, outbox.UserUpdated, userID, user); err != nil {
+		return nil, err
+	}
+	if err := recordRevision(ctx, tx, userID, audit.UserReverted); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return user, nil
+}
+
+// recordRevision stores the current row of the user as its next revision.
+// Rows locked by the mutation serialize the numbering. Nothing is recorded
+// for users that no longer exist, so DeleteUser records before deleting.
+func recordRevision(ctx context.Context, tx *sql.Tx, userID int64, action string) error {
+	query := `
+		INSERT INTO user_revisions (user_id, revision, actor, action, snapshot)
+		SELECT id, COALESCE((SELECT MAX(revision) FROM user_revisions WHERE user_id = $1), 0) + 1, $2, $3,
+			to_jsonb(users) - 'otp' - 'otp_created_at'
+		FROM users WHERE id = $1`
+	if _, err := tx.ExecContext(ctx, query, userID, audit.Actor(ctx), action); err != nil {
+		return fmt.Errorf("failed to record revision: %v", err)
+	}
+	return nil
+}
+
+// userSnapshot is a users row as encoded by to_jsonb.
+type userSnapshot struct {
+	ID               int64      `json:"id"`
+	PublicID         string     `json:"public_id"`
+	FirstName        string     `json:"first_name"`
+	LastName         string     `json:"last_name"`
+	PhoneNumber      string     `json:"phone_number"`
+	Blocked          bool       `json:"blocked"`
+	RegistrationDate *time.Time `json:"registration_date"`
+	Gender           string     `json:"gender"`
+	DateOfBirth      string     `json:"date_of_birth"`
+	Location         string     `json:"location"`
+	Email            string     `json:"email"`
+	ProfilePhotoURL  string     `json:"profile_photo_url"`
+	Country          string     `json:"country"`
+	EmailVerified    bool       `json:"email_verified"`
+	// Missing in revisions recorded before custom attributes existed
+	CustomAttributes map[string]string `json:"custom_attributes"`
+}
+
+// scanRevision scans a row selected with revisionColumns.
+func scanRevision(row rowScanner) (*UserRevision, error) {
+	var revision UserRevision
+	var data []byte
+
+	if err := row.Scan(&revision.UserID, &revision.Revision, &revision.Actor, &revision.Action, &data, &revision.CreatedAt); err != nil {
+		return nil, err
+	}
+
+	var snapshot userSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("invalid snapshot of revision %d: %v", revision.Revision, err)
+	}
+	user := &pb.GetUserResponse{
+		Id:              snapshot.ID,
+		PublicId:        snapshot.PublicID,
+		FirstName:       snapshot.FirstName,
+		LastName:        snapshot.LastName,
+		PhoneNumber:     snapshot.PhoneNumber,
+		Blocked:         snapshot.Blocked,
+		Gender:          snapshot.Gender,
+		Location:        snapshot.Location,
+		Email:           snapshot.Email,
+		ProfilePhotoUrl: snapshot.ProfilePhotoURL,
+		Country:         snapshot.Country,
+		EmailVerified:   snapshot.EmailVerified,
+	}
+	if len(snapshot.CustomAttributes) > 0 {
+		user.CustomAttributes = snapshot.CustomAttributes
+	}
+	if snapshot.RegistrationDate != nil {
+		user.RegistrationTime = apitime.Timestamp(*snapshot.RegistrationDate)
+		user.RegistrationDate = apitime.CustomTimestamp(*snapshot.RegistrationDate)
+	}
+	if snapshot.DateOfBirth != "" {
+		dateOfBirth, err := time.Parse("2006-01-02", snapshot.DateOfBirth)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date of birth in revision %d: %v", revision.Revision, err)
+		}
+		user.DateOfBirth = toDateOfBirth(sql.NullTime{Time: dateOfBirth, Valid: true})
+		user.BirthDate = apitime.Date(user.DateOfBirth)
+	}
+	revision.User = user
+	return &revision, nil
+}
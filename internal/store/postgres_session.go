@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/utils"
+	"github.com/lib/pq"
+)
+
+const sessionColumns = "id, user_id, (SELECT public_id FROM users WHERE users.id = sessions.user_id), device_id, device_name, created_at, last_used_at, expires_at"
+
+func (p *Postgres) GetUserByPhone(ctx context.Context, phoneNumber string) (*pb.GetUserResponse, error) {
+	query := "SELECT " + userColumns + " FROM users WHERE phone_number = $1 AND merged_into IS NULL"
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRowContext(ctx, query, phoneNumber))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %v", err)
+	}
+	return user, nil
+}
+
+func (p *Postgres) StartLogin(ctx context.Context, code LoginCode) error {
+	query := `
+		INSERT INTO login_codes (user_id, code_hash, attempts, expires_at)
+		VALUES ($1, $2, 0, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET code_hash = EXCLUDED.code_hash, attempts = 0, expires_at = EXCLUDED.expires_at, created_at = CURRENT_TIMESTAMP`
+
+	if _, err := p.exec(ctx, query, code.UserID, code.CodeHash, code.ExpiresAt.UTC()); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return ErrNotFound
+		}
+		return fmt.Errorf("failed to store login code: %v", err)
+	}
+	return nil
+}
+
+func (p *Postgres) ConsumeLoginCode(ctx context.Context, userID int64, codeHash []byte, maxAttempts int, now time.Time) error {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var storedHash []byte
+	var attempts int
+	var expiresAt time.Time
+	query := "SELECT code_hash, attempts, expires_at FROM login_codes WHERE user_id = $1 FOR UPDATE"
+	err = tx.QueryRowContext(ctx, query, userID).Scan(&storedHash, &attempts, &expiresAt)
+	if err == sql.ErrNoRows {
+		return ErrInvalidToken
+	}
+	if err != nil {
+		return fmt.Errorf("failed to fetch login code: %v", err)
+	}
+
+	if !now.UTC().Before(expiresAt) {
+		return ErrExpired
+	}
+	if attempts >= maxAttempts {
+		return ErrTooManyAttempts
+	}
+	if subtle.ConstantTimeCompare(storedHash, codeHash) != 1 {
+		// The failed attempt has to be persisted, so commit before reporting it
+		if _, err := tx.ExecContext(ctx, "UPDATE login_codes SET attempts = attempts + 1 WHERE user_id = $1", userID); err != nil {
+			return fmt.Errorf("failed to count attempt: %v", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		if attempts+1 >= maxAttempts {
+			return ErrTooManyAttempts
+		}
+		return ErrCodeMismatch
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM login_codes WHERE user_id = $1", userID); err != nil {
+		return fmt.Errorf("failed to delete login code: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func (p *Postgres) CreateSession(ctx context.Context, session Session, refreshHash []byte) error {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// A device has at most one active session
+	_, err = tx.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND device_id = $3 AND revoked_at IS NULL",
+		session.CreatedAt.UTC(), session.UserID, session.DeviceID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke previous session: %v", err)
+	}
+
+	query := `
+		INSERT INTO sessions (id, user_id, device_id, device_name, refresh_token_hash, created_at, last_used_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err = tx.ExecContext(ctx, query,
+		session.ID,
+		session.UserID,
+		session.DeviceID,
+		utils.CreateNullString(session.DeviceName),
+		refreshHash,
+		session.CreatedAt.UTC(),
+		session.LastUsedAt.UTC(),
+		session.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return nil
+}
+
+func (p *Postgres) RotateSession(ctx context.Context, refreshHash, newRefreshHash []byte, now, expiresAt time.Time) (*Session, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	var revokedAt sql.NullTime
+	query := "SELECT " + sessionColumns + ", revoked_at FROM sessions WHERE refresh_token_hash = $1 FOR UPDATE"
+	session, err := scanSession(tx.QueryRowContext(ctx, query, refreshHash), &revokedAt)
+	if err == sql.ErrNoRows {
+		// A rotated token presented again means it was copied: end the session
+		result, err := tx.ExecContext(ctx,
+			"UPDATE sessions SET revoked_at = $1 WHERE previous_token_hash = $2 AND revoked_at IS NULL",
+			now.UTC(), refreshHash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to revoke session: %v", err)
+		}
+		if n, err := result.RowsAffected(); err != nil || n == 0 {
+			return nil, ErrInvalidToken
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("failed to commit transaction: %v", err)
+		}
+		return nil, ErrTokenReused
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch session: %v", err)
+	}
+	if revokedAt.Valid {
+		return nil, ErrInvalidToken
+	}
+	if !now.UTC().Before(session.ExpiresAt) {
+		return nil, ErrExpired
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE sessions
+		SET previous_token_hash = refresh_token_hash, refresh_token_hash = $1, last_used_at = $2, expires_at = $3
+		WHERE id = $4`,
+		newRefreshHash, now.UTC(), expiresAt.UTC(), session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate session: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	session.LastUsedAt = now
+	session.ExpiresAt = expiresAt
+	return session, nil
+}
+
+func (p *Postgres) ListSessions(ctx context.Context, userID int64, now time.Time) ([]*Session, error) {
+	query := "SELECT " + sessionColumns + " FROM sessions WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2 ORDER BY last_used_at DESC"
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, userID, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sessions: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []*Session
+	for rows.Next() {
+		session, err := scanSession(rows, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over sessions: %v", err)
+	}
+	return sessions, nil
+}
+
+func (p *Postgres) RevokeSession(ctx context.Context, userID int64, sessionID string, now time.Time) error {
+	result, err := p.exec(ctx,
+		"UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL",
+		now.UTC(), sessionID, userID)
+	return checkAffected(result, err)
+}
+
+// revokeSessions revokes all active sessions of a user inside tx.
+func revokeSessions(ctx context.Context, tx *sql.Tx, userID int64, now time.Time) error {
+	_, err := tx.ExecContext(ctx, "UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL", now.UTC(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke sessions: %v", err)
+	}
+	return nil
+}
+
+// scanSession scans a row selected with sessionColumns, optionally followed by revoked_at.
+func scanSession(row rowScanner, revokedAt *sql.NullTime) (*Session, error) {
+	var session Session
+	var deviceName sql.NullString
+
+	dest := []interface{}{
+		&session.ID,
+		&session.UserID,
+		&session.UserPublicID,
+		&session.DeviceID,
+		&deviceName,
+		&session.CreatedAt,
+		&session.LastUsedAt,
+		&session.ExpiresAt,
+	}
+	if revokedAt != nil {
+		dest = append(dest, revokedAt)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return nil, err
+	}
+
+	session.DeviceName = utils.NullableStringToString(deviceName.Valid, deviceName.String)
+	return &session, nil
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func (p *Postgres) UserStats(ctx context.Context, q UserStatsQuery) (*UserStats, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	where, args := userFilterClause(q.Filter, 2)
+	rangeArgs := append([]interface{}{q.From, q.To}, args...)
+	stats := &UserStats{}
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE blocked),
+			COUNT(*) FILTER (WHERE registration_date >= $1 AND registration_date < $2)
+		FROM users WHERE `+where, rangeArgs...).Scan(&stats.Total, &stats.Blocked, &stats.Registered)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %v", err)
+	}
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(DISTINCT user_id) FROM phone_number_history WHERE change_type = $1",
+		phoneReleasedDeleted).Scan(&stats.Deleted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count deleted users: %v", err)
+	}
+
+	// Bucket starts are read as dates in the zone of the query
+	where, args = userFilterClause(q.Filter, 4)
+	days, err := groupCounts(ctx, tx, `
+		SELECT to_char(date_trunc($3, registration_date AT TIME ZONE $4), 'YYYY-MM-DD'), COUNT(*)
+		FROM users WHERE registration_date >= $1 AND registration_date < $2 AND `+where+`
+		GROUP BY 1`, append([]interface{}{q.From, q.To, q.Interval, q.Location.String()}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	registrations := make(map[int64]int64, len(days))
+	for day, count := range days {
+		start, err := time.ParseInLocation("2006-01-02", day, q.Location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse bucket %q: %v", day, err)
+		}
+		registrations[start.Unix()] = count
+	}
+	stats.Registrations = registrationBuckets(q, registrations)
+
+	where, args = userFilterClause(q.Filter, 0)
+	genders, err := groupCounts(ctx, tx, `
+		SELECT COALESCE(NULLIF(LOWER(TRIM(gender)), ''), '`+statsUnknown+`'), COUNT(*)
+		FROM users WHERE `+where+` GROUP BY 1`, args...)
+	if err != nil {
+		return nil, err
+	}
+	stats.Genders = sortedCounts(genders)
+
+	where, args = userFilterClause(q.Filter, 1)
+	ages, err := groupCounts(ctx, tx, `
+		SELECT COALESCE(EXTRACT(YEAR FROM age($1::DATE, date_of_birth))::INT::TEXT, ''), COUNT(*)
+		FROM users WHERE `+where+` GROUP BY 1`,
+		append([]interface{}{q.Now.In(q.Location).Format("2006-01-02")}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	bands := make(map[string]int64)
+	for years, count := range ages {
+		key := statsUnknown
+		if years != "" {
+			n, err := strconv.Atoi(years)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse age %q: %v", years, err)
+			}
+			key = ageBand(n)
+		}
+		bands[key] += count
+	}
+	stats.AgeBands = ageBandCounts(bands)
+
+	where, args = userFilterClause(q.Filter, 1)
+	locations, err := groupCounts(ctx, tx, `
+		SELECT COALESCE(NULLIF(TRIM(location), ''), '`+statsUnknown+`'), COUNT(*)
+		FROM users WHERE `+where+` GROUP BY 1 ORDER BY 2 DESC, 1 LIMIT $1`,
+		append([]interface{}{q.LocationLimit}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	stats.Locations = appendOther(sortedCounts(locations), stats.Total)
+	return stats, nil
+}
+
+// groupCounts runs a query returning keys and their counts.
+func groupCounts(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (map[string]int64, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user stats: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var key string
+		var count int64
+		if err := rows.Scan(&key, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan user stats: %v", err)
+		}
+		counts[key] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over user stats: %v", err)
+	}
+	return counts, nil
+}
@@ -0,0 +1,281 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/lib/pq"
+)
+
+// userFilterClause returns the condition on the users table selecting the
+// users of the filter, with its placeholders numbered after the first n
+// arguments of the query.
+func userFilterClause(filter UserFilter, n int) (string, []interface{}) {
+	// Users merged into another user are never selected
+	conditions := []string{"merged_into IS NULL"}
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return "$" + strconv.Itoa(n+len(args))
+	}
+
+	if len(filter.IDs) > 0 {
+		conditions = append(conditions, "id = ANY("+arg(pq.Array(filter.IDs))+")")
+	}
+	if filter.Country != "" {
+		conditions = append(conditions, "country = "+arg(filter.Country))
+	}
+	if filter.PhonePrefix != "" {
+		conditions = append(conditions, "starts_with(phone_number, "+arg(filter.PhonePrefix)+")")
+	}
+	if filter.Blocked != nil {
+		conditions = append(conditions, "blocked = "+arg(*filter.Blocked))
+	}
+	if len(filter.CustomAttributes) > 0 {
+		conditions = append(conditions, "custom_attributes @> "+arg(attributesJSON(filter.CustomAttributes))+"::jsonb")
+	}
+	if len(filter.Tags) > 0 {
+		// Tags are distinct, so a user has all of them when every one matches
+		conditions = append(conditions, `id IN (
+			SELECT user_tags.user_id FROM user_tags JOIN tags ON tags.id = user_tags.tag_id
+			WHERE tags.name = ANY(`+arg(pq.Array(filter.Tags))+`)
+			GROUP BY user_tags.user_id HAVING COUNT(*) = `+arg(len(filter.Tags))+`)`)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+func (p *Postgres) GetUserTags(ctx context.Context, userID int64) ([]string, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	return userTags(ctx, tx, userID)
+}
+
+func (p *Postgres) AddUserTags(ctx context.Context, userID int64, tags []string, entry audit.Entry) ([]string, error) {
+	return p.changeUserTags(ctx, userID, tags, nil, entry)
+}
+
+func (p *Postgres) RemoveUserTags(ctx context.Context, userID int64, tags []string, entry audit.Entry) ([]string, error) {
+	return p.changeUserTags(ctx, userID, nil, tags, entry)
+}
+
+func (p *Postgres) changeUserTags(ctx context.Context, userID int64, add, remove []string, entry audit.Entry) ([]string, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the user so a concurrent delete cannot leave dangling changes
+	var id int64
+	err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 AND merged_into IS NULL FOR UPDATE", userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch user: %v", err)
+	}
+
+	if err := changeTags(ctx, tx, "id = $1", []interface{}{userID}, add, remove, entry.Actor); err != nil {
+		return nil, err
+	}
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	tags, err := userTags(ctx, tx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return tags, nil
+}
+
+func (p *Postgres) BulkTagUsers(ctx context.Context, filter UserFilter, add, remove []string, entry audit.Entry) (int64, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	where, args := userFilterClause(filter, 0)
+	var matched int64
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM users WHERE "+where, args...).Scan(&matched); err != nil {
+		return 0, fmt.Errorf("failed to count users: %v", err)
+	}
+	if err := changeTags(ctx, tx, where, args, add, remove, entry.Actor); err != nil {
+		return 0, err
+	}
+
+	if details, ok := entry.Details.(map[string]interface{}); ok {
+		details["matched_users"] = matched
+	}
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return matched, nil
+}
+
+// changeTags adds and removes tags on the users selected by where, a
+// condition on the users table using args. Unknown tags are added to the
+// catalog.
+func changeTags(ctx context.Context, tx *sql.Tx, where string, args []interface{}, add, remove []string, actor string) error {
+	n := len(args)
+	if len(add) > 0 {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO tags (name, created_by) SELECT unnest($1::TEXT[]), $2 ON CONFLICT (tenant_id, name) DO NOTHING",
+			pq.Array(add), actor)
+		if err != nil {
+			return fmt.Errorf("failed to add tags to catalog: %v", err)
+		}
+
+		query := fmt.Sprintf(`
+			INSERT INTO user_tags (user_id, tag_id, created_by)
+			SELECT users.id, tags.id, $%d FROM (SELECT id FROM users WHERE %s) users
+			CROSS JOIN tags WHERE tags.name = ANY($%d)
+			ON CONFLICT DO NOTHING`, n+1, where, n+2)
+		if _, err := tx.ExecContext(ctx, query, append(args, actor, pq.Array(add))...); err != nil {
+			return fmt.Errorf("failed to tag users: %v", err)
+		}
+	}
+	if len(remove) > 0 {
+		query := fmt.Sprintf(`
+			DELETE FROM user_tags
+			WHERE user_id IN (SELECT id FROM users WHERE %s)
+				AND tag_id IN (SELECT id FROM tags WHERE name = ANY($%d))`, where, n+1)
+		if _, err := tx.ExecContext(ctx, query, append(args, pq.Array(remove))...); err != nil {
+			return fmt.Errorf("failed to untag users: %v", err)
+		}
+	}
+	return nil
+}
+
+func userTags(ctx context.Context, db querier, userID int64) ([]string, error) {
+	// The user is selected as well, so unknown users can be told apart from users without tags
+	rows, err := db.QueryContext(ctx, `
+		SELECT tags.name FROM users
+		LEFT JOIN user_tags ON user_tags.user_id = users.id
+		LEFT JOIN tags ON tags.id = user_tags.tag_id
+		WHERE users.id = $1 AND users.merged_into IS NULL
+		ORDER BY tags.name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user tags: %v", err)
+	}
+	defer rows.Close()
+
+	found := false
+	tags := []string{}
+	for rows.Next() {
+		found = true
+		var name sql.NullString
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan user tag: %v", err)
+		}
+		if name.Valid {
+			tags = append(tags, name.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over user tags: %v", err)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	return tags, nil
+}
+
+func (p *Postgres) ListTags(ctx context.Context) ([]*Tag, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT tags.name, COUNT(user_tags.user_id), tags.created_by, tags.created_at
+		FROM tags LEFT JOIN user_tags ON user_tags.tag_id = tags.id
+		GROUP BY tags.id
+		ORDER BY tags.name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tags: %v", err)
+	}
+	defer rows.Close()
+
+	var tags []*Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Name, &tag.UserCount, &tag.CreatedBy, &tag.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %v", err)
+		}
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over tags: %v", err)
+	}
+	return tags, nil
+}
+
+func (p *Postgres) AddUserNote(ctx context.Context, note UserNote) (*UserNote, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO user_notes (user_id, author, body) VALUES ($1, $2, $3) RETURNING id, created_at",
+		note.UserID, note.Author, note.Body).Scan(&note.ID, &note.CreatedAt)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to add note: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return &note, nil
+}
+
+func (p *Postgres) ListUserNotes(ctx context.Context, userID int64, limit, offset int32) ([]*UserNote, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, user_id, author, body, created_at FROM user_notes
+		WHERE user_id = $1
+		ORDER BY id DESC
+		LIMIT $2 OFFSET $3`, userID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notes: %v", err)
+	}
+	defer rows.Close()
+
+	var notes []*UserNote
+	for rows.Next() {
+		var note UserNote
+		if err := rows.Scan(&note.ID, &note.UserID, &note.Author, &note.Body, &note.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan note: %v", err)
+		}
+		notes = append(notes, &note)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over notes: %v", err)
+	}
+	return notes, nil
+}
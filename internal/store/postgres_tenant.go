@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/lib/pq"
+)
+
+const tenantColumns = `id, slug, name, phone_countries, phone_default_country, phone_otp_ttl_seconds,
+	phone_otp_max_attempts, disabled, created_by, created_at, updated_at`
+
+func (p *Postgres) CreateTenant(ctx context.Context, t Tenant, entry audit.Entry) (*Tenant, error) {
+	query := `
+		INSERT INTO tenants (slug, name, phone_countries, phone_default_country, phone_otp_ttl_seconds,
+			phone_otp_max_attempts, disabled, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + tenantColumns
+	return p.mutateTenant(ctx, entry, query, t.Slug, t.Name, pq.Array(t.Config.PhoneCountries), t.Config.PhoneDefaultCountry,
+		int64(t.Config.PhoneOTPTTL/time.Second), t.Config.PhoneOTPMaxAttempts, t.Disabled, t.CreatedBy)
+}
+
+func (p *Postgres) GetTenant(ctx context.Context, id int64) (*Tenant, error) {
+	return getTenant(p.db.QueryRowContext(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE id = $1", id))
+}
+
+func (p *Postgres) GetTenantBySlug(ctx context.Context, slug string) (*Tenant, error) {
+	return getTenant(p.db.QueryRowContext(ctx, "SELECT "+tenantColumns+" FROM tenants WHERE slug = $1", slug))
+}
+
+func getTenant(row *sql.Row) (*Tenant, error) {
+	t, err := scanTenant(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tenant: %v", err)
+	}
+	return t, nil
+}
+
+func (p *Postgres) ListTenants(ctx context.Context) ([]*Tenant, error) {
+	rows, err := p.db.QueryContext(ctx, "SELECT "+tenantColumns+" FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tenants: %v", err)
+	}
+	defer rows.Close()
+
+	var tenants []*Tenant
+	for rows.Next() {
+		t, err := scanTenant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan tenant: %v", err)
+		}
+		tenants = append(tenants, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate over tenants: %v", err)
+	}
+	return tenants, nil
+}
+
+func (p *Postgres) UpdateTenant(ctx context.Context, t Tenant, entry audit.Entry) (*Tenant, error) {
+	query := `
+		UPDATE tenants SET name = $2, phone_countries = $3, phone_default_country = $4, phone_otp_ttl_seconds = $5,
+			phone_otp_max_attempts = $6, disabled = $7, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+		RETURNING ` + tenantColumns
+	return p.mutateTenant(ctx, entry, query, t.ID, t.Name, pq.Array(t.Config.PhoneCountries), t.Config.PhoneDefaultCountry,
+		int64(t.Config.PhoneOTPTTL/time.Second), t.Config.PhoneOTPMaxAttempts, t.Disabled)
+}
+
+// mutateTenant runs a statement returning tenantColumns and writes entry to
+// the audit log of the calling tenant in the same transaction.
+func (p *Postgres) mutateTenant(ctx context.Context, entry audit.Entry, query string, args ...interface{}) (*Tenant, error) {
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	t, err := scanTenant(tx.QueryRowContext(ctx, query, args...))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrAlreadyExists
+		}
+		return nil, fmt.Errorf("failed to %s: %v", entry.Action, err)
+	}
+
+	if err := audit.Write(ctx, tx, entry); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %v", err)
+	}
+	return t, nil
+}
+
+// scanTenant scans a row selected with tenantColumns.
+func scanTenant(row rowScanner) (*Tenant, error) {
+	var t Tenant
+	var otpTTL int64
+	err := row.Scan(
+		&t.ID,
+		&t.Slug,
+		&t.Name,
+		pq.Array(&t.Config.PhoneCountries),
+		&t.Config.PhoneDefaultCountry,
+		&otpTTL,
+		&t.Config.PhoneOTPMaxAttempts,
+		&t.Disabled,
+		&t.CreatedBy,
+		&t.CreatedAt,
+		&t.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	t.Config.PhoneOTPTTL = time.Duration(otpTTL) * time.Second
+	return &t, nil
+}
@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+)
+
+// newTestPostgres creates the schema in a new PostgreSQL schema of the
+// database at TEST_DATABASE_URL and drops it when the test ends. The role must
+// not bypass row-level security, like the role of the server.
+func newTestPostgres(t *testing.T) *Postgres {
+	t.Helper()
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	// search_path is set per connection, so all statements must share one
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxLifetime(0)
+
+	var bypass bool
+	if err := db.QueryRow("SELECT rolsuper OR rolbypassrls FROM pg_roles WHERE rolname = current_user").Scan(&bypass); err != nil {
+		t.Fatal(err)
+	}
+	if bypass {
+		t.Skip("the role of TEST_DATABASE_URL bypasses row-level security")
+	}
+
+	schema := fmt.Sprintf("store_test_%d", time.Now().UnixNano())
+	if _, err := db.Exec("CREATE SCHEMA " + schema + "; SET search_path TO " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := db.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error(err)
+		}
+	})
+	for _, file := range []string{"../../sql/users.sql", "../../sql/webhooks.sql"} {
+		ddl, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec(string(ddl)); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+	}
+	return NewPostgres(db)
+}
+
+func TestPostgresRefusesReleasedPhonesInCoolDown(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+	created, err := p.CreateUser(ctx, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	policy := PhonePolicy{CoolDownSince: now.Add(-time.Hour), Now: now}
+	if _, err := p.ChangePhoneNumber(ctx, created.Id, "+99365000002", policy, audit.Entry{Actor: "test", Action: audit.PhoneOverridden}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.CheckPhoneAvailable(ctx, "+99365000002", 0, now.Add(-time.Hour)); err != ErrAlreadyExists {
+		t.Errorf("got %v for the number in use, want ErrAlreadyExists", err)
+	}
+	if err := p.CheckPhoneAvailable(ctx, "+99365000001", 0, now.Add(-time.Hour)); err != ErrInCoolDown {
+		t.Errorf("got %v for the released number, want ErrInCoolDown", err)
+	}
+	if err := p.CheckPhoneAvailable(ctx, "+99365000001", created.Id, now.Add(-time.Hour)); err != nil {
+		t.Errorf("got %v for the user who released the number, want nil", err)
+	}
+	if err := p.CheckPhoneAvailable(ctx, "+99365000001", 0, now.Add(time.Minute)); err != nil {
+		t.Errorf("got %v after the cool-down, want nil", err)
+	}
+}
+
+func TestPostgresReturnsTheTagsOfAUser(t *testing.T) {
+	p := newTestPostgres(t)
+	ctx := context.Background()
+	created, err := p.CreateUser(ctx, &pb.CreateUserRequest{FirstName: "Aman", PhoneNumber: "+99365000001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.AddUserTags(ctx, created.Id, []string{"vip", "beta"}, audit.Entry{Actor: "test", Action: audit.UserTagged}); err != nil {
+		t.Fatal(err)
+	}
+
+	tags, err := p.GetUserTags(ctx, created.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tags) != 2 || tags[0] != "beta" || tags[1] != "vip" {
+		t.Errorf("got tags %v, want [beta vip]", tags)
+	}
+	if _, err := p.GetUserTags(ctx, created.Id+1); err != ErrNotFound {
+		t.Errorf("got %v for an unknown user, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,117 @@
+package store
+
+import (
+	"sort"
+	"time"
+)
+
+// Keys of users without a value and of the locations beyond the limit.
+const (
+	statsUnknown = "unknown"
+	statsOther   = "other"
+)
+
+// ageBands are the age bands of UserStats in order, each starting at its
+// minimum age.
+var ageBands = []struct {
+	key    string
+	minAge int
+}{
+	{"0-17", 0},
+	{"18-24", 18},
+	{"25-34", 25},
+	{"35-44", 35},
+	{"45-54", 45},
+	{"55-64", 55},
+	{"65+", 65},
+}
+
+// bucketStart returns the start of the bucket t falls in. Weeks start on
+// Monday like date_trunc in Postgres.
+func bucketStart(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch interval {
+	case StatsWeek:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case StatsMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+func nextBucket(start time.Time, interval string) time.Time {
+	switch interval {
+	case StatsWeek:
+		return start.AddDate(0, 0, 7)
+	case StatsMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+// registrationBuckets lists every bucket of the range of q with the counts
+// keyed by the Unix time of their start.
+func registrationBuckets(q UserStatsQuery, counts map[int64]int64) []StatsBucket {
+	var buckets []StatsBucket
+	for start := bucketStart(q.From, q.Interval, q.Location); start.Before(q.To); start = nextBucket(start, q.Interval) {
+		buckets = append(buckets, StatsBucket{Start: start, Count: counts[start.Unix()]})
+	}
+	return buckets
+}
+
+// ageBand returns the key of the band of an age.
+func ageBand(age int) string {
+	key := ageBands[0].key
+	for _, band := range ageBands {
+		if age >= band.minAge {
+			key = band.key
+		}
+	}
+	return key
+}
+
+// ageBandCounts lists all bands in order, followed by "unknown".
+func ageBandCounts(counts map[string]int64) []StatsCount {
+	result := make([]StatsCount, 0, len(ageBands)+1)
+	for _, band := range ageBands {
+		result = append(result, StatsCount{Key: band.key, Count: counts[band.key]})
+	}
+	return append(result, StatsCount{Key: statsUnknown, Count: counts[statsUnknown]})
+}
+
+// age returns the age in whole years on the given date of someone born on
+// the birth date.
+func age(birthYear int, birthMonth time.Month, birthDay int, on time.Time) int {
+	years := on.Year() - birthYear
+	if on.Month() < birthMonth || on.Month() == birthMonth && on.Day() < birthDay {
+		years--
+	}
+	return years
+}
+
+// sortedCounts orders counts by count, most frequent first, then by key.
+func sortedCounts(counts map[string]int64) []StatsCount {
+	result := make([]StatsCount, 0, len(counts))
+	for key, count := range counts {
+		result = append(result, StatsCount{Key: key, Count: count})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// appendOther adds the users of total not counted yet as "other".
+func appendOther(counts []StatsCount, total int64) []StatsCount {
+	for _, c := range counts {
+		total -= c.Count
+	}
+	if total > 0 {
+		counts = append(counts, StatsCount{Key: statsOther, Count: total})
+	}
+	return counts
+}
@@ -0,0 +1,463 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+)
+
+var (
+	// ErrNotFound is returned when the requested user does not exist.
+	ErrNotFound = errors.New("user not found")
+	// ErrAlreadyExists is returned when a unique field (phone number, email) is already taken.
+	ErrAlreadyExists = errors.New("user already exists")
+	// ErrInvalidToken is returned for tokens that are unknown, already used or
+	// were issued for a value that has changed since.
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrExpired is returned for tokens that are past their expiry time.
+	ErrExpired = errors.New("token expired")
+	// ErrInCoolDown is returned for phone numbers another user released too recently.
+	ErrInCoolDown = errors.New("phone number was recently released by another user")
+	// ErrNoPendingChange is returned when a user has no phone change to confirm.
+	ErrNoPendingChange = errors.New("no pending phone change")
+	// ErrCodeMismatch is returned when a confirmation code is wrong.
+	ErrCodeMismatch = errors.New("confirmation code does not match")
+	// ErrTooManyAttempts is returned once a pending change has seen too many wrong codes.
+	ErrTooManyAttempts = errors.New("too many failed attempts")
+	// ErrTokenReused is returned when a refresh token that was already rotated
+	// is presented again. The session is revoked since the token may be stolen.
+	ErrTokenReused = errors.New("refresh token reused")
+	// ErrConflict is returned when a user changed after it was read for a
+	// change computed from it.
+	ErrConflict = errors.New("user changed concurrently")
+)
+
+// PhoneChange is a phone number change waiting for the code sent to the new number.
+type PhoneChange struct {
+	UserID      int64
+	PhoneNumber string
+	CodeHash    []byte
+	ExpiresAt   time.Time
+}
+
+// LoginCode is a one-time code sent to the phone of a user signing in.
+type LoginCode struct {
+	UserID    int64
+	CodeHash  []byte
+	ExpiresAt time.Time
+}
+
+// Session is the refresh session of a user on one device.
+type Session struct {
+	ID           string
+	UserID       int64
+	UserPublicID string
+	DeviceID     string
+	DeviceName   string
+	CreatedAt    time.Time
+	LastUsedAt   time.Time
+	ExpiresAt    time.Time
+}
+
+// PhonePolicy holds the limits applied when a phone number changes.
+type PhonePolicy struct {
+	// MaxAttempts is the number of wrong codes after which a pending change is discarded.
+	MaxAttempts int
+	// CoolDownSince rejects numbers released by another user after this time.
+	CoolDownSince time.Time
+	// Now is the time of the change.
+	Now time.Time
+}
+
+// UserStatus is the state of a user returned by GetUsersStatus.
+type UserStatus struct {
+	ID          int64
+	PublicID    string
+	PhoneNumber string
+	Blocked     bool
+	Deleted     bool
+}
+
+// UserRevision is a snapshot of a user taken when a change was committed.
+type UserRevision struct {
+	UserID   int64
+	Revision int64
+	Actor    string
+	// Action is the event of the change, or audit.UserReverted for reverts.
+	Action string
+	// User is the user after the change, or as it was deleted.
+	User      *pb.GetUserResponse
+	CreatedAt time.Time
+}
+
+// Admin is an operator account of the admin API.
+type Admin struct {
+	ID           int64
+	Username     string
+	Roles        []string
+	PasswordHash string
+	TOTPSecret   string
+	// TOTPStep is the time step of the last accepted code, so a code cannot
+	// be used twice.
+	TOTPStep     int64
+	Disabled     bool
+	FailedLogins int
+	LockedUntil  time.Time
+	// CredentialsChangedAt invalidates the tokens issued before it, or in the
+	// same second. It equals CreatedAt until the credentials are reset.
+	CredentialsChangedAt time.Time
+	LastLoginAt          time.Time
+	CreatedAt            time.Time
+}
+
+// LockoutPolicy locks admins out after repeated failed logins.
+type LockoutPolicy struct {
+	// MaxFailures is the number of failed logins in a row that locks the admin.
+	MaxFailures int
+	// Duration is how long the admin stays locked.
+	Duration time.Duration
+	// Now is the time of the failed login.
+	Now time.Time
+}
+
+// UserFilter selects users. Empty fields match every user.
+type UserFilter struct {
+	IDs []int64 `json:"ids,omitempty"`
+	// Tags selects users with all of the tags.
+	Tags        []string `json:"tags,omitempty"`
+	Country     string   `json:"country,omitempty"`
+	PhonePrefix string   `json:"phone_prefix,omitempty"`
+	// Blocked selects blocked or unblocked users when set.
+	Blocked *bool `json:"blocked,omitempty"`
+	// CustomAttributes selects users having all of the attribute values.
+	CustomAttributes map[string]string `json:"custom_attributes,omitempty"`
+}
+
+// Empty reports whether the filter matches every user.
+func (f UserFilter) Empty() bool {
+	return len(f.IDs) == 0 && len(f.Tags) == 0 && f.Country == "" && f.PhonePrefix == "" && f.Blocked == nil &&
+		len(f.CustomAttributes) == 0
+}
+
+// Registration buckets of UserStats.
+const (
+	StatsDay   = "day"
+	StatsWeek  = "week"
+	StatsMonth = "month"
+)
+
+// UserStatsQuery selects the users and the registrations counted by
+// UserStats.
+type UserStatsQuery struct {
+	Filter UserFilter
+	// Registrations in [From, To) are counted per bucket of Interval, whose
+	// starts are computed in Location.
+	From, To time.Time
+	Interval string
+	Location *time.Location
+	// Ages are computed on the date of Now in Location.
+	Now time.Time
+	// LocationLimit is the number of locations listed before "other".
+	LocationLimit int
+}
+
+// UserStats are the counts of UserStatsQuery. Deleted counts all deleted
+// users since they cannot be filtered.
+type UserStats struct {
+	Total      int64
+	Blocked    int64
+	Deleted    int64
+	Registered int64
+	// Registrations has a bucket for every interval of the range, in order.
+	Registrations []StatsBucket
+	Genders       []StatsCount
+	AgeBands      []StatsCount
+	Locations     []StatsCount
+}
+
+type StatsBucket struct {
+	Start time.Time
+	Count int64
+}
+
+type StatsCount struct {
+	Key   string
+	Count int64
+}
+
+// DuplicateCandidate is a pair of users sharing an email, a first and last
+// name, or a birth date.
+type DuplicateCandidate struct {
+	User      *pb.GetUserResponse
+	Duplicate *pb.GetUserResponse
+}
+
+// Types of custom attributes.
+const (
+	AttributeString  = "string"
+	AttributeInteger = "integer"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeDate    = "date"
+)
+
+// AttributeDefinition describes a custom attribute of users. Values are
+// stored as text in their canonical form, e.g. "42" or "true".
+type AttributeDefinition struct {
+	Name string
+	Type string
+	// AllowedValues restricts string attributes to these values when set.
+	AllowedValues []string
+	Required      bool
+	// PII marks personal data, which is left out of webhook payloads.
+	PII         bool
+	Description string
+	CreatedBy   string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// Tag is an entry of the tag catalog.
+type Tag struct {
+	Name      string
+	UserCount int64
+	CreatedBy string
+	CreatedAt time.Time
+}
+
+// UserNote is an entry of the append-only notes timeline of a user.
+type UserNote struct {
+	ID        int64
+	UserID    int64
+	Author    string
+	Body      string
+	CreatedAt time.Time
+}
+
+// APIKey is a long-lived credential of a backend service. Only a hash of
+// the key is stored.
+type APIKey struct {
+	ID int64
+	// TenantID is the tenant the key acts for.
+	TenantID int64
+	Name     string
+	// Prefix is the start of the key, shown to tell keys apart.
+	Prefix string
+	// Scopes are the admin permissions granted to the key.
+	Scopes []string
+	// ExpiresAt is zero for keys that do not expire.
+	ExpiresAt  time.Time
+	LastUsedAt time.Time
+	RotatedAt  time.Time
+	RevokedAt  time.Time
+	CreatedBy  string
+	CreatedAt  time.Time
+}
+
+// Tenant is a brand whose users, admins and API keys are kept apart from
+// those of other tenants.
+type Tenant struct {
+	ID int64
+	// Slug names the tenant in the x-tenant header.
+	Slug   string
+	Name   string
+	Config TenantConfig
+	// Disabled tenants reject all calls.
+	Disabled  bool
+	CreatedBy string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// TenantConfig overrides settings of the server for one tenant. Zero fields
+// keep the settings of the server.
+type TenantConfig struct {
+	PhoneCountries      []string
+	PhoneDefaultCountry string
+	PhoneOTPTTL         time.Duration
+	PhoneOTPMaxAttempts int
+}
+
+// Store persists users. Every mutation publishes the matching outbox event
+// atomically with the change. All methods only see the data of the tenant
+// of the context (see package tenant), except for the tenant methods and
+// GetAPIKeyByHash.
+type Store interface {
+	// ListUsers returns the users matching the filter ordered by ID.
+	ListUsers(ctx context.Context, filter UserFilter, limit, offset int32) ([]*pb.GetUserResponse, error)
+	GetUser(ctx context.Context, id int64) (*pb.GetUserResponse, error)
+	// ResolvePublicIDs maps public IDs to the internal IDs of the users.
+	// Unknown public IDs are left out.
+	ResolvePublicIDs(ctx context.Context, publicIDs []string) (map[string]int64, error)
+	// GetUserByPhone returns the user with the given E.164 phone number.
+	GetUserByPhone(ctx context.Context, phoneNumber string) (*pb.GetUserResponse, error)
+	// GetUserByEmail returns the user with the given email, ignoring case.
+	GetUserByEmail(ctx context.Context, email string) (*pb.GetUserResponse, error)
+	CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error)
+	// UpdateUser changes the non-empty fields of req. The value "null" clears
+	// a nullable field or removes a custom attribute, other custom attributes
+	// are kept.
+	UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error)
+	DeleteUser(ctx context.Context, id int64) error
+	// SetBlocked changes the blocked flag. Blocking revokes all sessions of the user.
+	SetBlocked(ctx context.Context, id int64, blocked bool) error
+	// SetProfilePhotoURL replaces the photo URL and also returns the previous
+	// one, so the replaced photo can be deleted.
+	SetProfilePhotoURL(ctx context.Context, id int64, url string) (user *pb.UpdateUserResponse, previous string, err error)
+	// GetUsersStatus returns the status of the users with the given IDs or
+	// E.164 phone numbers. Users deleted since are returned with Deleted set
+	// when looked up by ID. Unknown users are left out.
+	GetUsersStatus(ctx context.Context, ids []int64, phoneNumbers []string) ([]UserStatus, error)
+	// UserStats counts the users matching the query by status, registration
+	// bucket, gender, age band and location.
+	UserStats(ctx context.Context, q UserStatsQuery) (*UserStats, error)
+
+	// CreateEmailVerification stores the hash of a verification token for the
+	// given email of the user, replacing any pending token of the user.
+	CreateEmailVerification(ctx context.Context, userID int64, email string, tokenHash []byte, expiresAt time.Time) error
+	// ConfirmEmail consumes a verification token and marks the email it was
+	// issued for as verified, provided the user still has that email.
+	ConfirmEmail(ctx context.Context, tokenHash []byte, now time.Time) (*pb.UpdateUserResponse, error)
+
+	// CheckPhoneAvailable returns ErrAlreadyExists if another user has the phone
+	// number and ErrInCoolDown if another user released it after since.
+	CheckPhoneAvailable(ctx context.Context, phoneNumber string, userID int64, since time.Time) error
+	// StartPhoneChange stores a pending phone change, replacing any earlier one of the user.
+	StartPhoneChange(ctx context.Context, change PhoneChange) error
+	// ConfirmPhoneChange applies the pending change of the user if codeHash
+	// matches. The old number is kept in the phone history and entry is
+	// written to the audit log with the old and new number as details.
+	ConfirmPhoneChange(ctx context.Context, userID int64, codeHash []byte, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error)
+	// ChangePhoneNumber replaces the phone number without verification,
+	// recording the old number and the audit entry like ConfirmPhoneChange.
+	ChangePhoneNumber(ctx context.Context, userID int64, phoneNumber string, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error)
+
+	// StartLogin stores a login code, replacing any earlier code of the user.
+	StartLogin(ctx context.Context, code LoginCode) error
+	// ConsumeLoginCode deletes the login code of the user if codeHash matches.
+	// Wrong codes count as failed attempts like in ConfirmPhoneChange.
+	ConsumeLoginCode(ctx context.Context, userID int64, codeHash []byte, maxAttempts int, now time.Time) error
+	// CreateSession stores a new session with the hash of its refresh token,
+	// revoking an existing session of the same device.
+	CreateSession(ctx context.Context, session Session, refreshHash []byte) error
+	// RotateSession replaces the refresh token of the session owning
+	// refreshHash and extends the session until expiresAt.
+	RotateSession(ctx context.Context, refreshHash, newRefreshHash []byte, now, expiresAt time.Time) (*Session, error)
+	// ListSessions returns the active sessions of the user, most recently used first.
+	ListSessions(ctx context.Context, userID int64, now time.Time) ([]*Session, error)
+	// RevokeSession revokes one session of the user.
+	RevokeSession(ctx context.Context, userID int64, sessionID string, now time.Time) error
+
+	// ListUserRevisions returns revisions of the user, newest first. Every
+	// mutation above records a revision, deleted users keep theirs.
+	ListUserRevisions(ctx context.Context, userID int64, limit, offset int32) ([]*UserRevision, error)
+	// GetUserRevision returns one revision of the user.
+	GetUserRevision(ctx context.Context, userID, revision int64) (*UserRevision, error)
+	// GetUserAsOf returns the latest revision of the user made at or before at.
+	GetUserAsOf(ctx context.Context, userID int64, at time.Time) (*UserRevision, error)
+	// RevertUser restores the fields, phone number and blocked flag of the
+	// user to target. A changed phone number is checked and kept in the phone
+	// history like in ChangePhoneNumber, and entry is written to the audit log.
+	RevertUser(ctx context.Context, userID int64, target *pb.GetUserResponse, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error)
+
+	// FindDuplicateCandidates returns candidate pairs of users, most recently
+	// registered duplicates first. Emails and names are compared ignoring
+	// case. With userID set, only the pairs of that user are returned, with
+	// the user first, else the user registered first comes first.
+	FindDuplicateCandidates(ctx context.Context, userID int64, limit int32) ([]DuplicateCandidate, error)
+	// MergeUsers gives the target the fields of merged and moves the tags,
+	// notes, active sessions and phone history of the source to it. The
+	// source is then marked as merged into the target: it is kept, but
+	// served like a user deleted with DeleteUser and keeps its change
+	// history. source and target are the users merged was computed from;
+	// ErrConflict is returned if either changed since. merged must have the
+	// phone number of one of the users, another number is checked like in
+	// ChangePhoneNumber. entry is written to the audit log.
+	MergeUsers(ctx context.Context, source, target, merged *pb.GetUserResponse, policy PhonePolicy, entry audit.Entry) (*pb.UpdateUserResponse, error)
+
+	// CreateAdmin stores a new admin and writes entry to the audit log.
+	// Usernames are unique, ErrAlreadyExists is returned for taken ones.
+	CreateAdmin(ctx context.Context, admin Admin, entry audit.Entry) (*Admin, error)
+	GetAdmin(ctx context.Context, id int64) (*Admin, error)
+	GetAdminByUsername(ctx context.Context, username string) (*Admin, error)
+	// ListAdmins returns all admins ordered by username.
+	ListAdmins(ctx context.Context) ([]*Admin, error)
+	// SetAdminDisabled disables or enables the admin and writes entry to the audit log.
+	SetAdminDisabled(ctx context.Context, id int64, disabled bool, entry audit.Entry) (*Admin, error)
+	// SetAdminRoles replaces the roles of the admin and writes entry to the audit log.
+	SetAdminRoles(ctx context.Context, id int64, roles []string, entry audit.Entry) (*Admin, error)
+	// ResetAdminCredentials replaces the password hash and TOTP secret, lifts
+	// a lockout and invalidates tokens issued before now. entry is written to
+	// the audit log.
+	ResetAdminCredentials(ctx context.Context, id int64, passwordHash, totpSecret string, now time.Time, entry audit.Entry) (*Admin, error)
+	// RecordAdminLogin clears the failed logins of the admin and remembers
+	// the TOTP step of the login. ErrInvalidToken is returned if a login
+	// with the same or a later step was recorded meanwhile.
+	RecordAdminLogin(ctx context.Context, id, totpStep int64, now time.Time) error
+	// RecordAdminLoginFailure counts a failed login and locks the admin once
+	// the policy's limit is reached.
+	RecordAdminLoginFailure(ctx context.Context, id int64, policy LockoutPolicy) (*Admin, error)
+
+	// GetUserTags returns the tags of the user sorted by name.
+	GetUserTags(ctx context.Context, userID int64) ([]string, error)
+	// AddUserTags tags the user, adding unknown tags to the catalog, and
+	// writes entry to the audit log. The resulting tags are returned.
+	AddUserTags(ctx context.Context, userID int64, tags []string, entry audit.Entry) ([]string, error)
+	// RemoveUserTags removes tags from the user and writes entry to the audit
+	// log. The resulting tags are returned.
+	RemoveUserTags(ctx context.Context, userID int64, tags []string, entry audit.Entry) ([]string, error)
+	// BulkTagUsers adds and removes tags on all users matching the filter in
+	// one transaction, writes entry to the audit log and returns the number
+	// of matching users. The number is added to map details of the entry as
+	// "matched_users".
+	BulkTagUsers(ctx context.Context, filter UserFilter, add, remove []string, entry audit.Entry) (int64, error)
+	// ListTags returns the tag catalog ordered by name.
+	ListTags(ctx context.Context) ([]*Tag, error)
+	// AddUserNote appends a note to the timeline of the user.
+	AddUserNote(ctx context.Context, note UserNote) (*UserNote, error)
+	// ListUserNotes returns the notes of the user, newest first.
+	ListUserNotes(ctx context.Context, userID int64, limit, offset int32) ([]*UserNote, error)
+
+	// PutAttributeDefinition creates or replaces the definition with the
+	// same name and writes entry to the audit log. CreatedBy and CreatedAt of
+	// an existing definition are kept.
+	PutAttributeDefinition(ctx context.Context, def AttributeDefinition, entry audit.Entry) (*AttributeDefinition, error)
+	// ListAttributeDefinitions returns all definitions ordered by name.
+	ListAttributeDefinitions(ctx context.Context) ([]*AttributeDefinition, error)
+	// DeleteAttributeDefinition deletes a definition and writes entry to the
+	// audit log. Values of the attribute are kept.
+	DeleteAttributeDefinition(ctx context.Context, name string, entry audit.Entry) error
+
+	// CreateAPIKey stores a new API key with the hash of its secret and writes
+	// entry to the audit log. Names are unique, ErrAlreadyExists is returned
+	// for taken ones.
+	CreateAPIKey(ctx context.Context, key APIKey, hash []byte, entry audit.Entry) (*APIKey, error)
+	GetAPIKey(ctx context.Context, id int64) (*APIKey, error)
+	// GetAPIKeyByHash returns the key whose secret has the given hash,
+	// including revoked and expired keys, of any tenant.
+	GetAPIKeyByHash(ctx context.Context, hash []byte) (*APIKey, error)
+	// ListAPIKeys returns all API keys ordered by name.
+	ListAPIKeys(ctx context.Context) ([]*APIKey, error)
+	// RotateAPIKey replaces the prefix and secret hash of a key that is not
+	// revoked and writes entry to the audit log. ErrNotFound is returned for
+	// unknown and revoked keys.
+	RotateAPIKey(ctx context.Context, id int64, prefix string, hash []byte, now time.Time, entry audit.Entry) (*APIKey, error)
+	// RevokeAPIKey revokes the key and writes entry to the audit log.
+	RevokeAPIKey(ctx context.Context, id int64, now time.Time, entry audit.Entry) (*APIKey, error)
+	// TouchAPIKey records the last use of the key.
+	TouchAPIKey(ctx context.Context, id int64, now time.Time) error
+
+	// CreateTenant stores a new tenant and writes entry to the audit log.
+	// Slugs are unique, ErrAlreadyExists is returned for taken ones.
+	CreateTenant(ctx context.Context, t Tenant, entry audit.Entry) (*Tenant, error)
+	GetTenant(ctx context.Context, id int64) (*Tenant, error)
+	GetTenantBySlug(ctx context.Context, slug string) (*Tenant, error)
+	// ListTenants returns all tenants ordered by ID.
+	ListTenants(ctx context.Context) ([]*Tenant, error)
+	// UpdateTenant replaces the name, configuration and disabled flag of the
+	// tenant with the ID of t and writes entry to the audit log.
+	UpdateTenant(ctx context.Context, t Tenant, entry audit.Entry) (*Tenant, error)
+}
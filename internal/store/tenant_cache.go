@@ -0,0 +1,94 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TenantCache keeps tenants in memory, since every call looks up its tenant.
+// Changes made through Forget are seen at once, changes made by other
+// replicas once the TTL has passed.
+type TenantCache struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	byID    map[int64]*cachedTenant
+	slugIDs map[string]int64
+}
+
+type cachedTenant struct {
+	tenant    *Tenant
+	expiresAt time.Time
+}
+
+// NewTenantCache caches the tenants of store for ttl.
+func NewTenantCache(store Store, ttl time.Duration) *TenantCache {
+	return &TenantCache{
+		store:   store,
+		ttl:     ttl,
+		now:     time.Now,
+		byID:    make(map[int64]*cachedTenant),
+		slugIDs: make(map[string]int64),
+	}
+}
+
+// GetTenant returns the tenant with the given ID. The result must not be modified.
+func (c *TenantCache) GetTenant(ctx context.Context, id int64) (*Tenant, error) {
+	if t := c.cached(id); t != nil {
+		return t, nil
+	}
+	t, err := c.store.GetTenant(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	c.add(t)
+	return t, nil
+}
+
+// GetTenantBySlug returns the tenant with the given slug. The result must not be modified.
+func (c *TenantCache) GetTenantBySlug(ctx context.Context, slug string) (*Tenant, error) {
+	c.mu.Lock()
+	id, ok := c.slugIDs[slug]
+	c.mu.Unlock()
+	if ok {
+		if t := c.cached(id); t != nil && t.Slug == slug {
+			return t, nil
+		}
+	}
+
+	t, err := c.store.GetTenantBySlug(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	c.add(t)
+	return t, nil
+}
+
+// Forget drops a changed tenant.
+func (c *TenantCache) Forget(id int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.byID, id)
+}
+
+func (c *TenantCache) cached(id int64) *Tenant {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.byID[id]
+	if !ok || !c.now().Before(entry.expiresAt) {
+		return nil
+	}
+	return entry.tenant
+}
+
+func (c *TenantCache) add(t *Tenant) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.byID[t.ID] = &cachedTenant{tenant: t, expiresAt: c.now().Add(c.ttl)}
+	c.slugIDs[t.Slug] = t.ID
+}
@@ -0,0 +1,34 @@
+// Package tenant carries the tenant a call is made for. Every user, admin
+// and API key belongs to one tenant, and the stores only show the data of
+// the tenant in the context.
+package tenant
+
+import "context"
+
+// Default is the tenant of installations without tenants and of calls that
+// do not name one. Its admins manage the other tenants.
+const Default int64 = 1
+
+// Header is the metadata key naming the tenant of a call by its slug.
+const Header = "x-tenant"
+
+type key struct{}
+
+// WithID returns a context scoped to the tenant.
+func WithID(ctx context.Context, id int64) context.Context {
+	return context.WithValue(ctx, key{}, id)
+}
+
+// FromContext returns the tenant stored with WithID.
+func FromContext(ctx context.Context) (int64, bool) {
+	id, ok := ctx.Value(key{}).(int64)
+	return id, ok
+}
+
+// ID returns the tenant stored with WithID, or Default if there is none.
+func ID(ctx context.Context) int64 {
+	if id, ok := FromContext(ctx); ok {
+		return id
+	}
+	return Default
+}
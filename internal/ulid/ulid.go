@@ -0,0 +1,61 @@
+// Package ulid generates the opaque public IDs of users. A ULID is a 48-bit
+// millisecond timestamp followed by 80 random bits, written as 26 characters
+// of Crockford's base32, so IDs sort by creation time but cannot be guessed.
+package ulid
+
+import (
+	"crypto/rand"
+	"strings"
+	"time"
+)
+
+// Length is the number of characters of a ULID.
+const Length = 26
+
+const alphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// New returns a ULID for time t.
+func New(t time.Time) (string, error) {
+	var id [16]byte
+	ms := uint64(t.UnixMilli())
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+	return encode(id), nil
+}
+
+// encode writes the 128 bits as 26 base32 digits, the first digit holding
+// only the top 3 bits.
+func encode(id [16]byte) string {
+	var out [Length]byte
+	var acc uint64
+	bits := 2 // 130 bits of output for 128 bits of input
+	pos := 0
+	for _, b := range id {
+		acc = acc<<8 | uint64(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = alphabet[acc>>uint(bits)&31]
+			pos++
+		}
+	}
+	return string(out[:])
+}
+
+// Valid reports whether s is a well-formed ULID in canonical upper case.
+func Valid(s string) bool {
+	if len(s) != Length || s[0] > '7' {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(alphabet, s[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
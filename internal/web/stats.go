@@ -0,0 +1,113 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+var statsIntervals = map[string]pb.StatsInterval{
+	"":      pb.StatsInterval_STATS_INTERVAL_DAY,
+	"day":   pb.StatsInterval_STATS_INTERVAL_DAY,
+	"week":  pb.StatsInterval_STATS_INTERVAL_WEEK,
+	"month": pb.StatsInterval_STATS_INTERVAL_MONTH,
+}
+
+// statsTable lists counts with bars relative to the largest one.
+type statsTable struct {
+	Title string
+	Rows  []statsRow
+}
+
+type statsRow struct {
+	Label string
+	Count int64
+	Max   int64
+}
+
+// handleStats shows the statistics of the users matching the filter of the
+// form. Dates are taken in the time zone of the form.
+func (d *Dashboard) handleStats(w http.ResponseWriter, r *http.Request, s *session) {
+	query := r.URL.Query()
+	interval, ok := statsIntervals[query.Get("interval")]
+	if !ok {
+		d.renderError(w, r, http.StatusBadRequest, "Unknown interval.")
+		return
+	}
+	timeZone := strings.TrimSpace(query.Get("time_zone"))
+	loc := time.UTC
+	if timeZone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timeZone); err != nil || timeZone == "Local" {
+			d.renderError(w, r, http.StatusBadRequest, "Unknown time zone.")
+			return
+		}
+	}
+
+	req := &pb.GetUserStatsRequest{
+		Filter:   &pb.UserFilter{Country: strings.ToUpper(strings.TrimSpace(query.Get("country")))},
+		Interval: interval,
+		TimeZone: timeZone,
+	}
+	if tag := strings.TrimSpace(query.Get("tag")); tag != "" {
+		req.Filter.Tags = []string{tag}
+	}
+	for _, field := range []struct {
+		name   string
+		target **timestamppb.Timestamp
+	}{
+		{"from", &req.StartTime},
+		{"to", &req.EndTime},
+	} {
+		if value := query.Get(field.name); value != "" {
+			t, err := time.ParseInLocation("2006-01-02", value, loc)
+			if err != nil {
+				d.renderError(w, r, http.StatusBadRequest, "Dates must look like 2024-05-31.")
+				return
+			}
+			*field.target = timestamppb.New(t)
+		}
+	}
+
+	stats, err := d.users.GetUserStats(callContext(r, s), req)
+	if err != nil {
+		d.callError(w, r, err)
+		return
+	}
+	var registrations []statsRow
+	for _, b := range stats.Registrations {
+		registrations = append(registrations, statsRow{Label: b.StartTime.AsTime().In(loc).Format("2006-01-02"), Count: b.Count})
+	}
+	counts := func(title string, counts []*pb.StatsCount) statsTable {
+		rows := make([]statsRow, 0, len(counts))
+		for _, c := range counts {
+			rows = append(rows, statsRow{Label: c.Key, Count: c.Count})
+		}
+		return newStatsTable(title, rows)
+	}
+	d.render(w, r, http.StatusOK, "stats.html", map[string]interface{}{
+		"Query":         query,
+		"Stats":         stats,
+		"Registrations": newStatsTable("Registrations", registrations),
+		"Genders":       counts("Gender", stats.Genders),
+		"AgeBands":      counts("Age", stats.AgeBands),
+		"Locations":     counts("Location", stats.Locations),
+	})
+}
+
+// newStatsTable sets the largest count of rows as their maximum.
+func newStatsTable(title string, rows []statsRow) statsTable {
+	var max int64 = 1
+	for _, row := range rows {
+		if row.Count > max {
+			max = row.Count
+		}
+	}
+	for i := range rows {
+		rows[i].Max = max
+	}
+	return statsTable{Title: title, Rows: rows}
+}
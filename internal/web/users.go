@@ -0,0 +1,298 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	usersPageSize     = 50
+	revisionsPageSize = 20
+)
+
+// notices are the messages shown after a change, by the "done" parameter of
+// the page redirected to.
+var notices = map[string]string{
+	"updated":   "The user was updated.",
+	"unchanged": "Nothing was changed.",
+	"blocked":   "The user was blocked and signed out of all sessions.",
+	"unblocked": "The user was unblocked.",
+	"deleted":   "The user was deleted.",
+}
+
+// userForm holds the editable fields of a user as shown in the edit form.
+type userForm struct {
+	FirstName string
+	LastName  string
+	Email     string
+	Gender    string
+	Location  string
+	BirthDate string
+}
+
+func formOf(u *pb.GetUserResponse) userForm {
+	return userForm{
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Email:     u.Email,
+		Gender:    u.Gender,
+		Location:  u.Location,
+		BirthDate: formatDate(u.BirthDate),
+	}
+}
+
+func formFrom(r *http.Request) userForm {
+	value := func(name string) string { return strings.TrimSpace(r.PostFormValue(name)) }
+	return userForm{
+		FirstName: value("first_name"),
+		LastName:  value("last_name"),
+		Email:     value("email"),
+		Gender:    value("gender"),
+		Location:  value("location"),
+		BirthDate: value("birth_date"),
+	}
+}
+
+// handleUsers lists users, optionally filtered. A search for an ID, public
+// ID, phone number or email opens the user directly.
+func (d *Dashboard) handleUsers(w http.ResponseWriter, r *http.Request, s *session) {
+	ctx := callContext(r, s)
+	query := r.URL.Query()
+	if q := strings.TrimSpace(query.Get("q")); q != "" {
+		req, ok := lookupRequest(q)
+		if !ok {
+			d.renderError(w, r, http.StatusBadRequest, "Search for an ID, public ID, phone number in international format or email.")
+			return
+		}
+		user, err := d.users.LookupUser(ctx, req)
+		if err != nil {
+			d.callError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("%susers/%d", Prefix, user.Id), http.StatusSeeOther)
+		return
+	}
+
+	filter := &pb.UserFilter{Country: strings.ToUpper(strings.TrimSpace(query.Get("country")))}
+	if tag := strings.TrimSpace(query.Get("tag")); tag != "" {
+		filter.Tags = []string{tag}
+	}
+	switch query.Get("blocked") {
+	case "true":
+		filter.Blocked = pb.BlockedFilter_BLOCKED_FILTER_BLOCKED
+	case "false":
+		filter.Blocked = pb.BlockedFilter_BLOCKED_FILTER_NOT_BLOCKED
+	}
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+
+	resp, err := d.users.GetAllUsers(ctx, &pb.PaginationRequest{Page: int32(page), PageSize: usersPageSize, Filter: filter})
+	if err != nil {
+		d.callError(w, r, err)
+		return
+	}
+	pageURL := func(page int32) string {
+		if page <= 0 {
+			return ""
+		}
+		q := url.Values{}
+		for _, name := range []string{"tag", "country", "blocked"} {
+			if v := query.Get(name); v != "" {
+				q.Set(name, v)
+			}
+		}
+		q.Set("page", strconv.Itoa(int(page)))
+		return Prefix + "users?" + q.Encode()
+	}
+	d.render(w, r, http.StatusOK, "users.html", map[string]interface{}{
+		"Users":    resp.Users,
+		"Query":    query,
+		"Notice":   notices[query.Get("done")],
+		"Previous": pageURL(resp.PreviousPage),
+		"Next":     pageURL(resp.NextPage),
+	})
+}
+
+// lookupRequest tells the kind of key from its form.
+func lookupRequest(q string) (*pb.LookupUserRequest, bool) {
+	// Checked first, as ParseInt accepts a leading plus sign
+	if strings.HasPrefix(q, "+") {
+		return &pb.LookupUserRequest{Key: &pb.LookupUserRequest_PhoneNumber{PhoneNumber: q}}, true
+	}
+	if id, err := strconv.ParseInt(q, 10, 64); err == nil && id > 0 {
+		return &pb.LookupUserRequest{Key: &pb.LookupUserRequest_Id{Id: id}}, true
+	}
+	switch {
+	case strings.Contains(q, "@"):
+		return &pb.LookupUserRequest{Key: &pb.LookupUserRequest_Email{Email: q}}, true
+	case len(q) == 26:
+		return &pb.LookupUserRequest{Key: &pb.LookupUserRequest_PublicId{PublicId: strings.ToUpper(q)}}, true
+	}
+	return nil, false
+}
+
+// handleUser serves the page of a user under users/ID and its actions under
+// users/ID/ACTION.
+func (d *Dashboard) handleUser(w http.ResponseWriter, r *http.Request, s *session) {
+	rest := strings.TrimPrefix(r.URL.Path, Prefix+"users/")
+	idPart, action, _ := strings.Cut(rest, "/")
+	id, err := strconv.ParseInt(idPart, 10, 64)
+	if err != nil || id <= 0 {
+		d.renderError(w, r, http.StatusNotFound, "Page not found.")
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		d.showUser(w, r, s, id, http.StatusOK, "", nil)
+	case action == "" && r.Method == http.MethodPost:
+		d.updateUser(w, r, s, id)
+	case (action == "block" || action == "unblock") && r.Method == http.MethodPost:
+		d.setBlocked(w, r, s, id, action == "block")
+	case action == "delete" && r.Method == http.MethodGet:
+		user, err := d.users.GetUserById(callContext(r, s), &pb.UserID{Id: id})
+		if err != nil {
+			d.callError(w, r, err)
+			return
+		}
+		d.render(w, r, http.StatusOK, "delete.html", user)
+	case action == "delete" && r.Method == http.MethodPost:
+		if _, err := d.users.DeleteUser(callContext(r, s), &pb.UserID{Id: id}); err != nil {
+			d.callError(w, r, err)
+			return
+		}
+		http.Redirect(w, r, Prefix+"users?done=deleted", http.StatusSeeOther)
+	default:
+		d.renderError(w, r, http.StatusNotFound, "Page not found.")
+	}
+}
+
+// showUser renders the page of a user with its tags and change history. A
+// form that failed to save is shown again with its error.
+func (d *Dashboard) showUser(w http.ResponseWriter, r *http.Request, s *session, id int64, code int, formError string, form *userForm) {
+	ctx := callContext(r, s)
+	user, err := d.users.GetUserById(ctx, &pb.UserID{Id: id})
+	if err != nil {
+		d.callError(w, r, err)
+		return
+	}
+	tags, err := d.users.GetUserTags(ctx, &pb.UserID{Id: id})
+	if err != nil {
+		d.callError(w, r, err)
+		return
+	}
+	revisions, err := d.users.ListUserRevisions(ctx, &pb.ListUserRevisionsRequest{UserId: id, PageSize: revisionsPageSize})
+	if err != nil {
+		d.callError(w, r, err)
+		return
+	}
+	if form == nil {
+		f := formOf(user)
+		form = &f
+	}
+	d.render(w, r, code, "user.html", map[string]interface{}{
+		"User":      user,
+		"Tags":      tags.Tags,
+		"Revisions": revisions.Revisions,
+		"Form":      form,
+		"Error":     formError,
+		"Notice":    notices[r.URL.Query().Get("done")],
+	})
+}
+
+// updateUser saves the fields of the edit form that changed. Emptied fields
+// are cleared.
+func (d *Dashboard) updateUser(w http.ResponseWriter, r *http.Request, s *session, id int64) {
+	ctx := callContext(r, s)
+	user, err := d.users.GetUserById(ctx, &pb.UserID{Id: id})
+	if err != nil {
+		d.callError(w, r, err)
+		return
+	}
+	current, form := formOf(user), formFrom(r)
+
+	req := &pb.UpdateUserRequest{Id: id}
+	changed := false
+	// UpdateUser keeps empty fields and clears those set to "null"
+	for _, field := range []struct {
+		old, new string
+		target   *string
+	}{
+		{current.FirstName, form.FirstName, &req.FirstName},
+		{current.LastName, form.LastName, &req.LastName},
+		{current.Email, form.Email, &req.Email},
+		{current.Gender, form.Gender, &req.Gender},
+		{current.Location, form.Location, &req.Location},
+	} {
+		if field.old == field.new {
+			continue
+		}
+		changed = true
+		*field.target = field.new
+		if field.new == "" {
+			*field.target = "null"
+		}
+	}
+	if current.BirthDate != form.BirthDate {
+		changed = true
+		req.BirthDate = &date.Date{}
+		if form.BirthDate != "" {
+			t, err := time.Parse("2006-01-02", form.BirthDate)
+			if err != nil {
+				d.showUser(w, r, s, id, http.StatusBadRequest, "Birth date must be a date like 1990-05-31.", &form)
+				return
+			}
+			req.BirthDate = &date.Date{Year: int32(t.Year()), Month: int32(t.Month()), Day: int32(t.Day())}
+		}
+	}
+	if !changed {
+		http.Redirect(w, r, fmt.Sprintf("%susers/%d?done=unchanged", Prefix, id), http.StatusSeeOther)
+		return
+	}
+
+	if _, err := d.users.UpdateUser(ctx, req); err != nil {
+		switch status.Code(err) {
+		case codes.InvalidArgument, codes.AlreadyExists:
+			d.showUser(w, r, s, id, httpStatus(err), status.Convert(err).Message(), &form)
+		default:
+			d.callError(w, r, err)
+		}
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("%susers/%d?done=updated", Prefix, id), http.StatusSeeOther)
+}
+
+func (d *Dashboard) setBlocked(w http.ResponseWriter, r *http.Request, s *session, id int64, blocked bool) {
+	ctx := callContext(r, s)
+	var err error
+	done := "blocked"
+	if blocked {
+		_, err = d.users.BlockUser(ctx, &pb.UserID{Id: id})
+	} else {
+		_, err = d.users.UnblockUser(ctx, &pb.UserID{Id: id})
+		done = "unblocked"
+	}
+	if err != nil {
+		d.callError(w, r, err)
+		return
+	}
+	http.Redirect(w, r, fmt.Sprintf("%susers/%d?done=%s", Prefix, id, done), http.StatusSeeOther)
+}
+
+func formatDate(d *date.Date) string {
+	if d == nil || d.Year == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
@@ -0,0 +1,324 @@
+// Package web serves the admin dashboard, a server-rendered UI for support
+// staff. Every page calls the gRPC services over an in-process connection
+// with the access token of the signed-in admin, so it is subject to the same
+// permissions, tenants, rate limits and audit log as other clients.
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"embed"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/inprocess"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Prefix is the path the dashboard is served under.
+const Prefix = "/admin/"
+
+const (
+	sessionCookie = "useradmin_session"
+	csrfCookie    = "useradmin_csrf"
+	csrfField     = "csrf_token"
+)
+
+//go:embed templates static
+var files embed.FS
+
+// pages are the templates rendered inside layout.html.
+var pages = []string{"login.html", "users.html", "user.html", "delete.html", "stats.html", "error.html"}
+
+// Dashboard serves the admin dashboard.
+type Dashboard struct {
+	users     pb.UserServiceClient
+	admins    pb.AdminServiceClient
+	secure    bool
+	templates map[string]*template.Template
+	static    http.Handler
+	mux       *http.ServeMux
+}
+
+// session is kept in the session cookie. Only the token is trusted, the
+// other fields are shown in the page header.
+type session struct {
+	Token     string    `json:"token"`
+	Username  string    `json:"username"`
+	Tenant    string    `json:"tenant,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// New creates the dashboard on top of a connection to the server. With
+// secureCookies the cookies are only sent over HTTPS.
+func New(conn grpc.ClientConnInterface, secureCookies bool) (*Dashboard, error) {
+	d := &Dashboard{
+		users:     pb.NewUserServiceClient(conn),
+		admins:    pb.NewAdminServiceClient(conn),
+		secure:    secureCookies,
+		templates: make(map[string]*template.Template),
+		mux:       http.NewServeMux(),
+	}
+	for _, page := range pages {
+		t, err := template.New(page).Funcs(funcs).ParseFS(files, "templates/layout.html", "templates/"+page)
+		if err != nil {
+			return nil, err
+		}
+		d.templates[page] = t
+	}
+	static, err := fs.Sub(files, "static")
+	if err != nil {
+		return nil, err
+	}
+	d.static = http.StripPrefix(Prefix+"static/", http.FileServer(http.FS(static)))
+
+	d.mux.HandleFunc(Prefix, d.handleIndex)
+	d.mux.Handle(Prefix+"static/", d.static)
+	d.mux.HandleFunc(Prefix+"login", d.handleLogin)
+	d.mux.HandleFunc(Prefix+"logout", d.handleLogout)
+	d.mux.HandleFunc(Prefix+"users", d.signedIn(d.handleUsers))
+	d.mux.HandleFunc(Prefix+"users/", d.signedIn(d.handleUser))
+	d.mux.HandleFunc(Prefix+"stats", d.signedIn(d.handleStats))
+	return d, nil
+}
+
+// ServeHTTP sets the security headers of all pages and checks the CSRF token
+// of every form submission.
+func (d *Dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("Content-Security-Policy", "default-src 'self'; form-action 'self'; frame-ancestors 'none'")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("X-Frame-Options", "DENY")
+	h.Set("Referrer-Policy", "same-origin")
+	if !strings.HasPrefix(r.URL.Path, Prefix+"static/") {
+		h.Set("Cache-Control", "no-store")
+	}
+
+	if r.Method == http.MethodPost && !d.validCSRF(r) {
+		d.renderError(w, r, http.StatusForbidden, "The form has expired, please reload the page and try again.")
+		return
+	}
+	d.mux.ServeHTTP(w, r)
+}
+
+func (d *Dashboard) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != Prefix {
+		d.renderError(w, r, http.StatusNotFound, "Page not found.")
+		return
+	}
+	http.Redirect(w, r, Prefix+"users", http.StatusSeeOther)
+}
+
+func (d *Dashboard) handleLogin(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{"Tenant": r.FormValue("tenant")}
+	if r.Method != http.MethodPost {
+		d.render(w, r, http.StatusOK, "login.html", data)
+		return
+	}
+
+	username := strings.TrimSpace(r.PostFormValue("username"))
+	slug := strings.TrimSpace(r.PostFormValue("tenant"))
+	data["Username"] = username
+	ctx := inprocess.WithClientIP(r.Context(), clientIP(r))
+	if slug != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, tenant.Header, slug)
+	}
+	resp, err := d.admins.AdminLogin(ctx, &pb.AdminLoginRequest{
+		Username: username,
+		Password: r.PostFormValue("password"),
+		TotpCode: strings.TrimSpace(r.PostFormValue("totp_code")),
+	})
+	if err != nil {
+		data["Error"] = status.Convert(err).Message()
+		d.render(w, r, httpStatus(err), "login.html", data)
+		return
+	}
+
+	s := session{
+		Token:     resp.AccessToken,
+		Username:  resp.Admin.GetUsername(),
+		Tenant:    slug,
+		ExpiresAt: resp.AccessTokenExpireTime.AsTime(),
+	}
+	value, err := json.Marshal(s)
+	if err != nil {
+		log.Printf("Error encoding dashboard session: %v", err)
+		d.renderError(w, r, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	d.setCookie(w, sessionCookie, base64.RawURLEncoding.EncodeToString(value), s.ExpiresAt)
+	log.Printf("Admin %s signed in to the dashboard", s.Username)
+	http.Redirect(w, r, Prefix+"users", http.StatusSeeOther)
+}
+
+func (d *Dashboard) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		d.renderError(w, r, http.StatusMethodNotAllowed, "Method not allowed.")
+		return
+	}
+	d.setCookie(w, sessionCookie, "", time.Unix(1, 0))
+	http.Redirect(w, r, Prefix+"login", http.StatusSeeOther)
+}
+
+type sessionHandler func(w http.ResponseWriter, r *http.Request, s *session)
+
+// signedIn passes the session to h, sending visitors without one to the
+// login page. Admin credentials are optional on the server, so calls must
+// never be made without a token.
+func (d *Dashboard) signedIn(h sessionHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s := readSession(r)
+		if s == nil || time.Now().After(s.ExpiresAt) {
+			http.Redirect(w, r, Prefix+"login", http.StatusSeeOther)
+			return
+		}
+		h(w, r, s)
+	}
+}
+
+func readSession(r *http.Request) *session {
+	c, err := r.Cookie(sessionCookie)
+	if err != nil || c.Value == "" {
+		return nil
+	}
+	value, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return nil
+	}
+	var s session
+	if err := json.Unmarshal(value, &s); err != nil || s.Token == "" {
+		return nil
+	}
+	return &s
+}
+
+// callContext authenticates calls made for the request with the token of the
+// session.
+func callContext(r *http.Request, s *session) context.Context {
+	ctx := inprocess.WithClientIP(r.Context(), clientIP(r))
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+s.Token)
+}
+
+// clientIP returns the address of the browser. Like the gRPC API, the
+// dashboard does not trust forwarding headers.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// csrfToken returns the CSRF token of the browser, issuing one when it has
+// none. Forms send it back, and cross-site requests cannot read the cookie.
+func (d *Dashboard) csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(csrfCookie); err == nil && len(c.Value) == 43 {
+		return c.Value
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	d.setCookie(w, csrfCookie, token, time.Time{})
+	// Requests later in the same response see the new token
+	r.AddCookie(&http.Cookie{Name: csrfCookie, Value: token})
+	return token
+}
+
+func (d *Dashboard) validCSRF(r *http.Request) bool {
+	c, err := r.Cookie(csrfCookie)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(c.Value), []byte(r.PostFormValue(csrfField))) == 1
+}
+
+// setCookie sets a cookie of the dashboard. A zero expiry makes it a
+// browser session cookie.
+func (d *Dashboard) setCookie(w http.ResponseWriter, name, value string, expires time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     Prefix,
+		Expires:  expires,
+		Secure:   d.secure,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// render writes a page. data is passed to the template as .Data, next to the
+// session and CSRF token.
+func (d *Dashboard) render(w http.ResponseWriter, r *http.Request, code int, page string, data interface{}) {
+	view := map[string]interface{}{
+		"Prefix":  Prefix,
+		"CSRF":    d.csrfToken(w, r),
+		"Session": readSession(r),
+		"Data":    data,
+	}
+	var buf bytes.Buffer
+	if err := d.templates[page].ExecuteTemplate(&buf, "layout", view); err != nil {
+		log.Printf("Error rendering %s: %v", page, err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	buf.WriteTo(w)
+}
+
+func (d *Dashboard) renderError(w http.ResponseWriter, r *http.Request, code int, message string) {
+	d.render(w, r, code, "error.html", message)
+}
+
+// callError shows the error of a call. Expired or revoked sessions are sent
+// to the login page.
+func (d *Dashboard) callError(w http.ResponseWriter, r *http.Request, err error) {
+	if status.Code(err) == codes.Unauthenticated {
+		d.setCookie(w, sessionCookie, "", time.Unix(1, 0))
+		http.Redirect(w, r, Prefix+"login", http.StatusSeeOther)
+		return
+	}
+	d.renderError(w, r, httpStatus(err), status.Convert(err).Message())
+}
+
+// httpStatus maps the code of a call error to an HTTP status.
+func httpStatus(err error) int {
+	switch status.Code(err) {
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.AlreadyExists:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	}
+	return http.StatusInternalServerError
+}
+
+var funcs = template.FuncMap{
+	"timestamp": func(t *timestamppb.Timestamp) string {
+		if t == nil {
+			return ""
+		}
+		return t.AsTime().UTC().Format("2006-01-02 15:04 UTC")
+	},
+}
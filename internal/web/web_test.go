@@ -0,0 +1,54 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/web"
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/usertest"
+)
+
+func TestDashboardRejectsFormsWithoutTheCSRFToken(t *testing.T) {
+	srv := usertest.New(t)
+	dashboard, err := web.New(srv.Conn, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	dashboard.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, web.Prefix+"login", nil))
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == "useradmin_csrf" {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("the login page did not issue a CSRF cookie")
+	}
+	if !strings.Contains(rec.Body.String(), cookie.Value) {
+		t.Error("the login form does not carry the CSRF token")
+	}
+
+	post := func(token string) int {
+		form := url.Values{"csrf_token": {token}}
+		r := httptest.NewRequest(http.MethodPost, web.Prefix+"logout", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.AddCookie(cookie)
+		rec := httptest.NewRecorder()
+		dashboard.ServeHTTP(rec, r)
+		return rec.Code
+	}
+	if code := post(""); code != http.StatusForbidden {
+		t.Errorf("got status %d without a token, want %d", code, http.StatusForbidden)
+	}
+	if code := post(strings.Repeat("x", len(cookie.Value))); code != http.StatusForbidden {
+		t.Errorf("got status %d with a wrong token, want %d", code, http.StatusForbidden)
+	}
+	if code := post(cookie.Value); code != http.StatusSeeOther {
+		t.Errorf("got status %d with the token, want %d", code, http.StatusSeeOther)
+	}
+}
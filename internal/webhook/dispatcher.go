@@ -0,0 +1,333 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DispatcherConfig controls polling and retry behaviour of the Dispatcher.
+type DispatcherConfig struct {
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	Timeout      time.Duration
+	// AllowPrivate lets webhooks use http and reach loopback, private and
+	// link-local addresses, e.g. in development.
+	AllowPrivate bool
+}
+
+// Dispatcher reads events from the outbox, fans them out into one delivery per
+// matching subscription and delivers them to the subscribed endpoints.
+type Dispatcher struct {
+	db     *sql.DB
+	cfg    DispatcherConfig
+	client *http.Client
+}
+
+// NewDispatcher creates a Dispatcher, filling in defaults for unset settings.
+func NewDispatcher(db *sql.DB, cfg DispatcherConfig) *Dispatcher {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = 5 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 10
+	}
+	if cfg.BaseBackoff <= 0 {
+		cfg.BaseBackoff = 10 * time.Second
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = time.Hour
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	return &Dispatcher{
+		db:     db,
+		cfg:    cfg,
+		client: newClient(cfg.Timeout, cfg.AllowPrivate),
+	}
+}
+
+// Run polls the outbox until the context is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := d.fanOut(ctx); err != nil {
+			log.Printf("Error fanning out outbox events: %v", err)
+		}
+		if err := d.deliverDue(ctx); err != nil {
+			log.Printf("Error delivering webhooks: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// fanOut creates a pending delivery for every subscription interested in each
+// undispatched event and marks the events as dispatched.
+func (d *Dispatcher) fanOut(ctx context.Context) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx,
+		"SELECT id FROM outbox_events WHERE dispatched_at IS NULL ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED",
+		d.cfg.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	var eventIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		eventIDs = append(eventIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(eventIDs) == 0 {
+		return nil
+	}
+
+	// An empty event_types list subscribes to every event of the tenant
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (event_id, subscription_id)
+		SELECT e.id, s.id FROM outbox_events e
+		JOIN webhook_subscriptions s ON s.tenant_id = e.tenant_id
+			AND (cardinality(s.event_types) = 0 OR e.event_type = ANY(s.event_types))
+		WHERE e.id = ANY($1)
+	`, pq.Array(eventIDs))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE outbox_events SET dispatched_at = CURRENT_TIMESTAMP WHERE id = ANY($1)",
+		pq.Array(eventIDs))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type pendingDelivery struct {
+	id        int64
+	attempts  int
+	eventID   int64
+	eventType string
+	payload   []byte
+	url       string
+	secret    string
+}
+
+// deliverDue sends every pending delivery whose next attempt is due, one
+// tenant at a time.
+func (d *Dispatcher) deliverDue(ctx context.Context) error {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT DISTINCT e.tenant_id
+		FROM webhook_deliveries dl
+		JOIN outbox_events e ON e.id = dl.event_id
+		WHERE dl.status = $1 AND dl.next_attempt_at <= CURRENT_TIMESTAMP
+	`, StatusPending)
+	if err != nil {
+		return err
+	}
+
+	var tenantIDs []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		tenantIDs = append(tenantIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, tenantID := range tenantIDs {
+		if err := d.deliverTenant(ctx, tenantID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deliverTenant sends the due deliveries of a tenant. No transaction is held
+// open while endpoints are called: the deliveries are claimed first, and the
+// result of each is recorded as soon as it is known.
+func (d *Dispatcher) deliverTenant(ctx context.Context, tenantID int64) error {
+	deliveries, err := d.claim(ctx, tenantID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range deliveries {
+		statusCode, sendErr := d.send(ctx, p)
+		if err := d.record(ctx, p, statusCode, sendErr); err != nil {
+			// Deliveries not yet sent are picked up again when their claim expires
+			return err
+		}
+	}
+	return nil
+}
+
+// claim selects the due deliveries of a tenant and postpones their next
+// attempt until the whole batch could have timed out, so other dispatchers
+// skip them while they are being sent. The tenant is set on the transaction
+// since attribute definitions are only visible to their tenant.
+func (d *Dispatcher) claim(ctx context.Context, tenantID int64) ([]pendingDelivery, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.tenant_id', $1, true)", strconv.FormatInt(tenantID, 10)); err != nil {
+		return nil, err
+	}
+
+	// Only custom attributes defined as not PII are delivered
+	rows, err := tx.QueryContext(ctx, `
+		SELECT dl.id, dl.attempts, e.id, e.event_type,
+			CASE WHEN e.payload ? 'custom_attributes' THEN jsonb_set(e.payload, '{custom_attributes}', COALESCE((
+				SELECT jsonb_object_agg(a.key, a.value) FROM jsonb_each(e.payload->'custom_attributes') a
+				JOIN attribute_definitions d ON d.name = a.key AND NOT d.pii), '{}'))
+			ELSE e.payload END,
+			s.url, s.secret
+		FROM webhook_deliveries dl
+		JOIN outbox_events e ON e.id = dl.event_id
+		JOIN webhook_subscriptions s ON s.id = dl.subscription_id
+		WHERE dl.status = $1 AND dl.next_attempt_at <= CURRENT_TIMESTAMP AND e.tenant_id = $3
+		ORDER BY dl.next_attempt_at
+		LIMIT $2
+		FOR UPDATE OF dl SKIP LOCKED
+	`, StatusPending, d.cfg.BatchSize, tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	var deliveries []pendingDelivery
+	var ids []int64
+	for rows.Next() {
+		var p pendingDelivery
+		if err := rows.Scan(&p.id, &p.attempts, &p.eventID, &p.eventType, &p.payload, &p.url, &p.secret); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		deliveries = append(deliveries, p)
+		ids = append(ids, p.id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, nil
+	}
+
+	lease := time.Duration(len(deliveries)+1) * d.cfg.Timeout
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE webhook_deliveries SET next_attempt_at = CURRENT_TIMESTAMP + $1 * INTERVAL '1 second' WHERE id = ANY($2)",
+		lease.Seconds(), pq.Array(ids)); err != nil {
+		return nil, err
+	}
+	return deliveries, tx.Commit()
+}
+
+// record stores the outcome of a delivery attempt: delivered, retried with
+// backoff or moved to the dead-letter list.
+func (d *Dispatcher) record(ctx context.Context, p pendingDelivery, statusCode int, sendErr error) error {
+	attempts := p.attempts + 1
+	var err error
+	if sendErr == nil {
+		_, err = d.db.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET status = $1, attempts = $2, last_status_code = $3, last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+			WHERE id = $4
+		`, StatusDelivered, attempts, statusCode, p.id)
+	} else if attempts >= d.cfg.MaxAttempts {
+		log.Printf("Webhook delivery %d moved to dead-letter list after %d attempts: %v", p.id, attempts, sendErr)
+		_, err = d.db.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET status = $1, attempts = $2, last_status_code = $3, last_error = $4
+			WHERE id = $5
+		`, StatusDead, attempts, nullStatusCode(statusCode), sendErr.Error(), p.id)
+	} else {
+		delay := Backoff(d.cfg.BaseBackoff, d.cfg.MaxBackoff, attempts)
+		_, err = d.db.ExecContext(ctx, `
+			UPDATE webhook_deliveries
+			SET attempts = $1, last_status_code = $2, last_error = $3, next_attempt_at = CURRENT_TIMESTAMP + $4 * INTERVAL '1 second'
+			WHERE id = $5
+		`, attempts, nullStatusCode(statusCode), sendErr.Error(), delay.Seconds(), p.id)
+	}
+	return err
+}
+
+// send posts the event payload to the subscription endpoint. Any non-2xx
+// response is treated as a failure.
+func (d *Dispatcher) send(ctx context.Context, p pendingDelivery) (int, error) {
+	// Subscriptions registered before the URL rules applied are held to them too
+	if err := ValidateURL(p.url, d.cfg.AllowPrivate); err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(p.payload))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, p.eventType)
+	req.Header.Set(HeaderEventID, strconv.FormatInt(p.eventID, 10))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(p.secret, timestamp, p.payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func nullStatusCode(code int) sql.NullInt32 {
+	return sql.NullInt32{Int32: int32(code), Valid: code != 0}
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Errors of ValidateURL.
+var (
+	ErrInvalidURL = errors.New("invalid webhook URL")
+	ErrInsecure   = errors.New("webhook URL must use https")
+	// ErrPrivateTarget is also returned for connections to loopback, private
+	// or link-local addresses.
+	ErrPrivateTarget = errors.New("webhook target is not a public address")
+)
+
+// ValidateURL checks a webhook URL before it is registered. Unless
+// allowPrivate is set, it must use https and must not name a loopback,
+// private or link-local address. Host names are checked again when the
+// dispatcher connects, since they may resolve to anything.
+func ValidateURL(rawURL string, allowPrivate bool) error {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil || endpoint.Host == "" {
+		return ErrInvalidURL
+	}
+	switch {
+	case endpoint.Scheme == "https":
+	case endpoint.Scheme == "http" && allowPrivate:
+	default:
+		return ErrInsecure
+	}
+	if allowPrivate {
+		return nil
+	}
+
+	host := strings.ToLower(endpoint.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrPrivateTarget
+	}
+	if ip := net.ParseIP(host); ip != nil && !publicIP(ip) {
+		return ErrPrivateTarget
+	}
+	return nil
+}
+
+// newClient returns the HTTP client of the dispatcher. Unless allowPrivate is
+// set, it refuses to connect to non-public addresses, including those a host
+// name resolves to or a redirect points at.
+func newClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if req.URL.Scheme != "https" && !allowPrivate {
+				return errors.New("webhook redirected to a URL without https")
+			}
+			if len(via) >= 5 {
+				return errors.New("stopped after 5 redirects")
+			}
+			return nil
+		},
+	}
+}
+
+// refusePrivate is a net.Dialer Control function that runs after the address
+// is resolved, so it sees the address actually connected to.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || !publicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, host)
+	}
+	return nil
+}
+
+// cgnat is the shared address space of carrier-grade NAT, RFC 6598.
+var cgnat = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified() || cgnat.Contains(ip))
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url          string
+		allowPrivate bool
+		want         error
+	}{
+		{"https://hooks.example.com/users", false, nil},
+		{"http://hooks.example.com/users", false, ErrInsecure},
+		{"http://hooks.example.com/users", true, nil},
+		{"https://localhost/users", false, ErrPrivateTarget},
+		{"https://127.0.0.1/users", false, ErrPrivateTarget},
+		{"https://10.0.0.5/users", false, ErrPrivateTarget},
+		{"https://192.168.1.1/users", false, ErrPrivateTarget},
+		{"https://169.254.169.254/latest/meta-data", false, ErrPrivateTarget},
+		{"https://[::1]/users", false, ErrPrivateTarget},
+		{"https://100.64.0.1/users", false, ErrPrivateTarget},
+		{"https://10.0.0.5/users", true, nil},
+		{"ftp://hooks.example.com", false, ErrInsecure},
+		{"https://", false, ErrInvalidURL},
+	}
+	for _, tt := range tests {
+		if err := ValidateURL(tt.url, tt.allowPrivate); err != tt.want {
+			t.Errorf("ValidateURL(%q, %v) = %v, want %v", tt.url, tt.allowPrivate, err, tt.want)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer receiver.Close()
+
+	_, err := newClient(time.Second, false).Post(receiver.URL, "application/json", nil)
+	if !errors.Is(err, ErrPrivateTarget) {
+		t.Fatalf("got %v, want %v", err, ErrPrivateTarget)
+	}
+
+	resp, err := newClient(time.Second, true).Post(receiver.URL, "application/json", nil)
+	if err != nil {
+		t.Fatalf("with private addresses allowed: %v", err)
+	}
+	resp.Body.Close()
+}
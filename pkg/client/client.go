@@ -0,0 +1,446 @@
+// Package client is the Go SDK for UserService. It dials with production
+// defaults (keepalive, retries of read-only calls, per-call deadlines),
+// attaches credentials and converts gRPC statuses into typed Go errors.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// DefaultTimeout is applied to calls whose context has no deadline.
+const DefaultTimeout = 10 * time.Second
+
+// retryServiceConfig retries read-only methods on Unavailable. Methods that
+// change users are deliberately excluded: the first attempt may have been
+// applied before the connection failed, and a retry would then write a second
+// audit entry and revision or fail, as DeleteUser would with NotFound.
+const retryServiceConfig = `{
+	"methodConfig": [{
+		"name": [
+			{"service": "user.UserService", "method": "GetAllUsers"},
+			{"service": "user.UserService", "method": "GetUserById"},
+			{"service": "user.UserService", "method": "GetUserByPhone"},
+			{"service": "user.UserService", "method": "GetUserByEmail"},
+			{"service": "user.UserService", "method": "LookupUser"},
+			{"service": "user.UserService", "method": "CheckUsersStatus"},
+			{"service": "user.UserService", "method": "ListUserRevisions"},
+			{"service": "user.UserService", "method": "GetUserAsOf"},
+			{"service": "user.UserService", "method": "GetUserTags"},
+			{"service": "user.UserService", "method": "ListTags"},
+			{"service": "user.UserService", "method": "ListUserNotes"},
+			{"service": "user.UserService", "method": "GetUserStats"},
+			{"service": "user.UserService", "method": "FindDuplicateUsers"}
+		],
+		"retryPolicy": {
+			"maxAttempts": 4,
+			"initialBackoff": "0.1s",
+			"maxBackoff": "2s",
+			"backoffMultiplier": 2,
+			"retryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
+type options struct {
+	timeout     time.Duration
+	token       string
+	apiKey      string
+	tls         *tls.Config
+	insecure    bool
+	timeZone    string
+	tenant      string
+	dialOptions []grpc.DialOption
+}
+
+// Option configures a Client.
+type Option func(*options)
+
+// WithTimeout overrides the default deadline applied to calls without one.
+// A zero timeout disables the default deadline.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) { o.timeout = timeout }
+}
+
+// WithToken sends the token as a bearer credential with every call.
+func WithToken(token string) Option {
+	return func(o *options) { o.token = token }
+}
+
+// WithAPIKey sends the API key in the "x-api-key" header with every call,
+// for backend services that do not sign in as an admin.
+func WithAPIKey(key string) Option {
+	return func(o *options) { o.apiKey = key }
+}
+
+// WithTLS uses the given TLS configuration for the connection.
+func WithTLS(cfg *tls.Config) Option {
+	return func(o *options) { o.tls = cfg }
+}
+
+// WithInsecure disables transport security, e.g. for local development.
+func WithInsecure() Option {
+	return func(o *options) { o.insecure = true }
+}
+
+// WithTimeZone asks the server to render the deprecated CustomTimestamp
+// fields in the given IANA time zone, e.g. "Asia/Ashgabat".
+func WithTimeZone(zone string) Option {
+	return func(o *options) { o.timeZone = zone }
+}
+
+// WithTenant sends the tenant slug in the "x-tenant" header with every call.
+// It is only needed for calls without credentials, since tokens and API keys
+// belong to a tenant already.
+func WithTenant(slug string) Option {
+	return func(o *options) { o.tenant = slug }
+}
+
+// WithDialOptions appends raw gRPC dial options, applied after the defaults.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) { o.dialOptions = append(o.dialOptions, opts...) }
+}
+
+// Client is a UserService client.
+type Client struct {
+	conn *grpc.ClientConn
+	rpc  pb.UserServiceClient
+}
+
+// Dial connects to the UserService at target.
+func Dial(ctx context.Context, target string, opts ...Option) (*Client, error) {
+	o := options{timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	dialOptions := []grpc.DialOption{
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                30 * time.Second,
+			Timeout:             10 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithDefaultServiceConfig(retryServiceConfig),
+		grpc.WithChainUnaryInterceptor(deadlineInterceptor(o.timeout), timeZoneInterceptor(o.timeZone), tenantInterceptor(o.tenant), errorInterceptor),
+		grpc.WithChainStreamInterceptor(tenantStreamInterceptor(o.tenant), streamErrorInterceptor),
+	}
+
+	switch {
+	case o.insecure:
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	case o.tls != nil:
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(o.tls)))
+	default:
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})))
+	}
+
+	if o.token != "" {
+		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(tokenCredentials{token: o.token, secure: !o.insecure}))
+	}
+	if o.apiKey != "" {
+		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(apiKeyCredentials{key: o.apiKey, secure: !o.insecure}))
+	}
+
+	dialOptions = append(dialOptions, o.dialOptions...)
+
+	conn, err := grpc.DialContext(ctx, target, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s: %w", target, err)
+	}
+	return New(conn), nil
+}
+
+// New wraps an existing connection. Dial defaults such as retries, deadlines
+// and error conversion are only applied by connections created with Dial.
+func New(conn *grpc.ClientConn) *Client {
+	return &Client{conn: conn, rpc: pb.NewUserServiceClient(conn)}
+}
+
+// Close closes the underlying connection.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Conn returns the underlying connection, e.g. to create clients for other services.
+func (c *Client) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
+// Raw returns the generated client for calls not wrapped by this package.
+func (c *Client) Raw() pb.UserServiceClient {
+	return c.rpc
+}
+
+// ListUsers returns one page of users.
+func (c *Client) ListUsers(ctx context.Context, page, pageSize int32) (*pb.UsersList, error) {
+	resp, err := c.rpc.GetAllUsers(ctx, &pb.PaginationRequest{Page: page, PageSize: pageSize})
+	return resp, convertError(err)
+}
+
+// FilterUsers returns one page of the users matching the filter.
+func (c *Client) FilterUsers(ctx context.Context, filter *pb.UserFilter, page, pageSize int32) (*pb.UsersList, error) {
+	resp, err := c.rpc.GetAllUsers(ctx, &pb.PaginationRequest{Page: page, PageSize: pageSize, Filter: filter})
+	return resp, convertError(err)
+}
+
+// GetUser returns the user with the given ID.
+func (c *Client) GetUser(ctx context.Context, id int64) (*pb.GetUserResponse, error) {
+	resp, err := c.rpc.GetUserById(ctx, &pb.UserID{Id: id})
+	return resp, convertError(err)
+}
+
+// GetUserByPublicID returns the user with the given public ID.
+func (c *Client) GetUserByPublicID(ctx context.Context, publicID string) (*pb.GetUserResponse, error) {
+	resp, err := c.rpc.GetUserById(ctx, &pb.UserID{PublicId: publicID})
+	return resp, convertError(err)
+}
+
+// GetUserByPhone returns the user with the given phone number, which may be
+// in national or international form.
+func (c *Client) GetUserByPhone(ctx context.Context, phoneNumber string) (*pb.GetUserResponse, error) {
+	resp, err := c.rpc.GetUserByPhone(ctx, &pb.GetUserByPhoneRequest{PhoneNumber: phoneNumber})
+	return resp, convertError(err)
+}
+
+// GetUserByEmail returns the user with the given email, ignoring case.
+func (c *Client) GetUserByEmail(ctx context.Context, email string) (*pb.GetUserResponse, error) {
+	resp, err := c.rpc.GetUserByEmail(ctx, &pb.GetUserByEmailRequest{Email: email})
+	return resp, convertError(err)
+}
+
+// CreateUser creates a new user.
+func (c *Client) CreateUser(ctx context.Context, req *pb.CreateUserRequest) (*pb.CreateUserResponse, error) {
+	resp, err := c.rpc.CreateUser(ctx, req)
+	return resp, convertError(err)
+}
+
+// UpdateUser updates the non-empty fields of req.
+func (c *Client) UpdateUser(ctx context.Context, req *pb.UpdateUserRequest) (*pb.UpdateUserResponse, error) {
+	resp, err := c.rpc.UpdateUser(ctx, req)
+	return resp, convertError(err)
+}
+
+// DeleteUser deletes the user with the given ID.
+func (c *Client) DeleteUser(ctx context.Context, id int64) error {
+	_, err := c.rpc.DeleteUser(ctx, &pb.UserID{Id: id})
+	return convertError(err)
+}
+
+// BlockUser blocks the user with the given ID.
+func (c *Client) BlockUser(ctx context.Context, id int64) error {
+	_, err := c.rpc.BlockUser(ctx, &pb.UserID{Id: id})
+	return convertError(err)
+}
+
+// UnblockUser unblocks the user with the given ID.
+func (c *Client) UnblockUser(ctx context.Context, id int64) error {
+	_, err := c.rpc.UnblockUser(ctx, &pb.UserID{Id: id})
+	return convertError(err)
+}
+
+// SendEmailVerification emails the user a link to confirm their email address.
+func (c *Client) SendEmailVerification(ctx context.Context, id int64) error {
+	_, err := c.rpc.SendEmailVerification(ctx, &pb.UserID{Id: id})
+	return convertError(err)
+}
+
+// ConfirmEmail confirms the email address a verification token was sent to.
+func (c *Client) ConfirmEmail(ctx context.Context, token string) (*pb.ConfirmEmailResponse, error) {
+	resp, err := c.rpc.ConfirmEmail(ctx, &pb.ConfirmEmailRequest{Token: token})
+	return resp, convertError(err)
+}
+
+// StartPhoneChange sends a confirmation code to the new phone number of a user.
+func (c *Client) StartPhoneChange(ctx context.Context, id int64, phoneNumber string) (*pb.StartPhoneChangeResponse, error) {
+	resp, err := c.rpc.StartPhoneChange(ctx, &pb.StartPhoneChangeRequest{UserId: id, PhoneNumber: phoneNumber})
+	return resp, convertError(err)
+}
+
+// ConfirmPhoneChange applies a pending phone change with the code sent to the new number.
+func (c *Client) ConfirmPhoneChange(ctx context.Context, id int64, code string) (*pb.UpdateUserResponse, error) {
+	resp, err := c.rpc.ConfirmPhoneChange(ctx, &pb.ConfirmPhoneChangeRequest{UserId: id, Code: code})
+	return resp, convertError(err)
+}
+
+// OverridePhoneNumber changes a phone number without verification. The reason is audited.
+func (c *Client) OverridePhoneNumber(ctx context.Context, id int64, phoneNumber, reason string) (*pb.UpdateUserResponse, error) {
+	resp, err := c.rpc.OverridePhoneNumber(ctx, &pb.OverridePhoneNumberRequest{UserId: id, PhoneNumber: phoneNumber, Reason: reason})
+	return resp, convertError(err)
+}
+
+// CheckUsersStatus returns whether the users with the given IDs and phone
+// numbers exist and are not blocked, one entry per ID followed by one per number.
+func (c *Client) CheckUsersStatus(ctx context.Context, ids []int64, phoneNumbers []string) ([]*pb.UserStatusEntry, error) {
+	resp, err := c.rpc.CheckUsersStatus(ctx, &pb.CheckUsersStatusRequest{Ids: ids, PhoneNumbers: phoneNumbers})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return resp.Users, nil
+}
+
+// CheckPublicIDsStatus is CheckUsersStatus for public IDs, e.g. the subjects
+// of end-user access tokens. It returns one entry per public ID.
+func (c *Client) CheckPublicIDsStatus(ctx context.Context, publicIDs []string) ([]*pb.UserStatusEntry, error) {
+	resp, err := c.rpc.CheckUsersStatus(ctx, &pb.CheckUsersStatusRequest{PublicIds: publicIDs})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return resp.Users, nil
+}
+
+// ListUserRevisions returns one page of the change history of a user, newest first.
+func (c *Client) ListUserRevisions(ctx context.Context, id int64, page, pageSize int32) (*pb.UserRevisionsList, error) {
+	resp, err := c.rpc.ListUserRevisions(ctx, &pb.ListUserRevisionsRequest{UserId: id, Page: page, PageSize: pageSize})
+	return resp, convertError(err)
+}
+
+// GetUserAsOf returns the user as it was at time t.
+func (c *Client) GetUserAsOf(ctx context.Context, id int64, t time.Time) (*pb.GetUserResponse, error) {
+	resp, err := c.rpc.GetUserAsOf(ctx, &pb.GetUserAsOfRequest{UserId: id, Time: timestamppb.New(t)})
+	return resp, convertError(err)
+}
+
+// RevertUser restores a user to an earlier revision. The reason is audited.
+func (c *Client) RevertUser(ctx context.Context, id, revision int64, reason string) (*pb.UpdateUserResponse, error) {
+	resp, err := c.rpc.RevertUser(ctx, &pb.RevertUserRequest{UserId: id, Revision: revision, Reason: reason})
+	return resp, convertError(err)
+}
+
+// AddUserTags tags a user and returns all of its tags.
+func (c *Client) AddUserTags(ctx context.Context, id int64, tags ...string) ([]string, error) {
+	resp, err := c.rpc.AddUserTags(ctx, &pb.UserTagsRequest{UserId: id, Tags: tags})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return resp.Tags, nil
+}
+
+// RemoveUserTags removes tags from a user and returns the remaining ones.
+func (c *Client) RemoveUserTags(ctx context.Context, id int64, tags ...string) ([]string, error) {
+	resp, err := c.rpc.RemoveUserTags(ctx, &pb.UserTagsRequest{UserId: id, Tags: tags})
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return resp.Tags, nil
+}
+
+// AddUserNote appends a note to the timeline of a user.
+func (c *Client) AddUserNote(ctx context.Context, id int64, body string) (*pb.UserNote, error) {
+	resp, err := c.rpc.AddUserNote(ctx, &pb.AddUserNoteRequest{UserId: id, Body: body})
+	return resp, convertError(err)
+}
+
+// GetUserStats returns counts of the users matching the request. Results may
+// be cached by the server for a short time.
+func (c *Client) GetUserStats(ctx context.Context, req *pb.GetUserStatsRequest) (*pb.UserStats, error) {
+	resp, err := c.rpc.GetUserStats(ctx, req)
+	return resp, convertError(err)
+}
+
+// FindDuplicateUsers returns pairs of users that are likely the same person,
+// most likely first.
+func (c *Client) FindDuplicateUsers(ctx context.Context, req *pb.FindDuplicateUsersRequest) ([]*pb.DuplicateUsers, error) {
+	resp, err := c.rpc.FindDuplicateUsers(ctx, req)
+	if err != nil {
+		return nil, convertError(err)
+	}
+	return resp.Duplicates, nil
+}
+
+// MergeUsers merges the source user into the target and retires the source.
+// The reason of the request is audited.
+func (c *Client) MergeUsers(ctx context.Context, req *pb.MergeUsersRequest) (*pb.UpdateUserResponse, error) {
+	resp, err := c.rpc.MergeUsers(ctx, req)
+	return resp, convertError(err)
+}
+
+// deadlineInterceptor applies the default timeout to calls without a deadline.
+func deadlineInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// errorInterceptor converts gRPC statuses into typed errors for calls made
+// through Raw as well.
+// timeZoneInterceptor sends the x-time-zone header when a zone is set.
+func timeZoneInterceptor(zone string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if zone != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-time-zone", zone)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// tenantInterceptor sends the x-tenant header when a tenant is set.
+func tenantInterceptor(slug string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if slug != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-tenant", slug)
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// tenantStreamInterceptor is tenantInterceptor for streaming calls.
+func tenantStreamInterceptor(slug string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		if slug != "" {
+			ctx = metadata.AppendToOutgoingContext(ctx, "x-tenant", slug)
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+func errorInterceptor(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return convertError(invoker(ctx, method, req, reply, cc, opts...))
+}
+
+func streamErrorInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	stream, err := streamer(ctx, desc, cc, method, opts...)
+	return stream, convertError(err)
+}
+
+// tokenCredentials attaches a bearer token to every call.
+type tokenCredentials struct {
+	token  string
+	secure bool
+}
+
+func (t tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + t.token}, nil
+}
+
+func (t tokenCredentials) RequireTransportSecurity() bool {
+	return t.secure
+}
+
+// apiKeyCredentials attaches an API key to every call.
+type apiKeyCredentials struct {
+	key    string
+	secure bool
+}
+
+func (k apiKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"x-api-key": k.key}, nil
+}
+
+func (k apiKeyCredentials) RequireTransportSecurity() bool {
+	return k.secure
+}
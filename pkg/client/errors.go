@@ -0,0 +1,130 @@
+package client
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Sentinel errors matched with errors.Is against errors returned by Client.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrAlreadyExists     = errors.New("already exists")
+	ErrInvalidArgument   = errors.New("invalid argument")
+	ErrUnauthenticated   = errors.New("unauthenticated")
+	ErrPermissionDenied  = errors.New("permission denied")
+	ErrResourceExhausted = errors.New("resource exhausted")
+	ErrUnavailable       = errors.New("service unavailable")
+)
+
+var sentinels = map[codes.Code]error{
+	codes.NotFound:          ErrNotFound,
+	codes.AlreadyExists:     ErrAlreadyExists,
+	codes.InvalidArgument:   ErrInvalidArgument,
+	codes.Unauthenticated:   ErrUnauthenticated,
+	codes.PermissionDenied:  ErrPermissionDenied,
+	codes.ResourceExhausted: ErrResourceExhausted,
+	codes.Unavailable:       ErrUnavailable,
+}
+
+// Error is returned for every failed call. It still carries the gRPC status, so
+// status.Code(err) keeps working.
+type Error struct {
+	Code    codes.Code
+	Message string
+	// RetryAfter is how long the server asked to wait before retrying, e.g.
+	// when a rate limit was exceeded.
+	RetryAfter time.Duration
+	status     *status.Status
+}
+
+func (e *Error) Error() string {
+	return "userservice: " + strings.ToLower(e.Code.String()) + ": " + e.Message
+}
+
+// Is matches the sentinel error corresponding to the status code.
+func (e *Error) Is(target error) bool {
+	sentinel, ok := sentinels[e.Code]
+	return ok && sentinel == target
+}
+
+// GRPCStatus returns the original status.
+func (e *Error) GRPCStatus() *status.Status {
+	return e.status
+}
+
+// FieldViolation describes a single invalid request field.
+type FieldViolation struct {
+	Field       string
+	Description string
+}
+
+// ValidationError is returned for InvalidArgument statuses carrying field
+// details. It also matches ErrInvalidArgument.
+type ValidationError struct {
+	err        *Error
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Error() string {
+	var fields []string
+	for _, v := range e.Violations {
+		fields = append(fields, v.Field+": "+v.Description)
+	}
+	return e.err.Error() + " (" + strings.Join(fields, "; ") + ")"
+}
+
+// Unwrap exposes the underlying Error.
+func (e *ValidationError) Unwrap() error {
+	return e.err
+}
+
+// GRPCStatus returns the original status.
+func (e *ValidationError) GRPCStatus() *status.Status {
+	return e.err.status
+}
+
+// convertError turns a gRPC status error into an *Error or *ValidationError.
+// Errors that are not statuses, or are already converted, are returned as is.
+func convertError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var converted *Error
+	if errors.As(err, &converted) {
+		return err
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	base := &Error{Code: st.Code(), Message: st.Message(), status: st}
+	for _, detail := range st.Details() {
+		if retryInfo, ok := detail.(*errdetails.RetryInfo); ok {
+			base.RetryAfter = retryInfo.RetryDelay.AsDuration()
+		}
+	}
+	if st.Code() != codes.InvalidArgument {
+		return base
+	}
+
+	var violations []FieldViolation
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range badRequest.FieldViolations {
+				violations = append(violations, FieldViolation{Field: v.Field, Description: v.Description})
+			}
+		}
+	}
+	if len(violations) == 0 {
+		return base
+	}
+	return &ValidationError{err: base, Violations: violations}
+}
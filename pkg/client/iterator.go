@@ -0,0 +1,93 @@
+package client
+
+import (
+	"context"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+)
+
+// DefaultPageSize is used by UserIterator when no page size is given.
+const DefaultPageSize = 100
+
+// UserIterator walks all pages of GetAllUsers.
+//
+//	it := c.AllUsers(ctx, 0)
+//	for it.Next() {
+//		user := it.User()
+//	}
+//	if err := it.Err(); err != nil { ... }
+type UserIterator struct {
+	ctx      context.Context
+	client   *Client
+	pageSize int32
+	page     int32
+	buffer   []*pb.GetUserResponse
+	current  *pb.GetUserResponse
+	done     bool
+	err      error
+}
+
+// AllUsers returns an iterator over every user, fetching pageSize users per call.
+func (c *Client) AllUsers(ctx context.Context, pageSize int32) *UserIterator {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	return &UserIterator{ctx: ctx, client: c, pageSize: pageSize, page: 1}
+}
+
+// Next advances to the next user, fetching the next page when needed. It
+// returns false when all users have been read or an error occurred.
+func (it *UserIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	for len(it.buffer) == 0 {
+		if it.done {
+			return false
+		}
+		resp, err := it.client.ListUsers(it.ctx, it.page, it.pageSize)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.buffer = resp.Users
+		// A short page means there is nothing left to fetch
+		if int32(len(resp.Users)) < it.pageSize {
+			it.done = true
+		}
+		it.page++
+	}
+
+	it.current, it.buffer = it.buffer[0], it.buffer[1:]
+	return true
+}
+
+// User returns the current user.
+func (it *UserIterator) User() *pb.GetUserResponse {
+	return it.current
+}
+
+// Err returns the error that stopped the iteration, if any.
+func (it *UserIterator) Err() error {
+	return it.err
+}
+
+// Pages calls fn with every page of users until fn returns false or an error occurs.
+func (c *Client) Pages(ctx context.Context, pageSize int32, fn func(users []*pb.GetUserResponse) bool) error {
+	if pageSize <= 0 {
+		pageSize = DefaultPageSize
+	}
+	for page := int32(1); ; page++ {
+		resp, err := c.ListUsers(ctx, page, pageSize)
+		if err != nil {
+			return err
+		}
+		if len(resp.Users) > 0 && !fn(resp.Users) {
+			return nil
+		}
+		if int32(len(resp.Users)) < pageSize {
+			return nil
+		}
+	}
+}
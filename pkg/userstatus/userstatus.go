@@ -0,0 +1,230 @@
+// Package userstatus is a gRPC server interceptor for services that act on
+// behalf of users. It rejects calls of users that are blocked, deleted or
+// unknown, asking UserService through CheckUsersStatus and caching the answer.
+package userstatus
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/flight"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Checker looks up the status of users. *client.Client implements it.
+type Checker interface {
+	CheckUsersStatus(ctx context.Context, ids []int64, phoneNumbers []string) ([]*pb.UserStatusEntry, error)
+}
+
+// PublicIDChecker looks up the status of users by public ID. Checkers have
+// to implement it to check public IDs. *client.Client implements it.
+type PublicIDChecker interface {
+	CheckPublicIDsStatus(ctx context.Context, publicIDs []string) ([]*pb.UserStatusEntry, error)
+}
+
+// Options configures a Guard.
+type Options struct {
+	// UserID returns the user a call is made for. Calls without a user are
+	// passed through. Either UserID or PublicID is required.
+	UserID func(ctx context.Context) (int64, bool)
+	// PublicID returns the public ID of the user a call is made for, e.g.
+	// the subject of a UserService access token. It is used when UserID is
+	// not set.
+	PublicID func(ctx context.Context) (string, bool)
+	// TTL is how long a status is cached. Defaults to 30 seconds, so a
+	// blocked user may get through for up to that long.
+	TTL time.Duration
+	// Size limits the number of cached users. Defaults to 10000.
+	Size int
+	// FailOpen lets calls through when the status cannot be checked.
+	// By default they fail with Unavailable.
+	FailOpen bool
+}
+
+// Guard checks users and caches their status.
+type Guard struct {
+	checker Checker
+	opts    Options
+	now     func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cached
+	// epoch changes with every Forget, so a check that started before it
+	// does not cache the status it got.
+	epoch uint64
+	group flight.Group
+}
+
+type cached struct {
+	status    pb.UserStatus
+	expiresAt time.Time
+}
+
+// New creates a Guard.
+func New(checker Checker, opts Options) *Guard {
+	if opts.TTL <= 0 {
+		opts.TTL = 30 * time.Second
+	}
+	if opts.Size <= 0 {
+		opts.Size = 10000
+	}
+	return &Guard{checker: checker, opts: opts, now: time.Now, cache: make(map[string]cached)}
+}
+
+// Check returns nil for active users, PermissionDenied for blocked users and
+// Unauthenticated for deleted or unknown users.
+func (g *Guard) Check(ctx context.Context, userID int64) error {
+	return g.check(ctx, strconv.FormatInt(userID, 10), []int64{userID}, nil)
+}
+
+// CheckPublicID is Check for the public ID of a user.
+func (g *Guard) CheckPublicID(ctx context.Context, publicID string) error {
+	return g.check(ctx, publicKey(publicID), nil, []string{publicID})
+}
+
+func (g *Guard) check(ctx context.Context, key string, ids []int64, publicIDs []string) error {
+	userStatus, err := g.status(ctx, key, ids, publicIDs)
+	if err != nil {
+		if g.opts.FailOpen {
+			return nil
+		}
+		return status.Errorf(codes.Unavailable, "User status is unavailable")
+	}
+
+	switch userStatus {
+	case pb.UserStatus_USER_STATUS_ACTIVE:
+		return nil
+	case pb.UserStatus_USER_STATUS_BLOCKED:
+		return status.Errorf(codes.PermissionDenied, "User is blocked")
+	}
+	return status.Errorf(codes.Unauthenticated, "User does not exist")
+}
+
+// status looks up the user of the cache key, given by either ids or publicIDs.
+func (g *Guard) status(ctx context.Context, key string, ids []int64, publicIDs []string) (pb.UserStatus, error) {
+	g.mu.Lock()
+	entry, ok := g.cache[key]
+	epoch := g.epoch
+	g.mu.Unlock()
+	if ok && g.now().Before(entry.expiresAt) {
+		return entry.status, nil
+	}
+
+	value, err := g.group.Do(ctx, key, func(ctx context.Context) (interface{}, error) {
+		users, err := g.lookup(ctx, ids, publicIDs)
+		if err != nil {
+			return nil, err
+		}
+		userStatus := pb.UserStatus_USER_STATUS_NOT_FOUND
+		if len(users) == 1 {
+			userStatus = users[0].Status
+		}
+		g.store(key, userStatus, epoch)
+		return userStatus, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return value.(pb.UserStatus), nil
+}
+
+func (g *Guard) lookup(ctx context.Context, ids []int64, publicIDs []string) ([]*pb.UserStatusEntry, error) {
+	if len(publicIDs) == 0 {
+		return g.checker.CheckUsersStatus(ctx, ids, nil)
+	}
+	checker, ok := g.checker.(PublicIDChecker)
+	if !ok {
+		return nil, errors.New("userstatus: checker cannot look up public IDs")
+	}
+	return checker.CheckPublicIDsStatus(ctx, publicIDs)
+}
+
+func (g *Guard) store(key string, userStatus pb.UserStatus, epoch uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.epoch != epoch {
+		return
+	}
+
+	now := g.now()
+	if len(g.cache) >= g.opts.Size {
+		for id, entry := range g.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(g.cache, id)
+			}
+		}
+	}
+	if len(g.cache) >= g.opts.Size {
+		// Still full of live entries, drop an arbitrary one
+		for id := range g.cache {
+			delete(g.cache, id)
+			break
+		}
+	}
+	g.cache[key] = cached{status: userStatus, expiresAt: now.Add(g.opts.TTL)}
+}
+
+// Forget drops the cached status of a user, e.g. after being told it changed.
+// Checks in flight do not cache what they get, and later checks ask again.
+func (g *Guard) Forget(userID int64) {
+	g.forget(strconv.FormatInt(userID, 10))
+}
+
+// ForgetPublicID is Forget for the public ID of a user.
+func (g *Guard) ForgetPublicID(publicID string) {
+	g.forget(publicKey(publicID))
+}
+
+func (g *Guard) forget(key string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.cache, key)
+	g.epoch++
+	g.group.Forget(key)
+}
+
+// publicKey is the cache key of a public ID, which never looks like an ID.
+func publicKey(publicID string) string {
+	return "public:" + publicID
+}
+
+// checkCaller checks the user a call is made for, if any.
+func (g *Guard) checkCaller(ctx context.Context) error {
+	if g.opts.UserID != nil {
+		if userID, ok := g.opts.UserID(ctx); ok {
+			return g.Check(ctx, userID)
+		}
+		return nil
+	}
+	if publicID, ok := g.opts.PublicID(ctx); ok {
+		return g.CheckPublicID(ctx, publicID)
+	}
+	return nil
+}
+
+// UnaryServerInterceptor checks the user of every unary call.
+func (g *Guard) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := g.checkCaller(ctx); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor checks the user when a stream is opened.
+func (g *Guard) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := g.checkCaller(ss.Context()); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
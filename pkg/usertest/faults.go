@@ -0,0 +1,108 @@
+package usertest
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// fault is injected into every call of one method.
+type fault struct {
+	latency time.Duration
+	code    codes.Code
+	message string
+}
+
+type faults struct {
+	mu      sync.Mutex
+	methods map[string]fault
+}
+
+func newFaults() *faults {
+	return &faults{methods: make(map[string]fault)}
+}
+
+// InjectLatency delays every call to method by d. Method is either the short
+// name ("GetUserById") or the full gRPC method ("/user.UserService/GetUserById").
+func (s *Server) InjectLatency(method string, d time.Duration) {
+	s.faults.update(method, func(f *fault) { f.latency = d })
+}
+
+// InjectError makes every call to method fail with the given code.
+func (s *Server) InjectError(method string, code codes.Code) {
+	s.InjectErrorMessage(method, code, "injected "+code.String())
+}
+
+// InjectErrorMessage makes every call to method fail with the given code and message.
+func (s *Server) InjectErrorMessage(method string, code codes.Code, message string) {
+	s.faults.update(method, func(f *fault) {
+		f.code = code
+		f.message = message
+	})
+}
+
+// ClearFaults removes all injected latency and errors.
+func (s *Server) ClearFaults() {
+	s.faults.mu.Lock()
+	defer s.faults.mu.Unlock()
+	s.faults.methods = make(map[string]fault)
+}
+
+func (f *faults) update(method string, apply func(*fault)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	name := shortName(method)
+	current := f.methods[name]
+	apply(&current)
+	f.methods[name] = current
+}
+
+// apply waits for the injected latency and returns the injected error, if any.
+func (f *faults) apply(ctx context.Context, fullMethod string) error {
+	f.mu.Lock()
+	current, ok := f.methods[shortName(fullMethod)]
+	f.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if current.latency > 0 {
+		select {
+		case <-time.After(current.latency):
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if current.code != codes.OK {
+		return status.Error(current.code, current.message)
+	}
+	return nil
+}
+
+func (f *faults) unaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if err := f.apply(ctx, info.FullMethod); err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (f *faults) streamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if err := f.apply(ss.Context(), info.FullMethod); err != nil {
+		return err
+	}
+	return handler(srv, ss)
+}
+
+// shortName strips the "/package.Service/" prefix of a full method name.
+func shortName(method string) string {
+	if i := strings.LastIndex(method, "/"); i >= 0 {
+		return method[i+1:]
+	}
+	return method
+}
@@ -0,0 +1,298 @@
+// Package usertest runs a real UserService in-process for tests. The server
+// uses the same wiring as production but keeps users in memory and talks to
+// the client over an in-memory bufconn listener, so no database is required.
+//
+//	func TestSomething(t *testing.T) {
+//		srv := usertest.New(t)
+//		users := srv.Seed(t, &pb.CreateUserRequest{PhoneNumber: "+99365123456"})
+//		srv.InjectError("GetUserById", codes.Unavailable)
+//		_, err := srv.Client.GetUser(ctx, users[0].Id)
+//	}
+package usertest
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/hojamuhammet/user-admin-grpc-go/gen"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/audit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/auth"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/config"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/jwt"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/mailer"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/ratelimit"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/server"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/sms"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/storage"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/store"
+	"github.com/hojamuhammet/user-admin-grpc-go/internal/tenant"
+	"github.com/hojamuhammet/user-admin-grpc-go/pkg/client"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const bufSize = 1 << 20
+
+// Server is a running in-process UserService.
+type Server struct {
+	// Client is an SDK client connected to the server.
+	Client *client.Client
+	// Conn is the underlying connection, for use with generated clients.
+	Conn *grpc.ClientConn
+
+	store   *store.Memory
+	mailer  *mailer.Memory
+	sms     *sms.Memory
+	signer  *jwt.Signer
+	faults  *faults
+	admins  int32
+	apiKeys int32
+}
+
+type options struct {
+	clientOptions  []client.Option
+	users          []*pb.CreateUserRequest
+	phoneCountries []string
+	rateLimits     string
+}
+
+// Option configures the test server.
+type Option func(*options)
+
+// WithClientOptions passes additional options to the SDK client.
+func WithClientOptions(opts ...client.Option) Option {
+	return func(o *options) { o.clientOptions = append(o.clientOptions, opts...) }
+}
+
+// WithPhoneCountries sets the countries whose phone numbers are accepted. The
+// first country is used for national numbers. Defaults to Turkmenistan only.
+func WithPhoneCountries(countries ...string) Option {
+	return func(o *options) { o.phoneCountries = countries }
+}
+
+// WithRateLimits enables rate limiting with rules in the format of
+// RATE_LIMITS, e.g. "user.SessionService/VerifyLoginCode=5/m". Buckets are
+// kept in memory. Rate limiting is disabled by default.
+func WithRateLimits(rules string) Option {
+	return func(o *options) { o.rateLimits = rules }
+}
+
+// WithUsers seeds the given users before the server starts.
+func WithUsers(users ...*pb.CreateUserRequest) Option {
+	return func(o *options) { o.users = append(o.users, users...) }
+}
+
+// New starts a server and a connected client. Both are shut down through t.Cleanup.
+func New(t testing.TB, opts ...Option) *Server {
+	t.Helper()
+
+	o := options{phoneCountries: []string{"TM"}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	photoStorage, err := storage.NewFilesystem(t.TempDir(), "http://usertest.invalid/photos")
+	if err != nil {
+		t.Fatalf("usertest: failed to create photo storage: %v", err)
+	}
+
+	s := &Server{
+		store:  store.NewMemory(),
+		mailer: mailer.NewMemory(),
+		sms:    sms.NewMemory(),
+		faults: newFaults(),
+	}
+	s.Seed(t, o.users...)
+
+	cfg := &config.Config{
+		PhotoMaxBytes:        5 << 20,
+		PhoneCountries:       o.phoneCountries,
+		PhoneDefaultCountry:  o.phoneCountries[0],
+		EmailVerificationTTL: 24 * time.Hour,
+		PhoneOTPTTL:          10 * time.Minute,
+		PhoneOTPMaxAttempts:  5,
+		PhoneReuseCoolDown:   90 * 24 * time.Hour,
+		SessionIssuer:        "usertest",
+		SessionAccessTTL:     15 * time.Minute,
+		SessionRefreshTTL:    30 * 24 * time.Hour,
+		AdminTokenTTL:        time.Hour,
+		AdminMaxFailedLogins: 5,
+		AdminLockoutDuration: 15 * time.Minute,
+		AdminTOTPIssuer:      "usertest",
+	}
+	if o.rateLimits != "" {
+		cfg.RateLimiter = "memory"
+		if cfg.RateLimits, err = ratelimit.ParseRules(o.rateLimits); err != nil {
+			t.Fatalf("usertest: %v", err)
+		}
+	}
+	key, err := jwt.GenerateKey()
+	if err != nil {
+		t.Fatalf("usertest: failed to generate signing key: %v", err)
+	}
+	s.signer, err = jwt.NewSigner(cfg.SessionIssuer, key)
+	if err != nil {
+		t.Fatalf("usertest: failed to create signer: %v", err)
+	}
+
+	lis := bufconn.Listen(bufSize)
+
+	srv := server.NewServer(context.Background(), cfg, nil,
+		server.WithStore(s.store),
+		server.WithStorage(photoStorage),
+		server.WithMailer(s.mailer),
+		server.WithSMS(s.sms),
+		server.WithSigner(s.signer),
+		server.WithListener(lis),
+		server.WithServerOptions(
+			grpc.ChainUnaryInterceptor(s.faults.unaryInterceptor),
+			grpc.ChainStreamInterceptor(s.faults.streamInterceptor),
+		),
+	)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if err := srv.Start(); err != nil {
+			t.Errorf("usertest: server failed: %v", err)
+		}
+	}()
+
+	clientOptions := append([]client.Option{
+		client.WithInsecure(),
+		client.WithDialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		})),
+	}, o.clientOptions...)
+
+	c, err := client.Dial(context.Background(), "bufnet", clientOptions...)
+	if err != nil {
+		srv.Stop()
+		t.Fatalf("usertest: failed to dial server: %v", err)
+	}
+	s.Client = c
+	s.Conn = c.Conn()
+
+	t.Cleanup(func() {
+		c.Close()
+		srv.Stop()
+		lis.Close()
+		<-done
+	})
+
+	return s
+}
+
+// Seed stores users directly, bypassing request validation, and returns them
+// with their assigned IDs. The test fails if a user cannot be stored.
+func (s *Server) Seed(t testing.TB, users ...*pb.CreateUserRequest) []*pb.CreateUserResponse {
+	t.Helper()
+
+	var created []*pb.CreateUserResponse
+	for _, user := range users {
+		resp, err := s.store.CreateUser(context.Background(), user)
+		if err != nil {
+			t.Fatalf("usertest: failed to seed user %q: %v", user.PhoneNumber, err)
+		}
+		created = append(created, resp)
+	}
+	return created
+}
+
+// SetBlocked changes the blocked flag of a seeded user.
+func (s *Server) SetBlocked(t testing.TB, id int64, blocked bool) {
+	t.Helper()
+
+	if err := s.store.SetBlocked(context.Background(), id, blocked); err != nil {
+		t.Fatalf("usertest: failed to set blocked flag of user %d: %v", id, err)
+	}
+}
+
+// Email is a message sent by the server.
+type Email struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// SentEmails returns the emails sent by the server so far, oldest first.
+func (s *Server) SentEmails() []Email {
+	var emails []Email
+	for _, msg := range s.mailer.Messages() {
+		emails = append(emails, Email{To: msg.To, Subject: msg.Subject, Body: msg.Body})
+	}
+	return emails
+}
+
+// SMS is a text message sent by the server.
+type SMS struct {
+	To   string
+	Text string
+}
+
+// SentSMS returns the text messages sent by the server so far, oldest first.
+func (s *Server) SentSMS() []SMS {
+	var messages []SMS
+	for _, msg := range s.sms.Messages() {
+		messages = append(messages, SMS{To: msg.To, Text: msg.Text})
+	}
+	return messages
+}
+
+// AdminToken creates an admin with the given roles, e.g. "superadmin", and
+// returns an access token for it, valid for an hour, without going through
+// the login flow. Use it with client.WithToken for admin methods.
+func (s *Server) AdminToken(t testing.TB, roles ...string) string {
+	t.Helper()
+
+	username := "usertest-admin-" + strconv.Itoa(int(atomic.AddInt32(&s.admins, 1)))
+	admin, err := s.store.CreateAdmin(context.Background(), store.Admin{Username: username, Roles: roles}, audit.Entry{Actor: "usertest", Action: audit.AdminCreated})
+	if err != nil {
+		t.Fatalf("usertest: failed to create admin: %v", err)
+	}
+	token, err := s.signer.Sign(auth.AdminAudience, auth.AdminSubject(admin.ID), "", tenant.Default, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("usertest: failed to sign admin token: %v", err)
+	}
+	return token
+}
+
+// APIKey creates an API key with the given scopes, e.g. "users:read", and
+// returns it. Use it with client.WithAPIKey.
+func (s *Server) APIKey(t testing.TB, scopes ...string) string {
+	t.Helper()
+
+	n := atomic.AddInt32(&s.apiKeys, 1)
+	key := auth.APIKeyPrefix + "usertest-" + strconv.Itoa(int(n))
+	_, err := s.store.CreateAPIKey(context.Background(), store.APIKey{
+		Name:      "usertest-key-" + strconv.Itoa(int(n)),
+		Prefix:    key,
+		Scopes:    scopes,
+		CreatedBy: "usertest",
+	}, auth.HashAPIKey(key), audit.Entry{Actor: "usertest", Action: audit.APIKeyCreated})
+	if err != nil {
+		t.Fatalf("usertest: failed to create API key: %v", err)
+	}
+	return key
+}
+
+// AccessToken returns an end-user access token for the user, valid for an
+// hour, without going through the login flow. Use it with
+// client.WithToken or as a bearer token for ProfileService calls.
+func (s *Server) AccessToken(t testing.TB, userID int64) string {
+	t.Helper()
+
+	user, err := s.store.GetUser(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("usertest: failed to get user %d: %v", userID, err)
+	}
+	token, err := s.signer.Sign(auth.EndUserAudience, user.PublicId, "usertest", tenant.Default, time.Now(), time.Hour)
+	if err != nil {
+		t.Fatalf("usertest: failed to sign access token: %v", err)
+	}
+	return token
+}
@@ -0,0 +1,7 @@
+-- Phone numbers are stored in E.164 form, which allows up to 15 digits plus "+".
+ALTER TABLE users ALTER COLUMN phone_number TYPE VARCHAR(16);
+
+ALTER TABLE users ADD COLUMN country VARCHAR(2);
+
+-- Existing numbers are all Turkmen numbers in the form +993XXXXXXXX
+UPDATE users SET country = 'TM' WHERE phone_number LIKE '+993%';
@@ -0,0 +1,32 @@
+CREATE TABLE phone_change_requests (
+    user_id INTEGER PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    phone_number VARCHAR(16) NOT NULL,
+    code_hash BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Numbers users gave up. Rows outlive the user so deleted accounts are covered by the cool-down too.
+CREATE TABLE phone_number_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    phone_number VARCHAR(16) NOT NULL,
+    replaced_by VARCHAR(16),
+    change_type VARCHAR(20) NOT NULL, -- verified, override or deleted
+    released_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX phone_number_history_phone_number_idx ON phone_number_history (phone_number, released_at);
+
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    user_id INTEGER,
+    reason TEXT,
+    details JSONB,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, created_at);
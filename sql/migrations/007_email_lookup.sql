@@ -0,0 +1,2 @@
+-- Serves case-insensitive lookups by email
+CREATE INDEX users_email_lower_idx ON users (LOWER(email));
@@ -0,0 +1,43 @@
+-- Store all timestamps with time zone. Existing values are interpreted in the
+-- session's TimeZone, which is also the zone CURRENT_TIMESTAMP defaults were
+-- written in. Run "SET TimeZone = '<zone>';" first if the server wrote
+-- timestamps in a zone other than the database default.
+ALTER TABLE users
+    ALTER COLUMN registration_date TYPE TIMESTAMPTZ,
+    ALTER COLUMN otp_created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE email_verification_tokens
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE phone_change_requests
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE phone_number_history
+    ALTER COLUMN released_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE audit_log
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE login_codes
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE sessions
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN last_used_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN expires_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN revoked_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE outbox_events
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN dispatched_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE webhook_subscriptions
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
+
+ALTER TABLE webhook_deliveries
+    ALTER COLUMN next_attempt_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN delivered_at TYPE TIMESTAMPTZ,
+    ALTER COLUMN created_at TYPE TIMESTAMPTZ;
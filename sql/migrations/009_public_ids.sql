@@ -0,0 +1,41 @@
+-- Widen user IDs to 64 bits. Rewrites the users table and every table
+-- referencing it, so run it in a maintenance window on large databases.
+ALTER TABLE users ALTER COLUMN id TYPE BIGINT;
+ALTER SEQUENCE users_id_seq AS BIGINT;
+
+ALTER TABLE email_verification_tokens ALTER COLUMN user_id TYPE BIGINT;
+ALTER TABLE phone_change_requests ALTER COLUMN user_id TYPE BIGINT;
+ALTER TABLE phone_number_history ALTER COLUMN user_id TYPE BIGINT;
+ALTER TABLE audit_log ALTER COLUMN user_id TYPE BIGINT;
+ALTER TABLE login_codes ALTER COLUMN user_id TYPE BIGINT;
+ALTER TABLE sessions ALTER COLUMN user_id TYPE BIGINT;
+
+-- Generates a ULID: a 48-bit millisecond timestamp and 80 random bits in
+-- Crockford's base32, the same format as the ulid package of the server
+CREATE FUNCTION generate_ulid() RETURNS CHAR(26) AS $$
+DECLARE
+    alphabet CONSTANT TEXT := '0123456789ABCDEFGHJKMNPQRSTVWXYZ';
+    ms BIGINT := FLOOR(EXTRACT(EPOCH FROM clock_timestamp()) * 1000);
+    -- Bytes 6 to 11 of a version 4 UUID carry the fixed version and variant bits
+    random BYTEA := uuid_send(gen_random_uuid());
+    bytes BYTEA;
+    acc BIGINT := 0;
+    bits INTEGER := 2;
+    result TEXT := '';
+BEGIN
+    bytes := decode(lpad(to_hex(ms), 12, '0'), 'hex') || substring(random FROM 1 FOR 6) || substring(random FROM 13 FOR 4);
+    FOR i IN 0..15 LOOP
+        acc := (acc << 8) | get_byte(bytes, i);
+        bits := bits + 8;
+        WHILE bits >= 5 LOOP
+            bits := bits - 5;
+            result := result || substr(alphabet, ((acc >> bits) & 31)::INTEGER + 1, 1);
+        END LOOP;
+        acc := acc & ((1 << bits) - 1);
+    END LOOP;
+    RETURN result;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The volatile default gives every existing user its own public ID
+ALTER TABLE users ADD COLUMN public_id CHAR(26) NOT NULL UNIQUE DEFAULT generate_ulid();
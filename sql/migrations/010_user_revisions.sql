@@ -0,0 +1,17 @@
+-- Snapshots of every user after each change, for the change history
+CREATE TABLE user_revisions (
+    user_id BIGINT NOT NULL,
+    revision BIGINT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, revision)
+);
+
+CREATE INDEX user_revisions_created_at_idx ON user_revisions (user_id, created_at);
+
+-- Existing users start their history with their current state
+INSERT INTO user_revisions (user_id, revision, actor, action, snapshot)
+SELECT id, 1, 'migration', 'user.created', to_jsonb(users) - 'otp' - 'otp_created_at'
+FROM users;
@@ -0,0 +1,17 @@
+-- Operator accounts of the admin API. Passwords are argon2id or bcrypt hashes.
+CREATE TABLE admins (
+    id BIGSERIAL PRIMARY KEY,
+    username VARCHAR(64) NOT NULL UNIQUE,
+    roles TEXT[] NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    totp_secret VARCHAR(64) NOT NULL,
+    -- Time step of the last accepted TOTP code, so a code cannot be used twice
+    totp_step BIGINT NOT NULL DEFAULT 0,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    failed_logins INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    -- Access tokens issued before this time are rejected
+    credentials_changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
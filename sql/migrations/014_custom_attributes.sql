@@ -0,0 +1,17 @@
+-- Values of admin-defined attributes, as text in their canonical form
+ALTER TABLE users ADD COLUMN custom_attributes JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX users_custom_attributes_idx ON users USING GIN (custom_attributes jsonb_path_ops);
+
+-- Schema of the custom attributes, managed by superadmins
+CREATE TABLE attribute_definitions (
+    name VARCHAR(50) PRIMARY KEY,
+    type VARCHAR(10) NOT NULL,
+    allowed_values TEXT[] NOT NULL DEFAULT '{}',
+    required BOOLEAN NOT NULL DEFAULT false,
+    pii BOOLEAN NOT NULL DEFAULT false,
+    description TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,111 @@
+-- Brands served by the same deployment. Existing data belongs to the default tenant.
+CREATE TABLE tenants (
+    id BIGSERIAL PRIMARY KEY,
+    slug VARCHAR(32) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    -- Overrides of the server settings, empty or 0 when not overridden
+    phone_countries TEXT[] NOT NULL DEFAULT '{}',
+    phone_default_country VARCHAR(2) NOT NULL DEFAULT '',
+    phone_otp_ttl_seconds INTEGER NOT NULL DEFAULT 0,
+    phone_otp_max_attempts INTEGER NOT NULL DEFAULT 0,
+    disabled BOOLEAN NOT NULL DEFAULT false,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO tenants (id, slug, name, created_by) VALUES (1, 'default', 'Default', 'migration');
+SELECT setval('tenants_id_seq', 1);
+
+-- The tenant of the current transaction, set by the server with
+-- set_config('app.tenant_id', ..., true). NULL when it is not set, so no rows
+-- are visible and inserts fail.
+CREATE FUNCTION current_tenant_id() RETURNS BIGINT AS $$
+    SELECT NULLIF(current_setting('app.tenant_id', true), '')::BIGINT
+$$ LANGUAGE sql STABLE;
+
+-- Existing rows get the default tenant, new rows the tenant of the transaction
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'users', 'email_verification_tokens', 'phone_change_requests', 'phone_number_history', 'audit_log',
+        'login_codes', 'sessions', 'user_revisions', 'admins', 'api_keys', 'tags', 'user_tags', 'user_notes',
+        'attribute_definitions', 'outbox_events', 'webhook_subscriptions'
+    ] LOOP
+        EXECUTE format('ALTER TABLE %I ADD COLUMN tenant_id BIGINT NOT NULL DEFAULT 1 REFERENCES tenants (id)', t);
+        EXECUTE format('ALTER TABLE %I ALTER COLUMN tenant_id SET DEFAULT current_tenant_id()', t);
+    END LOOP;
+END;
+$$;
+
+-- Phone numbers, emails, usernames and names are unique per tenant
+ALTER TABLE users DROP CONSTRAINT users_phone_number_key;
+ALTER TABLE users ADD CONSTRAINT users_phone_number_key UNIQUE (tenant_id, phone_number);
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (tenant_id, email);
+ALTER TABLE admins DROP CONSTRAINT admins_username_key;
+ALTER TABLE admins ADD CONSTRAINT admins_username_key UNIQUE (tenant_id, username);
+ALTER TABLE api_keys DROP CONSTRAINT api_keys_name_key;
+ALTER TABLE api_keys ADD CONSTRAINT api_keys_name_key UNIQUE (tenant_id, name);
+ALTER TABLE tags DROP CONSTRAINT tags_name_key;
+ALTER TABLE tags ADD CONSTRAINT tags_name_key UNIQUE (tenant_id, name);
+ALTER TABLE attribute_definitions DROP CONSTRAINT attribute_definitions_pkey;
+ALTER TABLE attribute_definitions ADD PRIMARY KEY (tenant_id, name);
+
+DROP INDEX phone_number_history_phone_number_idx;
+CREATE INDEX phone_number_history_phone_number_idx ON phone_number_history (tenant_id, phone_number, released_at);
+DROP INDEX users_email_lower_idx;
+CREATE INDEX users_email_lower_idx ON users (tenant_id, LOWER(email));
+
+-- Foreign key checks bypass row-level security, so rows may only reference
+-- users and tags of their own tenant
+ALTER TABLE users ADD CONSTRAINT users_tenant_id_id_key UNIQUE (tenant_id, id);
+ALTER TABLE tags ADD CONSTRAINT tags_tenant_id_id_key UNIQUE (tenant_id, id);
+
+ALTER TABLE email_verification_tokens DROP CONSTRAINT email_verification_tokens_user_id_fkey;
+ALTER TABLE email_verification_tokens ADD CONSTRAINT email_verification_tokens_user_id_fkey
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE;
+ALTER TABLE phone_change_requests DROP CONSTRAINT phone_change_requests_user_id_fkey;
+ALTER TABLE phone_change_requests ADD CONSTRAINT phone_change_requests_user_id_fkey
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE;
+ALTER TABLE login_codes DROP CONSTRAINT login_codes_user_id_fkey;
+ALTER TABLE login_codes ADD CONSTRAINT login_codes_user_id_fkey
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE;
+ALTER TABLE sessions DROP CONSTRAINT sessions_user_id_fkey;
+ALTER TABLE sessions ADD CONSTRAINT sessions_user_id_fkey
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE;
+ALTER TABLE user_tags DROP CONSTRAINT user_tags_user_id_fkey;
+ALTER TABLE user_tags ADD CONSTRAINT user_tags_user_id_fkey
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE;
+ALTER TABLE user_tags DROP CONSTRAINT user_tags_tag_id_fkey;
+ALTER TABLE user_tags ADD CONSTRAINT user_tags_tag_id_fkey
+    FOREIGN KEY (tenant_id, tag_id) REFERENCES tags (tenant_id, id) ON DELETE CASCADE;
+ALTER TABLE user_notes DROP CONSTRAINT user_notes_user_id_fkey;
+ALTER TABLE user_notes ADD CONSTRAINT user_notes_user_id_fkey
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE;
+
+-- Every statement only sees the rows of the tenant of its transaction, also
+-- for the table owner. The server must not connect as a superuser or a role
+-- with BYPASSRLS. api_keys and the webhook tables are filtered by the server
+-- instead, since keys are looked up before the tenant is known and the
+-- webhook dispatcher serves all tenants.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'users', 'email_verification_tokens', 'phone_change_requests', 'phone_number_history', 'audit_log',
+        'login_codes', 'sessions', 'user_revisions', 'admins', 'tags', 'user_tags', 'user_notes',
+        'attribute_definitions'
+    ] LOOP
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_id = current_tenant_id())', t);
+    END LOOP;
+END;
+$$;
+
+CREATE INDEX outbox_events_tenant_id_idx ON outbox_events (tenant_id);
+CREATE INDEX webhook_subscriptions_tenant_id_idx ON webhook_subscriptions (tenant_id);
@@ -0,0 +1,11 @@
+-- Serve the joins of FindDuplicateUsers
+CREATE INDEX users_name_lower_idx ON users (tenant_id, LOWER(last_name), LOWER(first_name));
+CREATE INDEX users_date_of_birth_idx ON users (tenant_id, date_of_birth);
+
+-- Users merged into another user are kept, so their numbers and emails are
+-- only unique among the users still served
+ALTER TABLE users ADD COLUMN merged_into BIGINT, ADD COLUMN merged_at TIMESTAMPTZ;
+ALTER TABLE users DROP CONSTRAINT users_phone_number_key;
+CREATE UNIQUE INDEX users_phone_number_key ON users (tenant_id, phone_number) WHERE merged_into IS NULL;
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+CREATE UNIQUE INDEX users_email_key ON users (tenant_id, email) WHERE merged_into IS NULL;
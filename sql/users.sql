@@ -1,15 +1,317 @@
+-- Generates a ULID: a 48-bit millisecond timestamp and 80 random bits in
+-- Crockford's base32, the same format as the ulid package of the server
+CREATE FUNCTION generate_ulid() RETURNS CHAR(26) AS $$
+DECLARE
+    alphabet CONSTANT TEXT := '0123456789ABCDEFGHJKMNPQRSTVWXYZ';
+    ms BIGINT := FLOOR(EXTRACT(EPOCH FROM clock_timestamp()) * 1000);
+    -- Bytes 6 to 11 of a version 4 UUID carry the fixed version and variant bits
+    random BYTEA := uuid_send(gen_random_uuid());
+    bytes BYTEA;
+    acc BIGINT := 0;
+    bits INTEGER := 2;
+    result TEXT := '';
+BEGIN
+    bytes := decode(lpad(to_hex(ms), 12, '0'), 'hex') || substring(random FROM 1 FOR 6) || substring(random FROM 13 FOR 4);
+    FOR i IN 0..15 LOOP
+        acc := (acc << 8) | get_byte(bytes, i);
+        bits := bits + 8;
+        WHILE bits >= 5 LOOP
+            bits := bits - 5;
+            result := result || substr(alphabet, ((acc >> bits) & 31)::INTEGER + 1, 1);
+        END LOOP;
+        acc := acc & ((1 << bits) - 1);
+    END LOOP;
+    RETURN result;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Brands served by the same deployment. The default tenant manages the others.
+CREATE TABLE tenants (
+    id BIGSERIAL PRIMARY KEY,
+    slug VARCHAR(32) NOT NULL UNIQUE,
+    name VARCHAR(100) NOT NULL,
+    -- Overrides of the server settings, empty or 0 when not overridden
+    phone_countries TEXT[] NOT NULL DEFAULT '{}',
+    phone_default_country VARCHAR(2) NOT NULL DEFAULT '',
+    phone_otp_ttl_seconds INTEGER NOT NULL DEFAULT 0,
+    phone_otp_max_attempts INTEGER NOT NULL DEFAULT 0,
+    disabled BOOLEAN NOT NULL DEFAULT false,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO tenants (id, slug, name, created_by) VALUES (1, 'default', 'Default', 'schema');
+SELECT setval('tenants_id_seq', 1);
+
+-- The tenant of the current transaction, set by the server with
+-- set_config('app.tenant_id', ..., true). NULL when it is not set, so no rows
+-- are visible and inserts fail.
+CREATE FUNCTION current_tenant_id() RETURNS BIGINT AS $$
+    SELECT NULLIF(current_setting('app.tenant_id', true), '')::BIGINT
+$$ LANGUAGE sql STABLE;
+
 CREATE TABLE users (
-    id SERIAL PRIMARY KEY,
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    public_id CHAR(26) NOT NULL UNIQUE DEFAULT generate_ulid(),
     first_name VARCHAR(30),
     last_name VARCHAR(30),
-    phone_number VARCHAR(12) NOT NULL UNIQUE,
+    phone_number VARCHAR(16) NOT NULL,
     blocked BOOLEAN NOT NULL DEFAULT false,
-    registration_date TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    registration_date TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     otp INTEGER(6) UNIQUE,
-    otp_created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    otp_created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
     gender VARCHAR(10),
     date_of_birth DATE,
     location VARCHAR(100),
-    email VARCHAR(100) UNIQUE DEFAULT NULL,
-    profile_photo_url VARCHAR(255)
-);
\ No newline at end of file
+    email VARCHAR(100) DEFAULT NULL,
+    profile_photo_url VARCHAR(255),
+    country VARCHAR(2),
+    email_verified BOOLEAN NOT NULL DEFAULT false,
+    -- Values of admin-defined attributes, as text in their canonical form
+    custom_attributes JSONB NOT NULL DEFAULT '{}',
+    -- Set on users merged into another user. Their rows are kept but no longer served.
+    merged_into BIGINT,
+    merged_at TIMESTAMPTZ,
+    -- Referenced by the tables below, so rows only reference users of their own tenant
+    UNIQUE (tenant_id, id)
+);
+
+-- Phone numbers and emails are unique per tenant among users not merged away
+CREATE UNIQUE INDEX users_phone_number_key ON users (tenant_id, phone_number) WHERE merged_into IS NULL;
+CREATE UNIQUE INDEX users_email_key ON users (tenant_id, email) WHERE merged_into IS NULL;
+
+CREATE TABLE email_verification_tokens (
+    token_hash BYTEA PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    user_id BIGINT NOT NULL,
+    email VARCHAR(100) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX email_verification_tokens_user_id_idx ON email_verification_tokens (user_id);
+
+CREATE TABLE phone_change_requests (
+    user_id BIGINT PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    phone_number VARCHAR(16) NOT NULL,
+    code_hash BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE
+);
+
+-- Numbers users gave up. Rows outlive the user so deleted accounts are covered by the cool-down too.
+CREATE TABLE phone_number_history (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    user_id BIGINT NOT NULL,
+    phone_number VARCHAR(16) NOT NULL,
+    replaced_by VARCHAR(16),
+    change_type VARCHAR(20) NOT NULL, -- verified, override or deleted
+    released_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX phone_number_history_phone_number_idx ON phone_number_history (tenant_id, phone_number, released_at);
+
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    user_id BIGINT,
+    reason TEXT,
+    details JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX audit_log_user_id_idx ON audit_log (user_id, created_at);
+
+CREATE TABLE login_codes (
+    user_id BIGINT PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    code_hash BYTEA NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE
+);
+
+CREATE TABLE sessions (
+    id VARCHAR(32) PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    user_id BIGINT NOT NULL,
+    device_id VARCHAR(100) NOT NULL,
+    device_name VARCHAR(100),
+    refresh_token_hash BYTEA NOT NULL UNIQUE,
+    -- The hash of the last rotated token, used to detect stolen refresh tokens
+    previous_token_hash BYTEA,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_used_at TIMESTAMPTZ NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMPTZ,
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
+CREATE INDEX sessions_previous_token_hash_idx ON sessions (previous_token_hash);
+
+-- Token buckets shared by all replicas when RATE_LIMITER=postgres
+CREATE UNLOGGED TABLE rate_limit_buckets (
+    key VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
+
+-- Announces changed users so every replica can drop them from its user cache
+CREATE FUNCTION notify_user_changed() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('user_changed', OLD.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_notify_changed
+    AFTER UPDATE OR DELETE ON users
+    FOR EACH ROW EXECUTE FUNCTION notify_user_changed();
+
+-- Serves case-insensitive lookups by email
+CREATE INDEX users_email_lower_idx ON users (tenant_id, LOWER(email));
+
+-- Snapshots of every user after each change, for the change history
+CREATE TABLE user_revisions (
+    user_id BIGINT NOT NULL,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    revision BIGINT NOT NULL,
+    actor VARCHAR(255) NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    snapshot JSONB NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, revision)
+);
+
+CREATE INDEX user_revisions_created_at_idx ON user_revisions (user_id, created_at);
+
+-- Operator accounts of the admin API. Passwords are argon2id or bcrypt hashes.
+CREATE TABLE admins (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    username VARCHAR(64) NOT NULL,
+    roles TEXT[] NOT NULL,
+    password_hash VARCHAR(255) NOT NULL,
+    totp_secret VARCHAR(64) NOT NULL,
+    -- Time step of the last accepted TOTP code, so a code cannot be used twice
+    totp_step BIGINT NOT NULL DEFAULT 0,
+    disabled BOOLEAN NOT NULL DEFAULT FALSE,
+    failed_logins INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    -- Access tokens issued before this time are rejected
+    credentials_changed_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_login_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT admins_username_key UNIQUE (tenant_id, username)
+);
+
+-- Long-lived credentials of backend services. Only a SHA-256 hash of the key is stored.
+CREATE TABLE api_keys (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    name VARCHAR(64) NOT NULL,
+    -- Start of the key, shown to tell keys apart
+    key_prefix VARCHAR(16) NOT NULL,
+    key_hash BYTEA NOT NULL UNIQUE,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    rotated_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT api_keys_name_key UNIQUE (tenant_id, name)
+);
+
+-- Catalog of the tags support puts on users. Tags are added on first use.
+CREATE TABLE tags (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    name VARCHAR(50) NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT tags_name_key UNIQUE (tenant_id, name),
+    UNIQUE (tenant_id, id)
+);
+
+CREATE TABLE user_tags (
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    user_id BIGINT NOT NULL,
+    tag_id BIGINT NOT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, tag_id),
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE,
+    FOREIGN KEY (tenant_id, tag_id) REFERENCES tags (tenant_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX user_tags_tag_id_idx ON user_tags (tag_id);
+
+-- Append-only notes timeline of a user, written by admins
+CREATE TABLE user_notes (
+    id BIGSERIAL PRIMARY KEY,
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    user_id BIGINT NOT NULL,
+    author VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (tenant_id, user_id) REFERENCES users (tenant_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX user_notes_user_id_idx ON user_notes (user_id, id);
+
+CREATE INDEX users_custom_attributes_idx ON users USING GIN (custom_attributes jsonb_path_ops);
+
+-- Schema of the custom attributes, managed by superadmins
+CREATE TABLE attribute_definitions (
+    tenant_id BIGINT NOT NULL DEFAULT current_tenant_id() REFERENCES tenants (id),
+    name VARCHAR(50) NOT NULL,
+    type VARCHAR(10) NOT NULL,
+    allowed_values TEXT[] NOT NULL DEFAULT '{}',
+    required BOOLEAN NOT NULL DEFAULT false,
+    pii BOOLEAN NOT NULL DEFAULT false,
+    description TEXT NOT NULL DEFAULT '',
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (tenant_id, name)
+);
+
+-- Serve the joins of FindDuplicateUsers
+CREATE INDEX users_name_lower_idx ON users (tenant_id, LOWER(last_name), LOWER(first_name));
+CREATE INDEX users_date_of_birth_idx ON users (tenant_id, date_of_birth);
+
+-- Every statement only sees the rows of the tenant of its transaction, also
+-- for the table owner. The server must not connect as a superuser or a role
+-- with BYPASSRLS. api_keys and the webhook tables are filtered by the server
+-- instead, since keys are looked up before the tenant is known and the
+-- webhook dispatcher serves all tenants.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'users', 'email_verification_tokens', 'phone_change_requests', 'phone_number_history', 'audit_log',
+        'login_codes', 'sessions', 'user_revisions', 'admins', 'tags', 'user_tags', 'user_notes',
+        'attribute_definitions'
+    ] LOOP
+        EXECUTE format('ALTER TABLE %I ENABLE ROW LEVEL SECURITY', t);
+        EXECUTE format('ALTER TABLE %I FORCE ROW LEVEL SECURITY', t);
+        EXECUTE format('CREATE POLICY tenant_isolation ON %I USING (tenant_id = current_tenant_id())', t);
+    END LOOP;
+END;
+$$;